@@ -2,8 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gopher-net/gopher-net/configuration"
+	"io"
+	"net"
 	"net/http"
+	"strings"
 
 	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
 	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/docker/libchan"
@@ -64,6 +68,22 @@ func (rs *RestServer) GetRibLocalHandler(w http.ResponseWriter, r *http.Request)
 	w.Write(res.Data)
 }
 
+// Get the multicast RIBs of the multicast neighbors, the routes used for
+// the RPF checks of the multicast traffic
+// curl -i -X GET http://127.0.0.1:8080/v1/bgp/routes/multicast
+func (rs *RestServer) GetMulticastRib(w http.ResponseWriter, r *http.Request) {
+	req := NewRestRequest(API_MULTICAST_RIB, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
 //curl -i -X GET http://127.0.0.1:8080/v1/bgp/routes/adj-rib-local/172.16.86.135
 func (rs *RestServer) GetNeighborLocalRib(w http.ResponseWriter, r *http.Request) {
 
@@ -260,6 +280,141 @@ func (rs *RestServer) PostDelNeighbor(w http.ResponseWriter, r *http.Request) {
 	w.Write(res.Data)
 }
 
+// List the dampened prefixes received from a neighbor
+// curl -i -X GET http://127.0.0.1:8080/v1/bgp/neighbor/172.16.86.135/dampening
+func (rs *RestServer) GetNeighborDampening(w http.ResponseWriter, r *http.Request) {
+	arg := mux.Vars(r)
+	remoteAddr, found := arg[NEIGHBOR_ADDR]
+	if !found {
+		errStr := "neighbor address is not specified"
+		log.Debug(errStr)
+		http.Error(w, errStr, http.StatusInternalServerError)
+		return
+	}
+	req := NewRestRequest(API_NEIGHBOR_DAMPENING, remoteAddr)
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Clear the dampening state of one prefix, or of all prefixes if no body is given
+// curl -X POST http://127.0.0.1:8080/v1/bgp/neighbor/172.16.86.135/dampening/clear -d
+// '{"ip_prefix":"10.1.1.0","ip_mask":24}'
+func (rs *RestServer) PostClearDampening(w http.ResponseWriter, r *http.Request) {
+	arg := mux.Vars(r)
+	remoteAddr, found := arg[NEIGHBOR_ADDR]
+	if !found {
+		errStr := "neighbor address is not specified"
+		log.Debug(errStr)
+		http.Error(w, errStr, http.StatusInternalServerError)
+		return
+	}
+	var route RestRoute
+	err := json.NewDecoder(r.Body).Decode(&route)
+	if err != nil && err != io.EOF {
+		http.Error(w, "HTTP decoding error", 500)
+		return
+	}
+	req := RouteRequest(API_NEIGHBOR_DAMPENING_CLEAR, route)
+	req.RemoteAddr = remoteAddr
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Debugf("REST Response clear dampening: %s", res)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Explain the best path selection for a prefix in every neighbor's rib
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/routes/10.1.1.0/24/explain"
+func (rs *RestServer) GetRouteExplain(w http.ResponseWriter, r *http.Request) {
+	arg := mux.Vars(r)
+	prefix, found := arg[ROUTE_PREFIX_ARG]
+	if !found {
+		errStr := "prefix is not specified"
+		log.Debug(errStr)
+		http.Error(w, errStr, http.StatusInternalServerError)
+		return
+	}
+	if !strings.Contains(prefix, "/") {
+		if ip := net.ParseIP(prefix); ip != nil && ip.To4() != nil {
+			prefix += "/32"
+		} else {
+			prefix += "/128"
+		}
+	}
+	_, ipNet, err := net.ParseCIDR(prefix)
+	if err != nil {
+		errStr := fmt.Sprintf("invalid prefix %s", prefix)
+		log.Debug(errStr)
+		http.Error(w, errStr, http.StatusBadRequest)
+		return
+	}
+	ones, _ := ipNet.Mask.Size()
+	route := RestRoute{
+		IpPrefix:   ipNet.IP.String(),
+		PrefixMask: uint8(ones),
+	}
+	req := RouteRequest(API_ROUTE_EXPLAIN, route)
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get all vrfs and their routes
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/vrfs"
+func (rs *RestServer) GetVrfs(w http.ResponseWriter, r *http.Request) {
+	req := NewRestRequest(API_VRFS, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get a vrf and its routes
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/vrf/<vrf_name>"
+func (rs *RestServer) GetVrf(w http.ResponseWriter, r *http.Request) {
+	arg := mux.Vars(r)
+	name, found := arg[VRF_NAME_ARG]
+	if !found {
+		errStr := "vrf name is not specified"
+		log.Debug(errStr)
+		http.Error(w, errStr, http.StatusInternalServerError)
+		return
+	}
+	req := RouteRequest(API_VRF, RestRoute{Vrf: name})
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
 func NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 }
@@ -301,3 +456,237 @@ func RemovePeer() int {
 	log.Print("remove peer")
 	return 0
 }
+
+// Get the local labels of the labeled unicast routes and their swap or
+// pop actions
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/labels"
+func (rs *RestServer) GetLabels(w http.ResponseWriter, r *http.Request) {
+	req := NewRestRequest(API_LABELS, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get the VPLS instances with their remote sites and the VPLS routes
+// received from the neighbors
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/vpls"
+func (rs *RestServer) GetVpls(w http.ResponseWriter, r *http.Request) {
+	req := VplsRequest(API_VPLS, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get a VPLS instance, its label block and the pseudowires to its
+// remote sites
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/vpls/blue"
+func (rs *RestServer) GetVplsInstance(w http.ResponseWriter, r *http.Request) {
+	arg := mux.Vars(r)
+	name, found := arg[VPLS_NAME_ARG]
+	if !found {
+		errStr := "vpls name is not specified"
+		log.Debug(errStr)
+		http.Error(w, errStr, http.StatusInternalServerError)
+		return
+	}
+	req := VplsRequest(API_VPLS_INSTANCE, name)
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get the EVPN routes originated for the local hosts and the ones
+// received from the neighbors
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/evpn"
+func (rs *RestServer) GetEvpn(w http.ResponseWriter, r *http.Request) {
+	req := NewRestRequest(API_EVPN, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+/*
+curl -X "POST" "http://127.0.0.1:8080/v1/bgp/evpn/add" \
+	-d $'{
+	"mac": "02:42:ac:11:00:02",
+	"ip": "172.17.0.2",
+	"rd": "65000:100",
+	"vni": 10100,
+	"ext_communities": ["rt:65000:100"]
+}'
+*/
+func (rs *RestServer) PostNewEvpnRoute(w http.ResponseWriter, r *http.Request) {
+	var route RestEvpnRoute
+	err := json.NewDecoder(r.Body).Decode(&route)
+	if err != nil {
+		http.Error(w, "HTTP decoding error", 500)
+		return
+	}
+	req := EvpnRouteRequest(API_ADD_EVPN_ROUTE, route)
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Debugf("REST Response post new evpn route: %s", res)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+func (rs *RestServer) PostDelEvpnRoute(w http.ResponseWriter, r *http.Request) {
+	var route RestEvpnRoute
+	err := json.NewDecoder(r.Body).Decode(&route)
+	if err != nil {
+		http.Error(w, "HTTP decoding error", 500)
+		return
+	}
+	req := EvpnRouteRequest(API_DEL_EVPN_ROUTE, route)
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Debugf("REST Response post delete evpn route: %s", res)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get the local flow specification rules and the ones received from the
+// neighbors, in the order they apply to the traffic
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/flowspec"
+func (rs *RestServer) GetFlowSpec(w http.ResponseWriter, r *http.Request) {
+	req := NewRestRequest(API_FLOWSPEC, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+/*
+curl -X "POST" "http://127.0.0.1:8080/v1/bgp/flowspec/add" \
+	-d $'{
+	"route_family": "ipv4-flowspec",
+	"match": "destination 10.0.0.1/32 protocol ==udp source-port ==53",
+	"actions": ["rate-limit:0"]
+}'
+*/
+func (rs *RestServer) PostNewFlowSpec(w http.ResponseWriter, r *http.Request) {
+	var flowSpec RestFlowSpec
+	err := json.NewDecoder(r.Body).Decode(&flowSpec)
+	if err != nil {
+		http.Error(w, "HTTP decoding error", 500)
+		return
+	}
+	req := FlowSpecRequest(API_ADD_FLOWSPEC, flowSpec)
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Debugf("REST Response post new flowspec: %s", res)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+func (rs *RestServer) PostDelFlowSpec(w http.ResponseWriter, r *http.Request) {
+	var flowSpec RestFlowSpec
+	err := json.NewDecoder(r.Body).Decode(&flowSpec)
+	if err != nil {
+		http.Error(w, "HTTP decoding error", 500)
+		return
+	}
+	req := FlowSpecRequest(API_DEL_FLOWSPEC, flowSpec)
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Debugf("REST Response post delete flowspec: %s", res)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get the link-state topology built from the BGP-LS routes: the nodes,
+// the links with their IGP metrics and the prefixes
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/ls"
+func (rs *RestServer) GetLs(w http.ResponseWriter, r *http.Request) {
+	rs.getLsTopology(w, API_LS)
+}
+
+// Get the nodes of the link-state topology
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/ls/nodes"
+func (rs *RestServer) GetLsNodes(w http.ResponseWriter, r *http.Request) {
+	rs.getLsTopology(w, API_LS_NODES)
+}
+
+// Get the links of the link-state topology and their metrics
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/ls/links"
+func (rs *RestServer) GetLsLinks(w http.ResponseWriter, r *http.Request) {
+	rs.getLsTopology(w, API_LS_LINKS)
+}
+
+func (rs *RestServer) getLsTopology(w http.ResponseWriter, reqType int) {
+	req := NewRestRequest(reqType, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
+
+// Get the configured aggregates, whether they are advertised and the
+// more specific routes they are built from
+// curl -X "GET" "http://127.0.0.1:8080/v1/bgp/aggregates"
+func (rs *RestServer) GetAggregates(w http.ResponseWriter, r *http.Request) {
+	req := NewRestRequest(API_AGGREGATES, "")
+	rs.bgpServerCh <- req
+	res := <-req.ResponseCh
+	if e := res.Err(); e != nil {
+		log.Debug(e.Error())
+		http.Error(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res.Data)
+}
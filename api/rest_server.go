@@ -29,6 +29,25 @@ const (
 	API_NEIGHBOR_SOFT_RESET
 	API_NEIGHBOR_SOFT_RESET_IN
 	API_NEIGHBOR_SOFT_RESET_OUT
+	API_NEIGHBOR_DAMPENING
+	API_NEIGHBOR_DAMPENING_CLEAR
+	API_ROUTE_EXPLAIN
+	API_VRFS
+	API_VRF
+	API_LABELS
+	API_VPLS
+	API_VPLS_INSTANCE
+	API_EVPN
+	API_ADD_EVPN_ROUTE
+	API_DEL_EVPN_ROUTE
+	API_FLOWSPEC
+	API_ADD_FLOWSPEC
+	API_DEL_FLOWSPEC
+	API_LS
+	API_LS_NODES
+	API_LS_LINKS
+	API_MULTICAST_RIB
+	API_AGGREGATES
 )
 
 const (
@@ -36,8 +55,10 @@ const (
 	ROUTES             = "/bgp/routes"
 	ADJ_RIB_LOCAL      = "/adj-rib-local"
 	RIB_LOCAL          = "/local-rib"
+	MULTICAST_RIB      = "/multicast"
 	NEIGHBOR_ADDR      = "remotePeerAddr"
 	REMOTE_AS_ARG      = "remoteAS"
+	ROUTE_PREFIX_ARG   = "prefix"
 	REMOTE_NEIGHBOR_AS = "/neighbor-as"
 	GLOBAL_CONF        = "/bgp/conf/global"
 	NEIGHBORS_CONF     = "/bgp/conf/neighbors"
@@ -45,8 +66,23 @@ const (
 	DEL                = "/delete"
 	RIB_OUT_PREFIX     = "/routes-out"
 	RIB_IN_PREFIX      = "/routes-in"
+	DAMPENING          = "/dampening"
+	CLEAR              = "/clear"
+	EXPLAIN            = "/explain"
 	NEIGHBOR_PREFIX    = "/bgp/neighbor"
 	NEIGHBORS_PREFIX   = "/bgp/neighbors"
+	VRF_NAME_ARG       = "vrfName"
+	VRF_PREFIX         = "/bgp/vrf"
+	VRFS_PREFIX        = "/bgp/vrfs"
+	LABELS_PREFIX      = "/bgp/labels"
+	VPLS_NAME_ARG      = "vplsName"
+	VPLS_PREFIX        = "/bgp/vpls"
+	EVPN_PREFIX        = "/bgp/evpn"
+	FLOWSPEC_PREFIX    = "/bgp/flowspec"
+	LS_PREFIX          = "/bgp/ls"
+	LS_NODES           = "/nodes"
+	LS_LINKS           = "/links"
+	AGGREGATES_PREFIX  = "/bgp/aggregates"
 	NEIGHBOR           = BASE_VERSION + NEIGHBOR_PREFIX
 	NEIGHBORS          = BASE_VERSION + NEIGHBORS_PREFIX
 	ROUTE_TABLES       = BASE_VERSION + ROUTES
@@ -54,15 +90,26 @@ const (
 	NEIGHBORS_CONFIG   = BASE_VERSION + NEIGHBORS_CONF
 	RIB_IN             = ROUTE_TABLES + RIB_IN_PREFIX
 	RIB_OUT            = ROUTE_TABLES + RIB_OUT_PREFIX
+	VRF                = BASE_VERSION + VRF_PREFIX
+	VRFS               = BASE_VERSION + VRFS_PREFIX
+	LABELS             = BASE_VERSION + LABELS_PREFIX
+	VPLS               = BASE_VERSION + VPLS_PREFIX
+	EVPN               = BASE_VERSION + EVPN_PREFIX
+	FLOWSPEC           = BASE_VERSION + FLOWSPEC_PREFIX
+	LS                 = BASE_VERSION + LS_PREFIX
+	AGGREGATES         = BASE_VERSION + AGGREGATES_PREFIX
 	REST_PORT          = 8080
 )
 
 type RestRequest struct {
 	RequestType int
 	RemoteAddr  string
+	VplsName    string
 	ResponseCh  chan *RestResponse
 	NodeConfig  configuration.NeighborType
 	RestRoute   RestRoute
+	EvpnRoute   RestEvpnRoute
+	FlowSpec    RestFlowSpec
 	Err         error
 }
 
@@ -132,6 +179,33 @@ func RouteRequest(reqType int, route RestRoute) *RestRequest {
 	return r
 }
 
+func VplsRequest(reqType int, name string) *RestRequest {
+	r := &RestRequest{
+		RequestType: reqType,
+		VplsName:    name,
+		ResponseCh:  make(chan *RestResponse),
+	}
+	return r
+}
+
+func EvpnRouteRequest(reqType int, route RestEvpnRoute) *RestRequest {
+	r := &RestRequest{
+		RequestType: reqType,
+		EvpnRoute:   route,
+		ResponseCh:  make(chan *RestResponse),
+	}
+	return r
+}
+
+func FlowSpecRequest(reqType int, flowSpec RestFlowSpec) *RestRequest {
+	r := &RestRequest{
+		RequestType: reqType,
+		FlowSpec:    flowSpec,
+		ResponseCh:  make(chan *RestResponse),
+	}
+	return r
+}
+
 func NewRestRequest(reqType int, remoteAddr string) *RestRequest {
 	r := &RestRequest{
 		RequestType: reqType,
@@ -149,6 +223,41 @@ type RestRoute struct {
 	LocalPref   uint32 `json:"local_pref"`
 	RF          string `json:"route_family"`
 	ExCommunity string `json:"opaque"`
+	// ExtCommunities are in the text form of bgp.ParseExtendedCommunity
+	ExtCommunities []string `json:"ext_communities"`
+	// LargeCommunities are in the "asn:x:y" form
+	LargeCommunities []string `json:"large_communities"`
+	// Vrf is the name of the vrf the route is added to or deleted from,
+	// the route is advertised to the neighbors directly if it is empty
+	Vrf string `json:"vrf"`
+}
+
+// RestEvpnRoute is a local host, such as a container, advertised in an
+// EVPN MAC/IP advertisement route
+type RestEvpnRoute struct {
+	Mac string `json:"mac"`
+	// Ip is optional
+	Ip   string `json:"ip"`
+	Rd   string `json:"rd"`
+	ETag uint32 `json:"etag"`
+	// Esi is ten colon separated hex bytes, the single homed hosts have
+	// none
+	Esi string `json:"esi"`
+	Vni uint32 `json:"vni"`
+	// ExtCommunities are in the text form of bgp.ParseExtendedCommunity
+	ExtCommunities []string `json:"ext_communities"`
+}
+
+// RestFlowSpec is a flow specification rule, such as a DDoS mitigation
+// rule, advertised to the FlowSpec neighbors
+type RestFlowSpec struct {
+	// RouteFamily is "ipv4-flowspec", the default, or "ipv6-flowspec"
+	RouteFamily string `json:"route_family"`
+	// Match is in the text form of bgp.ParseFlowSpecComponents
+	Match string `json:"match"`
+	// Actions are the traffic action extended communities,
+	// "rate-limit:0", "redirect:65000:100" or "mark:46"
+	Actions []string `json:"actions"`
 }
 
 func (rs *RestServer) Serve() {
@@ -157,17 +266,50 @@ func (rs *RestServer) Serve() {
 	// add/delete/get routes
 	r.HandleFunc(ROUTE_TABLES+ADJ_RIB_LOCAL+"/{"+NEIGHBOR_ADDR+"}", rs.GetNeighborLocalRib).Methods("GET")
 	r.HandleFunc(ROUTE_TABLES+RIB_LOCAL, rs.GetRibLocalHandler).Methods("GET")
+	r.HandleFunc(ROUTE_TABLES+MULTICAST_RIB, rs.GetMulticastRib).Methods("GET")
 	r.HandleFunc(ROUTE_TABLES, rs.GetRouteTables).Methods("GET")
 	r.HandleFunc(ROUTE_TABLES+RIB_OUT_PREFIX, rs.GetRibOut).Methods("GET")
 	r.HandleFunc(ROUTE_TABLES+RIB_IN_PREFIX, rs.GetRibIn).Methods("GET")
 	r.HandleFunc(ROUTE_TABLES+ADD, rs.PostNewRoute).Methods("POST")
 	r.HandleFunc(ROUTE_TABLES+DEL, rs.PostDelRoute).Methods("POST")
+	r.HandleFunc(ROUTE_TABLES+"/{"+ROUTE_PREFIX_ARG+":[0-9a-fA-F.:]+/?[0-9]*}"+EXPLAIN, rs.GetRouteExplain).Methods("GET")
 
 	// add/delete/get neighbors
 	r.HandleFunc(NEIGHBOR+"/{"+NEIGHBOR_ADDR+"}", rs.GetNeighbor).Methods("GET")
 	r.HandleFunc(NEIGHBORS, rs.GetNeighbors).Methods("GET")
 	r.HandleFunc(NEIGHBOR+ADD, rs.PostNewNeighbor).Methods("POST")
 	r.HandleFunc(NEIGHBOR+DEL, rs.PostDelNeighbor).Methods("POST")
+	r.HandleFunc(NEIGHBOR+"/{"+NEIGHBOR_ADDR+"}"+DAMPENING, rs.GetNeighborDampening).Methods("GET")
+	r.HandleFunc(NEIGHBOR+"/{"+NEIGHBOR_ADDR+"}"+DAMPENING+CLEAR, rs.PostClearDampening).Methods("POST")
+
+	// get vrfs
+	r.HandleFunc(VRFS, rs.GetVrfs).Methods("GET")
+	r.HandleFunc(VRF+"/{"+VRF_NAME_ARG+"}", rs.GetVrf).Methods("GET")
+
+	// get the local labels of the labeled unicast routes
+	r.HandleFunc(LABELS, rs.GetLabels).Methods("GET")
+
+	// get the VPLS instances and their remote sites
+	r.HandleFunc(VPLS, rs.GetVpls).Methods("GET")
+	r.HandleFunc(VPLS+"/{"+VPLS_NAME_ARG+"}", rs.GetVplsInstance).Methods("GET")
+
+	// add/delete/get the EVPN routes of the local hosts
+	r.HandleFunc(EVPN, rs.GetEvpn).Methods("GET")
+	r.HandleFunc(EVPN+ADD, rs.PostNewEvpnRoute).Methods("POST")
+	r.HandleFunc(EVPN+DEL, rs.PostDelEvpnRoute).Methods("POST")
+
+	// add/withdraw/get the flow specification rules
+	r.HandleFunc(FLOWSPEC, rs.GetFlowSpec).Methods("GET")
+	r.HandleFunc(FLOWSPEC+ADD, rs.PostNewFlowSpec).Methods("POST")
+	r.HandleFunc(FLOWSPEC+DEL, rs.PostDelFlowSpec).Methods("POST")
+
+	// get the link-state topology of the BGP-LS routes
+	r.HandleFunc(LS, rs.GetLs).Methods("GET")
+	r.HandleFunc(LS+LS_NODES, rs.GetLsNodes).Methods("GET")
+	r.HandleFunc(LS+LS_LINKS, rs.GetLsLinks).Methods("GET")
+
+	// get the aggregates and their more specific routes
+	r.HandleFunc(AGGREGATES, rs.GetAggregates).Methods("GET")
 
 	// Get node and global configuration
 	r.HandleFunc(GLOBAL_CONFIG, rs.GetGlobalConfig).Methods("GET")
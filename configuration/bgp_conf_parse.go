@@ -11,6 +11,10 @@ import (
 const (
 	DEFAULT_HOLDTIME                  = 90
 	DEFAULT_IDLE_HOLDTIME_AFTER_RESET = 30
+	DEFAULT_DAMPING_HALF_LIFE         = 900
+	DEFAULT_DAMPING_REUSE             = 750
+	DEFAULT_DAMPING_SUPPRESS          = 2000
+	DEFAULT_DAMPING_MAX_SUPPRESS_TIME = 3600
 )
 
 func ReadConfigfileServe(path string, configCh chan BgpType, reloadCh chan bool) {
@@ -50,8 +54,36 @@ func setTimersTypeDefault(timersT *TimersType) {
 	}
 }
 
+func setRouteFlapDampingParamsDefault(dampingT *RouteFlapDampingParamsType) {
+	if dampingT.HalfLife == 0 {
+		dampingT.HalfLife = float64(DEFAULT_DAMPING_HALF_LIFE)
+	}
+	if dampingT.ReuseThreshold == 0 {
+		dampingT.ReuseThreshold = DEFAULT_DAMPING_REUSE
+	}
+	if dampingT.SuppressThreshold == 0 {
+		dampingT.SuppressThreshold = DEFAULT_DAMPING_SUPPRESS
+	}
+	if dampingT.MaxSuppressTime == 0 {
+		dampingT.MaxSuppressTime = float64(DEFAULT_DAMPING_MAX_SUPPRESS_TIME)
+	}
+}
+
 func SetNeighborTypeDefault(neighborT *NeighborType) {
 	setTimersTypeDefault(&neighborT.Timers)
+	setRouteFlapDampingParamsDefault(&neighborT.RouteFlapDampingParams)
+}
+
+// VplsList returns the vpls instances of the l2vpn containers of the
+// address families in afiList.
+func VplsList(afiList []AfiType) []VplsType {
+	vplsList := make([]VplsType, 0)
+	for _, afi := range afiList {
+		for _, safi := range afi.SafiList {
+			vplsList = append(vplsList, safi.L2vpn.VplsList...)
+		}
+	}
+	return vplsList
 }
 
 // Below is old
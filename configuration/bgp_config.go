@@ -121,8 +121,14 @@ type AttributeEq struct {
 }
 
 // typedef for identity bgp-policy:bgp-attribute-comparison
-type BgpAttributeComparison struct {
-}
+type BgpAttributeComparison int
+
+const (
+	_ BgpAttributeComparison = iota
+	BGP_ATTRIBUTE_COMPARISON_EQ
+	BGP_ATTRIBUTE_COMPARISON_GE
+	BGP_ATTRIBUTE_COMPARISON_LE
+)
 
 // typedef for identity bgp-mp:multicast-vpn-safi
 type MulticastVpnSafi struct {
@@ -195,6 +201,15 @@ type SetExtCommunityType struct {
 	Options SetCommunityOptionType
 }
 
+//struct for container set-large-community
+type SetLargeCommunityType struct {
+	// original -> bgp-policy:communities
+	//original type is list of union
+	Communities []string
+	// original -> bgp-policy:options
+	Options SetCommunityOptionType
+}
+
 //struct for container set-community
 type SetCommunityType struct {
 	// original -> bgp-policy:communities
@@ -218,14 +233,18 @@ type ActionsType struct {
 	SetCommunity SetCommunityType
 	// original -> bgp-policy:set-ext-community
 	SetExtCommunity SetExtCommunityType
+	// original -> bgp-policy:set-large-community
+	SetLargeCommunity SetLargeCommunityType
 	// original -> bgp-policy:set-route-origin
 	SetRouteOrigin BgpOriginAttrType
 	// original -> bgp-policy:set-local-pref
-	SetLocalPref uint32
+	//nil if not set, 0 is a valid value
+	SetLocalPref *uint32
 	// original -> bgp-policy:set-next-hop
 	SetNextHop BgpNextHopType
 	// original -> bgp-policy:set-med
-	SetMed uint32
+	//nil if not set, 0 is a valid value
+	SetMed *uint32
 	// original -> bgp-policy:accept-route
 	//accept-route's original type is empty
 	AcceptRoute bool
@@ -263,6 +282,8 @@ type ConditionsType struct {
 	MatchCommunitySet string
 	// original -> bgp-policy:match-ext-community-set
 	MatchExtCommunitySet string
+	// original -> bgp-policy:match-large-community-set
+	MatchLargeCommunitySet string
 	// original -> bgp-policy:match-as-path-set
 	MatchAsPathSet string
 	// original -> bgp-policy:match-prefix-set
@@ -270,14 +291,16 @@ type ConditionsType struct {
 	// original -> bgp-policy:match-set-options
 	MatchSetOptions MatchSetOptionsType
 	// original -> bgp-policy:med-eq
-	MedEq uint32
+	//nil if not set, 0 is a valid value
+	MedEq *uint32
 	// original -> bgp-policy:origin-eq
 	OriginEq BgpOriginAttrType
 	// original -> bgp-policy:next-hop-in
 	//original type is list of inet:ip-address
 	NextHopIn []net.IP
 	// original -> bgp-policy:local-pref-eq
-	LocalPrefEq uint32
+	//nil if not set, 0 is a valid value
+	LocalPrefEq *uint32
 	// original -> bgp-policy:community-count
 	CommunityCount CommunityCountType
 	// original -> bgp-policy:as-path-length
@@ -328,6 +351,15 @@ type ExtCommunitySetType struct {
 	ExtCommunityMembers []string
 }
 
+//struct for container large-community-set
+type LargeCommunitySetType struct {
+	// original -> bgp-policy:large-community-set-name
+	LargeCommunitySetName string
+	// original -> bgp-policy:large-community-members
+	//original type is list of union
+	LargeCommunityMembers []string
+}
+
 //struct for container community-set
 type CommunitySetType struct {
 	// original -> bgp-policy:community-set-name
@@ -364,6 +396,8 @@ type DefinedSetsType struct {
 	CommunitySetList []CommunitySetType
 	// original -> bgp-policy:ext-community-set
 	ExtCommunitySetList []ExtCommunitySetType
+	// original -> bgp-policy:large-community-set
+	LargeCommunitySetList []LargeCommunitySetType
 	// original -> bgp-policy:as-path-set
 	AsPathSetList []AsPathSetType
 }
@@ -421,6 +455,12 @@ type BgpNeighborCommonStateType struct {
 	// Dropped
 	DroppedCount uint32
 	Flops        uint32
+
+	// Malformed update count by RFC 7606 error handling
+	AttributeDiscardCount uint32
+	TreatAsWithdrawCount  uint32
+	AfiSafiDisableCount   uint32
+	SessionResetCount     uint32
 }
 
 //struct for container transport-options
@@ -479,6 +519,20 @@ type TimersType struct {
 	IdleHoldTImeAfterReset float64
 }
 
+//struct for container route-flap-damping-params
+type RouteFlapDampingParamsType struct {
+	// original -> bgp:half-life
+	//half-life's original type is decimal64
+	HalfLife float64
+	// original -> bgp:reuse-threshold
+	ReuseThreshold uint32
+	// original -> bgp:suppress-threshold
+	SuppressThreshold uint32
+	// original -> bgp:max-suppress-time
+	//max-suppress-time's original type is decimal64
+	MaxSuppressTime float64
+}
+
 //struct for container bgp-af-common-state
 type BgpAfCommonStateType struct {
 	// received prefix count
@@ -514,8 +568,28 @@ type Ipv6MulticastVpnType struct {
 type Ipv4MulticastVpnType struct {
 }
 
+//struct for a vpls instance, RFC 4761
+type VplsType struct {
+	Name string
+	//in the "65000:100", "10.0.0.1:100" or "4200000000:100" form
+	RouteDistinguisher string
+	// route targets of the label blocks of the remote sites such as
+	// "rt:65000:100"
+	ImportRt []string
+	// route targets added to the label block of the local site
+	ExportRt []string
+	// VE ID of the local site, unique in the vpls
+	VeId uint16
+	// size of the label block, 8 if it is zero
+	BlockSize uint16
+	// MTU advertised in the Layer2 Info extended community, all the
+	// sites of the vpls must use the same
+	Mtu uint16
+}
+
 //struct for container l2vpn
 type L2vpnType struct {
+	VplsList []VplsType
 }
 
 //struct for container ipv4-labeled-unicast
@@ -531,7 +605,14 @@ type VrfsType struct {
 	// original -> bgp-mp:name
 	Name string
 	// original -> bgp-mp:route-distinguisher
-	RouteDistinguisher uint64
+	//route-distinguisher's original type is uint64, it is in the
+	//"65000:100", "10.0.0.1:100" or "4200000000:100" form
+	RouteDistinguisher string
+	// route targets of the VPN routes leaked into the vrf such as
+	// "rt:65000:100"
+	ImportRt []string
+	// route targets added to the routes exported from the vrf
+	ExportRt []string
 	// original -> bgp-policy:apply-policy
 	ApplyPolicy ApplyPolicyType
 }
@@ -636,10 +717,14 @@ type RouteSelectionOptionsType struct {
 	ExternalCompareRouterId bool
 	// original -> bgp:advertise-inactive-routes
 	//advertise-inactive-routes's original type is boolean
+	// a best path whose next hop is unreachable is inactive, it is
+	// advertised only with this set
 	AdvertiseInactiveRoutes bool
 	// original -> bgp:enable-aigp
 	//enable-aigp's original type is empty
 	EnableAigp bool
+
+	DeterministicMed bool
 }
 
 //struct for container neighbor
@@ -684,6 +769,17 @@ type NeighborType struct {
 	// original -> bgp:route-flap-damping
 	//route-flap-damping's original type is boolean
 	RouteFlapDamping bool
+	// original -> bgp:route-flap-damping-params
+	RouteFlapDampingParams RouteFlapDampingParamsType
+	// exchange the AIGP attribute with this neighbor, the session is
+	// the border of the AIGP administrative domain otherwise
+	AigpSession bool
+	// type codes of the path attributes removed from the updates received
+	// from and sent to this neighbor
+	DiscardAttributes []uint32
+	// route family of the session such as "l3vpn-ipv4-unicast", the
+	// unicast family of the neighbor address if it is empty
+	RouteFamily string
 	// original -> bgp-op:bgp-neighbor-common-state
 	BgpNeighborCommonState BgpNeighborCommonStateType
 }
@@ -774,6 +870,35 @@ type GlobalType struct {
 	Confederation ConfederationType
 	// original -> bgp-op:bgp-global-state
 	BgpGlobalState BgpGlobalStateType
+	// static routes used to resolve BGP next hops
+	StaticRoutes []StaticRouteType
+	// original -> bgp-mp:vrfs
+	VrfList []VrfsType
+	// local label allocation of the labeled unicast routes,
+	// "per-prefix" (the default) or "per-nexthop"
+	LabelAllocation string
+	// aggregates originated from the more specific unicast routes
+	AggregateList []AggregateType
+}
+
+//struct for an aggregate, RFC 4271 section 9.2.2.2
+type AggregateType struct {
+	// aggregate prefix such as 10.0.0.0/8
+	Prefix string
+	// advertise the aggregate only, not the more specific routes
+	SummaryOnly bool
+	// keep the AS numbers of the more specific routes in an AS_SET
+	AsSet bool
+	// the router id if empty, mandatory for an IPv6 aggregate
+	Nexthop net.IP
+}
+
+//struct for a static route
+type StaticRouteType struct {
+	// destination prefix such as 10.0.0.0/8
+	Prefix  string
+	Nexthop net.IP
+	Metric  uint32
 }
 
 //struct for container bgp
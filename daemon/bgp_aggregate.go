@@ -0,0 +1,74 @@
+package daemon
+
+import (
+	"encoding/json"
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// aggregateServer takes part in the unicast route families as a sibling
+// of the neighbors: it keeps the Loc-RIB of the paths received from them
+// and advertises the configured aggregates that have more specific
+// prefixes in it. The neighbors share its manager to leave out the
+// prefixes of the summary-only aggregates.
+type aggregateServer struct {
+	manager *table.AggregateManager
+	siblingServer
+}
+
+func newAggregateServer(g configuration.GlobalType) *aggregateServer {
+	s := &aggregateServer{
+		manager:       table.NewAggregateManager(g.As, g.RouterId),
+		siblingServer: newSiblingServer(bgp.RF_IPv4_UC, bgp.RF_IPv6_UC),
+	}
+	for _, c := range g.AggregateList {
+		aggregate, err := s.manager.AddAggregate(c.Prefix, c.SummaryOnly, c.AsSet, c.Nexthop)
+		if err != nil {
+			log.Errorf("invalid aggregate %s, ignoring it: %s", c.Prefix, err)
+			continue
+		}
+		log.Infof("added aggregate %s, summary-only %t, as-set %t", aggregate.Prefix, aggregate.SummaryOnly, aggregate.AsSet)
+	}
+	go s.serve(s.handleServerMsg, s.handleNeighborMsg)
+	return s
+}
+
+func (s *aggregateServer) handleREST(restReq *api.RestRequest) {
+	result := &api.RestResponse{}
+	switch restReq.RequestType {
+	case api.API_AGGREGATES:
+		j, _ := json.MarshalIndent(s.manager, "", "\t")
+		result.Data = j
+	}
+	restReq.ResponseCh <- result
+	close(restReq.ResponseCh)
+}
+
+func (s *aggregateServer) handleServerMsg(m *daemonMsg) {
+	switch m.msgType {
+	case SRV_MSG_PEER_ADDED:
+		d := m.msgData.(*daemonMsgDataNeighbor)
+		if s.addSibling(d) {
+			s.sendPaths(d, s.manager.GetPathList())
+		}
+	case SRV_MSG_PEER_DELETED:
+		d := m.msgData.(*table.PeerInfo)
+		s.deleteSibling(d.Address)
+		s.sendPathsToSiblings(s.manager.DeletePathsforPeer(d))
+	case SRV_MSG_API:
+		s.handleREST(m.msgData.(*api.RestRequest))
+	}
+}
+
+func (s *aggregateServer) handleNeighborMsg(m *neighborMsg) {
+	switch m.msgType {
+	case PEER_MSG_PATH:
+		s.sendPathsToSiblings(s.manager.ProcessPaths(m.msgData.([]table.Path)))
+	case PEER_MSG_PEER_DOWN:
+		s.sendPathsToSiblings(s.manager.DeletePathsforPeer(m.msgData.(*table.PeerInfo)))
+	}
+}
@@ -9,6 +9,7 @@ import (
 	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
 	tomb "github.com/gopher-net/gopher-net/Godeps/_workspace/src/gopkg.in/tomb.v2"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
 )
 
 type fsmMsgType int
@@ -76,6 +77,62 @@ func (fsm *FSM) bgpMessageStateUpdate(MessageType uint8, isIn bool) {
 	}
 }
 
+func (fsm *FSM) updateErrorStateUpdate(handling bgp.ErrorHandling) {
+	state := &fsm.neighborConfig.BgpNeighborCommonState
+	switch handling {
+	case bgp.ERROR_HANDLING_ATTRIBUTE_DISCARD:
+		state.AttributeDiscardCount++
+	case bgp.ERROR_HANDLING_TREAT_AS_WITHDRAW:
+		state.TreatAsWithdrawCount++
+	case bgp.ERROR_HANDLING_AFISAFI_DISABLE:
+		state.AfiSafiDisableCount++
+	case bgp.ERROR_HANDLING_SESSION_RESET:
+		state.SessionResetCount++
+	}
+}
+
+// handleUpdateError validates the received update m and applies the
+// revised error handling of RFC 7606 to the validation error and to err,
+// the error found when parsing m, whichever is the most severe. m is nil
+// if it couldn't be parsed. It returns the error which resets the
+// session, nil if m can be processed.
+func (fsm *FSM) handleUpdateError(m *bgp.BGPMessage, err error) error {
+	handling := bgp.ERROR_HANDLING_NONE
+	if err != nil {
+		handling = bgp.ERROR_HANDLING_SESSION_RESET
+		if e, y := err.(*bgp.MessageError); y {
+			handling = e.ErrorHandling
+		}
+	}
+	if m != nil && handling < bgp.ERROR_HANDLING_AFISAFI_DISABLE {
+		if e := bgp.ValidateBGPMessage(m); e != nil {
+			if h := e.(*bgp.MessageError).ErrorHandling; h > handling {
+				err, handling = e, h
+			}
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	fsm.updateErrorStateUpdate(handling)
+	switch handling {
+	case bgp.ERROR_HANDLING_ATTRIBUTE_DISCARD, bgp.ERROR_HANDLING_TREAT_AS_WITHDRAW:
+		log.WithFields(log.Fields{
+			"Topic":    "Peer",
+			"Key":      fsm.neighborConfig.NeighborAddress,
+			"error":    err,
+			"handling": handling,
+		}).Warn("malformed BGP update")
+		if handling == bgp.ERROR_HANDLING_TREAT_AS_WITHDRAW {
+			table.TreatAsWithdraw(m.Body.(*bgp.BGPUpdate))
+		}
+		return nil
+	}
+	// a session carries a single address family so disabling it means
+	// resetting the session
+	return err
+}
+
 func NewFSM(gConfig *configuration.GlobalType, nConfig *configuration.NeighborType, connCh chan *net.TCPConn) *FSM {
 	return &FSM{
 		globalConfig:   gConfig,
@@ -214,16 +271,20 @@ func (h *FSMHandler) active() bgp.FSMState {
 }
 
 func buildopen(global *configuration.GlobalType, peerConf *configuration.NeighborType) *bgp.BGPMessage {
-	var afi int
-	if peerConf.NeighborAddress.To4() != nil {
-		afi = bgp.AFI_IP
-	} else {
-		afi = bgp.AFI_IP6
-	}
+	rf, _ := neighborRouteFamily(peerConf)
+	afi, safi := bgp.RouteFamilyToAfiSafi(rf)
 	p1 := bgp.NewOptionParameterCapability(
 		[]bgp.ParameterCapabilityInterface{bgp.NewCapRouteRefresh()})
-	p2 := bgp.NewOptionParameterCapability(
-		[]bgp.ParameterCapabilityInterface{bgp.NewCapMultiProtocol(uint16(afi), bgp.SAFI_UNICAST)})
+	// the session carries a single route family, a multicast one (SAFI
+	// 2) is negotiated without the unicast family of the same AFI so that
+	// the RPF routes stay apart from the unicast ones
+	mpCaps := []bgp.ParameterCapabilityInterface{bgp.NewCapMultiProtocol(afi, safi)}
+	if rf == bgp.RF_IPv4_VPN || rf == bgp.RF_IPv6_VPN {
+		// the route target memberships of RFC 4684
+		afi, safi := bgp.RouteFamilyToAfiSafi(bgp.RF_RTC_UC)
+		mpCaps = append(mpCaps, bgp.NewCapMultiProtocol(afi, safi))
+	}
+	p2 := bgp.NewOptionParameterCapability(mpCaps)
 	p3 := bgp.NewOptionParameterCapability(
 		[]bgp.ParameterCapabilityInterface{bgp.NewCapFourOctetASNumber(global.As)})
 	holdTime := uint16(peerConf.Timers.HoldTime)
@@ -277,7 +338,9 @@ func (h *FSMHandler) recvMessageWithError() error {
 
 	var fmsg *fsmMsg
 	m, err := bgp.ParseBGPBody(hd, bodyBuf)
-	if err == nil {
+	if hd.Type == bgp.BGP_MSG_UPDATE {
+		err = h.fsm.handleUpdateError(m, err)
+	} else if err == nil {
 		err = bgp.ValidateBGPMessage(m)
 	}
 	if err != nil {
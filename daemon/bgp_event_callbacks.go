@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"sync"
 
 	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
 	"github.com/gopher-net/gopher-net/configuration"
@@ -17,6 +18,7 @@ const (
 	EVENT_PREFIX_WITHDRAWN
 	EVENT_NODE_JOIN
 	EVENT_NODE_REMOVE
+	EVENT_PREFIX_MULTIPATH
 )
 
 // todo: add to mathod calls
@@ -30,6 +32,8 @@ func (t NodeEvent) String() string {
 		return "node_joined"
 	case EVENT_NODE_REMOVE:
 		return "node_removed"
+	case EVENT_PREFIX_MULTIPATH:
+		return "prefix_multipath"
 	default:
 		panic(fmt.Sprintf("unknown event: [ %d ]", t))
 	}
@@ -44,13 +48,50 @@ func ContainerPrefixEvent(routes []table.Path, bgpMsgBody *bgp.BGPUpdate) {
 		log.Debugf("Container Event: All NLRI -> [ %s ]", jsn)
 
 		if route.IsWithdraw() {
-			wd := route.GetNlri().(*bgp.WithdrawnRoute)
 			log.Debugln("Container Event: Route Withdraw Notification")
-			log.Debugf("Container Event: Prefix Withdrawn -> [ %s ]/[ %d ]", wd.IPAddrPrefix.String(), wd.Length)
+			log.Debugf("Container Event: Prefix Withdrawn -> [ %s ]", route.GetPrefix())
 		}
 	}
 }
 
+// PrefixEventHandler consumes the paths of a prefix, a FIB installs the
+// next hops of the multipath set for instance. The paths of an
+// EVENT_PREFIX_MULTIPATH are the multipath set, the best path first.
+type PrefixEventHandler func(event NodeEvent, prefix string, pathList []table.Path)
+
+var prefixEventHandlers struct {
+	sync.RWMutex
+	handlers []PrefixEventHandler
+}
+
+// AddPrefixEventHandler registers a consumer of the prefix events. The
+// handlers are called from the goroutines of the neighbors, concurrently.
+func AddPrefixEventHandler(h PrefixEventHandler) {
+	prefixEventHandlers.Lock()
+	defer prefixEventHandlers.Unlock()
+	prefixEventHandlers.handlers = append(prefixEventHandlers.handlers, h)
+}
+
+func prefixEvent(event NodeEvent, prefix string, pathList []table.Path) {
+	prefixEventHandlers.RLock()
+	defer prefixEventHandlers.RUnlock()
+	for _, h := range prefixEventHandlers.handlers {
+		h(event, prefix, pathList)
+	}
+}
+
+// MultiPathEvent passes the equal cost paths of each destination to the
+// consumers of best paths. An empty path list means the prefix is gone.
+func MultiPathEvent(dests []table.Destination) {
+	for _, dest := range dests {
+		log.Debugf("Container Event: %s -> [ %s ]", EVENT_PREFIX_MULTIPATH, dest)
+		for _, path := range dest.GetMultiPathList() {
+			log.Debugf("Container Event: Prefix Nexthop: -> [ %s ]", path.GetNexthop())
+		}
+		prefixEvent(EVENT_PREFIX_MULTIPATH, dest.GetNlri().String(), dest.GetMultiPathList())
+	}
+}
+
 func (d *FSMHandler) NodeAddedFSMEvent(nConf *configuration.NeighborType) {
 	log.Debugln("Container Event: New Neighbor Added")
 	log.Debugf("Container Event: Established Neighbor IP address -> [ %d ]", nConf.PeerAs)
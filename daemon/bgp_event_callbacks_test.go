@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+func TestMultiPathEventHandler(t *testing.T) {
+	events := make(map[string][]table.Path)
+	AddPrefixEventHandler(func(event NodeEvent, prefix string, pathList []table.Path) {
+		if event == EVENT_PREFIX_MULTIPATH {
+			events[prefix] = pathList
+		}
+	})
+
+	rib := table.NewTableManager()
+	rib.SetLocalAsn(65000)
+	rib.SetUseMultiplePaths(configuration.UseMultiplePathsType{
+		Ebgp: configuration.EbgpType{MaximumPaths: 2},
+	})
+	path := func(address string) table.Path {
+		peer := &table.PeerInfo{AS: 65001, ID: net.ParseIP(address).To4(), Address: net.ParseIP(address), RF: bgp.RF_IPv4_UC}
+		return table.CreatePath(peer, bgp.NewNLRInfo(24, "10.1.1.0"), []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001})}),
+			bgp.NewPathAttributeNextHop(address),
+		}, false)
+	}
+	rib.ProcessPaths([]table.Path{path("10.0.0.2"), path("10.0.0.3")})
+	MultiPathEvent(rib.GetMultiPathUpdates())
+
+	pathList, found := events["10.1.1.0/24"]
+	if !found {
+		t.Fatal("no multipath event for 10.1.1.0/24")
+	}
+	if len(pathList) != 2 {
+		t.Errorf("unexpected multipath set %v", pathList)
+	}
+}
@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+	"net"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// evpnServer takes part in the EVPN route family as a sibling of the
+// neighbors: it advertises the MAC/IP routes of the local hosts added
+// over the REST API, with the router ID as VTEP address, and holds the
+// Loc-RIB of the EVPN routes received from the neighbors.
+type evpnServer struct {
+	rib     *table.TableManager
+	manager *table.EVPNManager
+	siblingServer
+}
+
+func newEvpnServer(g configuration.GlobalType) *evpnServer {
+	s := &evpnServer{
+		rib:           table.NewTableManager(),
+		manager:       table.NewEVPNManager(g.RouterId),
+		siblingServer: newSiblingServer(bgp.RF_EVPN),
+	}
+	s.rib.SetLocalAsn(g.As)
+	go s.serve(s.handleServerMsg, s.handleNeighborMsg)
+	return s
+}
+
+// evpnMacIPRoute parses a route added to or deleted from the local
+// hosts over the REST API.
+func evpnMacIPRoute(route api.RestEvpnRoute) (*table.EVPNMacIPRoute, error) {
+	mac, err := net.ParseMAC(route.Mac)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mac address %s", route.Mac)
+	}
+	var ip net.IP
+	if route.Ip != "" {
+		if ip = net.ParseIP(route.Ip); ip == nil {
+			return nil, fmt.Errorf("invalid ip address %s", route.Ip)
+		}
+		if ip.To4() != nil {
+			ip = ip.To4()
+		}
+	}
+	rd, err := bgp.ParseRouteDistinguisher(route.Rd)
+	if err != nil {
+		return nil, err
+	}
+	esi, err := bgp.ParseEthernetSegmentIdentifier(route.Esi)
+	if err != nil {
+		return nil, err
+	}
+	extCommunities := make([]bgp.ExtendedCommunityInterface, 0, len(route.ExtCommunities))
+	for _, c := range route.ExtCommunities {
+		e, err := bgp.ParseExtendedCommunity(c)
+		if err != nil {
+			return nil, err
+		}
+		extCommunities = append(extCommunities, e)
+	}
+	return &table.EVPNMacIPRoute{
+		Rd:             rd,
+		Esi:            *esi,
+		ETag:           route.ETag,
+		Mac:            mac,
+		IP:             ip,
+		Vni:            route.Vni,
+		ExtCommunities: extCommunities,
+	}, nil
+}
+
+func (s *evpnServer) handleREST(restReq *api.RestRequest) {
+	result := &api.RestResponse{}
+	route := restReq.EvpnRoute
+	switch restReq.RequestType {
+	case api.API_EVPN:
+		j, _ := json.MarshalIndent(struct {
+			Local    *table.EVPNManager `json:"local"`
+			Received table.Table        `json:"received"`
+		}{s.manager, s.rib.Tables[bgp.RF_EVPN]}, "", "\t")
+		result.Data = j
+	case api.API_ADD_EVPN_ROUTE, api.API_DEL_EVPN_ROUTE:
+		r, err := evpnMacIPRoute(route)
+		var pathList []table.Path
+		if err == nil && restReq.RequestType == api.API_ADD_EVPN_ROUTE {
+			pathList = s.manager.AddMacIPRoute(r)
+		} else if err == nil {
+			pathList, err = s.manager.DeleteMacIPRoute(r)
+		}
+		if err != nil {
+			log.Errorf("Error updating evpn route: %s", err)
+			result.ResponseErr = err
+			break
+		}
+		s.sendPathsToSiblings(pathList)
+		returnMsg := fmt.Sprintf("Added evpn route [ Mac: %s , Ip: %s, Vni: %d ]", route.Mac, route.Ip, route.Vni)
+		if restReq.RequestType == api.API_DEL_EVPN_ROUTE {
+			returnMsg = fmt.Sprintf("Deleting evpn route [ Mac: %s , Ip: %s ]", route.Mac, route.Ip)
+		}
+		j, _ := json.MarshalIndent(returnMsg, "", "\t")
+		result.Data = j
+	}
+	restReq.ResponseCh <- result
+	close(restReq.ResponseCh)
+}
+
+func (s *evpnServer) handleServerMsg(m *daemonMsg) {
+	switch m.msgType {
+	case SRV_MSG_PEER_ADDED:
+		d := m.msgData.(*daemonMsgDataNeighbor)
+		if s.addSibling(d) {
+			s.sendPaths(d, s.manager.GetPathList())
+		}
+	case SRV_MSG_PEER_DELETED:
+		d := m.msgData.(*table.PeerInfo)
+		s.deleteSibling(d.Address)
+		s.rib.DeletePathsforPeer(d)
+	case SRV_MSG_API:
+		s.handleREST(m.msgData.(*api.RestRequest))
+	}
+}
+
+func (s *evpnServer) handleNeighborMsg(m *neighborMsg) {
+	switch m.msgType {
+	case PEER_MSG_PATH:
+		s.rib.ProcessPaths(m.msgData.([]table.Path))
+	case PEER_MSG_PEER_DOWN:
+		s.rib.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+	}
+}
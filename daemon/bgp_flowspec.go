@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// flowSpecServer takes part in the FlowSpec route families (RFC 8955 and
+// RFC 8956) as a sibling of the neighbors: it advertises the flow
+// specification rules added over the REST API and holds the Loc-RIB of
+// the ones received from the neighbors.
+type flowSpecServer struct {
+	rib   *table.TableManager
+	local *table.TableManager
+	siblingServer
+}
+
+func newFlowSpecServer(g configuration.GlobalType) *flowSpecServer {
+	s := &flowSpecServer{
+		rib:           table.NewTableManager(),
+		local:         table.NewTableManager(),
+		siblingServer: newSiblingServer(bgp.RF_FS_IPv4_UC, bgp.RF_FS_IPv6_UC),
+	}
+	s.rib.SetLocalAsn(g.As)
+	s.local.SetLocalAsn(g.As)
+	go s.serve(s.handleServerMsg, s.handleNeighborMsg)
+	return s
+}
+
+// flowSpecPath builds the locally originated path of a flow
+// specification rule added over the REST API.
+func flowSpecPath(flowSpec api.RestFlowSpec) (table.Path, error) {
+	rf := bgp.RF_FS_IPv4_UC
+	if flowSpec.RouteFamily != "" {
+		var err error
+		if rf, err = bgp.GetRouteFamily(flowSpec.RouteFamily); err != nil {
+			return nil, err
+		}
+	}
+	l, err := bgp.ParseFlowSpecComponents(rf, flowSpec.Match)
+	if err != nil {
+		return nil, err
+	}
+	nlri := bgp.NewFlowSpecIPv4Unicast(l)
+	if rf == bgp.RF_FS_IPv6_UC {
+		nlri = bgp.NewFlowSpecIPv6Unicast(l)
+	}
+	extCommunities := make([]bgp.ExtendedCommunityInterface, 0, len(flowSpec.Actions))
+	for _, a := range flowSpec.Actions {
+		e, err := bgp.ParseExtendedCommunity(a)
+		if err != nil {
+			return nil, err
+		}
+		extCommunities = append(extCommunities, e)
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI("", []bgp.AddrPrefixInterface{nlri}),
+	}
+	if len(extCommunities) > 0 {
+		pathAttributes = append(pathAttributes, bgp.NewPathAttributeExtendedCommunities(extCommunities))
+	}
+	return table.CreatePath(nil, nlri, pathAttributes, false), nil
+}
+
+func (s *flowSpecServer) handleREST(restReq *api.RestRequest) {
+	result := &api.RestResponse{}
+	switch restReq.RequestType {
+	case api.API_FLOWSPEC:
+		j, _ := json.MarshalIndent(struct {
+			Local    map[string]table.Table `json:"local"`
+			Received map[string]table.Table `json:"received"`
+		}{
+			map[string]table.Table{
+				"ipv4": s.local.Tables[bgp.RF_FS_IPv4_UC],
+				"ipv6": s.local.Tables[bgp.RF_FS_IPv6_UC],
+			},
+			map[string]table.Table{
+				"ipv4": s.rib.Tables[bgp.RF_FS_IPv4_UC],
+				"ipv6": s.rib.Tables[bgp.RF_FS_IPv6_UC],
+			},
+		}, "", "\t")
+		result.Data = j
+	case api.API_ADD_FLOWSPEC, api.API_DEL_FLOWSPEC:
+		path, err := flowSpecPath(restReq.FlowSpec)
+		if err == nil && restReq.RequestType == api.API_DEL_FLOWSPEC {
+			dest, found := s.local.Tables[path.GetRouteFamily()].GetDestinations()[path.GetPrefix()]
+			if found {
+				path = dest.GetBestPath().Clone(true)
+			} else {
+				err = fmt.Errorf("flowspec %s does not exist", path.GetPrefix())
+			}
+		}
+		if err != nil {
+			log.Errorf("Error updating flowspec: %s", err)
+			result.ResponseErr = err
+			break
+		}
+		s.local.ProcessPaths([]table.Path{path})
+		s.sendPathsToSiblings([]table.Path{path})
+		returnMsg := fmt.Sprintf("Added flowspec [ Match: %s , Actions: %v ]", path.GetPrefix(), restReq.FlowSpec.Actions)
+		if path.IsWithdraw() {
+			returnMsg = fmt.Sprintf("Withdrawing flowspec [ Match: %s ]", path.GetPrefix())
+		}
+		j, _ := json.MarshalIndent(returnMsg, "", "\t")
+		result.Data = j
+	}
+	restReq.ResponseCh <- result
+	close(restReq.ResponseCh)
+}
+
+func (s *flowSpecServer) handleServerMsg(m *daemonMsg) {
+	switch m.msgType {
+	case SRV_MSG_PEER_ADDED:
+		d := m.msgData.(*daemonMsgDataNeighbor)
+		if s.addSibling(d) {
+			s.sendPaths(d, s.local.GetBestPathList(d.rf))
+		}
+	case SRV_MSG_PEER_DELETED:
+		d := m.msgData.(*table.PeerInfo)
+		s.deleteSibling(d.Address)
+		s.rib.DeletePathsforPeer(d)
+	case SRV_MSG_API:
+		s.handleREST(m.msgData.(*api.RestRequest))
+	}
+}
+
+func (s *flowSpecServer) handleNeighborMsg(m *neighborMsg) {
+	switch m.msgType {
+	case PEER_MSG_PATH:
+		s.rib.ProcessPaths(m.msgData.([]table.Path))
+	case PEER_MSG_PEER_DOWN:
+		s.rib.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+	}
+}
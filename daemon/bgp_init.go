@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/gopher-net/gopher-net/api"
 	"github.com/gopher-net/gopher-net/configuration"
+	"github.com/gopher-net/gopher-net/policy"
 	"net"
 	"os"
 	"strconv"
@@ -12,6 +13,7 @@ import (
 
 	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
 )
 
 type daemonMsgType int
@@ -21,6 +23,8 @@ const (
 	SRV_MSG_PEER_ADDED
 	SRV_MSG_PEER_DELETED
 	SRV_MSG_API
+	SRV_MSG_NEXTHOP_CHANGED
+	SRV_MSG_POLICY_UPDATED
 )
 
 type daemonMsg struct {
@@ -34,6 +38,17 @@ type daemonMsgDataNeighbor struct {
 	rf            bgp.RouteFamily
 }
 
+// siblingKey identifies a sibling by its address and route family, the
+// servers taking part in several route families share an address.
+func siblingKey(address net.IP, rf bgp.RouteFamily) string {
+	return fmt.Sprintf("%s/%s", address, rf)
+}
+
+type daemonMsgDataPolicy struct {
+	policy      *policy.RoutingPolicy
+	applyPolicy configuration.ApplyPolicyType
+}
+
 type neighborMapInfo struct {
 	neighbor        *Neighbor
 	daemonMsgCh     chan *daemonMsg
@@ -43,21 +58,35 @@ type neighborMapInfo struct {
 
 type Daemon struct {
 	bgpConfig         configuration.BgpType
-	globalTypeCh      chan configuration.GlobalType
+	globalConfigCh    chan configuration.BgpType
 	addedNeighborCh   chan configuration.NeighborType
 	deletedNeighborCh chan configuration.NeighborType
+	policyCh          chan configuration.BgpType
+	staticRoutesCh    chan configuration.BgpType
 	RestReqCh         chan *api.RestRequest
 	listenPort        int
 	neighborMap       map[string]neighborMapInfo
+	nexthopResolver   *nexthopResolver
+	policy            *policy.RoutingPolicy
+	vrfServer         *vrfServer
+	labelServer       *labelServer
+	vplsServer        *vplsServer
+	evpnServer        *evpnServer
+	flowSpecServer    *flowSpecServer
+	lsServer          *lsServer
+	aggregateServer   *aggregateServer
 }
 
 func NewBgpDaemon(port int) *Daemon {
 	b := Daemon{}
-	b.globalTypeCh = make(chan configuration.GlobalType)
+	b.globalConfigCh = make(chan configuration.BgpType)
 	b.addedNeighborCh = make(chan configuration.NeighborType)
 	b.deletedNeighborCh = make(chan configuration.NeighborType)
+	b.policyCh = make(chan configuration.BgpType)
+	b.staticRoutesCh = make(chan configuration.BgpType)
 	b.RestReqCh = make(chan *api.RestRequest, 1)
 	b.listenPort = port
+	b.nexthopResolver = newNexthopResolver()
 	return &b
 }
 
@@ -96,7 +125,23 @@ func listenAndAccept(proto string, port int, ch chan *net.TCPConn) (*net.TCPList
 }
 
 func (daemon *Daemon) Serve() {
-	daemon.bgpConfig.Global = <-daemon.globalTypeCh
+	c := <-daemon.globalConfigCh
+	daemon.bgpConfig.Global = c.Global
+	daemon.bgpConfig.AfiList = c.AfiList
+	labels := table.NewLabelPool()
+	daemon.vrfServer = newVrfServer(daemon.bgpConfig.Global, labels)
+	daemon.labelServer = newLabelServer(daemon.bgpConfig.Global, labels)
+	daemon.vplsServer = newVplsServer(daemon.bgpConfig.Global, configuration.VplsList(daemon.bgpConfig.AfiList), labels)
+	daemon.evpnServer = newEvpnServer(daemon.bgpConfig.Global)
+	daemon.flowSpecServer = newFlowSpecServer(daemon.bgpConfig.Global)
+	daemon.lsServer = newLsServer(daemon.bgpConfig.Global)
+	daemon.aggregateServer = newAggregateServer(daemon.bgpConfig.Global)
+	daemon.nexthopResolver.setStaticRoutes(daemon.bgpConfig.Global.StaticRoutes)
+	if _, err := daemon.nexthopResolver.loadKernelRoutes(); err != nil {
+		log.Warnf("can't read the kernel routing table, next hops are not tracked: %s", err)
+	}
+	nexthopTicker := time.NewTicker(NEXTHOP_INTERVAL)
+	defer nexthopTicker.Stop()
 	listenerMap := make(map[string]*net.TCPListener)
 	acceptCh := make(chan *net.TCPConn)
 	l4, err1 := listenAndAccept("tcp4", daemon.listenPort, acceptCh)
@@ -149,7 +194,14 @@ func (daemon *Daemon) Serve() {
 				l[i] = v.neighborMsgData
 				i++
 			}
-			p := NewNeighbor(daemon.bgpConfig.Global, neighbor, sch, pch, l)
+			l = append(l, daemon.vrfServer.neighborMsgData...)
+			l = append(l, daemon.labelServer.neighborMsgData...)
+			l = append(l, daemon.vplsServer.neighborMsgData...)
+			l = append(l, daemon.evpnServer.neighborMsgData...)
+			l = append(l, daemon.flowSpecServer.neighborMsgData...)
+			l = append(l, daemon.lsServer.neighborMsgData...)
+			l = append(l, daemon.aggregateServer.neighborMsgData...)
+			p := NewNeighbor(daemon.bgpConfig.Global, neighbor, sch, pch, l, daemon.nexthopResolver, daemon.policy, daemon.aggregateServer.manager)
 			d := &daemonMsgDataNeighbor{
 				address:       neighbor.NeighborAddress,
 				neighborMsgCh: pch,
@@ -160,6 +212,11 @@ func (daemon *Daemon) Serve() {
 				msgData: d,
 			}
 			sendServerMsgToAll(daemon.neighborMap, msg)
+			daemon.vrfServer.daemonMsgCh <- msg
+			daemon.vplsServer.daemonMsgCh <- msg
+			daemon.evpnServer.daemonMsgCh <- msg
+			daemon.flowSpecServer.daemonMsgCh <- msg
+			daemon.aggregateServer.daemonMsgCh <- msg
 			daemon.neighborMap[neighbor.NeighborAddress.String()] = neighborMapInfo{
 				neighbor:        p,
 				daemonMsgCh:     sch,
@@ -186,11 +243,54 @@ func (daemon *Daemon) Serve() {
 					msgData: info.neighbor.neighborInfo,
 				}
 				sendServerMsgToAll(daemon.neighborMap, msg)
+				daemon.vrfServer.daemonMsgCh <- msg
+				daemon.labelServer.daemonMsgCh <- msg
+				daemon.vplsServer.daemonMsgCh <- msg
+				daemon.evpnServer.daemonMsgCh <- msg
+				daemon.flowSpecServer.daemonMsgCh <- msg
+				daemon.lsServer.daemonMsgCh <- msg
+				daemon.aggregateServer.daemonMsgCh <- msg
 			} else {
 				log.Info("Can't delete a peer configuration for ", addr)
 			}
+		case c := <-daemon.policyCh:
+			p, err := policy.NewRoutingPolicy(c.Policy, daemon.bgpConfig.Global.As)
+			if err != nil {
+				log.Errorf("invalid routing policy, keeping the current one: %s", err)
+				continue
+			}
+			daemon.policy = p
+			for _, neighbor := range c.NeighborList {
+				info, found := daemon.neighborMap[neighbor.NeighborAddress.String()]
+				if !found {
+					continue
+				}
+				info.daemonMsgCh <- &daemonMsg{
+					msgType: SRV_MSG_POLICY_UPDATED,
+					msgData: &daemonMsgDataPolicy{
+						policy:      p,
+						applyPolicy: neighbor.ApplyPolicy,
+					},
+				}
+			}
+		case c := <-daemon.staticRoutesCh:
+			daemon.bgpConfig.Global.StaticRoutes = c.Global.StaticRoutes
+			if daemon.nexthopResolver.setStaticRoutes(c.Global.StaticRoutes) {
+				msg := &daemonMsg{
+					msgType: SRV_MSG_NEXTHOP_CHANGED,
+				}
+				sendServerMsgToAll(daemon.neighborMap, msg)
+			}
 		case restReq := <-daemon.RestReqCh:
 			go daemon.handleRest(restReq)
+		case <-nexthopTicker.C:
+			changed, err := daemon.nexthopResolver.loadKernelRoutes()
+			if err == nil && changed {
+				msg := &daemonMsg{
+					msgType: SRV_MSG_NEXTHOP_CHANGED,
+				}
+				sendServerMsgToAll(daemon.neighborMap, msg)
+			}
 		}
 	}
 }
@@ -201,8 +301,24 @@ func sendServerMsgToAll(neighborMap map[string]neighborMapInfo, msg *daemonMsg)
 	}
 }
 
-func (daemon *Daemon) SetGlobalType(g configuration.GlobalType) {
-	daemon.globalTypeCh <- g
+// SetGlobalConfig passes the global settings and the global address
+// family settings of bgpConfig to the daemon, it starts serving with
+// them.
+func (daemon *Daemon) SetGlobalConfig(bgpConfig configuration.BgpType) {
+	daemon.globalConfigCh <- bgpConfig
+}
+
+// SetPolicy compiles the routing policy in bgpConfig, the neighbors in it
+// which are already running re-evaluate their routes with it.
+func (daemon *Daemon) SetPolicy(bgpConfig configuration.BgpType) {
+	daemon.policyCh <- bgpConfig
+}
+
+// SetStaticRoutes replaces the static routes the next hops are resolved
+// with by the ones of bgpConfig, the neighbors re-evaluate their routes
+// if they have changed.
+func (daemon *Daemon) SetStaticRoutes(bgpConfig configuration.BgpType) {
+	daemon.staticRoutesCh <- bgpConfig
 }
 
 func (daemon *Daemon) NeighborAdd(neighbor configuration.NeighborType) {
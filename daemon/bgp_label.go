@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"encoding/json"
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// labelServer holds the Loc-RIB of the labeled unicast route families
+// (RFC 8277), it takes part in them as a sibling of the neighbors and
+// binds local labels to the best paths received from them.
+type labelServer struct {
+	rib     *table.TableManager
+	manager *table.LabelManager
+	siblingServer
+}
+
+func newLabelServer(g configuration.GlobalType, labels *table.LabelPool) *labelServer {
+	mode, err := table.ParseLabelAllocationMode(g.LabelAllocation)
+	if err != nil {
+		log.Errorf("%s, allocating the labels per prefix", err)
+	}
+	s := &labelServer{
+		rib:           table.NewTableManager(),
+		manager:       table.NewLabelManager(mode, labels),
+		siblingServer: newSiblingServer(bgp.RF_IPv4_MPLS, bgp.RF_IPv6_MPLS),
+	}
+	s.rib.SetLocalAsn(g.As)
+	go s.serve(s.handleServerMsg, s.handleNeighborMsg)
+	return s
+}
+
+func (s *labelServer) update(pList []table.Path, wList []table.Path) {
+	if err := s.manager.Update(append(pList, wList...)); err != nil {
+		log.Errorf("can't bind a local label: %s", err)
+	}
+}
+
+func (s *labelServer) handleREST(restReq *api.RestRequest) {
+	result := &api.RestResponse{}
+	switch restReq.RequestType {
+	case api.API_LABELS:
+		j, _ := json.MarshalIndent(s.manager, "", "\t")
+		result.Data = j
+	}
+	restReq.ResponseCh <- result
+	close(restReq.ResponseCh)
+}
+
+func (s *labelServer) handleServerMsg(m *daemonMsg) {
+	switch m.msgType {
+	case SRV_MSG_PEER_DELETED:
+		pList, wList, _ := s.rib.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+		s.update(pList, wList)
+	case SRV_MSG_API:
+		s.handleREST(m.msgData.(*api.RestRequest))
+	}
+}
+
+func (s *labelServer) handleNeighborMsg(m *neighborMsg) {
+	switch m.msgType {
+	case PEER_MSG_PATH:
+		pList, wList, _ := s.rib.ProcessPaths(m.msgData.([]table.Path))
+		s.update(pList, wList)
+	case PEER_MSG_PEER_DOWN:
+		pList, wList, _ := s.rib.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+		s.update(pList, wList)
+	}
+}
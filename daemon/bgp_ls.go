@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"encoding/json"
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// lsServer holds the Loc-RIB of the BGP-LS route family (RFC 7752), it
+// takes part in it as a sibling of the neighbors and builds the
+// link-state topology of the IGPs from the best paths received from
+// them.
+type lsServer struct {
+	rib      *table.TableManager
+	topology *table.LsTopology
+	siblingServer
+}
+
+func newLsServer(g configuration.GlobalType) *lsServer {
+	s := &lsServer{
+		rib:           table.NewTableManager(),
+		topology:      table.NewLsTopology(),
+		siblingServer: newSiblingServer(bgp.RF_LS),
+	}
+	s.rib.SetLocalAsn(g.As)
+	go s.serve(s.handleServerMsg, s.handleNeighborMsg)
+	return s
+}
+
+func (s *lsServer) update(pList []table.Path, wList []table.Path) {
+	s.topology.Update(append(pList, wList...))
+}
+
+func (s *lsServer) handleREST(restReq *api.RestRequest) {
+	result := &api.RestResponse{}
+	switch restReq.RequestType {
+	case api.API_LS:
+		j, _ := json.MarshalIndent(s.topology, "", "\t")
+		result.Data = j
+	case api.API_LS_NODES:
+		j, _ := json.MarshalIndent(s.topology.GetNodes(), "", "\t")
+		result.Data = j
+	case api.API_LS_LINKS:
+		j, _ := json.MarshalIndent(s.topology.GetLinks(), "", "\t")
+		result.Data = j
+	}
+	restReq.ResponseCh <- result
+	close(restReq.ResponseCh)
+}
+
+func (s *lsServer) handleServerMsg(m *daemonMsg) {
+	switch m.msgType {
+	case SRV_MSG_PEER_DELETED:
+		pList, wList, _ := s.rib.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+		s.update(pList, wList)
+	case SRV_MSG_API:
+		s.handleREST(m.msgData.(*api.RestRequest))
+	}
+}
+
+func (s *lsServer) handleNeighborMsg(m *neighborMsg) {
+	switch m.msgType {
+	case PEER_MSG_PATH:
+		pList, wList, _ := s.rib.ProcessPaths(m.msgData.([]table.Path))
+		s.update(pList, wList)
+	case PEER_MSG_PEER_DOWN:
+		pList, wList, _ := s.rib.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+		s.update(pList, wList)
+	}
+}
@@ -25,6 +25,29 @@ type RestRoute struct {
 	ExCommunity  string `json:"extended_community"`
 }
 
+type RestExplanation struct {
+	NeighborAddr net.IP                     `json:"neighbor_ip"`
+	Explanation  *table.BestPathExplanation `json:"explanation"`
+}
+
+// neighborRestResponses hands a copy of restReq to each of neighbors, which
+// answers from its own goroutine, and returns the responses in the same
+// order. It runs apart from the daemon goroutine, which neighbors may be
+// waiting for.
+func neighborRestResponses(restReq *api.RestRequest, neighbors []*Neighbor) []*api.RestResponse {
+	responses := make([]*api.RestResponse, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		req := *restReq
+		req.ResponseCh = make(chan *api.RestResponse)
+		neighbor.daemonMsgCh <- &daemonMsg{
+			msgType: SRV_MSG_API,
+			msgData: &req,
+		}
+		responses = append(responses, <-req.ResponseCh)
+	}
+	return responses
+}
+
 func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 	switch restReq.RequestType {
 
@@ -70,7 +93,7 @@ func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 		restReq.ResponseCh <- result
 		close(restReq.ResponseCh)
 
-	case api.API_ADJ_RIB_LOCAL:
+	case api.API_ADJ_RIB_LOCAL, api.API_NEIGHBOR_DAMPENING, api.API_NEIGHBOR_DAMPENING_CLEAR:
 		remoteAddr := restReq.RemoteAddr
 		result := &api.RestResponse{}
 		info, found := daemon.neighborMap[remoteAddr]
@@ -123,6 +146,51 @@ func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 		restReq.ResponseCh <- result
 		close(restReq.ResponseCh)
 
+	case api.API_MULTICAST_RIB:
+		var addrs []string
+		var neighbors []*Neighbor
+		for addr, peer := range daemon.neighborMap {
+			if isMulticastRouteFamily(peer.neighbor.rf) {
+				addrs = append(addrs, addr)
+				neighbors = append(neighbors, peer.neighbor)
+			}
+		}
+		go func() {
+			result := &api.RestResponse{}
+			tables := make(map[string]json.RawMessage)
+			for i, r := range neighborRestResponses(restReq, neighbors) {
+				tables[addrs[i]] = r.Data
+			}
+			j, _ := json.MarshalIndent(tables, "", "\t")
+			result.Data = j
+			restReq.ResponseCh <- result
+			close(restReq.ResponseCh)
+		}()
+
+	case api.API_ROUTE_EXPLAIN:
+		neighbors := make([]*Neighbor, 0, len(daemon.neighborMap))
+		for _, peer := range daemon.neighborMap {
+			neighbors = append(neighbors, peer.neighbor)
+		}
+		go func() {
+			result := &api.RestResponse{}
+			explanations := make([]json.RawMessage, 0)
+			for _, r := range neighborRestResponses(restReq, neighbors) {
+				if r.ResponseErr == nil {
+					explanations = append(explanations, r.Data)
+				}
+			}
+			if len(explanations) == 0 {
+				prefix := CidrToString(net.ParseIP(restReq.RestRoute.IpPrefix), restReq.RestRoute.PrefixMask)
+				result.ResponseErr = fmt.Errorf("Destination [ %s ] does not exist.", prefix)
+			} else {
+				j, _ := json.MarshalIndent(explanations, "", "\t")
+				result.Data = j
+			}
+			restReq.ResponseCh <- result
+			close(restReq.ResponseCh)
+		}()
+
 	case api.API_RIB_OUT:
 		result := &api.RestResponse{}
 		ribOutList := make([]table.Path, 0)
@@ -197,7 +265,32 @@ func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 		restReq.ResponseCh <- result
 		close(restReq.ResponseCh)
 
+	case api.API_VRFS, api.API_VRF:
+		daemon.vrfServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+
+	case api.API_LABELS:
+		daemon.labelServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+
+	case api.API_VPLS, api.API_VPLS_INSTANCE:
+		daemon.vplsServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+
+	case api.API_EVPN, api.API_ADD_EVPN_ROUTE, api.API_DEL_EVPN_ROUTE:
+		daemon.evpnServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+
+	case api.API_FLOWSPEC, api.API_ADD_FLOWSPEC, api.API_DEL_FLOWSPEC:
+		daemon.flowSpecServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+
+	case api.API_LS, api.API_LS_NODES, api.API_LS_LINKS:
+		daemon.lsServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+
+	case api.API_AGGREGATES:
+		daemon.aggregateServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+
 	case api.API_ADD_ROUTE:
+		if restReq.RestRoute.Vrf != "" {
+			daemon.vrfServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+			return
+		}
 		// TODO routes are not inserted if a host is not conencted
 		result := &api.RestResponse{}
 		log.Debugf("Adding route:  [ Prefix: %s , Netmask: %d , Nexthop: %s ]",
@@ -218,38 +311,21 @@ func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 			result.ResponseErr = fmt.Errorf("IP Prefix, Mask and IP Nexthop are mandatory.")
 			return
 		}
+		pathAttributes, err := restRoutePathAttrs(restReq.RestRoute, bgp.NewPathAttributeNextHop(restReq.RestRoute.NextHop))
+		if err != nil {
+			log.Errorf("Error adding route: %s", err)
+			result.ResponseErr = err
+			restReq.ResponseCh <- result
+			close(restReq.ResponseCh)
+			return
+		}
 		for _, p := range daemon.neighborMap {
 			if p.neighbor.neighborConfig.BgpNeighborCommonState.State != uint32(bgp.BGP_FSM_ESTABLISHED) {
 				continue
 			}
-			origin := bgp.NewPathAttributeOrigin(0)
-			aspathParam := []bgp.AsPathParamInterface{}
-			aspath := bgp.NewPathAttributeAsPath(aspathParam)
-			nexthop := bgp.NewPathAttributeNextHop(restReq.RestRoute.NextHop)
-			med := bgp.NewPathAttributeMultiExitDisc(0)
-			localpref := bgp.NewPathAttributeLocalPref(100)
-			pathAttributes := []bgp.PathAttributeInterface{
-				origin,
-				aspath,
-				nexthop,
-				med,
-				localpref,
-			}
-			if restReq.RestRoute.ExCommunity != "" && len(restReq.RestRoute.ExCommunity) < 8 {
-				exCommStr := restReq.RestRoute.ExCommunity
-				exCommunity := []byte(exCommStr)
-				exComm := []bgp.ExtendedCommunityInterface{
-					&bgp.OpaqueExtended{Value: exCommunity},
-				}
-				excommunity := bgp.NewPathAttributeExtendedCommunities(exComm)
-				pathAttributes = []bgp.PathAttributeInterface{
-					origin,
-					aspath,
-					nexthop,
-					med,
-					localpref,
-					excommunity,
-				}
+			// the unicast routes aren't sent on the multicast sessions
+			if isMulticastRouteFamily(p.neighbor.rf) {
+				continue
 			}
 			prefix := *bgp.NewNLRInfo(restReq.RestRoute.PrefixMask, restReq.RestRoute.IpPrefix)
 			nlri := []bgp.NLRInfo{prefix}
@@ -265,6 +341,10 @@ func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 		}
 
 	case api.API_DEL_ROUTE:
+		if restReq.RestRoute.Vrf != "" {
+			daemon.vrfServer.daemonMsgCh <- &daemonMsg{msgType: SRV_MSG_API, msgData: restReq}
+			return
+		}
 		// TODO: Verify if route exists in RIB
 		result := &api.RestResponse{} // Todo condense and cleanup null checks
 		log.Debugf("Deleting route:  [ Prefix: %s , Netmask: %d ]",
@@ -283,6 +363,10 @@ func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 			if p.neighbor.neighborConfig.BgpNeighborCommonState.State != uint32(bgp.BGP_FSM_ESTABLISHED) {
 				continue
 			}
+			// the unicast routes aren't sent on the multicast sessions
+			if isMulticastRouteFamily(p.neighbor.rf) {
+				continue
+			}
 			origin := bgp.NewPathAttributeOrigin(0)
 			aspathParam := []bgp.AsPathParamInterface{}
 			aspath := bgp.NewPathAttributeAsPath(aspathParam)
@@ -311,6 +395,45 @@ func (daemon *Daemon) handleRest(restReq *api.RestRequest) {
 	}
 }
 
+// restRoutePathAttrs builds the path attributes of a route added over
+// the REST API around the given next hop attribute.
+func restRoutePathAttrs(route api.RestRoute, nexthop bgp.PathAttributeInterface) ([]bgp.PathAttributeInterface, error) {
+	extCommunities := make([]bgp.ExtendedCommunityInterface, 0, len(route.ExtCommunities))
+	for _, s := range route.ExtCommunities {
+		e, err := bgp.ParseExtendedCommunity(s)
+		if err != nil {
+			return nil, err
+		}
+		extCommunities = append(extCommunities, e)
+	}
+	largeCommunities := make([]*bgp.LargeCommunity, 0, len(route.LargeCommunities))
+	for _, s := range route.LargeCommunities {
+		c, err := bgp.ParseLargeCommunity(s)
+		if err != nil {
+			return nil, err
+		}
+		largeCommunities = append(largeCommunities, c)
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		nexthop,
+		bgp.NewPathAttributeMultiExitDisc(0),
+		bgp.NewPathAttributeLocalPref(100),
+	}
+	if route.ExCommunity != "" && len(route.ExCommunity) < 8 {
+		opaque := &bgp.OpaqueExtended{Value: []byte(route.ExCommunity)}
+		extCommunities = append([]bgp.ExtendedCommunityInterface{opaque}, extCommunities...)
+	}
+	if len(extCommunities) > 0 {
+		pathAttributes = append(pathAttributes, bgp.NewPathAttributeExtendedCommunities(extCommunities))
+	}
+	if len(largeCommunities) > 0 {
+		pathAttributes = append(pathAttributes, bgp.NewPathAttributeLargeCommunities(largeCommunities))
+	}
+	return pathAttributes, nil
+}
+
 func (restRoutes *RestRoute) String() string {
 	str := fmt.Sprintf("BGP_AS Source: %d, ", restRoutes.AS)
 	str = str + fmt.Sprintf(" IP_PREFIX: %s, ", restRoutes.IP4prefix)
@@ -2,8 +2,10 @@ package daemon
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/gopher-net/gopher-net/api"
 	"github.com/gopher-net/gopher-net/configuration"
+	"github.com/gopher-net/gopher-net/policy"
 	"net"
 	"time"
 
@@ -24,6 +26,7 @@ const (
 const (
 	FSM_CHANNEL_LENGTH = 1024
 	FLOP_THRESHOLD     = time.Second * 30
+	DAMPING_INTERVAL   = time.Second * 5
 )
 
 type neighborMsg struct {
@@ -42,13 +45,19 @@ type Neighbor struct {
 	adjRib         *table.AdjRib
 	rib            *table.TableManager
 	rf             bgp.RouteFamily
+	// the neighbor advertises its route target memberships (RFC 4684)
+	rtc            bool
 	capMap         map[bgp.BGPCapabilityCode]bgp.ParameterCapabilityInterface
 	neighborInfo   *table.PeerInfo
 	siblings       map[string]*daemonMsgDataNeighbor
 	outgoing       chan *bgp.BGPMessage
+	importPolicies []*policy.Policy
+	exportPolicies []*policy.Policy
+	// the configured aggregates, shared with the other neighbors
+	aggregates *table.AggregateManager
 }
 
-func NewNeighbor(g configuration.GlobalType, neighbor configuration.NeighborType, daemonMsgCh chan *daemonMsg, neighborMsgCh chan *neighborMsg, neighborList []*daemonMsgDataNeighbor) *Neighbor {
+func NewNeighbor(g configuration.GlobalType, neighbor configuration.NeighborType, daemonMsgCh chan *daemonMsg, neighborMsgCh chan *neighborMsg, neighborList []*daemonMsgDataNeighbor, resolver table.NexthopResolver, routingPolicy *policy.RoutingPolicy, aggregates *table.AggregateManager) *Neighbor {
 	p := &Neighbor{
 		globalConfig:   g,
 		neighborConfig: neighbor,
@@ -56,19 +65,20 @@ func NewNeighbor(g configuration.GlobalType, neighbor configuration.NeighborType
 		daemonMsgCh:    daemonMsgCh,
 		neighborMsgCh:  neighborMsgCh,
 		capMap:         make(map[bgp.BGPCapabilityCode]bgp.ParameterCapabilityInterface),
+		aggregates:     aggregates,
 	}
 	p.siblings = make(map[string]*daemonMsgDataNeighbor)
 	for _, s := range neighborList {
-		p.siblings[s.address.String()] = s
+		p.siblings[siblingKey(s.address, s.rf)] = s
 	}
 	p.fsm = NewFSM(&g, &neighbor, p.acceptedConnCh)
 	neighbor.BgpNeighborCommonState.State = uint32(bgp.BGP_FSM_IDLE)
 	neighbor.BgpNeighborCommonState.Downtime = time.Now()
-	if neighbor.NeighborAddress.To4() != nil {
-		p.rf = bgp.RF_IPv4_UC
-	} else {
-		p.rf = bgp.RF_IPv6_UC
+	rf, err := neighborRouteFamily(&neighbor)
+	if err != nil {
+		log.Errorf("neighbor %s: %s, using the unicast family of its address", neighbor.NeighborAddress, err)
 	}
+	p.rf = rf
 	p.neighborInfo = &table.PeerInfo{
 		AS:      neighbor.PeerAs,
 		LocalID: g.RouterId,
@@ -76,11 +86,45 @@ func NewNeighbor(g configuration.GlobalType, neighbor configuration.NeighborType
 		Address: neighbor.NeighborAddress,
 	}
 	p.adjRib = table.NewAdjRib()
+	if neighbor.RouteFlapDamping {
+		d := neighbor.RouteFlapDampingParams
+		p.adjRib.EnableDamping(table.DampingConfig{
+			HalfLife:          time.Duration(d.HalfLife * float64(time.Second)),
+			ReuseThreshold:    float64(d.ReuseThreshold),
+			SuppressThreshold: float64(d.SuppressThreshold),
+			MaxSuppressTime:   time.Duration(d.MaxSuppressTime * float64(time.Second)),
+		})
+	}
 	p.rib = table.NewTableManager()
+	p.rib.SetLocalAsn(g.As)
+	p.rib.SetRouteSelectionOptions(neighbor.RouteSelectionOptions)
+	p.rib.SetUseMultiplePaths(neighbor.UseMultiplePaths)
+	if resolver != nil {
+		p.rib.SetNexthopResolver(resolver)
+	}
+	p.setPolicies(routingPolicy)
 	p.t.Go(p.loop)
 	return p
 }
 
+// neighborRouteFamily returns the route family configured for the
+// session, the unicast family of the neighbor address is the default
+// and the fallback when the configured one isn't supported.
+func neighborRouteFamily(neighbor *configuration.NeighborType) (bgp.RouteFamily, error) {
+	rf := bgp.RF_IPv4_UC
+	if neighbor.NeighborAddress.To4() == nil {
+		rf = bgp.RF_IPv6_UC
+	}
+	if neighbor.RouteFamily == "" {
+		return rf, nil
+	}
+	configured, err := bgp.GetRouteFamily(neighbor.RouteFamily)
+	if err != nil {
+		return rf, err
+	}
+	return configured, nil
+}
+
 func (neighbor *Neighbor) handleBGPmessage(m *bgp.BGPMessage) {
 	log.WithFields(log.Fields{
 		"Topic": "Neighbor",
@@ -92,6 +136,7 @@ func (neighbor *Neighbor) handleBGPmessage(m *bgp.BGPMessage) {
 	case bgp.BGP_MSG_OPEN:
 		body := m.Body.(*bgp.BGPOpen)
 		neighbor.neighborInfo.ID = m.Body.(*bgp.BGPOpen).ID
+		neighbor.rtc = false
 		for _, p := range body.OptParams {
 			paramCap, y := p.(*bgp.OptionParameterCapability)
 			if !y {
@@ -99,37 +144,220 @@ func (neighbor *Neighbor) handleBGPmessage(m *bgp.BGPMessage) {
 			}
 			for _, c := range paramCap.Capability {
 				neighbor.capMap[c.Code()] = c
+				if mp, y := c.(*bgp.CapMultiProtocol); y && isVpnRouteFamily(neighbor.rf) {
+					v := mp.CapValue
+					if rtcAfi, rtcSafi := bgp.RouteFamilyToAfiSafi(bgp.RF_RTC_UC); v.AFI == rtcAfi && v.SAFI == rtcSafi {
+						neighbor.rtc = true
+					}
+				}
 			}
 		}
 
 	case bgp.BGP_MSG_ROUTE_REFRESH:
-		pathList := neighbor.adjRib.GetOutPathList(neighbor.rf)
-		neighbor.sendMessages(table.CreateUpdateMsgFromPaths(pathList))
+		neighbor.sendMessages(table.CreateUpdateMsgFromPaths(neighbor.outPathList()))
 	case bgp.BGP_MSG_UPDATE:
 		neighbor.neighborConfig.BgpNeighborCommonState.UpdateRecvTime = time.Now()
 		body := m.Body.(*bgp.BGPUpdate)
 
 		table.UpdatePathAttrs4ByteAs(body)
+		table.UpdatePathAttrsDiscard(body, neighbor.neighborConfig.DiscardAttributes)
+		if !neighbor.neighborConfig.AigpSession {
+			table.UpdatePathAttrsRemoveAigp(body)
+		}
 		msg := table.NewProcessMessage(m, neighbor.neighborInfo)
-		pathList := msg.ToPathList()
+		pathList, rtcList := splitRtcPaths(msg.ToPathList())
+		if len(rtcList) > 0 {
+			neighbor.updateRtcMemberships(rtcList)
+		}
+		pathList = neighbor.filterRouteFamily(pathList)
 		if len(pathList) == 0 {
 			return
 		}
+		dampedList := neighbor.adjRib.Dampen(pathList)
 		neighbor.adjRib.UpdateIn(pathList)
 
 		// Container Events Call docker_updates.go
 		ContainerPrefixEvent(pathList, body)
 
-		pm := &neighborMsg{
-			msgType: PEER_MSG_PATH,
-			msgData: pathList,
+		neighbor.sendPathsToSiblings(dampedList)
+	}
+}
+
+func isVpnRouteFamily(rf bgp.RouteFamily) bool {
+	return rf == bgp.RF_IPv4_VPN || rf == bgp.RF_IPv6_VPN
+}
+
+func isMulticastRouteFamily(rf bgp.RouteFamily) bool {
+	return rf == bgp.RF_IPv4_MC || rf == bgp.RF_IPv6_MC
+}
+
+// filterRouteFamily drops the paths of the route families not
+// negotiated for the session (RFC 4760 section 7), such as unicast
+// routes received on a multicast session, so that they don't reach the
+// tables of the siblings.
+func (neighbor *Neighbor) filterRouteFamily(pathList []table.Path) []table.Path {
+	filtered := make([]table.Path, 0, len(pathList))
+	for _, path := range pathList {
+		if path.GetRouteFamily() == neighbor.rf {
+			filtered = append(filtered, path)
 		}
-		for _, s := range neighbor.siblings {
-			if s.rf != neighbor.rf {
-				continue
+	}
+	if n := len(pathList) - len(filtered); n > 0 {
+		log.Warnf("neighbor %s: ignoring %d paths not of the %s session family", neighbor.neighborConfig.NeighborAddress, n, neighbor.rf)
+	}
+	return filtered
+}
+
+// splitRtcPaths separates the route target membership paths from the
+// paths to be passed to the siblings.
+func splitRtcPaths(pathList []table.Path) ([]table.Path, []table.Path) {
+	paths := make([]table.Path, 0, len(pathList))
+	rtcList := make([]table.Path, 0)
+	for _, path := range pathList {
+		if path.GetRouteFamily() == bgp.RF_RTC_UC {
+			rtcList = append(rtcList, path)
+		} else {
+			paths = append(paths, path)
+		}
+	}
+	return paths, rtcList
+}
+
+// updateRtcMemberships records the route target memberships received
+// from the neighbor, and advertises or withdraws the VPN paths whose
+// route targets it has joined or left.
+func (neighbor *Neighbor) updateRtcMemberships(rtcList []table.Path) {
+	if !neighbor.rtc {
+		return
+	}
+	oldMemberships := neighbor.adjRib.GetInPathList(bgp.RF_RTC_UC)
+	neighbor.adjRib.UpdateIn(rtcList)
+	pList, wList := table.RtcMembershipChanges(neighbor.adjRib.GetOutPathList(neighbor.rf),
+		oldMemberships, neighbor.adjRib.GetInPathList(bgp.RF_RTC_UC))
+	neighbor.sendMessages(table.CreateUpdateMsgFromPaths(append(pList, wList...)))
+}
+
+// filterRtc drops the VPN paths carrying none of the route targets the
+// neighbor is a member of, and the membership paths if the neighbor
+// doesn't take them. The Adj-RIB-Out keeps the dropped paths so that
+// they are sent when the memberships change.
+func (neighbor *Neighbor) filterRtc(pathList []table.Path) []table.Path {
+	if neighbor.rtc {
+		return table.FilterUninterestingPaths(pathList, neighbor.adjRib.GetInPathList(bgp.RF_RTC_UC))
+	}
+	filtered := make([]table.Path, 0, len(pathList))
+	for _, path := range pathList {
+		if path.GetRouteFamily() != bgp.RF_RTC_UC {
+			filtered = append(filtered, path)
+		}
+	}
+	return filtered
+}
+
+// outPathList returns the paths of the Adj-RIB-Out to be sent to the
+// neighbor, the route target memberships come first so that it can
+// filter the VPN paths it sends back.
+func (neighbor *Neighbor) outPathList() []table.Path {
+	pathList := neighbor.adjRib.GetOutPathList(bgp.RF_RTC_UC)
+	pathList = append(pathList, neighbor.adjRib.GetOutPathList(neighbor.rf)...)
+	return neighbor.filterRtc(pathList)
+}
+
+func (neighbor *Neighbor) setPolicies(routingPolicy *policy.RoutingPolicy) {
+	neighbor.importPolicies = nil
+	neighbor.exportPolicies = nil
+	if routingPolicy == nil {
+		return
+	}
+	neighbor.importPolicies = routingPolicy.GetPolicies(neighbor.neighborConfig.ApplyPolicy.ImportPolicies)
+	neighbor.exportPolicies = routingPolicy.GetPolicies(neighbor.neighborConfig.ApplyPolicy.ExportPolicies)
+}
+
+func (neighbor *Neighbor) scope() table.NeighborScope {
+	peerAs := neighbor.neighborConfig.PeerAs
+	if peerAs == neighbor.globalConfig.As {
+		return table.NEIGHBOR_SCOPE_INTERNAL
+	}
+	for _, as := range neighbor.globalConfig.Confederation.MemberAs {
+		if peerAs == as {
+			return table.NEIGHBOR_SCOPE_CONFED
+		}
+	}
+	return table.NEIGHBOR_SCOPE_EXTERNAL
+}
+
+// applyImportPolicies runs the import policies over paths received from
+// the neighbor, rejected paths are turned into withdrawals so that the
+// siblings drop them if they were accepted before. The LOCAL_PREF of
+// paths with the GRACEFUL_SHUTDOWN community is lowered first, so that
+// the import policies can override it.
+func (neighbor *Neighbor) applyImportPolicies(pathList []table.Path) []table.Path {
+	filtered := make([]table.Path, 0, len(pathList))
+	for _, path := range pathList {
+		if p := policy.ApplyPolicies(neighbor.importPolicies, table.UpdatePathGracefulShutdown(path)); p != nil {
+			filtered = append(filtered, p)
+		} else {
+			filtered = append(filtered, path.Clone(true))
+		}
+	}
+	return filtered
+}
+
+// applyExportPolicies runs the export policies over paths to be sent to
+// the neighbor and then checks the well-known communities of the result.
+// A rejected path is withdrawn if it was advertised before and dropped
+// otherwise, so are the paths suppressed by a summary-only aggregate.
+func (neighbor *Neighbor) applyExportPolicies(pList []table.Path, wList []table.Path) ([]table.Path, []table.Path) {
+	scope := neighbor.scope()
+	newPList := make([]table.Path, 0, len(pList))
+	newWList := make([]table.Path, 0, len(wList))
+	for _, path := range pList {
+		if p := policy.ApplyPolicies(neighbor.exportPolicies, path); p != nil && table.IsAdvertisable(p, scope) && !neighbor.aggregates.IsSuppressed(p) {
+			newPList = append(newPList, p)
+		} else if neighbor.adjRib.IsAdvertised(path) {
+			newWList = append(newWList, path.Clone(true))
+		}
+	}
+	for _, path := range wList {
+		if neighbor.adjRib.IsAdvertised(path) {
+			newWList = append(newWList, path)
+		}
+	}
+	return newPList, newWList
+}
+
+// suppressedPaths returns the withdrawals of the advertised paths that
+// the summary-only aggregates in pList suppress from now on.
+func (neighbor *Neighbor) suppressedPaths(pList []table.Path) []table.Path {
+	wList := make([]table.Path, 0)
+	for _, path := range pList {
+		if !neighbor.aggregates.IsSummaryOnly(path) {
+			continue
+		}
+		for _, p := range neighbor.adjRib.GetOutPathList(neighbor.rf) {
+			if neighbor.aggregates.IsSuppressed(p) {
+				wList = append(wList, p.Clone(true))
 			}
-			s.neighborMsgCh <- pm
 		}
+		break
+	}
+	return wList
+}
+
+func (neighbor *Neighbor) sendPathsToSiblings(pathList []table.Path) {
+	if len(pathList) == 0 {
+		return
+	}
+	pathList = neighbor.applyImportPolicies(pathList)
+	pm := &neighborMsg{
+		msgType: PEER_MSG_PATH,
+		msgData: pathList,
+	}
+	for _, s := range neighbor.siblings {
+		if s.rf != neighbor.rf {
+			continue
+		}
+		s.neighborMsgCh <- pm
 	}
 }
 
@@ -143,6 +371,13 @@ func (neighbor *Neighbor) sendMessages(msgs []*bgp.BGPMessage) {
 			log.Fatal("not update message ", m.Header.Type)
 		}
 
+		neighbor.removePrivateAs(m.Body.(*bgp.BGPUpdate))
+		table.UpdatePathAttrsUnknown(m.Body.(*bgp.BGPUpdate))
+		table.UpdatePathAttrsDiscard(m.Body.(*bgp.BGPUpdate), neighbor.neighborConfig.DiscardAttributes)
+		if !neighbor.neighborConfig.AigpSession {
+			table.UpdatePathAttrsRemoveAigp(m.Body.(*bgp.BGPUpdate))
+		}
+
 		_, y := neighbor.capMap[bgp.BGP_CAP_FOUR_OCTET_AS_NUMBER]
 		if !y {
 			log.WithFields(log.Fields{
@@ -157,15 +392,61 @@ func (neighbor *Neighbor) sendMessages(msgs []*bgp.BGPMessage) {
 	}
 }
 
+func (neighbor *Neighbor) removePrivateAs(body *bgp.BGPUpdate) {
+	localAs := neighbor.globalConfig.As
+	peerAs := neighbor.neighborConfig.PeerAs
+	if localAs == peerAs {
+		return
+	}
+	switch neighbor.neighborConfig.RemovePrivateAs {
+	case configuration.REMOVE_PRIVATE_AS_OPTION_ALL:
+		table.UpdatePathAttrsRemovePrivateAs(body, localAs, peerAs, false)
+	case configuration.REMOVE_PRIVATE_AS_OPTION_REPLACE:
+		table.UpdatePathAttrsRemovePrivateAs(body, localAs, peerAs, true)
+	}
+}
+
 func (neighbor *Neighbor) handleREST(restReq *api.RestRequest) {
 	result := &api.RestResponse{}
-	j, _ := json.Marshal(neighbor.rib.Tables[neighbor.rf])
-	result.Data = j
+	switch restReq.RequestType {
+	case api.API_NEIGHBOR_DAMPENING:
+		j, _ := json.Marshal(neighbor.adjRib.GetDampenedList(neighbor.rf))
+		result.Data = j
+	case api.API_NEIGHBOR_DAMPENING_CLEAR:
+		prefix := ""
+		if restReq.RestRoute.IpPrefix != "" {
+			prefix = CidrToString(net.ParseIP(restReq.RestRoute.IpPrefix), restReq.RestRoute.PrefixMask)
+		}
+		pathList := neighbor.adjRib.ClearDampened(neighbor.rf, prefix)
+		neighbor.sendPathsToSiblings(pathList)
+		j, _ := json.Marshal(fmt.Sprintf("Cleared dampening, %d prefixes reused", len(pathList)))
+		result.Data = j
+	case api.API_ROUTE_EXPLAIN:
+		ip := net.ParseIP(restReq.RestRoute.IpPrefix)
+		rf := bgp.RF_IPv4_UC
+		if ip.To4() == nil {
+			rf = bgp.RF_IPv6_UC
+		}
+		e, err := neighbor.rib.Explain(rf, CidrToString(ip, restReq.RestRoute.PrefixMask))
+		if err != nil {
+			result.ResponseErr = err
+			break
+		}
+		j, _ := json.Marshal(&RestExplanation{
+			NeighborAddr: neighbor.neighborConfig.NeighborAddress,
+			Explanation:  e,
+		})
+		result.Data = j
+	default:
+		j, _ := json.Marshal(neighbor.rib.Tables[neighbor.rf])
+		result.Data = j
+	}
 	restReq.ResponseCh <- result
 	close(restReq.ResponseCh)
 }
 
 func (neighbor *Neighbor) sendUpdateMsgFromPaths(pList []table.Path, wList []table.Path) {
+	pList, wList = neighbor.applyExportPolicies(pList, wList)
 	pathList := append([]table.Path(nil), pList...)
 	pathList = append(pathList, wList...)
 
@@ -175,16 +456,19 @@ func (neighbor *Neighbor) sendUpdateMsgFromPaths(pList []table.Path, wList []tab
 		}
 	}
 	neighbor.adjRib.UpdateOut(pathList)
-	neighbor.sendMessages(table.CreateUpdateMsgFromPaths(pathList))
+	neighbor.sendMessages(table.CreateUpdateMsgFromPaths(neighbor.filterRtc(pathList)))
 }
 
 func (neighbor *Neighbor) handleNeighborMsg(m *neighborMsg) {
 	switch m.msgType {
 	case PEER_MSG_PATH:
 		pList, wList, _ := neighbor.rib.ProcessPaths(m.msgData.([]table.Path))
+		MultiPathEvent(neighbor.rib.GetMultiPathUpdates())
+		wList = append(wList, neighbor.suppressedPaths(pList)...)
 		neighbor.sendUpdateMsgFromPaths(pList, wList)
 	case PEER_MSG_PEER_DOWN:
 		pList, wList, _ := neighbor.rib.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+		MultiPathEvent(neighbor.rib.GetMultiPathUpdates())
 		neighbor.sendUpdateMsgFromPaths(pList, wList)
 	}
 }
@@ -193,35 +477,33 @@ func (neighbor *Neighbor) handleServerMsg(m *daemonMsg) {
 	switch m.msgType {
 	case SRV_MSG_PEER_ADDED:
 		d := m.msgData.(*daemonMsgDataNeighbor)
-		neighbor.siblings[d.address.String()] = d
-		pathList := neighbor.adjRib.GetInPathList(d.rf)
-
-		if len(pathList) == 0 {
-			return
-		}
-		pm := &neighborMsg{
-			msgType: PEER_MSG_PATH,
-			msgData: pathList,
-		}
-		for _, s := range neighbor.siblings {
-			if s.rf != neighbor.rf {
-				continue
-			}
-			s.neighborMsgCh <- pm
-		}
+		neighbor.siblings[siblingKey(d.address, d.rf)] = d
+		pathList := neighbor.adjRib.FilterDampened(neighbor.adjRib.GetInPathList(d.rf))
+		neighbor.sendPathsToSiblings(pathList)
 	case SRV_MSG_PEER_DELETED:
 
 		d := m.msgData.(*table.PeerInfo)
-		_, found := neighbor.siblings[d.Address.String()]
+		_, found := neighbor.siblings[siblingKey(d.Address, d.RF)]
 		if found {
-			delete(neighbor.siblings, d.Address.String())
+			delete(neighbor.siblings, siblingKey(d.Address, d.RF))
 			pList, wList, _ := neighbor.rib.DeletePathsforPeer(d)
+			MultiPathEvent(neighbor.rib.GetMultiPathUpdates())
 			neighbor.sendUpdateMsgFromPaths(pList, wList)
 		} else {
 			log.Warning("can not find neighbor: ", d.Address.String())
 		}
 	case SRV_MSG_API:
 		neighbor.handleREST(m.msgData.(*api.RestRequest))
+	case SRV_MSG_NEXTHOP_CHANGED:
+		pList, wList, _ := neighbor.rib.RefreshNexthops()
+		MultiPathEvent(neighbor.rib.GetMultiPathUpdates())
+		neighbor.sendUpdateMsgFromPaths(pList, wList)
+	case SRV_MSG_POLICY_UPDATED:
+		d := m.msgData.(*daemonMsgDataPolicy)
+		neighbor.neighborConfig.ApplyPolicy = d.applyPolicy
+		neighbor.setPolicies(d.policy)
+		neighbor.sendPathsToSiblings(neighbor.adjRib.FilterDampened(neighbor.adjRib.GetInPathList(neighbor.rf)))
+		neighbor.sendUpdateMsgFromPaths(neighbor.rib.GetBestPathList(neighbor.rf), nil)
 	default:
 		log.Fatal("unknown daemon msg type ", m.msgType)
 	}
@@ -229,6 +511,12 @@ func (neighbor *Neighbor) handleServerMsg(m *daemonMsg) {
 
 // this goroutine handles routing table operations
 func (peer *Neighbor) loop() error {
+	var dampingCh <-chan time.Time
+	if peer.neighborConfig.RouteFlapDamping {
+		ticker := time.NewTicker(DAMPING_INTERVAL)
+		defer ticker.Stop()
+		dampingCh = ticker.C
+	}
 	for {
 		//		h := NewFSMHandler(neighbor.fsm)
 		incoming := make(chan *fsmMsg, FSM_CHANNEL_LENGTH)
@@ -254,8 +542,7 @@ func (peer *Neighbor) loop() error {
 					peer.fsm.StateChange(nextState)
 					sameState = false
 					if nextState == bgp.BGP_FSM_ESTABLISHED {
-						pathList := peer.adjRib.GetOutPathList(peer.rf)
-						peer.sendMessages(table.CreateUpdateMsgFromPaths(pathList))
+						peer.sendMessages(table.CreateUpdateMsgFromPaths(peer.outPathList()))
 						peer.fsm.neighborConfig.BgpNeighborCommonState.Uptime = time.Now()
 						peer.fsm.neighborConfig.BgpNeighborCommonState.EstablishedCount++
 					}
@@ -266,6 +553,7 @@ func (peer *Neighbor) loop() error {
 							peer.fsm.neighborConfig.BgpNeighborCommonState.Flops++
 						}
 						peer.adjRib.DropAllIn(peer.rf)
+						peer.adjRib.DropAllIn(bgp.RF_RTC_UC)
 						pm := &neighborMsg{
 							msgType: PEER_MSG_PEER_DOWN,
 							msgData: peer.neighborInfo,
@@ -292,6 +580,8 @@ func (peer *Neighbor) loop() error {
 				peer.handleServerMsg(m)
 			case m := <-peer.neighborMsgCh:
 				peer.handleNeighborMsg(m)
+			case <-dampingCh:
+				peer.sendPathsToSiblings(peer.adjRib.ReuseDampened(peer.rf))
 			}
 		}
 	}
@@ -368,6 +658,10 @@ func (neighbor *Neighbor) MarshalJSON() ([]byte, error) {
 		Advertized                uint32
 		OutQ                      int
 		Flops                     uint32
+		AttributeDiscard          uint32 `json:"attribute_discard"`
+		TreatAsWithdraw           uint32 `json:"treat_as_withdraw"`
+		AfiSafiDisable            uint32 `json:"afi_safi_disable"`
+		SessionReset              uint32 `json:"session_reset"`
 	}{
 
 		BgpState:                  f.state.String(),
@@ -391,6 +685,10 @@ func (neighbor *Neighbor) MarshalJSON() ([]byte, error) {
 		Advertized:                uint32(neighbor.adjRib.GetOutCount(neighbor.rf)),
 		OutQ:                      len(neighbor.outgoing),
 		Flops:                     s.Flops,
+		AttributeDiscard:          s.AttributeDiscardCount,
+		TreatAsWithdraw:           s.TreatAsWithdrawCount,
+		AfiSafiDisable:            s.AfiSafiDisableCount,
+		SessionReset:              s.SessionResetCount,
 	}
 
 	return json.Marshal(p)
@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+func TestNeighborDampingVpn(t *testing.T) {
+	g := configuration.GlobalType{As: 65000, RouterId: net.ParseIP("10.0.0.1").To4()}
+	c := configuration.NeighborType{
+		NeighborAddress:  net.ParseIP("10.0.0.2"),
+		PeerAs:           65001,
+		RouteFamily:      "l3vpn-ipv4-unicast",
+		RouteFlapDamping: true,
+		RouteFlapDampingParams: configuration.RouteFlapDampingParamsType{
+			HalfLife:          900,
+			ReuseThreshold:    750,
+			SuppressThreshold: 2000,
+			MaxSuppressTime:   3600,
+		},
+	}
+	n := NewNeighbor(g, c, make(chan *daemonMsg, 8), make(chan *neighborMsg, 4096), nil, nil, nil, nil)
+	defer n.Stop()
+	if n.rf != bgp.RF_IPv4_VPN {
+		t.Fatalf("unexpected route family %s", n.rf)
+	}
+
+	nlri := []bgp.AddrPrefixInterface{
+		bgp.NewLabelledVPNIPAddrPrefix(24, "10.20.0.0", *bgp.NewLabel(100), bgp.NewRouteDistinguisherTwoOctetAS(65001, 1)),
+	}
+	announce := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001})}),
+		bgp.NewPathAttributeMpReachNLRI("10.0.0.2", nlri),
+	}, nil)
+	withdraw := bgp.NewBGPUpdateMessage(nil, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeMpUnreachNLRI(nlri),
+	}, nil)
+
+	// the flaps of a VPN prefix are charged and suppress it
+	for i := 0; i < 3; i++ {
+		n.handleBGPmessage(announce)
+		n.handleBGPmessage(withdraw)
+	}
+	entries := n.adjRib.GetDampenedList(bgp.RF_IPv4_VPN)
+	if len(entries) != 1 {
+		t.Fatalf("unexpected damping entries %v", entries)
+	}
+	n.handleBGPmessage(announce)
+	if len(n.adjRib.FilterDampened(n.adjRib.GetInPathList(bgp.RF_IPv4_VPN))) != 0 {
+		t.Error("suppressed VPN prefix is used")
+	}
+}
+
+func TestNeighborRestResponsesExplain(t *testing.T) {
+	g := configuration.GlobalType{As: 65000, RouterId: net.ParseIP("10.0.0.1").To4()}
+	c := configuration.NeighborType{
+		NeighborAddress: net.ParseIP("10.0.0.2"),
+		PeerAs:          65001,
+		RouteFamily:     "ipv4-unicast",
+	}
+	n := NewNeighbor(g, c, make(chan *daemonMsg, 8), make(chan *neighborMsg, 4096), nil, nil, nil, nil)
+	defer n.Stop()
+
+	peer := &table.PeerInfo{AS: 65003, ID: net.ParseIP("10.0.0.3").To4(), Address: net.ParseIP("10.0.0.3"), RF: bgp.RF_IPv4_UC}
+	path := table.CreatePath(peer, bgp.NewNLRInfo(24, "10.1.1.0"), []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65003})}),
+		bgp.NewPathAttributeNextHop("10.0.0.3"),
+	}, false)
+	n.neighborMsgCh <- &neighborMsg{msgType: PEER_MSG_PATH, msgData: []table.Path{path}}
+
+	// the rib is read by the goroutine of the neighbor, once it has the path
+	req := api.RouteRequest(api.API_ROUTE_EXPLAIN, api.RestRoute{IpPrefix: "10.1.1.0", PrefixMask: 24})
+	var r *api.RestResponse
+	for i := 0; i < 100; i++ {
+		r = neighborRestResponses(req, []*Neighbor{n})[0]
+		if r.ResponseErr == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if r.ResponseErr != nil {
+		t.Fatal(r.ResponseErr)
+	}
+	var e struct {
+		NeighborAddr net.IP `json:"neighbor_ip"`
+	}
+	if err := json.Unmarshal(r.Data, &e); err != nil || !e.NeighborAddr.Equal(c.NeighborAddress) {
+		t.Errorf("unexpected explanation %s", r.Data)
+	}
+
+	req = api.RouteRequest(api.API_ROUTE_EXPLAIN, api.RestRoute{IpPrefix: "10.2.2.0", PrefixMask: 24})
+	if r = neighborRestResponses(req, []*Neighbor{n})[0]; r.ResponseErr == nil {
+		t.Errorf("unknown destination explained %s", r.Data)
+	}
+}
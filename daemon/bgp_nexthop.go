@@ -0,0 +1,185 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/gopher-net/gopher-net/configuration"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+)
+
+const (
+	NEXTHOP_INTERVAL  = time.Second * 10
+	PROC_NET_ROUTE    = "/proc/net/route"
+	PROC_NET_IPV6_RTE = "/proc/net/ipv6_route"
+
+	// route flags from linux/route.h
+	RTF_UP     = 0x0001
+	RTF_REJECT = 0x0200
+)
+
+// nexthopResolver resolves BGP next hops against the static routes and
+// the kernel routing table, in that order.
+type nexthopResolver struct {
+	mu     sync.RWMutex
+	static *RoutingTable
+	kernel *RoutingTable
+}
+
+func newNexthopResolver() *nexthopResolver {
+	return &nexthopResolver{
+		static: &RoutingTable{},
+	}
+}
+
+// Resolve implements table.NexthopResolver. If the kernel routing table
+// can't be read every next hop is taken as reachable, as it was before
+// next hops were tracked.
+func (r *nexthopResolver) Resolve(nexthop net.IP) (bool, uint32) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range []*RoutingTable{r.static, r.kernel} {
+		if t == nil {
+			continue
+		}
+		if e, found := t.Lookup(nexthop); found {
+			return true, uint32(e.Metric)
+		}
+	}
+	return r.kernel == nil, 0
+}
+
+// setStaticRoutes replaces the static routes and returns true if they
+// have changed.
+func (r *nexthopResolver) setStaticRoutes(routes []configuration.StaticRouteType) bool {
+	t := &RoutingTable{}
+	for _, route := range routes {
+		_, prefix, err := net.ParseCIDR(route.Prefix)
+		if err != nil {
+			log.Errorf("invalid static route prefix %s: %s", route.Prefix, err)
+			continue
+		}
+		length, _ := prefix.Mask.Size()
+		t.Add(&RibEntry{
+			DestIpPrefix: prefix.IP,
+			Length:       length,
+			Gateway:      route.Nexthop,
+			Metric:       int(route.Metric),
+		})
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := !reflect.DeepEqual(r.static.Rib, t.Rib)
+	r.static = t
+	return changed
+}
+
+// loadKernelRoutes reads the kernel routing table and returns true if it
+// has changed since the last call.
+func (r *nexthopResolver) loadKernelRoutes() (bool, error) {
+	t := &RoutingTable{}
+	for _, f := range []struct {
+		path  string
+		parse func(io.Reader) ([]RibEntry, error)
+	}{
+		{PROC_NET_ROUTE, parseProcNetRoute},
+		{PROC_NET_IPV6_RTE, parseProcNetIpv6Route},
+	} {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return false, err
+		}
+		entries, err := f.parse(file)
+		file.Close()
+		if err != nil {
+			return false, err
+		}
+		t.Rib = append(t.Rib, entries...)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changed := r.kernel == nil || !reflect.DeepEqual(r.kernel.Rib, t.Rib)
+	r.kernel = t
+	return changed, nil
+}
+
+// parseProcNetRoute parses /proc/net/route. Addresses are in host byte
+// order, which is little endian on the platforms we run on.
+func parseProcNetRoute(reader io.Reader) ([]RibEntry, error) {
+	entries := make([]RibEntry, 0)
+	scanner := bufio.NewScanner(reader)
+	// skip the header
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		dest, err1 := strconv.ParseUint(fields[1], 16, 32)
+		gw, err2 := strconv.ParseUint(fields[2], 16, 32)
+		flags, err3 := strconv.ParseUint(fields[3], 16, 32)
+		metric, err4 := strconv.Atoi(fields[6])
+		mask, err5 := strconv.ParseUint(fields[7], 16, 32)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil {
+			return nil, fmt.Errorf("malformed route entry: %s", scanner.Text())
+		}
+		if flags&RTF_UP == 0 || flags&RTF_REJECT != 0 {
+			continue
+		}
+		toIP := func(v uint64) net.IP {
+			b := make([]byte, 4)
+			binary.LittleEndian.PutUint32(b, uint32(v))
+			return net.IP(b)
+		}
+		length, _ := net.IPMask(toIP(mask)).Size()
+		entries = append(entries, RibEntry{
+			DestIpPrefix: toIP(dest),
+			Length:       length,
+			Gateway:      toIP(gw),
+			Metric:       metric,
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// parseProcNetIpv6Route parses /proc/net/ipv6_route, addresses are in
+// network byte order.
+func parseProcNetIpv6Route(reader io.Reader) ([]RibEntry, error) {
+	entries := make([]RibEntry, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 9 {
+			continue
+		}
+		dest, err1 := hex.DecodeString(fields[0])
+		length, err2 := strconv.ParseUint(fields[1], 16, 8)
+		gw, err3 := hex.DecodeString(fields[4])
+		metric, err4 := strconv.ParseUint(fields[5], 16, 32)
+		flags, err5 := strconv.ParseUint(fields[8], 16, 32)
+		if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil ||
+			len(dest) != net.IPv6len || len(gw) != net.IPv6len {
+			return nil, fmt.Errorf("malformed route entry: %s", scanner.Text())
+		}
+		if flags&RTF_UP == 0 || flags&RTF_REJECT != 0 {
+			continue
+		}
+		entries = append(entries, RibEntry{
+			DestIpPrefix: net.IP(dest),
+			Length:       int(length),
+			Gateway:      net.IP(gw),
+			Metric:       int(metric),
+		})
+	}
+	return entries, scanner.Err()
+}
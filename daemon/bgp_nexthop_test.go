@@ -0,0 +1,104 @@
+package daemon
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gopher-net/gopher-net/configuration"
+)
+
+const procNetRoute = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	00000000	0156A8C0	0003	0	0	100	00000000	0	0	0
+eth0	0056A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+eth1	000010AC	00000000	0001	0	0	10	0000FFFF	0	0	0
+eth1	005610AC	00000000	0201	0	0	10	00FFFFFF	0	0	0
+`
+
+const procNetIpv6Route = `20010db8000000000000000000000000 20 00000000000000000000000000000000 00 00000000000000000000000000000000 00000100 00000001 00000000 00000001     eth0
+00000000000000000000000000000000 00 00000000000000000000000000000000 00 00000000000000000000000000000000 ffffffff 00000001 00000000 00200200       lo
+`
+
+func TestParseProcNetRoute(t *testing.T) {
+	entries, err := parseProcNetRoute(strings.NewReader(procNetRoute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the reject route is skipped
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	if !entries[1].DestIpPrefix.Equal(net.ParseIP("192.168.86.0")) || entries[1].Length != 24 {
+		t.Errorf("bad entry %v", entries[1])
+	}
+	if !entries[0].Gateway.Equal(net.ParseIP("192.168.86.1")) || entries[0].Metric != 100 {
+		t.Errorf("bad entry %v", entries[0])
+	}
+}
+
+func TestParseProcNetIpv6Route(t *testing.T) {
+	entries, err := parseProcNetIpv6Route(strings.NewReader(procNetIpv6Route))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	if !entries[0].DestIpPrefix.Equal(net.ParseIP("2001:db8::")) || entries[0].Length != 32 || entries[0].Metric != 256 {
+		t.Errorf("bad entry %v", entries[0])
+	}
+}
+
+func TestRoutingTableLookup(t *testing.T) {
+	entries, _ := parseProcNetRoute(strings.NewReader(procNetRoute))
+	rib := &RoutingTable{Rib: entries}
+	rib.Add(&RibEntry{
+		DestIpPrefix: net.ParseIP("172.16.86.0").To4(),
+		Length:       24,
+		Metric:       5,
+	})
+	e, found := rib.Lookup(net.ParseIP("172.16.86.135"))
+	if !found || e.Length != 24 || e.Metric != 5 {
+		t.Errorf("longest prefix doesn't match: %v", e)
+	}
+	e, found = rib.Lookup(net.ParseIP("172.16.1.1"))
+	if !found || e.Length != 16 {
+		t.Errorf("bad match: %v", e)
+	}
+	// the default route doesn't resolve next hops
+	if _, found = rib.Lookup(net.ParseIP("8.8.8.8")); found {
+		t.Error("default route resolved a next hop")
+	}
+}
+
+func TestNexthopResolver(t *testing.T) {
+	r := newNexthopResolver()
+	// without the kernel table every next hop is reachable
+	if reachable, _ := r.Resolve(net.ParseIP("10.1.1.1")); !reachable {
+		t.Error("next hop isn't reachable without the kernel table")
+	}
+
+	entries, _ := parseProcNetRoute(strings.NewReader(procNetRoute))
+	r.kernel = &RoutingTable{Rib: entries}
+	if reachable, _ := r.Resolve(net.ParseIP("10.1.1.1")); reachable {
+		t.Error("unknown next hop is reachable")
+	}
+	routes := []configuration.StaticRouteType{
+		{Prefix: "10.1.0.0/16", Nexthop: net.ParseIP("192.168.86.2"), Metric: 30},
+		{Prefix: "172.16.0.0/16", Nexthop: net.ParseIP("192.168.86.2"), Metric: 40},
+	}
+	if !r.setStaticRoutes(routes) {
+		t.Error("new static routes aren't a change")
+	}
+	// a reload with the same static routes changes nothing
+	if r.setStaticRoutes(routes) {
+		t.Error("same static routes are a change")
+	}
+	if reachable, metric := r.Resolve(net.ParseIP("10.1.1.1")); !reachable || metric != 30 {
+		t.Errorf("static route isn't used: %t %d", reachable, metric)
+	}
+	// static routes take precedence over the kernel table
+	if _, metric := r.Resolve(net.ParseIP("172.16.1.1")); metric != 40 {
+		t.Errorf("unexpected metric %d", metric)
+	}
+}
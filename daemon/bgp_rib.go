@@ -40,6 +40,31 @@ func (r *RoutingTable) Add(entry *RibEntry) {
 	r.Rib = append(r.Rib, *entry)
 }
 
+// Lookup returns the longest prefix match for ip. Default routes are
+// ignored, a next hop that resolves only over a default route is usually
+// not reachable the way the BGP route expects.
+func (r *RoutingTable) Lookup(ip net.IP) (*RibEntry, bool) {
+	var best *RibEntry
+	for i := range r.Rib {
+		e := &r.Rib[i]
+		if e.Length == 0 || (e.DestIpPrefix.To4() == nil) != (ip.To4() == nil) {
+			continue
+		}
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		mask := net.CIDRMask(e.Length, bits)
+		if !e.DestIpPrefix.Mask(mask).Equal(ip.Mask(mask)) {
+			continue
+		}
+		if best == nil || e.Length > best.Length {
+			best = e
+		}
+	}
+	return best, best != nil
+}
+
 // Print displays the content of the Routing Table
 func (r *RoutingTable) Print() string {
 	result := fmt.Sprintf("%+v\n", r.Rib)
@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"net"
+
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// siblingServer is the part the servers taking part in route families as
+// a sibling of the neighbors have in common: the channels the daemon and
+// the neighbors send to, the neighbors of those route families and the
+// server in their sibling lists.
+type siblingServer struct {
+	daemonMsgCh   chan *daemonMsg
+	neighborMsgCh chan *neighborMsg
+	siblings      map[string]*daemonMsgDataNeighbor
+	// the server in the sibling lists of the neighbors, one entry per
+	// route family. The unspecified addresses can't be neighbors.
+	neighborMsgData []*daemonMsgDataNeighbor
+}
+
+func newSiblingServer(rfList ...bgp.RouteFamily) siblingServer {
+	s := siblingServer{
+		daemonMsgCh:   make(chan *daemonMsg, 8),
+		neighborMsgCh: make(chan *neighborMsg, 4096),
+		siblings:      make(map[string]*daemonMsgDataNeighbor),
+	}
+	for _, rf := range rfList {
+		address := net.IPv4zero
+		if afi, _ := bgp.RouteFamilyToAfiSafi(rf); afi == bgp.AFI_IP6 {
+			address = net.IPv6unspecified
+		}
+		s.neighborMsgData = append(s.neighborMsgData, &daemonMsgDataNeighbor{
+			neighborMsgCh: s.neighborMsgCh,
+			address:       address,
+			rf:            rf,
+		})
+	}
+	return s
+}
+
+// addSibling adds a neighbor to the siblings if its route family is one
+// of the server, it returns whether it did.
+func (s *siblingServer) addSibling(d *daemonMsgDataNeighbor) bool {
+	for _, data := range s.neighborMsgData {
+		if data.rf == d.rf {
+			s.siblings[d.address.String()] = d
+			return true
+		}
+	}
+	return false
+}
+
+func (s *siblingServer) deleteSibling(address net.IP) {
+	delete(s.siblings, address.String())
+}
+
+// sendPaths sends a sibling the paths of pathList in its route family or
+// in one of rfList.
+func (s *siblingServer) sendPaths(d *daemonMsgDataNeighbor, pathList []table.Path, rfList ...bgp.RouteFamily) {
+	l := make([]table.Path, 0, len(pathList))
+	for _, path := range pathList {
+		rf := path.GetRouteFamily()
+		if rf == d.rf {
+			l = append(l, path)
+			continue
+		}
+		for _, r := range rfList {
+			if rf == r {
+				l = append(l, path)
+				break
+			}
+		}
+	}
+	if len(l) > 0 {
+		d.neighborMsgCh <- &neighborMsg{
+			msgType: PEER_MSG_PATH,
+			msgData: l,
+		}
+	}
+}
+
+func (s *siblingServer) sendPathsToSiblings(pathList []table.Path, rfList ...bgp.RouteFamily) {
+	for _, d := range s.siblings {
+		s.sendPaths(d, pathList, rfList...)
+	}
+}
+
+// serve hands the messages of the daemon and of the neighbors to the
+// handlers of the server, one at a time.
+func (s *siblingServer) serve(handleServerMsg func(*daemonMsg), handleNeighborMsg func(*neighborMsg)) {
+	for {
+		select {
+		case m := <-s.daemonMsgCh:
+			handleServerMsg(m)
+		case m := <-s.neighborMsgCh:
+			handleNeighborMsg(m)
+		}
+	}
+}
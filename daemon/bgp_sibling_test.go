@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"net"
+	"testing"
+
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+func TestSiblingServerSendPaths(t *testing.T) {
+	s := newSiblingServer(bgp.RF_IPv4_UC, bgp.RF_IPv6_UC)
+	if len(s.neighborMsgData) != 2 {
+		t.Fatalf("unexpected sibling entries %v", s.neighborMsgData)
+	}
+	if !s.neighborMsgData[0].address.Equal(net.IPv4zero) || !s.neighborMsgData[1].address.Equal(net.IPv6unspecified) {
+		t.Errorf("unexpected sibling addresses %s %s", s.neighborMsgData[0].address, s.neighborMsgData[1].address)
+	}
+
+	v4 := &daemonMsgDataNeighbor{neighborMsgCh: make(chan *neighborMsg, 1), address: net.ParseIP("10.0.0.2"), rf: bgp.RF_IPv4_UC}
+	v6 := &daemonMsgDataNeighbor{neighborMsgCh: make(chan *neighborMsg, 1), address: net.ParseIP("2001:db8::2"), rf: bgp.RF_IPv6_UC}
+	vpn := &daemonMsgDataNeighbor{neighborMsgCh: make(chan *neighborMsg, 1), address: net.ParseIP("10.0.0.3"), rf: bgp.RF_IPv4_VPN}
+	if !s.addSibling(v4) || !s.addSibling(v6) {
+		t.Fatal("unicast neighbor not added")
+	}
+	if s.addSibling(vpn) {
+		t.Error("VPN neighbor added")
+	}
+
+	peer := &table.PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_IPv4_UC}
+	path := table.CreatePath(peer, bgp.NewNLRInfo(24, "10.1.1.0"), []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeNextHop("10.0.0.2"),
+	}, false)
+	s.sendPathsToSiblings([]table.Path{path})
+	select {
+	case m := <-v4.neighborMsgCh:
+		if l := m.msgData.([]table.Path); len(l) != 1 {
+			t.Errorf("unexpected paths %v", l)
+		}
+	default:
+		t.Error("no paths sent to the IPv4 sibling")
+	}
+	select {
+	case <-v6.neighborMsgCh:
+		t.Error("IPv4 paths sent to the IPv6 sibling")
+	default:
+	}
+
+	s.deleteSibling(v4.address)
+	s.sendPathsToSiblings([]table.Path{path})
+	if len(v4.neighborMsgCh) != 0 {
+		t.Error("paths sent to a deleted sibling")
+	}
+}
@@ -0,0 +1,88 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// vplsServer holds the VPLS instances (RFC 4761), it takes part in the
+// VPLS route family as a sibling of the neighbors: it advertises the
+// label blocks of the local sites, with the router ID as next hop, and
+// connects them to the remote sites received from the neighbors.
+type vplsServer struct {
+	manager *table.VplsManager
+	siblingServer
+}
+
+func newVplsServer(g configuration.GlobalType, vplsList []configuration.VplsType, labels *table.LabelPool) *vplsServer {
+	s := &vplsServer{
+		manager:       table.NewVplsManager(g.As, g.RouterId, labels),
+		siblingServer: newSiblingServer(bgp.RF_VPLS),
+	}
+	for _, c := range vplsList {
+		instance, err := s.manager.AddInstance(c.Name, c.RouteDistinguisher, c.ImportRt, c.ExportRt, c.VeId, c.BlockSize, c.Mtu)
+		if err != nil {
+			log.Errorf("invalid vpls %s, ignoring it: %s", c.Name, err)
+			continue
+		}
+		log.Infof("added vpls %s, route distinguisher %s, ve id %d, labels %d-%d", instance.Name, instance.Rd,
+			instance.VeId, instance.LabelBase, instance.LabelBase+uint32(instance.BlockSize)-1)
+	}
+	go s.serve(s.handleServerMsg, s.handleNeighborMsg)
+	return s
+}
+
+func (s *vplsServer) handleREST(restReq *api.RestRequest) {
+	result := &api.RestResponse{}
+	switch restReq.RequestType {
+	case api.API_VPLS:
+		j, _ := json.MarshalIndent(struct {
+			Instances *table.VplsManager `json:"instances"`
+			Received  table.Table        `json:"received"`
+		}{
+			s.manager,
+			s.manager.GetTable(),
+		}, "", "\t")
+		result.Data = j
+	case api.API_VPLS_INSTANCE:
+		if instance, found := s.manager.Instances[restReq.VplsName]; found {
+			j, _ := json.MarshalIndent(instance, "", "\t")
+			result.Data = j
+		} else {
+			result.ResponseErr = fmt.Errorf("vpls %s does not exist", restReq.VplsName)
+		}
+	}
+	restReq.ResponseCh <- result
+	close(restReq.ResponseCh)
+}
+
+func (s *vplsServer) handleServerMsg(m *daemonMsg) {
+	switch m.msgType {
+	case SRV_MSG_PEER_ADDED:
+		d := m.msgData.(*daemonMsgDataNeighbor)
+		if s.addSibling(d) {
+			s.sendPaths(d, s.manager.GetPathList())
+		}
+	case SRV_MSG_PEER_DELETED:
+		d := m.msgData.(*table.PeerInfo)
+		s.deleteSibling(d.Address)
+		s.manager.DeletePathsforPeer(d)
+	case SRV_MSG_API:
+		s.handleREST(m.msgData.(*api.RestRequest))
+	}
+}
+
+func (s *vplsServer) handleNeighborMsg(m *neighborMsg) {
+	switch m.msgType {
+	case PEER_MSG_PATH:
+		s.manager.ProcessPaths(m.msgData.([]table.Path))
+	case PEER_MSG_PEER_DOWN:
+		s.manager.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+	}
+}
@@ -0,0 +1,143 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gopher-net/gopher-net/api"
+	"github.com/gopher-net/gopher-net/configuration"
+	"net"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// vrfServer holds the VRFs, it takes part in the VPN route families as
+// a sibling of the neighbors: the VPN paths received from them are
+// imported into the VRFs and the paths originated in the VRFs are
+// advertised to them.
+type vrfServer struct {
+	manager *table.VrfManager
+	siblingServer
+}
+
+func newVrfServer(g configuration.GlobalType, labels *table.LabelPool) *vrfServer {
+	s := &vrfServer{
+		manager:       table.NewVrfManager(g.As, g.RouterId, labels),
+		siblingServer: newSiblingServer(bgp.RF_IPv4_VPN, bgp.RF_IPv6_VPN),
+	}
+	for _, c := range g.VrfList {
+		vrf, err := s.manager.AddVrf(c.Name, c.RouteDistinguisher, c.ImportRt, c.ExportRt)
+		if err != nil {
+			log.Errorf("invalid vrf %s, ignoring it: %s", c.Name, err)
+			continue
+		}
+		log.Infof("added vrf %s, route distinguisher %s, label %d", vrf.Name, vrf.Rd, vrf.Label)
+	}
+	go s.serve(s.handleServerMsg, s.handleNeighborMsg)
+	return s
+}
+
+// vrfRoutePath builds the locally originated unicast path of a route
+// added to or deleted from a vrf over the REST API.
+func vrfRoutePath(route api.RestRoute, withdraw bool) (table.Path, error) {
+	prefix := net.ParseIP(route.IpPrefix)
+	if prefix == nil {
+		return nil, fmt.Errorf("invalid prefix %s", route.IpPrefix)
+	}
+	ipv4 := prefix.To4() != nil
+	if ipv4 && route.PrefixMask > 32 || route.PrefixMask > 128 {
+		return nil, fmt.Errorf("invalid prefix length %d", route.PrefixMask)
+	}
+	var nlri bgp.AddrPrefixInterface
+	if ipv4 && withdraw {
+		nlri = &bgp.WithdrawnRoute{IPAddrPrefix: *bgp.NewIPAddrPrefix(route.PrefixMask, route.IpPrefix)}
+	} else if ipv4 {
+		nlri = bgp.NewNLRInfo(route.PrefixMask, route.IpPrefix)
+	} else {
+		nlri = bgp.NewIPv6AddrPrefix(route.PrefixMask, route.IpPrefix)
+	}
+	if withdraw {
+		return table.CreatePath(nil, nlri, nil, true), nil
+	}
+	nexthop := net.ParseIP(route.NextHop)
+	if nexthop == nil || (nexthop.To4() != nil) != ipv4 {
+		return nil, fmt.Errorf("invalid nexthop %s", route.NextHop)
+	}
+	var nexthopAttr bgp.PathAttributeInterface
+	if ipv4 {
+		nexthopAttr = bgp.NewPathAttributeNextHop(route.NextHop)
+	} else {
+		nexthopAttr = bgp.NewPathAttributeMpReachNLRI(route.NextHop, []bgp.AddrPrefixInterface{nlri})
+	}
+	pathAttributes, err := restRoutePathAttrs(route, nexthopAttr)
+	if err != nil {
+		return nil, err
+	}
+	return table.CreatePath(nil, nlri, pathAttributes, false), nil
+}
+
+func (s *vrfServer) handleREST(restReq *api.RestRequest) {
+	result := &api.RestResponse{}
+	route := restReq.RestRoute
+	switch restReq.RequestType {
+	case api.API_VRFS:
+		j, _ := json.MarshalIndent(s.manager, "", "\t")
+		result.Data = j
+	case api.API_VRF:
+		if vrf, found := s.manager.Vrfs[route.Vrf]; found {
+			j, _ := json.MarshalIndent(vrf, "", "\t")
+			result.Data = j
+		} else {
+			result.ResponseErr = fmt.Errorf("vrf %s does not exist", route.Vrf)
+		}
+	case api.API_ADD_ROUTE, api.API_DEL_ROUTE:
+		withdraw := restReq.RequestType == api.API_DEL_ROUTE
+		path, err := vrfRoutePath(route, withdraw)
+		if err == nil {
+			path, err = s.manager.AddLocalPath(route.Vrf, path)
+		}
+		if err != nil {
+			log.Errorf("Error updating vrf %s: %s", route.Vrf, err)
+			result.ResponseErr = err
+			break
+		}
+		s.sendPathsToSiblings([]table.Path{path})
+		returnMsg := fmt.Sprintf("Added prefix [ Prefix: %s , Netmask: %d, Nexthop: %s ] to vrf %s",
+			route.IpPrefix, route.PrefixMask, route.NextHop, route.Vrf)
+		if withdraw {
+			returnMsg = fmt.Sprintf("Deleting prefix [ Prefix: %s , Netmask: %d ] from vrf %s",
+				route.IpPrefix, route.PrefixMask, route.Vrf)
+		}
+		j, _ := json.MarshalIndent(returnMsg, "", "\t")
+		result.Data = j
+	}
+	restReq.ResponseCh <- result
+	close(restReq.ResponseCh)
+}
+
+func (s *vrfServer) handleServerMsg(m *daemonMsg) {
+	switch m.msgType {
+	case SRV_MSG_PEER_ADDED:
+		d := m.msgData.(*daemonMsgDataNeighbor)
+		if s.addSibling(d) {
+			// the route target memberships go along the VPN paths
+			s.sendPathsToSiblings(append(s.manager.GetRtcPathList(), s.manager.GetVpnPathList(d.rf)...), bgp.RF_RTC_UC)
+		}
+	case SRV_MSG_PEER_DELETED:
+		d := m.msgData.(*table.PeerInfo)
+		s.deleteSibling(d.Address)
+		s.manager.DeletePathsforPeer(d)
+	case SRV_MSG_API:
+		s.handleREST(m.msgData.(*api.RestRequest))
+	}
+}
+
+func (s *vrfServer) handleNeighborMsg(m *neighborMsg) {
+	switch m.msgType {
+	case PEER_MSG_PATH:
+		s.manager.Import(m.msgData.([]table.Path))
+	case PEER_MSG_PEER_DOWN:
+		s.manager.DeletePathsforPeer(m.msgData.(*table.PeerInfo))
+	}
+}
@@ -65,11 +65,13 @@ func main() {
 			var added []configuration.NeighborType
 			var deleted []configuration.NeighborType
 			if bgpConfig == nil {
-				bgpDaemon.SetGlobalType(newConfig.Global)
+				bgpDaemon.SetGlobalConfig(newConfig)
 				bgpConfig = &newConfig
 				added = newConfig.NeighborList
 				deleted = []configuration.NeighborType{}
 			}
+			bgpDaemon.SetPolicy(newConfig)
+			bgpDaemon.SetStaticRoutes(newConfig)
 			for _, p := range added {
 				log.Infof("Peer %v is added", p.NeighborAddress)
 				bgpDaemon.NeighborAdd(p)
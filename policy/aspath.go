@@ -0,0 +1,166 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gopher-net/gopher-net/configuration"
+	"regexp"
+	"strconv"
+	"strings"
+
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// ASPATH_REGEXP_DELIMITER is what "_" in an AS path regular expression
+// stands for, the same as in Quagga.
+const ASPATH_REGEXP_DELIMITER = `(^|[,{}()\[\] ]|$)`
+
+// as4PathSegments returns the segments of an AS_PATH with 4 octet AS
+// numbers. attr may be nil.
+func as4PathSegments(attr bgp.PathAttributeInterface) []*bgp.As4PathParam {
+	segments := make([]*bgp.As4PathParam, 0)
+	if attr == nil {
+		return segments
+	}
+	for _, p := range attr.(*bgp.PathAttributeAsPath).Value {
+		switch param := p.(type) {
+		case *bgp.AsPathParam:
+			as := make([]uint32, len(param.AS))
+			for i, v := range param.AS {
+				as[i] = uint32(v)
+			}
+			segments = append(segments, bgp.NewAs4PathParam(param.Type, as))
+		case *bgp.As4PathParam:
+			segments = append(segments, param)
+		}
+	}
+	return segments
+}
+
+func asPathSegments(path table.Path) []*bgp.As4PathParam {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	return as4PathSegments(attr)
+}
+
+// asPathString flattens the AS_PATH of path into the form the regular
+// expressions are matched against. AS_SEQUENCE is "1 2", AS_SET is
+// "{1,2}", AS_CONFED_SEQUENCE is "(1 2)" and AS_CONFED_SET is "[1,2]".
+func asPathString(path table.Path) string {
+	var buffer bytes.Buffer
+	for i, s := range asPathSegments(path) {
+		if i > 0 {
+			buffer.WriteString(" ")
+		}
+		begin, sep, end := "", " ", ""
+		switch s.Type {
+		case bgp.BGP_ASPATH_ATTR_TYPE_SET:
+			begin, sep, end = "{", ",", "}"
+		case bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SEQ:
+			begin, sep, end = "(", " ", ")"
+		case bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SET:
+			begin, sep, end = "[", ",", "]"
+		}
+		as := make([]string, len(s.AS))
+		for j, v := range s.AS {
+			as[j] = strconv.FormatUint(uint64(v), 10)
+		}
+		buffer.WriteString(begin + strings.Join(as, sep) + end)
+	}
+	return buffer.String()
+}
+
+// asPathLength returns the length of the AS_PATH of path as in RFC 4271
+// and RFC 5065, an AS_SET counts as one and confed segments don't count.
+func asPathLength(path table.Path) int {
+	l := 0
+	for _, s := range asPathSegments(path) {
+		switch s.Type {
+		case bgp.BGP_ASPATH_ATTR_TYPE_SEQ:
+			l += len(s.AS)
+		case bgp.BGP_ASPATH_ATTR_TYPE_SET:
+			l += 1
+		}
+	}
+	return l
+}
+
+type AsPathSet struct {
+	Name    string
+	members []*regexp.Regexp
+}
+
+func NewAsPathSet(config configuration.AsPathSetType) (*AsPathSet, error) {
+	s := &AsPathSet{
+		Name:    config.AsPathSetName,
+		members: make([]*regexp.Regexp, 0, len(config.AsPathSetMembers)),
+	}
+	for _, m := range config.AsPathSetMembers {
+		r, err := regexp.Compile(strings.Replace(m, "_", ASPATH_REGEXP_DELIMITER, -1))
+		if err != nil {
+			return nil, fmt.Errorf("as path set %s: invalid regular expression %s: %s", s.Name, m, err)
+		}
+		s.members = append(s.members, r)
+	}
+	return s, nil
+}
+
+// match returns whether any of the members match path, or all of them
+// if all is true.
+func (s *AsPathSet) match(path table.Path, all bool) bool {
+	aspath := asPathString(path)
+	for _, r := range s.members {
+		if r.MatchString(aspath) != all {
+			return !all
+		}
+	}
+	return all
+}
+
+type AsPathCondition struct {
+	set    *AsPathSet
+	option configuration.MatchSetOptionsType
+}
+
+func (c *AsPathCondition) evaluate(path table.Path) bool {
+	switch c.option {
+	case configuration.MATCH_SET_OPTIONS_TYPE_ALL:
+		return c.set.match(path, true)
+	case configuration.MATCH_SET_OPTIONS_TYPE_INVERT:
+		return !c.set.match(path, false)
+	}
+	return c.set.match(path, false)
+}
+
+type AsPathLengthCondition struct {
+	operator configuration.BgpAttributeComparison
+	value    uint32
+}
+
+func newAsPathLengthCondition(config configuration.AsPathLengthType) (*AsPathLengthCondition, error) {
+	switch config.Operator {
+	case configuration.BGP_ATTRIBUTE_COMPARISON_EQ, configuration.BGP_ATTRIBUTE_COMPARISON_GE, configuration.BGP_ATTRIBUTE_COMPARISON_LE:
+	default:
+		return nil, fmt.Errorf("invalid as-path-length operator %d", config.Operator)
+	}
+	return &AsPathLengthCondition{
+		operator: config.Operator,
+		value:    config.Value,
+	}, nil
+}
+
+func (c *AsPathLengthCondition) evaluate(path table.Path) bool {
+	return compareAttribute(c.operator, uint32(asPathLength(path)), c.value)
+}
+
+func compareAttribute(operator configuration.BgpAttributeComparison, v, value uint32) bool {
+	switch operator {
+	case configuration.BGP_ATTRIBUTE_COMPARISON_EQ:
+		return v == value
+	case configuration.BGP_ATTRIBUTE_COMPARISON_GE:
+		return v >= value
+	case configuration.BGP_ATTRIBUTE_COMPARISON_LE:
+		return v <= value
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+)
+
+func TestAsPathString(t *testing.T) {
+	path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SEQ, []uint32{65010, 65011}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SET, []uint32{65012, 65013}),
+		bgp.NewAsPathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint16{65000, 174}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SET, []uint32{100, 200}),
+	}))
+	if s := asPathString(path); s != "(65010 65011) [65012,65013] 65000 174 {100,200}" {
+		t.Errorf("unexpected as path string: %s", s)
+	}
+	if l := asPathLength(path); l != 3 {
+		t.Errorf("as path length should be 3: %d", l)
+	}
+}
+
+func TestAsPathSetMatch(t *testing.T) {
+	path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65000, 2914, 174}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SET, []uint32{64512, 64513}),
+	}))
+	for _, c := range []struct {
+		regexp string
+		match  bool
+	}{
+		{"^65000_", true},
+		{"^6500_", false},
+		{"_174_", true},
+		{"_174$", false},
+		{"_64513_", true},
+		{"_64513$", false},
+		{"_64512_", true},
+		{"_2914_174_", true},
+		{"^$", false},
+		{".*", true},
+	} {
+		s, err := NewAsPathSet(configuration.AsPathSetType{
+			AsPathSetName:    "as1",
+			AsPathSetMembers: []string{c.regexp},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.match(path, false) != c.match {
+			t.Errorf("%s: match should be %t", c.regexp, c.match)
+		}
+	}
+
+	s, _ := NewAsPathSet(configuration.AsPathSetType{
+		AsPathSetName:    "as2",
+		AsPathSetMembers: []string{"^65000_", "_65001_"},
+	})
+	any := &AsPathCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_ANY}
+	all := &AsPathCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_ALL}
+	invert := &AsPathCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_INVERT}
+	if !any.evaluate(path) || all.evaluate(path) || invert.evaluate(path) {
+		t.Error("only the any condition should match")
+	}
+
+	if _, err := NewAsPathSet(configuration.AsPathSetType{
+		AsPathSetName:    "as3",
+		AsPathSetMembers: []string{"^(65000"},
+	}); err == nil {
+		t.Error("invalid regular expression should be an error")
+	}
+}
+
+func TestAsPathLengthCondition(t *testing.T) {
+	path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SEQ, []uint32{65010}),
+		bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65000, 2914}),
+	}))
+	for _, c := range []struct {
+		operator configuration.BgpAttributeComparison
+		value    uint32
+		match    bool
+	}{
+		{configuration.BGP_ATTRIBUTE_COMPARISON_EQ, 2, true},
+		{configuration.BGP_ATTRIBUTE_COMPARISON_EQ, 3, false},
+		{configuration.BGP_ATTRIBUTE_COMPARISON_GE, 2, true},
+		{configuration.BGP_ATTRIBUTE_COMPARISON_GE, 3, false},
+		{configuration.BGP_ATTRIBUTE_COMPARISON_LE, 1, false},
+		{configuration.BGP_ATTRIBUTE_COMPARISON_LE, 2, true},
+	} {
+		cond, err := newAsPathLengthCondition(configuration.AsPathLengthType{Operator: c.operator, Value: c.value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond.evaluate(path) != c.match {
+			t.Errorf("operator %d value %d: match should be %t", c.operator, c.value, c.match)
+		}
+	}
+	if _, err := newAsPathLengthCondition(configuration.AsPathLengthType{Value: 2}); err == nil {
+		t.Error("missing operator should be an error")
+	}
+}
@@ -0,0 +1,469 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/gopher-net/gopher-net/configuration"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+var wellKnownCommunities = map[string]uint32{
+	"internet":            bgp.COMMUNITY_INTERNET,
+	"graceful-shutdown":   bgp.COMMUNITY_GRACEFUL_SHUTDOWN,
+	"no-export":           bgp.COMMUNITY_NO_EXPORT,
+	"no-advertise":        bgp.COMMUNITY_NO_ADVERTISE,
+	"no-export-subconfed": bgp.COMMUNITY_NO_EXPORT_SUBCONFED,
+}
+
+// parseCommunity parses "65000:100", a decimal value or the name of a
+// well-known community such as "no-export".
+func parseCommunity(s string) (uint32, error) {
+	if v, found := wellKnownCommunities[strings.ToLower(s)]; found {
+		return v, nil
+	}
+	elems := strings.Split(s, ":")
+	switch len(elems) {
+	case 1:
+		v, err := strconv.ParseUint(s, 10, 32)
+		if err == nil {
+			return uint32(v), nil
+		}
+	case 2:
+		as, err1 := strconv.ParseUint(elems[0], 10, 16)
+		v, err2 := strconv.ParseUint(elems[1], 10, 16)
+		if err1 == nil && err2 == nil {
+			return uint32(as<<16 | v), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid community %s", s)
+}
+
+func communityString(c uint32) string {
+	return fmt.Sprintf("%d:%d", c>>16, c&0xffff)
+}
+
+// communityMatcher matches the text form of a community or an extended
+// community, either exactly or with a regular expression.
+type communityMatcher struct {
+	value  string
+	regexp *regexp.Regexp
+}
+
+func (m *communityMatcher) match(s string) bool {
+	if m.regexp != nil {
+		return m.regexp.MatchString(s)
+	}
+	return m.value == s
+}
+
+func newCommunityMatcher(s string) (*communityMatcher, error) {
+	if c, err := parseCommunity(s); err == nil {
+		return &communityMatcher{value: communityString(c)}, nil
+	}
+	r, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid community %s: %s", s, err)
+	}
+	return &communityMatcher{regexp: r}, nil
+}
+
+func newExtCommunityMatcher(s string) (*communityMatcher, error) {
+	if e, err := bgp.ParseExtendedCommunity(s); err == nil {
+		return &communityMatcher{value: e.String()}, nil
+	}
+	r, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community %s: %s", s, err)
+	}
+	return &communityMatcher{regexp: r}, nil
+}
+
+func newLargeCommunityMatcher(s string) (*communityMatcher, error) {
+	if c, err := bgp.ParseLargeCommunity(s); err == nil {
+		return &communityMatcher{value: c.String()}, nil
+	}
+	r, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid large community %s: %s", s, err)
+	}
+	return &communityMatcher{regexp: r}, nil
+}
+
+// CommunitySet is a set of communities, extended communities or large
+// communities.
+type CommunitySet struct {
+	Name    string
+	members []*communityMatcher
+}
+
+func NewCommunitySet(config configuration.CommunitySetType) (*CommunitySet, error) {
+	s := &CommunitySet{Name: config.CommunitySetName}
+	for _, c := range config.CommunityMembers {
+		m, err := newCommunityMatcher(c)
+		if err != nil {
+			return nil, fmt.Errorf("community set %s: %s", s.Name, err)
+		}
+		s.members = append(s.members, m)
+	}
+	return s, nil
+}
+
+func NewExtCommunitySet(config configuration.ExtCommunitySetType) (*CommunitySet, error) {
+	s := &CommunitySet{Name: config.ExtCommunitySetName}
+	for _, c := range config.ExtCommunityMembers {
+		m, err := newExtCommunityMatcher(c)
+		if err != nil {
+			return nil, fmt.Errorf("ext community set %s: %s", s.Name, err)
+		}
+		s.members = append(s.members, m)
+	}
+	return s, nil
+}
+
+func NewLargeCommunitySet(config configuration.LargeCommunitySetType) (*CommunitySet, error) {
+	s := &CommunitySet{Name: config.LargeCommunitySetName}
+	for _, c := range config.LargeCommunityMembers {
+		m, err := newLargeCommunityMatcher(c)
+		if err != nil {
+			return nil, fmt.Errorf("large community set %s: %s", s.Name, err)
+		}
+		s.members = append(s.members, m)
+	}
+	return s, nil
+}
+
+// match returns whether any of the members match one of communities, or
+// all of them if option is MATCH_SET_OPTIONS_TYPE_ALL. With
+// MATCH_SET_OPTIONS_TYPE_INVERT it returns whether none of them match.
+func (s *CommunitySet) match(communities []string, option configuration.MatchSetOptionsType) bool {
+	matched := 0
+	for _, m := range s.members {
+		for _, c := range communities {
+			if m.match(c) {
+				matched++
+				break
+			}
+		}
+	}
+	switch option {
+	case configuration.MATCH_SET_OPTIONS_TYPE_ALL:
+		return matched == len(s.members)
+	case configuration.MATCH_SET_OPTIONS_TYPE_INVERT:
+		return matched == 0
+	}
+	return matched > 0
+}
+
+func getCommunities(pattrs []bgp.PathAttributeInterface) []uint32 {
+	attr := findPathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeCommunities{}))
+	if attr == nil {
+		return nil
+	}
+	return attr.(*bgp.PathAttributeCommunities).Value
+}
+
+func getExtCommunities(pattrs []bgp.PathAttributeInterface) []bgp.ExtendedCommunityInterface {
+	attr := findPathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeExtendedCommunities{}))
+	if attr == nil {
+		return nil
+	}
+	return attr.(*bgp.PathAttributeExtendedCommunities).Value
+}
+
+func getLargeCommunities(pattrs []bgp.PathAttributeInterface) []*bgp.LargeCommunity {
+	attr := findPathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeLargeCommunities{}))
+	if attr == nil {
+		return nil
+	}
+	return attr.(*bgp.PathAttributeLargeCommunities).Value
+}
+
+type CommunityCondition struct {
+	set    *CommunitySet
+	option configuration.MatchSetOptionsType
+}
+
+func (c *CommunityCondition) evaluate(path table.Path) bool {
+	communities := getCommunities(path.GetPathAttrs())
+	strs := make([]string, len(communities))
+	for i, v := range communities {
+		strs[i] = communityString(v)
+	}
+	return c.set.match(strs, c.option)
+}
+
+type ExtCommunityCondition struct {
+	set    *CommunitySet
+	option configuration.MatchSetOptionsType
+}
+
+func (c *ExtCommunityCondition) evaluate(path table.Path) bool {
+	communities := getExtCommunities(path.GetPathAttrs())
+	strs := make([]string, len(communities))
+	for i, v := range communities {
+		strs[i] = v.String()
+	}
+	return c.set.match(strs, c.option)
+}
+
+type LargeCommunityCondition struct {
+	set    *CommunitySet
+	option configuration.MatchSetOptionsType
+}
+
+func (c *LargeCommunityCondition) evaluate(path table.Path) bool {
+	communities := getLargeCommunities(path.GetPathAttrs())
+	strs := make([]string, len(communities))
+	for i, v := range communities {
+		strs[i] = v.String()
+	}
+	return c.set.match(strs, c.option)
+}
+
+type CommunityCountCondition struct {
+	operator configuration.BgpAttributeComparison
+	value    uint32
+}
+
+func newCommunityCountCondition(config configuration.CommunityCountType) (*CommunityCountCondition, error) {
+	switch config.Operator {
+	case configuration.BGP_ATTRIBUTE_COMPARISON_EQ, configuration.BGP_ATTRIBUTE_COMPARISON_GE, configuration.BGP_ATTRIBUTE_COMPARISON_LE:
+	default:
+		return nil, fmt.Errorf("invalid community-count operator %d", config.Operator)
+	}
+	return &CommunityCountCondition{
+		operator: config.Operator,
+		value:    config.Value,
+	}, nil
+}
+
+func (c *CommunityCountCondition) evaluate(path table.Path) bool {
+	return compareAttribute(c.operator, uint32(len(getCommunities(path.GetPathAttrs()))), c.value)
+}
+
+// CommunityAction adds, removes or replaces communities. The COMMUNITIES
+// attribute is created if needed and removed when no community is left.
+type CommunityAction struct {
+	option configuration.SetCommunityOptionType
+	values []uint32
+	remove []*communityMatcher
+}
+
+func newCommunityAction(config configuration.SetCommunityType) (*CommunityAction, error) {
+	a := &CommunityAction{option: config.Options}
+	switch config.Options {
+	case configuration.SET_COMMUNITY_OPTION_TYPE_ADD, configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE:
+		for _, s := range config.Communities {
+			c, err := parseCommunity(s)
+			if err != nil {
+				return nil, err
+			}
+			a.values = append(a.values, c)
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE:
+		for _, s := range config.Communities {
+			m, err := newCommunityMatcher(s)
+			if err != nil {
+				return nil, err
+			}
+			a.remove = append(a.remove, m)
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_NULL:
+	default:
+		return nil, fmt.Errorf("invalid set-community options %d", config.Options)
+	}
+	return a, nil
+}
+
+func (a *CommunityAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	old := getCommunities(pattrs)
+	communities := make([]uint32, 0, len(old)+len(a.values))
+	switch a.option {
+	case configuration.SET_COMMUNITY_OPTION_TYPE_ADD:
+		communities = append(communities, old...)
+		for _, v := range a.values {
+			found := false
+			for _, c := range communities {
+				if c == v {
+					found = true
+					break
+				}
+			}
+			if !found {
+				communities = append(communities, v)
+			}
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE:
+		for _, c := range old {
+			removed := false
+			for _, m := range a.remove {
+				if m.match(communityString(c)) {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				communities = append(communities, c)
+			}
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE:
+		communities = append(communities, a.values...)
+	}
+	if len(communities) == 0 {
+		return removePathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeCommunities{}))
+	}
+	return replacePathAttr(pattrs, bgp.NewPathAttributeCommunities(communities))
+}
+
+// ExtCommunityAction is CommunityAction for the EXTENDED_COMMUNITIES
+// attribute.
+type ExtCommunityAction struct {
+	option configuration.SetCommunityOptionType
+	values []bgp.ExtendedCommunityInterface
+	remove []*communityMatcher
+}
+
+func newExtCommunityAction(config configuration.SetExtCommunityType) (*ExtCommunityAction, error) {
+	a := &ExtCommunityAction{option: config.Options}
+	switch config.Options {
+	case configuration.SET_COMMUNITY_OPTION_TYPE_ADD, configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE:
+		for _, s := range config.Communities {
+			e, err := bgp.ParseExtendedCommunity(s)
+			if err != nil {
+				return nil, err
+			}
+			a.values = append(a.values, e)
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE:
+		for _, s := range config.Communities {
+			m, err := newExtCommunityMatcher(s)
+			if err != nil {
+				return nil, err
+			}
+			a.remove = append(a.remove, m)
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_NULL:
+	default:
+		return nil, fmt.Errorf("invalid set-ext-community options %d", config.Options)
+	}
+	return a, nil
+}
+
+func (a *ExtCommunityAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	old := getExtCommunities(pattrs)
+	communities := make([]bgp.ExtendedCommunityInterface, 0, len(old)+len(a.values))
+	switch a.option {
+	case configuration.SET_COMMUNITY_OPTION_TYPE_ADD:
+		communities = append(communities, old...)
+		for _, v := range a.values {
+			found := false
+			for _, e := range communities {
+				if e.String() == v.String() {
+					found = true
+					break
+				}
+			}
+			if !found {
+				communities = append(communities, v)
+			}
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE:
+		for _, e := range old {
+			removed := false
+			for _, m := range a.remove {
+				if m.match(e.String()) {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				communities = append(communities, e)
+			}
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE:
+		communities = append(communities, a.values...)
+	}
+	if len(communities) == 0 {
+		return removePathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeExtendedCommunities{}))
+	}
+	return replacePathAttr(pattrs, bgp.NewPathAttributeExtendedCommunities(communities))
+}
+
+// LargeCommunityAction is CommunityAction for the LARGE_COMMUNITY
+// attribute.
+type LargeCommunityAction struct {
+	option configuration.SetCommunityOptionType
+	values []*bgp.LargeCommunity
+	remove []*communityMatcher
+}
+
+func newLargeCommunityAction(config configuration.SetLargeCommunityType) (*LargeCommunityAction, error) {
+	a := &LargeCommunityAction{option: config.Options}
+	switch config.Options {
+	case configuration.SET_COMMUNITY_OPTION_TYPE_ADD, configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE:
+		for _, s := range config.Communities {
+			c, err := bgp.ParseLargeCommunity(s)
+			if err != nil {
+				return nil, err
+			}
+			a.values = append(a.values, c)
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE:
+		for _, s := range config.Communities {
+			m, err := newLargeCommunityMatcher(s)
+			if err != nil {
+				return nil, err
+			}
+			a.remove = append(a.remove, m)
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_NULL:
+	default:
+		return nil, fmt.Errorf("invalid set-large-community options %d", config.Options)
+	}
+	return a, nil
+}
+
+func (a *LargeCommunityAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	old := getLargeCommunities(pattrs)
+	communities := make([]*bgp.LargeCommunity, 0, len(old)+len(a.values))
+	switch a.option {
+	case configuration.SET_COMMUNITY_OPTION_TYPE_ADD:
+		communities = append(communities, old...)
+		for _, v := range a.values {
+			found := false
+			for _, c := range communities {
+				if *c == *v {
+					found = true
+					break
+				}
+			}
+			if !found {
+				communities = append(communities, v)
+			}
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE:
+		for _, c := range old {
+			removed := false
+			for _, m := range a.remove {
+				if m.match(c.String()) {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				communities = append(communities, c)
+			}
+		}
+	case configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE:
+		communities = append(communities, a.values...)
+	}
+	if len(communities) == 0 {
+		return removePathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeLargeCommunities{}))
+	}
+	return replacePathAttr(pattrs, bgp.NewPathAttributeLargeCommunities(communities))
+}
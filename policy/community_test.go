@@ -0,0 +1,246 @@
+package policy
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+)
+
+func TestParseCommunity(t *testing.T) {
+	for s, v := range map[string]uint32{
+		"65000:100":    65000<<16 | 100,
+		"100":          100,
+		"no-export":    bgp.COMMUNITY_NO_EXPORT,
+		"NO-ADVERTISE": bgp.COMMUNITY_NO_ADVERTISE,
+	} {
+		c, err := parseCommunity(s)
+		if err != nil || c != v {
+			t.Errorf("%s: expected %d, got %d, %v", s, v, c, err)
+		}
+	}
+	for _, s := range []string{"65536:1", "1:2:3", "foo", "4294967296"} {
+		if _, err := parseCommunity(s); err == nil {
+			t.Errorf("%s should be an error", s)
+		}
+	}
+}
+
+func TestCommunityCondition(t *testing.T) {
+	s, err := NewCommunitySet(configuration.CommunitySetType{
+		CommunitySetName: "cs1",
+		CommunityMembers: []string{"65000:100", "no-export", "^65001:.*$"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeCommunities([]uint32{65000<<16 | 100, 65001<<16 | 200}))
+	any := &CommunityCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_ANY}
+	all := &CommunityCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_ALL}
+	invert := &CommunityCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_INVERT}
+	if !any.evaluate(path) || all.evaluate(path) || invert.evaluate(path) {
+		t.Error("only the any condition should match")
+	}
+	path = testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeCommunities([]uint32{65000<<16 | 100, 65001<<16 | 200, bgp.COMMUNITY_NO_EXPORT}))
+	if !all.evaluate(path) {
+		t.Error("the all condition should match")
+	}
+	path = testPath("10.10.10.0", 24, "192.168.0.1", 0)
+	if any.evaluate(path) || !invert.evaluate(path) {
+		t.Error("a path without communities should only match the invert condition")
+	}
+
+	count, _ := newCommunityCountCondition(configuration.CommunityCountType{
+		Operator: configuration.BGP_ATTRIBUTE_COMPARISON_GE,
+		Value:    2,
+	})
+	if !count.evaluate(testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeCommunities([]uint32{1, 2}))) || count.evaluate(testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeCommunities([]uint32{1}))) {
+		t.Error("community count should be compared")
+	}
+}
+
+func TestExtCommunityCondition(t *testing.T) {
+	s, err := NewExtCommunitySet(configuration.ExtCommunitySetType{
+		ExtCommunitySetName: "ecs1",
+		ExtCommunityMembers: []string{"rt:65000:100", "^soo:10\\.0\\.0\\.1:", "encap:vxlan"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &ExtCommunityCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_ANY}
+	for _, e := range []struct {
+		ext   bgp.ExtendedCommunityInterface
+		match bool
+	}{
+		{&bgp.TwoOctetAsSpecificExtended{SubType: bgp.EC_SUBTYPE_ROUTE_TARGET, AS: 65000, LocalAdmin: 100}, true},
+		{&bgp.TwoOctetAsSpecificExtended{SubType: bgp.EC_SUBTYPE_ROUTE_ORIGIN, AS: 65000, LocalAdmin: 100}, false},
+		{&bgp.IPv4AddressSpecificExtended{SubType: bgp.EC_SUBTYPE_ROUTE_ORIGIN, IPv4: net.ParseIP("10.0.0.1").To4(), LocalAdmin: 1}, true},
+		{&bgp.IPv4AddressSpecificExtended{SubType: bgp.EC_SUBTYPE_ROUTE_TARGET, IPv4: net.ParseIP("10.0.0.1").To4(), LocalAdmin: 1}, false},
+		{&bgp.EncapExtended{TunnelType: bgp.TUNNEL_TYPE_VXLAN}, true},
+		{&bgp.EncapExtended{TunnelType: bgp.TUNNEL_TYPE_GRE}, false},
+	} {
+		path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{e.ext}))
+		if c.evaluate(path) != e.match {
+			t.Errorf("%s: match should be %t", e.ext.String(), e.match)
+		}
+	}
+}
+
+func TestCommunityAction(t *testing.T) {
+	path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeCommunities([]uint32{65000<<16 | 100, 65001<<16 | 200}))
+	for _, c := range []struct {
+		config   configuration.SetCommunityType
+		expected []uint32
+	}{
+		{configuration.SetCommunityType{
+			Communities: []string{"65000:100", "no-export"},
+			Options:     configuration.SET_COMMUNITY_OPTION_TYPE_ADD,
+		}, []uint32{65000<<16 | 100, 65001<<16 | 200, bgp.COMMUNITY_NO_EXPORT}},
+		{configuration.SetCommunityType{
+			Communities: []string{"^65001:"},
+			Options:     configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE,
+		}, []uint32{65000<<16 | 100}},
+		{configuration.SetCommunityType{
+			Communities: []string{"65002:1"},
+			Options:     configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE,
+		}, []uint32{65002<<16 | 1}},
+		{configuration.SetCommunityType{
+			Communities: []string{"65000:100", "65001:200"},
+			Options:     configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE,
+		}, nil},
+		{configuration.SetCommunityType{
+			Options: configuration.SET_COMMUNITY_OPTION_TYPE_NULL,
+		}, nil},
+	} {
+		a, err := newCommunityAction(c.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pattrs := a.apply(path.GetPathAttrs())
+		if communities := getCommunities(pattrs); !reflect.DeepEqual(communities, c.expected) {
+			t.Errorf("%v: expected %v, got %v", c.config, c.expected, communities)
+		}
+		if c.expected == nil && len(pattrs) != len(path.GetPathAttrs())-1 {
+			t.Errorf("%v: communities attribute should be removed", c.config)
+		}
+	}
+	if communities := getCommunities(path.GetPathAttrs()); len(communities) != 2 {
+		t.Errorf("original communities should be left untouched: %v", communities)
+	}
+
+	// the attribute is created if needed
+	a, _ := newCommunityAction(configuration.SetCommunityType{
+		Communities: []string{"65000:100"},
+		Options:     configuration.SET_COMMUNITY_OPTION_TYPE_ADD,
+	})
+	pattrs := a.apply(testPath("10.10.10.0", 24, "192.168.0.1", 0).GetPathAttrs())
+	if communities := getCommunities(pattrs); !reflect.DeepEqual(communities, []uint32{65000<<16 | 100}) {
+		t.Errorf("communities attribute should be created: %v", communities)
+	}
+
+	for _, config := range []configuration.SetCommunityType{
+		{Communities: []string{"65000:100"}},
+		{Communities: []string{"^65000:"}, Options: configuration.SET_COMMUNITY_OPTION_TYPE_ADD},
+	} {
+		if _, err := newCommunityAction(config); err == nil {
+			t.Errorf("%v should be an error", config)
+		}
+	}
+}
+
+func TestExtCommunityAction(t *testing.T) {
+	rt1 := &bgp.TwoOctetAsSpecificExtended{SubType: bgp.EC_SUBTYPE_ROUTE_TARGET, AS: 65000, LocalAdmin: 1}
+	rt2 := &bgp.TwoOctetAsSpecificExtended{SubType: bgp.EC_SUBTYPE_ROUTE_TARGET, AS: 65000, LocalAdmin: 2}
+	path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeExtendedCommunities([]bgp.ExtendedCommunityInterface{rt1}))
+
+	a, err := newExtCommunityAction(configuration.SetExtCommunityType{
+		Communities: []string{"rt:65000:1", "rt:65000:2"},
+		Options:     configuration.SET_COMMUNITY_OPTION_TYPE_ADD,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extCommunities := getExtCommunities(a.apply(path.GetPathAttrs()))
+	if !reflect.DeepEqual(extCommunities, []bgp.ExtendedCommunityInterface{rt1, rt2}) {
+		t.Errorf("unexpected extended communities: %v", extCommunities)
+	}
+
+	a, _ = newExtCommunityAction(configuration.SetExtCommunityType{
+		Communities: []string{"^rt:65000:"},
+		Options:     configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE,
+	})
+	pattrs := a.apply(path.GetPathAttrs())
+	if getExtCommunities(pattrs) != nil || len(pattrs) != len(path.GetPathAttrs())-1 {
+		t.Errorf("extended communities attribute should be removed: %v", pattrs)
+	}
+}
+
+func TestLargeCommunityCondition(t *testing.T) {
+	s, err := NewLargeCommunitySet(configuration.LargeCommunitySetType{
+		LargeCommunitySetName: "lcs1",
+		LargeCommunityMembers: []string{"4200000000:1:2", "^4200000001:100:"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &LargeCommunityCondition{set: s, option: configuration.MATCH_SET_OPTIONS_TYPE_ANY}
+	for _, e := range []struct {
+		community *bgp.LargeCommunity
+		match     bool
+	}{
+		{&bgp.LargeCommunity{ASN: 4200000000, LocalData1: 1, LocalData2: 2}, true},
+		{&bgp.LargeCommunity{ASN: 4200000000, LocalData1: 1, LocalData2: 3}, false},
+		{&bgp.LargeCommunity{ASN: 4200000001, LocalData1: 100, LocalData2: 7}, true},
+		{&bgp.LargeCommunity{ASN: 4200000001, LocalData1: 1000, LocalData2: 7}, false},
+	} {
+		if c.evaluate(testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeLargeCommunities([]*bgp.LargeCommunity{e.community}))) != e.match {
+			t.Errorf("%s: match should be %t", e.community, e.match)
+		}
+	}
+	if c.evaluate(testPath("10.10.10.0", 24, "192.168.0.1", 0)) {
+		t.Error("a path without large communities should not match")
+	}
+
+	if _, err := NewLargeCommunitySet(configuration.LargeCommunitySetType{
+		LargeCommunitySetName: "lcs2",
+		LargeCommunityMembers: []string{"^(4200000000"},
+	}); err == nil {
+		t.Error("invalid regular expression should be an error")
+	}
+}
+
+func TestLargeCommunityAction(t *testing.T) {
+	c1 := &bgp.LargeCommunity{ASN: 4200000000, LocalData1: 1, LocalData2: 1}
+	c2 := &bgp.LargeCommunity{ASN: 4200000000, LocalData1: 1, LocalData2: 2}
+	path := testPath("10.10.10.0", 24, "192.168.0.1", 0, bgp.NewPathAttributeLargeCommunities([]*bgp.LargeCommunity{c1}))
+
+	a, err := newLargeCommunityAction(configuration.SetLargeCommunityType{
+		Communities: []string{"4200000000:1:1", "4200000000:1:2"},
+		Options:     configuration.SET_COMMUNITY_OPTION_TYPE_ADD,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	communities := getLargeCommunities(a.apply(path.GetPathAttrs()))
+	if !reflect.DeepEqual(communities, []*bgp.LargeCommunity{c1, c2}) {
+		t.Errorf("unexpected large communities: %v", communities)
+	}
+
+	a, _ = newLargeCommunityAction(configuration.SetLargeCommunityType{
+		Communities: []string{"^4200000000:"},
+		Options:     configuration.SET_COMMUNITY_OPTION_TYPE_REMOVE,
+	})
+	pattrs := a.apply(path.GetPathAttrs())
+	if getLargeCommunities(pattrs) != nil || len(pattrs) != len(path.GetPathAttrs())-1 {
+		t.Errorf("large communities attribute should be removed: %v", pattrs)
+	}
+
+	if _, err := newLargeCommunityAction(configuration.SetLargeCommunityType{
+		Communities: []string{"65000:1"},
+		Options:     configuration.SET_COMMUNITY_OPTION_TYPE_REPLACE,
+	}); err == nil {
+		t.Error("invalid large community should be an error")
+	}
+}
@@ -0,0 +1,542 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/gopher-net/gopher-net/configuration"
+	"net"
+	"reflect"
+
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+type RouteType int
+
+const (
+	ROUTE_TYPE_NONE RouteType = iota
+	ROUTE_TYPE_ACCEPT
+	ROUTE_TYPE_REJECT
+)
+
+func (r RouteType) String() string {
+	switch r {
+	case ROUTE_TYPE_ACCEPT:
+		return "accept"
+	case ROUTE_TYPE_REJECT:
+		return "reject"
+	}
+	return "none"
+}
+
+// Condition is a match condition of a statement.
+type Condition interface {
+	evaluate(path table.Path) bool
+}
+
+// Action modifies the path attributes of a matched path. Path attributes
+// are shared between neighbors, so an action returns a new slice instead
+// of modifying pattrs or the attributes in it.
+type Action interface {
+	apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface
+}
+
+// replacePathAttr returns a copy of pattrs with the attribute of the type
+// of attr replaced by attr, attr is appended if there is none.
+func replacePathAttr(pattrs []bgp.PathAttributeInterface, attr bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	newAttrs := make([]bgp.PathAttributeInterface, 0, len(pattrs)+1)
+	replaced := false
+	for _, a := range pattrs {
+		if reflect.TypeOf(a) == reflect.TypeOf(attr) {
+			newAttrs = append(newAttrs, attr)
+			replaced = true
+		} else {
+			newAttrs = append(newAttrs, a)
+		}
+	}
+	if !replaced {
+		newAttrs = append(newAttrs, attr)
+	}
+	return newAttrs
+}
+
+// removePathAttr returns a copy of pattrs without the attribute of type t.
+func removePathAttr(pattrs []bgp.PathAttributeInterface, t reflect.Type) []bgp.PathAttributeInterface {
+	newAttrs := make([]bgp.PathAttributeInterface, 0, len(pattrs))
+	for _, a := range pattrs {
+		if reflect.TypeOf(a) != t {
+			newAttrs = append(newAttrs, a)
+		}
+	}
+	return newAttrs
+}
+
+func findPathAttr(pattrs []bgp.PathAttributeInterface, t reflect.Type) bgp.PathAttributeInterface {
+	for _, a := range pattrs {
+		if reflect.TypeOf(a) == t {
+			return a
+		}
+	}
+	return nil
+}
+
+// DefinedSets holds the compiled sets referred to by conditions.
+type DefinedSets struct {
+	prefixSets         map[string]*PrefixSet
+	asPathSets         map[string]*AsPathSet
+	communitySets      map[string]*CommunitySet
+	extCommunitySets   map[string]*CommunitySet
+	largeCommunitySets map[string]*CommunitySet
+}
+
+func NewDefinedSets(config configuration.DefinedSetsType) (*DefinedSets, error) {
+	d := &DefinedSets{
+		prefixSets:         make(map[string]*PrefixSet),
+		asPathSets:         make(map[string]*AsPathSet),
+		communitySets:      make(map[string]*CommunitySet),
+		extCommunitySets:   make(map[string]*CommunitySet),
+		largeCommunitySets: make(map[string]*CommunitySet),
+	}
+	for _, c := range config.PrefixSetList {
+		s, err := NewPrefixSet(c)
+		if err != nil {
+			return nil, err
+		}
+		d.prefixSets[s.Name] = s
+	}
+	for _, c := range config.AsPathSetList {
+		s, err := NewAsPathSet(c)
+		if err != nil {
+			return nil, err
+		}
+		d.asPathSets[s.Name] = s
+	}
+	for _, c := range config.CommunitySetList {
+		s, err := NewCommunitySet(c)
+		if err != nil {
+			return nil, err
+		}
+		d.communitySets[s.Name] = s
+	}
+	for _, c := range config.ExtCommunitySetList {
+		s, err := NewExtCommunitySet(c)
+		if err != nil {
+			return nil, err
+		}
+		d.extCommunitySets[s.Name] = s
+	}
+	for _, c := range config.LargeCommunitySetList {
+		s, err := NewLargeCommunitySet(c)
+		if err != nil {
+			return nil, err
+		}
+		d.largeCommunitySets[s.Name] = s
+	}
+	return d, nil
+}
+
+// PrefixCondition matches the IP prefix of the NLRI of a path. A path has
+// a single NLRI, so MATCH_SET_OPTIONS_TYPE_ALL is the same as ANY. Paths
+// without an IP prefix never match, not even an inverted set.
+type PrefixCondition struct {
+	set    *PrefixSet
+	option configuration.MatchSetOptionsType
+}
+
+func (c *PrefixCondition) evaluate(path table.Path) bool {
+	if _, _, ok := ipPrefix(path); !ok {
+		return false
+	}
+	return c.set.match(path) != (c.option == configuration.MATCH_SET_OPTIONS_TYPE_INVERT)
+}
+
+type NextHopCondition struct {
+	nexthops []net.IP
+}
+
+func (c *NextHopCondition) evaluate(path table.Path) bool {
+	nexthop := path.GetNexthop()
+	for _, n := range c.nexthops {
+		if n.Equal(nexthop) {
+			return true
+		}
+	}
+	return false
+}
+
+type MedCondition struct {
+	med uint32
+}
+
+func (c *MedCondition) evaluate(path table.Path) bool {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
+	return attr != nil && attr.(*bgp.PathAttributeMultiExitDisc).Value == c.med
+}
+
+type OriginCondition struct {
+	origin uint8
+}
+
+func (c *OriginCondition) evaluate(path table.Path) bool {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_ORIGIN)
+	return attr != nil && attr.(*bgp.PathAttributeOrigin).Value[0] == c.origin
+}
+
+type LocalPrefCondition struct {
+	localPref uint32
+}
+
+func (c *LocalPrefCondition) evaluate(path table.Path) bool {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	return attr != nil && attr.(*bgp.PathAttributeLocalPref).Value == c.localPref
+}
+
+type MedAction struct {
+	med uint32
+}
+
+func (a *MedAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	return replacePathAttr(pattrs, bgp.NewPathAttributeMultiExitDisc(a.med))
+}
+
+type LocalPrefAction struct {
+	localPref uint32
+}
+
+func (a *LocalPrefAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	return replacePathAttr(pattrs, bgp.NewPathAttributeLocalPref(a.localPref))
+}
+
+type OriginAction struct {
+	origin uint8
+}
+
+func (a *OriginAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	return replacePathAttr(pattrs, bgp.NewPathAttributeOrigin(a.origin))
+}
+
+// NextHopAction rewrites the NEXT_HOP attribute of IPv4 paths and the
+// next hop in MP_REACH_NLRI of IPv6 paths.
+type NextHopAction struct {
+	nexthop net.IP
+}
+
+func (a *NextHopAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	attr := findPathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeMpReachNLRI{}))
+	if attr == nil {
+		if a.nexthop.To4() == nil {
+			return pattrs
+		}
+		return replacePathAttr(pattrs, bgp.NewPathAttributeNextHop(a.nexthop.String()))
+	}
+	if a.nexthop.To4() != nil {
+		return pattrs
+	}
+	mp := attr.(*bgp.PathAttributeMpReachNLRI)
+	return replacePathAttr(pattrs, bgp.NewPathAttributeMpReachNLRI(a.nexthop.String(), mp.Value))
+}
+
+// AsPathPrependAction prepends the local AS to the AS_PATH. Received
+// paths carry 4 octet AS numbers, so the new AS_PATH is built from
+// As4PathParams.
+type AsPathPrependAction struct {
+	asn     uint32
+	repeatN uint8
+}
+
+func (a *AsPathPrependAction) apply(pattrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
+	prepend := make([]uint32, a.repeatN)
+	for i := range prepend {
+		prepend[i] = a.asn
+	}
+	params := make([]bgp.AsPathParamInterface, 0)
+	for _, s := range as4PathSegments(findPathAttr(pattrs, reflect.TypeOf(&bgp.PathAttributeAsPath{}))) {
+		params = append(params, s)
+	}
+	if len(params) > 0 {
+		first := params[0].(*bgp.As4PathParam)
+		if first.Type == bgp.BGP_ASPATH_ATTR_TYPE_SEQ && len(first.AS)+len(prepend) <= 255 {
+			params[0] = bgp.NewAs4PathParam(first.Type, append(prepend, first.AS...))
+			return replacePathAttr(pattrs, bgp.NewPathAttributeAsPath(params))
+		}
+	}
+	params = append([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, prepend)}, params...)
+	return replacePathAttr(pattrs, bgp.NewPathAttributeAsPath(params))
+}
+
+type Statement struct {
+	Name       string
+	conditions []Condition
+	actions    []Action
+	routeType  RouteType
+}
+
+func newConditions(config configuration.ConditionsType, defined *DefinedSets) ([]Condition, error) {
+	conditions := make([]Condition, 0)
+	switch {
+	case config.CallPolicy != "":
+		return nil, fmt.Errorf("call-policy is not supported")
+	case config.RouteType != "":
+		return nil, fmt.Errorf("route-type is not supported")
+	}
+	option := config.MatchSetOptions
+	switch option {
+	case 0:
+		option = configuration.MATCH_SET_OPTIONS_TYPE_ANY
+	case configuration.MATCH_SET_OPTIONS_TYPE_ANY, configuration.MATCH_SET_OPTIONS_TYPE_ALL, configuration.MATCH_SET_OPTIONS_TYPE_INVERT:
+	default:
+		return nil, fmt.Errorf("invalid match-set-options %d", config.MatchSetOptions)
+	}
+	if config.MatchPrefixSet != "" {
+		s, found := defined.prefixSets[config.MatchPrefixSet]
+		if !found {
+			return nil, fmt.Errorf("prefix set %s is not defined", config.MatchPrefixSet)
+		}
+		conditions = append(conditions, &PrefixCondition{set: s, option: option})
+	}
+	if config.MatchAsPathSet != "" {
+		s, found := defined.asPathSets[config.MatchAsPathSet]
+		if !found {
+			return nil, fmt.Errorf("as path set %s is not defined", config.MatchAsPathSet)
+		}
+		conditions = append(conditions, &AsPathCondition{set: s, option: option})
+	}
+	if config.MatchCommunitySet != "" {
+		s, found := defined.communitySets[config.MatchCommunitySet]
+		if !found {
+			return nil, fmt.Errorf("community set %s is not defined", config.MatchCommunitySet)
+		}
+		conditions = append(conditions, &CommunityCondition{set: s, option: option})
+	}
+	if config.MatchExtCommunitySet != "" {
+		s, found := defined.extCommunitySets[config.MatchExtCommunitySet]
+		if !found {
+			return nil, fmt.Errorf("ext community set %s is not defined", config.MatchExtCommunitySet)
+		}
+		conditions = append(conditions, &ExtCommunityCondition{set: s, option: option})
+	}
+	if config.MatchLargeCommunitySet != "" {
+		s, found := defined.largeCommunitySets[config.MatchLargeCommunitySet]
+		if !found {
+			return nil, fmt.Errorf("large community set %s is not defined", config.MatchLargeCommunitySet)
+		}
+		conditions = append(conditions, &LargeCommunityCondition{set: s, option: option})
+	}
+	if config.CommunityCount.Operator != 0 || config.CommunityCount.Value != 0 {
+		c, err := newCommunityCountCondition(config.CommunityCount)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	if config.AsPathLength.Operator != 0 || config.AsPathLength.Value != 0 {
+		c, err := newAsPathLengthCondition(config.AsPathLength)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+	if len(config.NextHopIn) > 0 {
+		conditions = append(conditions, &NextHopCondition{nexthops: config.NextHopIn})
+	}
+	if config.MedEq != nil {
+		conditions = append(conditions, &MedCondition{med: *config.MedEq})
+	}
+	if config.OriginEq != 0 {
+		conditions = append(conditions, &OriginCondition{origin: uint8(config.OriginEq - 1)})
+	}
+	if config.LocalPrefEq != nil {
+		conditions = append(conditions, &LocalPrefCondition{localPref: *config.LocalPrefEq})
+	}
+	return conditions, nil
+}
+
+func newActions(config configuration.ActionsType, localAs uint32) ([]Action, RouteType, error) {
+	actions := make([]Action, 0)
+	switch {
+	case config.GotoPolicy != "":
+		return nil, ROUTE_TYPE_NONE, fmt.Errorf("goto-policy is not supported")
+	case config.AcceptRoute && config.RejectRoute:
+		return nil, ROUTE_TYPE_NONE, fmt.Errorf("both accept-route and reject-route are set")
+	}
+	routeType := ROUTE_TYPE_NONE
+	if config.AcceptRoute {
+		routeType = ROUTE_TYPE_ACCEPT
+	} else if config.RejectRoute {
+		routeType = ROUTE_TYPE_REJECT
+	}
+	if config.SetMed != nil {
+		actions = append(actions, &MedAction{med: *config.SetMed})
+	}
+	if config.SetLocalPref != nil {
+		actions = append(actions, &LocalPrefAction{localPref: *config.SetLocalPref})
+	}
+	switch config.SetRouteOrigin {
+	case 0:
+	case configuration.BGP_ORIGIN_ATTR_TYPE_IGP, configuration.BGP_ORIGIN_ATTR_TYPE_EGP, configuration.BGP_ORIGIN_ATTR_TYPE_INCOMPLETE:
+		actions = append(actions, &OriginAction{origin: uint8(config.SetRouteOrigin - 1)})
+	default:
+		return nil, ROUTE_TYPE_NONE, fmt.Errorf("invalid set-route-origin %d", config.SetRouteOrigin)
+	}
+	if config.SetNextHop != "" {
+		nexthop := net.ParseIP(string(config.SetNextHop))
+		if nexthop == nil {
+			return nil, ROUTE_TYPE_NONE, fmt.Errorf("invalid set-next-hop %s", config.SetNextHop)
+		}
+		actions = append(actions, &NextHopAction{nexthop: nexthop})
+	}
+	if len(config.SetCommunity.Communities) > 0 || config.SetCommunity.Options != 0 {
+		a, err := newCommunityAction(config.SetCommunity)
+		if err != nil {
+			return nil, ROUTE_TYPE_NONE, err
+		}
+		actions = append(actions, a)
+	}
+	if len(config.SetExtCommunity.Communities) > 0 || config.SetExtCommunity.Options != 0 {
+		a, err := newExtCommunityAction(config.SetExtCommunity)
+		if err != nil {
+			return nil, ROUTE_TYPE_NONE, err
+		}
+		actions = append(actions, a)
+	}
+	if len(config.SetLargeCommunity.Communities) > 0 || config.SetLargeCommunity.Options != 0 {
+		a, err := newLargeCommunityAction(config.SetLargeCommunity)
+		if err != nil {
+			return nil, ROUTE_TYPE_NONE, err
+		}
+		actions = append(actions, a)
+	}
+	if config.SetAsPathPrepend.RepeatN != 0 {
+		actions = append(actions, &AsPathPrependAction{asn: localAs, repeatN: config.SetAsPathPrepend.RepeatN})
+	}
+	return actions, routeType, nil
+}
+
+func NewStatement(config configuration.StatementsType, defined *DefinedSets, localAs uint32) (*Statement, error) {
+	conditions, err := newConditions(config.Conditions, defined)
+	if err != nil {
+		return nil, fmt.Errorf("statement %s: %s", config.Name, err)
+	}
+	actions, routeType, err := newActions(config.Actions, localAs)
+	if err != nil {
+		return nil, fmt.Errorf("statement %s: %s", config.Name, err)
+	}
+	return &Statement{
+		Name:       config.Name,
+		conditions: conditions,
+		actions:    actions,
+		routeType:  routeType,
+	}, nil
+}
+
+// Apply evaluates the conditions of the statement against path. If all of
+// them match it returns true, the route type of the statement and path
+// with the actions applied.
+func (s *Statement) Apply(path table.Path) (bool, RouteType, table.Path) {
+	for _, c := range s.conditions {
+		if !c.evaluate(path) {
+			return false, ROUTE_TYPE_NONE, path
+		}
+	}
+	if s.routeType == ROUTE_TYPE_REJECT || len(s.actions) == 0 {
+		return true, s.routeType, path
+	}
+	pattrs := path.GetPathAttrs()
+	for _, a := range s.actions {
+		pattrs = a.apply(pattrs)
+	}
+	return true, s.routeType, path.CloneWithPathAttrs(pattrs)
+}
+
+type Policy struct {
+	Name       string
+	statements []*Statement
+}
+
+func NewPolicy(config configuration.PolicyDefinitionType, defined *DefinedSets, localAs uint32) (*Policy, error) {
+	p := &Policy{
+		Name:       config.Name,
+		statements: make([]*Statement, 0, len(config.StatementsList)),
+	}
+	for _, c := range config.StatementsList {
+		s, err := NewStatement(c, defined, localAs)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %s", p.Name, err)
+		}
+		p.statements = append(p.statements, s)
+	}
+	return p, nil
+}
+
+// Apply runs the statements of the policy in order until one of them
+// accepts or rejects path. The modifications of the matched statements
+// are kept when moving on to the next one.
+func (p *Policy) Apply(path table.Path) (RouteType, table.Path) {
+	for _, s := range p.statements {
+		var matched bool
+		var r RouteType
+		matched, r, path = s.Apply(path)
+		if matched && r != ROUTE_TYPE_NONE {
+			return r, path
+		}
+	}
+	return ROUTE_TYPE_NONE, path
+}
+
+type RoutingPolicy struct {
+	policies map[string]*Policy
+}
+
+func NewRoutingPolicy(config configuration.PolicyType, localAs uint32) (*RoutingPolicy, error) {
+	defined, err := NewDefinedSets(config.DefinedSets)
+	if err != nil {
+		return nil, err
+	}
+	r := &RoutingPolicy{
+		policies: make(map[string]*Policy),
+	}
+	for _, c := range config.PolicyDefinitions.PolicyDefinitionList {
+		p, err := NewPolicy(c, defined, localAs)
+		if err != nil {
+			return nil, err
+		}
+		r.policies[p.Name] = p
+	}
+	return r, nil
+}
+
+// GetPolicies returns the policies in names in order. Unknown names are
+// logged and skipped.
+func (r *RoutingPolicy) GetPolicies(names []string) []*Policy {
+	policies := make([]*Policy, 0, len(names))
+	for _, name := range names {
+		p, found := r.policies[name]
+		if !found {
+			log.Errorf("policy %s is not defined", name)
+			continue
+		}
+		policies = append(policies, p)
+	}
+	return policies
+}
+
+// ApplyPolicies runs policies in order until one of them accepts or
+// rejects path. It returns the modified path, or nil if path is rejected.
+// Paths which aren't accepted or rejected explicitly are accepted, and
+// withdrawals are never filtered.
+func ApplyPolicies(policies []*Policy, path table.Path) table.Path {
+	if path.IsWithdraw() {
+		return path
+	}
+	for _, p := range policies {
+		var r RouteType
+		r, path = p.Apply(path)
+		switch r {
+		case ROUTE_TYPE_ACCEPT:
+			return path
+		case ROUTE_TYPE_REJECT:
+			return nil
+		}
+	}
+	return path
+}
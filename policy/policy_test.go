@@ -0,0 +1,242 @@
+package policy
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// testPath creates a path received from AS 65001 with the ORIGIN,
+// AS_PATH, NEXT_HOP and MED attributes. pattrs replace the attribute of
+// the same type or are added to them. IPv6 prefixes are carried in an
+// MP_REACH_NLRI attribute instead of NEXT_HOP.
+func testPath(prefix string, length uint8, nexthop string, med uint32, pattrs ...bgp.PathAttributeInterface) table.Path {
+	peer := &table.PeerInfo{
+		AS:      65001,
+		ID:      net.ParseIP("10.0.0.2").To4(),
+		LocalID: net.ParseIP("10.0.0.1").To4(),
+	}
+	var nlri bgp.AddrPrefixInterface = bgp.NewNLRInfo(length, prefix)
+	var nexthopAttr bgp.PathAttributeInterface = bgp.NewPathAttributeNextHop(nexthop)
+	if net.ParseIP(prefix).To4() == nil {
+		nlri = bgp.NewIPv6AddrPrefix(length, prefix)
+		nexthopAttr = bgp.NewPathAttributeMpReachNLRI(nexthop, []bgp.AddrPrefixInterface{nlri})
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{
+			bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, []uint32{65001, 65002}),
+		}),
+		nexthopAttr,
+		bgp.NewPathAttributeMultiExitDisc(med),
+	}
+	for _, a := range pattrs {
+		i := 0
+		for i < len(pathAttributes) && pathAttributes[i].GetType() != a.GetType() {
+			i++
+		}
+		if i < len(pathAttributes) {
+			pathAttributes[i] = a
+		} else {
+			pathAttributes = append(pathAttributes, a)
+		}
+	}
+	return table.CreatePath(peer, nlri, pathAttributes, false)
+}
+
+func uint32Value(v uint32) *uint32 {
+	return &v
+}
+
+func testPolicyConfig() configuration.PolicyType {
+	return configuration.PolicyType{
+		DefinedSets: configuration.DefinedSetsType{
+			PrefixSetList: []configuration.PrefixSetType{{
+				PrefixSetName: "ps1",
+				PrefixList: []configuration.PrefixType{{
+					Address:    net.ParseIP("10.10.0.0"),
+					Masklength: 16,
+				}},
+			}},
+		},
+		PolicyDefinitions: configuration.PolicyDefinitionsType{
+			PolicyDefinitionList: []configuration.PolicyDefinitionType{{
+				Name: "pd1",
+				StatementsList: []configuration.StatementsType{{
+					Name: "reject-ps1",
+					Conditions: configuration.ConditionsType{
+						MatchPrefixSet: "ps1",
+					},
+					Actions: configuration.ActionsType{
+						RejectRoute: true,
+					},
+				}, {
+					Name: "med-100",
+					Conditions: configuration.ConditionsType{
+						MedEq: uint32Value(100),
+					},
+					Actions: configuration.ActionsType{
+						SetLocalPref: uint32Value(200),
+						GotoNext:     true,
+					},
+				}, {
+					Name: "accept-nexthop",
+					Conditions: configuration.ConditionsType{
+						NextHopIn: []net.IP{net.ParseIP("192.168.0.1")},
+					},
+					Actions: configuration.ActionsType{
+						SetMed:           uint32Value(50),
+						SetAsPathPrepend: configuration.SetAsPathPrependType{RepeatN: 2},
+						AcceptRoute:      true,
+					},
+				}, {
+					Name: "reject-all",
+					Actions: configuration.ActionsType{
+						RejectRoute: true,
+					},
+				}},
+			}},
+		},
+	}
+}
+
+func TestApplyPolicies(t *testing.T) {
+	r, err := NewRoutingPolicy(testPolicyConfig(), 65000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := r.GetPolicies([]string{"pd1"})
+
+	// rejected by the prefix set
+	if p := ApplyPolicies(policies, testPath("10.10.0.0", 16, "192.168.0.1", 100)); p != nil {
+		t.Errorf("path should be rejected: %v", p)
+	}
+
+	// rejected by the last statement
+	if p := ApplyPolicies(policies, testPath("10.20.0.0", 16, "192.168.0.2", 0)); p != nil {
+		t.Errorf("path should be rejected: %v", p)
+	}
+
+	// modified by the second and the third statement
+	path := testPath("10.20.0.0", 16, "192.168.0.1", 100)
+	p := ApplyPolicies(policies, path)
+	if p == nil {
+		t.Fatal("path should be accepted")
+	}
+	_, attr := p.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	if attr == nil || attr.(*bgp.PathAttributeLocalPref).Value != 200 {
+		t.Errorf("local pref should be 200: %v", attr)
+	}
+	_, attr = p.GetPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
+	if attr.(*bgp.PathAttributeMultiExitDisc).Value != 50 {
+		t.Errorf("med should be 50: %v", attr)
+	}
+	_, attr = p.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	as := attr.(*bgp.PathAttributeAsPath).Value[0].(*bgp.As4PathParam).AS
+	if len(as) != 4 || as[0] != 65000 || as[1] != 65000 || as[2] != 65001 {
+		t.Errorf("as path should be prepended: %v", as)
+	}
+
+	// the original path is left untouched
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
+	if attr.(*bgp.PathAttributeMultiExitDisc).Value != 100 {
+		t.Errorf("original med should be 100: %v", attr)
+	}
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	if attr != nil {
+		t.Errorf("original path should have no local pref: %v", attr)
+	}
+
+	// withdrawals are never filtered
+	w := testPath("10.10.0.0", 16, "192.168.0.1", 100).Clone(true)
+	if p := ApplyPolicies(policies, w); p != w {
+		t.Errorf("withdrawal should pass: %v", p)
+	}
+}
+
+func TestApplyPoliciesDefaultAccept(t *testing.T) {
+	config := testPolicyConfig()
+	config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList[0].Conditions.MatchSetOptions = configuration.MATCH_SET_OPTIONS_TYPE_INVERT
+	config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList = config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList[:1]
+	r, err := NewRoutingPolicy(config, 65000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := r.GetPolicies([]string{"pd1", "undefined"})
+	if len(policies) != 1 {
+		t.Errorf("undefined policies should be skipped: %v", policies)
+	}
+	path := testPath("10.10.0.0", 16, "192.168.0.1", 100)
+	if p := ApplyPolicies(policies, path); p != path {
+		t.Errorf("path should be accepted unmodified: %v", p)
+	}
+	if p := ApplyPolicies(policies, testPath("10.20.0.0", 16, "192.168.0.1", 100)); p != nil {
+		t.Errorf("path should be rejected: %v", p)
+	}
+}
+
+func TestApplyPoliciesZeroValues(t *testing.T) {
+	config := testPolicyConfig()
+	statement := &config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList[1]
+	statement.Conditions.MedEq = uint32Value(0)
+	statement.Actions.SetLocalPref = uint32Value(0)
+	config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList[2].Actions.SetMed = uint32Value(0)
+	r, err := NewRoutingPolicy(config, 65000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := r.GetPolicies([]string{"pd1"})
+
+	p := ApplyPolicies(policies, testPath("10.20.0.0", 16, "192.168.0.1", 0))
+	if p == nil {
+		t.Fatal("path should be accepted")
+	}
+	_, attr := p.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	if attr == nil || attr.(*bgp.PathAttributeLocalPref).Value != 0 {
+		t.Errorf("local pref should be 0: %v", attr)
+	}
+	p = ApplyPolicies(policies, testPath("10.20.0.0", 16, "192.168.0.1", 100))
+	_, attr = p.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	if attr != nil {
+		t.Errorf("med 100 shouldn't match med-eq 0: %v", attr)
+	}
+	_, attr = p.GetPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
+	if attr.(*bgp.PathAttributeMultiExitDisc).Value != 0 {
+		t.Errorf("med should be set to 0: %v", attr)
+	}
+}
+
+func TestSetNextHop(t *testing.T) {
+	a := &NextHopAction{nexthop: net.ParseIP("192.168.10.1")}
+	path := testPath("10.20.0.0", 16, "192.168.0.1", 0)
+	p := path.CloneWithPathAttrs(a.apply(path.GetPathAttrs()))
+	if !p.GetNexthop().Equal(net.ParseIP("192.168.10.1")) {
+		t.Errorf("next hop should be rewritten: %s", p.GetNexthop())
+	}
+	if !path.GetNexthop().Equal(net.ParseIP("192.168.0.1")) {
+		t.Errorf("original next hop should be kept: %s", path.GetNexthop())
+	}
+}
+
+func TestNewRoutingPolicyError(t *testing.T) {
+	config := testPolicyConfig()
+	config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList[0].Conditions.MatchPrefixSet = "undefined"
+	if _, err := NewRoutingPolicy(config, 65000); err == nil {
+		t.Error("undefined prefix set should be an error")
+	}
+
+	config = testPolicyConfig()
+	config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList[0].Actions.AcceptRoute = true
+	if _, err := NewRoutingPolicy(config, 65000); err == nil {
+		t.Error("accept and reject should be an error")
+	}
+
+	config = testPolicyConfig()
+	config.PolicyDefinitions.PolicyDefinitionList[0].StatementsList[2].Actions.SetNextHop = "self"
+	if _, err := NewRoutingPolicy(config, 65000); err == nil {
+		t.Error("invalid next hop should be an error")
+	}
+}
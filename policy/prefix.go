@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gopher-net/gopher-net/configuration"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/tchap/go-patricia/patricia"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+// maskLengthRange is an inclusive range of mask lengths.
+type maskLengthRange struct {
+	min uint8
+	max uint8
+}
+
+// parseMaskLengthRange parses a MasklengthRange such as "24..32". An
+// empty string matches the mask length of the prefix only.
+func parseMaskLengthRange(s string, masklength uint8, bits int) (maskLengthRange, error) {
+	if s == "" {
+		return maskLengthRange{masklength, masklength}, nil
+	}
+	elems := strings.Split(s, "..")
+	if len(elems) != 2 {
+		return maskLengthRange{}, fmt.Errorf("invalid masklength range %s", s)
+	}
+	min, err1 := strconv.ParseUint(strings.TrimSpace(elems[0]), 10, 8)
+	max, err2 := strconv.ParseUint(strings.TrimSpace(elems[1]), 10, 8)
+	if err1 != nil || err2 != nil || min > max || int(max) > bits || uint8(min) < masklength {
+		return maskLengthRange{}, fmt.Errorf("invalid masklength range %s for /%d", s, masklength)
+	}
+	return maskLengthRange{uint8(min), uint8(max)}, nil
+}
+
+// prefixKey returns the first length bits of ip as a patricia key, one
+// byte per bit.
+func prefixKey(ip net.IP, length int) patricia.Prefix {
+	key := make(patricia.Prefix, length)
+	for i := 0; i < length; i++ {
+		if ip[i/8]&(0x80>>uint(i%8)) != 0 {
+			key[i] = '1'
+		} else {
+			key[i] = '0'
+		}
+	}
+	return key
+}
+
+var errPrefixMatched = errors.New("matched")
+
+// PrefixSet matches the NLRI of a path against a set of prefixes and mask
+// length ranges. The prefixes are kept in a trie per address family, so
+// a lookup visits at most one node per bit of the NLRI regardless of the
+// size of the set, and each prefix is stored once along with its ranges.
+type PrefixSet struct {
+	Name string
+	v4   *patricia.Trie
+	v6   *patricia.Trie
+}
+
+func NewPrefixSet(config configuration.PrefixSetType) (*PrefixSet, error) {
+	s := &PrefixSet{
+		Name: config.PrefixSetName,
+		v4:   patricia.NewTrie(),
+		v6:   patricia.NewTrie(),
+	}
+	for _, p := range config.PrefixList {
+		if err := s.add(p); err != nil {
+			return nil, fmt.Errorf("prefix set %s: %s", s.Name, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *PrefixSet) add(p configuration.PrefixType) error {
+	trie, ip, bits := s.v6, p.Address.To16(), net.IPv6len*8
+	if ip4 := p.Address.To4(); ip4 != nil {
+		trie, ip, bits = s.v4, ip4, net.IPv4len*8
+	}
+	if ip == nil {
+		return fmt.Errorf("invalid address %s", p.Address)
+	}
+	if int(p.Masklength) > bits {
+		return fmt.Errorf("invalid masklength %d for %s", p.Masklength, p.Address)
+	}
+	r, err := parseMaskLengthRange(p.MasklengthRange, p.Masklength, bits)
+	if err != nil {
+		return err
+	}
+	key := prefixKey(ip, int(p.Masklength))
+	ranges, _ := trie.Get(key).([]maskLengthRange)
+	for _, e := range ranges {
+		if e == r {
+			return nil
+		}
+	}
+	trie.Set(key, append(ranges, r))
+	return nil
+}
+
+// ipPrefix returns the IP prefix of the NLRI of path, labels and route
+// distinguisher left out. ok is false for the route families without
+// one such as EVPN or route target membership.
+func ipPrefix(path table.Path) (ip net.IP, length uint8, ok bool) {
+	switch nlri := path.GetNlri().(type) {
+	case *bgp.NLRInfo:
+		return nlri.Prefix, nlri.Length, true
+	case *bgp.WithdrawnRoute:
+		return nlri.Prefix, nlri.Length, true
+	case *bgp.IPAddrPrefix:
+		return nlri.Prefix, nlri.Length, true
+	case *bgp.IPv6AddrPrefix:
+		return nlri.Prefix, nlri.Length, true
+	case *bgp.IPMulticastAddrPrefix:
+		return nlri.Prefix, nlri.Length, true
+	case *bgp.IPv6MulticastAddrPrefix:
+		return nlri.Prefix, nlri.Length, true
+	case *bgp.LabelledIPAddrPrefix:
+		return nlri.Prefix, nlri.IPPrefixLen(), true
+	case *bgp.LabelledIPv6AddrPrefix:
+		return nlri.Prefix, nlri.IPPrefixLen(), true
+	case *bgp.LabelledVPNIPAddrPrefix:
+		return nlri.Prefix, nlri.IPPrefixLen(), true
+	case *bgp.LabelledVPNIPv6AddrPrefix:
+		return nlri.Prefix, nlri.IPPrefixLen(), true
+	}
+	return nil, 0, false
+}
+
+func (s *PrefixSet) match(path table.Path) bool {
+	prefix, length, ok := ipPrefix(path)
+	if !ok {
+		return false
+	}
+	trie, ip := s.v6, prefix.To16()
+	if path.GetNlri().AFI() == bgp.AFI_IP {
+		trie, ip = s.v4, prefix.To4()
+	}
+	if ip == nil || int(length) > len(ip)*8 {
+		return false
+	}
+	matched := trie.VisitPrefixes(prefixKey(ip, int(length)), func(_ patricia.Prefix, item patricia.Item) error {
+		for _, r := range item.([]maskLengthRange) {
+			if r.min <= length && length <= r.max {
+				return errPrefixMatched
+			}
+		}
+		return nil
+	})
+	return matched == errPrefixMatched
+}
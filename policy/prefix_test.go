@@ -0,0 +1,167 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"github.com/gopher-net/gopher-net/third-party/github.com/gobgp/table"
+)
+
+func TestPrefixSetMatch(t *testing.T) {
+	s, err := NewPrefixSet(configuration.PrefixSetType{
+		PrefixSetName: "ps1",
+		PrefixList: []configuration.PrefixType{
+			{Address: net.ParseIP("10.10.0.0"), Masklength: 16},
+			{Address: net.ParseIP("10.10.0.0"), Masklength: 16, MasklengthRange: "24..26"},
+			{Address: net.ParseIP("172.16.0.0"), Masklength: 12, MasklengthRange: "12..32"},
+			{Address: net.ParseIP("2001:db8::"), Masklength: 32, MasklengthRange: "48..64"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		path  table.Path
+		match bool
+	}{
+		{testPath("10.10.0.0", 16, "192.168.0.1", 0), true},
+		{testPath("10.10.0.0", 17, "192.168.0.1", 0), false},
+		{testPath("10.10.20.0", 24, "192.168.0.1", 0), true},
+		{testPath("10.10.20.64", 26, "192.168.0.1", 0), true},
+		{testPath("10.10.20.128", 27, "192.168.0.1", 0), false},
+		{testPath("10.11.0.0", 24, "192.168.0.1", 0), false},
+		{testPath("172.16.0.0", 12, "192.168.0.1", 0), true},
+		{testPath("172.31.1.1", 32, "192.168.0.1", 0), true},
+		{testPath("172.0.0.0", 8, "192.168.0.1", 0), false},
+		{testPath("2001:db8:1::", 48, "2001:db8::1", 0), true},
+		{testPath("2001:db8:1:1::", 64, "2001:db8::1", 0), true},
+		{testPath("2001:db8::", 32, "2001:db8::1", 0), false},
+		{testPath("2001:db9::", 48, "2001:db8::1", 0), false},
+	} {
+		if s.match(c.path) != c.match {
+			t.Errorf("%s: match should be %t", c.path.GetPrefix(), c.match)
+		}
+	}
+}
+
+func TestPrefixSetDefaultRoute(t *testing.T) {
+	s, err := NewPrefixSet(configuration.PrefixSetType{
+		PrefixSetName: "any",
+		PrefixList: []configuration.PrefixType{
+			{Address: net.ParseIP("0.0.0.0"), Masklength: 0, MasklengthRange: "0..24"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.match(testPath("0.0.0.0", 0, "192.168.0.1", 0)) || !s.match(testPath("10.10.20.0", 24, "192.168.0.1", 0)) {
+		t.Error("prefixes up to /24 should match")
+	}
+	if s.match(testPath("10.10.20.0", 25, "192.168.0.1", 0)) || s.match(testPath("2001:db8::", 32, "2001:db8::1", 0)) {
+		t.Error("longer prefixes and IPv6 prefixes should not match")
+	}
+}
+
+func TestPrefixSetLabeledAndVPN(t *testing.T) {
+	config := configuration.PolicyType{
+		DefinedSets: configuration.DefinedSetsType{
+			PrefixSetList: []configuration.PrefixSetType{{
+				PrefixSetName: "ps1",
+				PrefixList: []configuration.PrefixType{
+					{Address: net.ParseIP("10.10.0.0"), Masklength: 16, MasklengthRange: "16..24"},
+				},
+			}},
+		},
+		PolicyDefinitions: configuration.PolicyDefinitionsType{
+			PolicyDefinitionList: []configuration.PolicyDefinitionType{{
+				Name: "pd1",
+				StatementsList: []configuration.StatementsType{{
+					Name: "reject-not-ps1",
+					Conditions: configuration.ConditionsType{
+						MatchPrefixSet:  "ps1",
+						MatchSetOptions: configuration.MATCH_SET_OPTIONS_TYPE_INVERT,
+					},
+					Actions: configuration.ActionsType{
+						RejectRoute: true,
+					},
+				}},
+			}},
+		},
+	}
+	r, err := NewRoutingPolicy(config, 65000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := r.GetPolicies([]string{"pd1"})
+
+	peer := &table.PeerInfo{
+		AS:      65001,
+		ID:      net.ParseIP("10.0.0.2").To4(),
+		LocalID: net.ParseIP("10.0.0.1").To4(),
+	}
+	rd := bgp.NewRouteDistinguisherTwoOctetAS(65001, 1)
+	rt, _ := bgp.ParseExtendedCommunity("rt:65000:100")
+	for _, c := range []struct {
+		nlri   bgp.AddrPrefixInterface
+		accept bool
+	}{
+		{bgp.NewLabelledIPAddrPrefix(24, "10.10.20.0", *bgp.NewLabel(100)), true},
+		{bgp.NewLabelledIPAddrPrefix(24, "10.20.20.0", *bgp.NewLabel(100)), false},
+		{bgp.NewLabelledVPNIPAddrPrefix(24, "10.10.20.0", *bgp.NewLabel(100), rd), true},
+		{bgp.NewLabelledVPNIPAddrPrefix(25, "10.10.20.0", *bgp.NewLabel(100), rd), false},
+		// route target membership has no IP prefix to match
+		{bgp.NewRouteTargetMembershipNLRI(65001, rt), true},
+	} {
+		path := table.CreatePath(peer, c.nlri, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			bgp.NewPathAttributeMpReachNLRI("10.0.0.2", []bgp.AddrPrefixInterface{c.nlri}),
+		}, false)
+		if p := ApplyPolicies(policies, path); (p != nil) != c.accept {
+			t.Errorf("%s: accept should be %t", c.nlri, c.accept)
+		}
+	}
+}
+
+func TestPrefixSetInvalidRange(t *testing.T) {
+	for _, r := range []string{"24", "24..", "26..24", "8..24", "24..33"} {
+		_, err := NewPrefixSet(configuration.PrefixSetType{
+			PrefixSetName: "ps1",
+			PrefixList: []configuration.PrefixType{
+				{Address: net.ParseIP("10.10.0.0"), Masklength: 16, MasklengthRange: r},
+			},
+		})
+		if err == nil {
+			t.Errorf("range %s should be an error", r)
+		}
+	}
+}
+
+func TestPrefixSetLarge(t *testing.T) {
+	config := configuration.PrefixSetType{PrefixSetName: "irr"}
+	for i := 0; i < 1<<16; i++ {
+		config.PrefixList = append(config.PrefixList, configuration.PrefixType{
+			Address:         net.IPv4(100, byte(i>>8), byte(i), 0),
+			Masklength:      24,
+			MasklengthRange: "24..28",
+		})
+	}
+	s, err := NewPrefixSet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range []int{0, 1, 0x1234, 0xffff} {
+		prefix := fmt.Sprintf("100.%d.%d.16", i>>8, i&0xff)
+		if !s.match(testPath(prefix, 28, "192.168.0.1", 0)) {
+			t.Errorf("%s/28 should match", prefix)
+		}
+		if s.match(testPath(prefix, 29, "192.168.0.1", 0)) {
+			t.Errorf("%s/29 should not match", prefix)
+		}
+	}
+	if s.match(testPath("101.0.0.0", 24, "192.168.0.1", 0)) {
+		t.Error("101.0.0.0/24 should not match")
+	}
+}
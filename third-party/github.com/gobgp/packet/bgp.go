@@ -16,6 +16,7 @@
 package bgp
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"errors"
@@ -23,21 +24,30 @@ import (
 	"math"
 	"net"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 // move somewhere else
 
 const (
-	AFI_IP  = 1
-	AFI_IP6 = 2
+	AFI_IP    = 1
+	AFI_IP6   = 2
+	AFI_L2VPN = 25
+	AFI_LS    = 16388
 )
 
 const (
 	SAFI_UNICAST                  = 1
 	SAFI_MULTICAST                = 2
 	SAFI_MPLS_LABEL               = 4
+	SAFI_VPLS                     = 65
+	SAFI_EVPN                     = 70
+	SAFI_LS                       = 71
 	SAFI_MPLS_VPN                 = 128
 	SAFI_ROUTE_TARGET_CONSTRTAINS = 132
+	SAFI_FLOW_SPEC_UNICAST        = 133
 )
 
 const (
@@ -437,6 +447,7 @@ type AddrPrefixInterface interface {
 	AFI() uint16
 	SAFI() uint8
 	Len() int
+	String() string
 }
 
 type IPAddrPrefixDefault struct {
@@ -490,7 +501,11 @@ func (r *IPAddrPrefixDefault) decodePrefix(data []byte, bitlen uint8, addrlen ui
 func (r *IPAddrPrefixDefault) serializePrefix(bitlen uint8) ([]byte, error) {
 	bytelen := (bitlen + 7) / 8
 	buf := make([]byte, bytelen)
-	copy(buf, r.Prefix.To4())
+	if ip := r.Prefix.To4(); ip != nil {
+		copy(buf, ip)
+	} else {
+		copy(buf, r.Prefix.To16())
+	}
 	return buf, nil
 }
 
@@ -562,6 +577,39 @@ func NewIPv6AddrPrefix(length uint8, prefix string) *IPv6AddrPrefix {
 	}
 }
 
+// IPMulticastAddrPrefix is a prefix of the IPv4 multicast route family
+// (RFC 4760), the multicast sources are checked against its routes
+// (RPF) instead of the unicast ones.
+type IPMulticastAddrPrefix struct {
+	IPAddrPrefix
+}
+
+func (r *IPMulticastAddrPrefix) SAFI() uint8 {
+	return SAFI_MULTICAST
+}
+
+func NewIPMulticastAddrPrefix(length uint8, prefix string) *IPMulticastAddrPrefix {
+	return &IPMulticastAddrPrefix{
+		*NewIPAddrPrefix(length, prefix),
+	}
+}
+
+// IPv6MulticastAddrPrefix is a prefix of the IPv6 multicast route
+// family.
+type IPv6MulticastAddrPrefix struct {
+	IPv6AddrPrefix
+}
+
+func (r *IPv6MulticastAddrPrefix) SAFI() uint8 {
+	return SAFI_MULTICAST
+}
+
+func NewIPv6MulticastAddrPrefix(length uint8, prefix string) *IPv6MulticastAddrPrefix {
+	return &IPv6MulticastAddrPrefix{
+		*NewIPv6AddrPrefix(length, prefix),
+	}
+}
+
 type WithdrawnRoute struct {
 	IPAddrPrefix
 }
@@ -576,6 +624,7 @@ type RouteDistinguisherInterface interface {
 	DecodeFromBytes([]byte) error
 	Serialize() ([]byte, error)
 	Len() int
+	String() string
 }
 
 type DefaultRouteDistinguisher struct {
@@ -598,6 +647,10 @@ func (rd *DefaultRouteDistinguisher) Serialize() ([]byte, error) {
 
 func (rd *DefaultRouteDistinguisher) Len() int { return 8 }
 
+func (rd *DefaultRouteDistinguisher) String() string {
+	return fmt.Sprintf("%d:0x%x", rd.Type, rd.Value)
+}
+
 type RouteDistinguisherTwoOctetASValue struct {
 	Admin    uint16
 	Assigned uint32
@@ -616,6 +669,10 @@ func (rd *RouteDistinguisherTwoOctetAS) Serialize() ([]byte, error) {
 	return rd.DefaultRouteDistinguisher.Serialize()
 }
 
+func (rd *RouteDistinguisherTwoOctetAS) String() string {
+	return fmt.Sprintf("%d:%d", rd.Value.Admin, rd.Value.Assigned)
+}
+
 func NewRouteDistinguisherTwoOctetAS(admin uint16, assigned uint32) *RouteDistinguisherTwoOctetAS {
 	return &RouteDistinguisherTwoOctetAS{
 		DefaultRouteDistinguisher{
@@ -640,12 +697,16 @@ type RouteDistinguisherIPAddressAS struct {
 
 func (rd *RouteDistinguisherIPAddressAS) Serialize() ([]byte, error) {
 	buf := make([]byte, 6)
-	copy(buf[0:], rd.Value.Admin)
+	copy(buf[0:], rd.Value.Admin.To4())
 	binary.BigEndian.PutUint16(buf[4:], rd.Value.Assigned)
 	rd.DefaultRouteDistinguisher.Value = buf
 	return rd.DefaultRouteDistinguisher.Serialize()
 }
 
+func (rd *RouteDistinguisherIPAddressAS) String() string {
+	return fmt.Sprintf("%s:%d", rd.Value.Admin, rd.Value.Assigned)
+}
+
 func NewRouteDistinguisherIPAddressAS(admin string, assigned uint16) *RouteDistinguisherIPAddressAS {
 	return &RouteDistinguisherIPAddressAS{
 		DefaultRouteDistinguisher{
@@ -676,6 +737,10 @@ func (rd *RouteDistinguisherFourOctetAS) Serialize() ([]byte, error) {
 	return rd.DefaultRouteDistinguisher.Serialize()
 }
 
+func (rd *RouteDistinguisherFourOctetAS) String() string {
+	return fmt.Sprintf("%dL:%d", rd.Value.Admin, rd.Value.Assigned)
+}
+
 func NewRouteDistinguisherFourOctetAS(admin uint32, assigned uint16) *RouteDistinguisherFourOctetAS {
 	return &RouteDistinguisherFourOctetAS{
 		DefaultRouteDistinguisher{
@@ -716,21 +781,61 @@ func getRouteDistinguisher(data []byte) RouteDistinguisherInterface {
 	}
 	rd := &RouteDistinguisherUnknown{}
 	rd.Type = rdtype
+	rd.Value = data[2:8]
 	return rd
 }
 
+// ParseRouteDistinguisher parses the text form returned by the String
+// method of the route distinguishers: "65000:100", "10.0.0.1:100" or
+// "4200000000:100". A two octet AS number can be forced into the four
+// octet type with an "L" suffix, as in "65000L:100".
+func ParseRouteDistinguisher(s string) (RouteDistinguisherInterface, error) {
+	elems := strings.Split(s, ":")
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid route distinguisher %s", s)
+	}
+	if ip := net.ParseIP(elems[0]).To4(); ip != nil {
+		assigned, err := strconv.ParseUint(elems[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route distinguisher %s", s)
+		}
+		return NewRouteDistinguisherIPAddressAS(ip.String(), uint16(assigned)), nil
+	}
+	four := strings.HasSuffix(elems[0], "L")
+	admin, err := strconv.ParseUint(strings.TrimSuffix(elems[0], "L"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route distinguisher %s", s)
+	}
+	if four || admin > math.MaxUint16 {
+		assigned, err := strconv.ParseUint(elems[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route distinguisher %s", s)
+		}
+		return NewRouteDistinguisherFourOctetAS(uint32(admin), uint16(assigned)), nil
+	}
+	assigned, err := strconv.ParseUint(elems[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid route distinguisher %s", s)
+	}
+	return NewRouteDistinguisherTwoOctetAS(uint16(admin), uint32(assigned)), nil
+}
+
 type Label struct {
 	Labels []uint32
 }
 
+// LABEL_WITHDRAW is the label value carried by a withdrawn labelled
+// prefix in place of its label stack (RFC 3107 section 3).
+const LABEL_WITHDRAW = 0x800000
+
 func (l *Label) DecodeFromBytes(data []byte) error {
 	labels := []uint32{}
 	foundBottom := false
-	for len(data) >= 4 {
-		label := uint32(data[0]<<16 | data[1]<<8 | data[2])
+	for len(data) >= 3 {
+		label := uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
 		data = data[3:]
 		labels = append(labels, label>>4)
-		if label&1 == 1 {
+		if label&1 == 1 || label == LABEL_WITHDRAW {
 			foundBottom = true
 			break
 		}
@@ -744,6 +849,9 @@ func (l *Label) DecodeFromBytes(data []byte) error {
 }
 
 func (l *Label) Serialize() ([]byte, error) {
+	if len(l.Labels) == 0 {
+		return []byte{}, nil
+	}
 	buf := make([]byte, len(l.Labels)*3)
 	for i, label := range l.Labels {
 		label = label << 4
@@ -769,6 +877,11 @@ type LabelledVPNIPAddrPrefix struct {
 }
 
 func (l *LabelledVPNIPAddrPrefix) DecodeFromBytes(data []byte) error {
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
+	if len(data) < 1 {
+		return NewMessageError(eCode, eSubCode, nil, "prefix misses length field")
+	}
 	l.Length = uint8(data[0])
 	data = data[1:]
 	l.Labels.DecodeFromBytes(data)
@@ -776,11 +889,16 @@ func (l *LabelledVPNIPAddrPrefix) DecodeFromBytes(data []byte) error {
 		l.Labels.Labels = []uint32{}
 	}
 	data = data[l.Labels.Len():]
+	if len(data) < 8 {
+		return NewMessageError(eCode, eSubCode, nil, "route distinguisher is short")
+	}
 	l.RD = getRouteDistinguisher(data)
 	data = data[l.RD.Len():]
 	restbits := int(l.Length) - 8*(l.Labels.Len()+l.RD.Len())
-	l.decodePrefix(data, uint8(restbits), l.addrlen)
-	return nil
+	if restbits < 0 || restbits > 8*int(l.addrlen) {
+		return NewMessageError(eCode, eSubCode, nil, "prefix length is incorrect")
+	}
+	return l.decodePrefix(data, uint8(restbits), l.addrlen)
 }
 
 func (l *LabelledVPNIPAddrPrefix) Serialize() ([]byte, error) {
@@ -809,6 +927,24 @@ func (l *LabelledVPNIPAddrPrefix) AFI() uint16 {
 	return AFI_IP
 }
 
+// IPPrefixLen returns the length of the IP prefix without the label
+// stack and the route distinguisher.
+func (l *LabelledVPNIPAddrPrefix) IPPrefixLen() uint8 {
+	rdlen := 0
+	if l.RD != nil {
+		rdlen = l.RD.Len()
+	}
+	return l.Length - uint8(8*(l.Labels.Len()+rdlen))
+}
+
+func (l *LabelledVPNIPAddrPrefix) String() string {
+	rd := ""
+	if l.RD != nil {
+		rd = l.RD.String()
+	}
+	return fmt.Sprintf("%s:%s/%d", rd, l.Prefix, l.IPPrefixLen())
+}
+
 func (l *LabelledVPNIPAddrPrefix) SAFI() uint8 {
 	return SAFI_MPLS_VPN
 }
@@ -849,144 +985,1977 @@ func NewLabelledVPNIPv6AddrPrefix(length uint8, prefix string, label Label, rd R
 	}
 }
 
-type LabelledIPAddrPrefix struct {
-	IPAddrPrefixDefault
-	Labels  Label
-	addrlen uint8
+type LabelledIPAddrPrefix struct {
+	IPAddrPrefixDefault
+	Labels  Label
+	addrlen uint8
+}
+
+func (r *LabelledIPAddrPrefix) AFI() uint16 {
+	return AFI_IP
+}
+
+func (r *LabelledIPAddrPrefix) SAFI() uint8 {
+	return SAFI_MPLS_LABEL
+}
+
+// IPPrefixLen returns the length of the IP prefix without the label
+// stack.
+func (r *LabelledIPAddrPrefix) IPPrefixLen() uint8 {
+	return r.Length - uint8(8*r.Labels.Len())
+}
+
+func (r *LabelledIPAddrPrefix) String() string {
+	return fmt.Sprintf("%s/%d", r.Prefix, r.IPPrefixLen())
+}
+
+func (r *IPAddrPrefix) decodeNextHop(data []byte) net.IP {
+	if r.addrlen == 0 {
+		r.addrlen = 4
+	}
+	var next net.IP = data[0:r.addrlen]
+	return next
+}
+
+func (r *LabelledVPNIPAddrPrefix) decodeNextHop(data []byte) net.IP {
+	// skip rd
+	var next net.IP = data[8 : 8+r.addrlen]
+	return next
+}
+
+func (r *LabelledIPAddrPrefix) decodeNextHop(data []byte) net.IP {
+	var next net.IP = data[0:r.addrlen]
+	return next
+}
+
+func (l *LabelledIPAddrPrefix) DecodeFromBytes(data []byte) error {
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
+	if len(data) < 1 {
+		return NewMessageError(eCode, eSubCode, nil, "prefix misses length field")
+	}
+	l.Length = uint8(data[0])
+	data = data[1:]
+	l.Labels.DecodeFromBytes(data)
+	if int(l.Length)-8*(l.Labels.Len()) < 0 {
+		l.Labels.Labels = []uint32{}
+	}
+	restbits := int(l.Length) - 8*(l.Labels.Len())
+	if restbits < 0 || restbits > 8*int(l.addrlen) {
+		return NewMessageError(eCode, eSubCode, nil, "prefix length is incorrect")
+	}
+	data = data[l.Labels.Len():]
+	return l.decodePrefix(data, uint8(restbits), l.addrlen)
+}
+
+func (l *LabelledIPAddrPrefix) Serialize() ([]byte, error) {
+	buf := make([]byte, 1)
+	buf[0] = l.Length
+	restbits := int(l.Length) - 8*(l.Labels.Len())
+	lbuf, err := l.Labels.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, lbuf...)
+	pbuf, err := l.serializePrefix(uint8(restbits))
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, pbuf...)
+	return buf, nil
+}
+
+func NewLabelledIPAddrPrefix(length uint8, prefix string, label Label) *LabelledIPAddrPrefix {
+	return &LabelledIPAddrPrefix{
+		IPAddrPrefixDefault{length + uint8(label.Len()*8), net.ParseIP(prefix)},
+		label,
+		4,
+	}
+}
+
+type LabelledIPv6AddrPrefix struct {
+	LabelledIPAddrPrefix
+}
+
+func (l *LabelledIPv6AddrPrefix) AFI() uint16 {
+	return AFI_IP6
+}
+
+func NewLabelledIPv6AddrPrefix(length uint8, prefix string, label Label) *LabelledIPv6AddrPrefix {
+	return &LabelledIPv6AddrPrefix{
+		LabelledIPAddrPrefix{
+			IPAddrPrefixDefault{length + uint8(label.Len()*8), net.ParseIP(prefix)},
+			label,
+			16,
+		},
+	}
+}
+
+// RouteTargetMembershipNLRI is the route target membership of RFC 4684,
+// a nil RouteTarget is the zero length default route target which
+// matches all the route targets.
+// RouteTargetMembershipNLRI is the NLRI of RFC 4684. Length is the
+// prefix length in bits: 0 for the default membership, 32 for an origin
+// AS alone, 96 for a whole route target and in between for a route
+// target prefix. The route target of a prefix is an UnknownExtended with
+// the bits past the prefix cleared.
+type RouteTargetMembershipNLRI struct {
+	Length      uint8
+	AS          uint32
+	RouteTarget ExtendedCommunityInterface
+}
+
+func (n *RouteTargetMembershipNLRI) DecodeFromBytes(data []byte) error {
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
+	if len(data) < 1 {
+		return NewMessageError(eCode, eSubCode, nil, "route target membership nlri is short")
+	}
+	n.Length = data[0]
+	n.AS = 0
+	n.RouteTarget = nil
+	if n.Length == 0 {
+		return nil
+	}
+	if n.Length < 32 || n.Length > 96 {
+		return NewMessageError(eCode, eSubCode, nil, fmt.Sprintf("unsupported route target membership length %d", n.Length))
+	}
+	if len(data) < n.Len() {
+		return NewMessageError(eCode, eSubCode, nil, "route target membership nlri is short")
+	}
+	n.AS = binary.BigEndian.Uint32(data[1:5])
+	if n.Length == 96 {
+		n.RouteTarget = parseExtended(data[5:13])
+	} else if n.Length > 32 {
+		buf := make([]byte, 8)
+		copy(buf, data[5:n.Len()])
+		if r := (n.Length - 32) % 8; r != 0 {
+			buf[(n.Length-32)/8] &= 0xff << (8 - r)
+		}
+		n.RouteTarget = &UnknownExtended{Type: BGPAttrType(buf[0]), Value: buf[1:]}
+	}
+	return nil
+}
+
+func (n *RouteTargetMembershipNLRI) Serialize() ([]byte, error) {
+	buf := make([]byte, 1, n.Len())
+	buf[0] = n.Length
+	if n.Length == 0 {
+		return buf, nil
+	}
+	buf = buf[:5]
+	binary.BigEndian.PutUint32(buf[1:], n.AS)
+	if n.Length > 32 {
+		ebuf, err := n.RouteTarget.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, ebuf[:n.Len()-5]...)
+	}
+	return buf, nil
+}
+
+func (n *RouteTargetMembershipNLRI) AFI() uint16 {
+	return AFI_IP
+}
+
+func (n *RouteTargetMembershipNLRI) SAFI() uint8 {
+	return SAFI_ROUTE_TARGET_CONSTRTAINS
+}
+
+func (n *RouteTargetMembershipNLRI) Len() int {
+	return 1 + (int(n.Length)+7)/8
+}
+
+func (n *RouteTargetMembershipNLRI) String() string {
+	switch {
+	case n.Length == 0:
+		return "default"
+	case n.Length == 32:
+		return fmt.Sprintf("%d:*", n.AS)
+	case n.Length < 96:
+		return fmt.Sprintf("%d:%s/%d", n.AS, n.RouteTarget, n.Length)
+	}
+	return fmt.Sprintf("%d:%s", n.AS, n.RouteTarget)
+}
+
+// NewRouteTargetMembershipNLRI returns the membership of a whole route
+// target, the default membership if target is nil.
+func NewRouteTargetMembershipNLRI(as uint32, target ExtendedCommunityInterface) *RouteTargetMembershipNLRI {
+	n := &RouteTargetMembershipNLRI{
+		AS:          as,
+		RouteTarget: target,
+	}
+	if target != nil {
+		n.Length = 96
+	}
+	return n
+}
+
+// EthernetSegmentIdentifier is the ESI of RFC 7432 section 5, the zero
+// value identifies a single-homed site.
+type EthernetSegmentIdentifier struct {
+	Type  uint8
+	Value []byte
+}
+
+func (esi *EthernetSegmentIdentifier) DecodeFromBytes(data []byte) error {
+	if len(data) < 10 {
+		return fmt.Errorf("ethernet segment identifier is short")
+	}
+	esi.Type = data[0]
+	esi.Value = data[1:10]
+	return nil
+}
+
+func (esi *EthernetSegmentIdentifier) Serialize() ([]byte, error) {
+	buf := make([]byte, 10)
+	buf[0] = esi.Type
+	copy(buf[1:], esi.Value)
+	return buf, nil
+}
+
+func (esi *EthernetSegmentIdentifier) String() string {
+	buf, _ := esi.Serialize()
+	s := make([]string, 0, len(buf))
+	for _, b := range buf {
+		s = append(s, fmt.Sprintf("%02x", b))
+	}
+	return strings.Join(s, ":")
+}
+
+// ParseEthernetSegmentIdentifier parses the ten colon separated hex
+// bytes returned by the String method, the empty string is the zero
+// ESI.
+func ParseEthernetSegmentIdentifier(s string) (*EthernetSegmentIdentifier, error) {
+	if s == "" {
+		return &EthernetSegmentIdentifier{Value: make([]byte, 9)}, nil
+	}
+	elems := strings.Split(s, ":")
+	if len(elems) != 10 {
+		return nil, fmt.Errorf("invalid ethernet segment identifier %s", s)
+	}
+	buf := make([]byte, 10)
+	for i, e := range elems {
+		b, err := strconv.ParseUint(e, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ethernet segment identifier %s", s)
+		}
+		buf[i] = byte(b)
+	}
+	esi := &EthernetSegmentIdentifier{}
+	esi.DecodeFromBytes(buf)
+	return esi, nil
+}
+
+const (
+	EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT  = 2
+	EVPN_INCLUSIVE_MULTICAST_ETHERNET_TAG = 3
+	EVPN_IP_PREFIX                        = 5
+)
+
+// the labels of the EVPN routes are 24 bit fields carrying the MPLS
+// label or, with the VXLAN encapsulation, the VNI (RFC 8365)
+func decodeEVPNLabel(data []byte) uint32 {
+	return uint32(data[0])<<16 | uint32(data[1])<<8 | uint32(data[2])
+}
+
+func serializeEVPNLabel(label uint32) []byte {
+	return []byte{byte(label >> 16), byte(label >> 8), byte(label)}
+}
+
+// the IP addresses of the EVPN routes are preceded by their length in
+// bits, zero for none
+func decodeEVPNIP(data []byte) (net.IP, int, error) {
+	if len(data) < 1 {
+		return nil, 0, fmt.Errorf("ip address length is missing")
+	}
+	switch data[0] {
+	case 0:
+		return nil, 1, nil
+	case 32, 128:
+		l := int(data[0]) / 8
+		if len(data) < 1+l {
+			return nil, 0, fmt.Errorf("ip address is short")
+		}
+		return net.IP(data[1 : 1+l]), 1 + l, nil
+	}
+	return nil, 0, fmt.Errorf("invalid ip address length %d", data[0])
+}
+
+func serializeEVPNIP(ip net.IP) []byte {
+	if ip == nil {
+		return []byte{0}
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return append([]byte{32}, ip4...)
+	}
+	return append([]byte{128}, ip.To16()...)
+}
+
+type EVPNRouteTypeInterface interface {
+	DecodeFromBytes([]byte) error
+	Serialize() ([]byte, error)
+	String() string
+}
+
+// EVPNMacIPAdvertisementRoute is the route type 2 advertising the MAC
+// address, and optionally the IP address, of a host.
+type EVPNMacIPAdvertisementRoute struct {
+	RD         RouteDistinguisherInterface
+	ESI        EthernetSegmentIdentifier
+	ETag       uint32
+	MacAddress net.HardwareAddr
+	IPAddress  net.IP
+	Labels     []uint32
+}
+
+func (er *EVPNMacIPAdvertisementRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 33 {
+		return fmt.Errorf("mac/ip advertisement route is short")
+	}
+	er.RD = getRouteDistinguisher(data)
+	er.ESI.DecodeFromBytes(data[8:18])
+	er.ETag = binary.BigEndian.Uint32(data[18:22])
+	if data[22] != 48 {
+		return fmt.Errorf("invalid mac address length %d", data[22])
+	}
+	er.MacAddress = net.HardwareAddr(data[23:29])
+	ip, l, err := decodeEVPNIP(data[29:])
+	if err != nil {
+		return err
+	}
+	er.IPAddress = ip
+	data = data[29+l:]
+	if len(data) != 3 && len(data) != 6 {
+		return fmt.Errorf("mac/ip advertisement route labels are incorrect")
+	}
+	er.Labels = []uint32{}
+	for ; len(data) > 0; data = data[3:] {
+		er.Labels = append(er.Labels, decodeEVPNLabel(data))
+	}
+	return nil
+}
+
+func (er *EVPNMacIPAdvertisementRoute) Serialize() ([]byte, error) {
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	ebuf, _ := er.ESI.Serialize()
+	buf = append(buf, ebuf...)
+	tbuf := make([]byte, 5)
+	binary.BigEndian.PutUint32(tbuf, er.ETag)
+	tbuf[4] = 48
+	buf = append(buf, tbuf...)
+	buf = append(buf, er.MacAddress...)
+	buf = append(buf, serializeEVPNIP(er.IPAddress)...)
+	for _, l := range er.Labels {
+		buf = append(buf, serializeEVPNLabel(l)...)
+	}
+	return buf, nil
+}
+
+// the route key, the ESI and the labels aren't part of it
+func (er *EVPNMacIPAdvertisementRoute) String() string {
+	s := fmt.Sprintf("[type:macadv][rd:%s][etag:%d][mac:%s]", er.RD, er.ETag, er.MacAddress)
+	if er.IPAddress != nil {
+		s += fmt.Sprintf("[ip:%s]", er.IPAddress)
+	}
+	return s
+}
+
+// EVPNMulticastEthernetTagRoute is the route type 3 announcing the
+// originating router as a receiver of the broadcast, unknown unicast
+// and multicast traffic of the Ethernet tag.
+type EVPNMulticastEthernetTagRoute struct {
+	RD        RouteDistinguisherInterface
+	ETag      uint32
+	IPAddress net.IP
+}
+
+func (er *EVPNMulticastEthernetTagRoute) DecodeFromBytes(data []byte) error {
+	if len(data) < 13 {
+		return fmt.Errorf("inclusive multicast ethernet tag route is short")
+	}
+	er.RD = getRouteDistinguisher(data)
+	er.ETag = binary.BigEndian.Uint32(data[8:12])
+	ip, l, err := decodeEVPNIP(data[12:])
+	if err != nil {
+		return err
+	}
+	if ip == nil || 12+l != len(data) {
+		return fmt.Errorf("inclusive multicast ethernet tag route length is incorrect")
+	}
+	er.IPAddress = ip
+	return nil
+}
+
+func (er *EVPNMulticastEthernetTagRoute) Serialize() ([]byte, error) {
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	tbuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(tbuf, er.ETag)
+	buf = append(buf, tbuf...)
+	return append(buf, serializeEVPNIP(er.IPAddress)...), nil
+}
+
+func (er *EVPNMulticastEthernetTagRoute) String() string {
+	return fmt.Sprintf("[type:multicast][rd:%s][etag:%d][ip:%s]", er.RD, er.ETag, er.IPAddress)
+}
+
+// EVPNIPPrefixRoute is the route type 5 of RFC 9136 advertising an IP
+// prefix, the gateway address is 0.0.0.0 or :: if there is none.
+type EVPNIPPrefixRoute struct {
+	RD             RouteDistinguisherInterface
+	ESI            EthernetSegmentIdentifier
+	ETag           uint32
+	IPPrefixLength uint8
+	IPPrefix       net.IP
+	GWIPAddress    net.IP
+	Label          uint32
+}
+
+func (er *EVPNIPPrefixRoute) DecodeFromBytes(data []byte) error {
+	addrlen := 0
+	switch len(data) {
+	case 34:
+		addrlen = 4
+	case 58:
+		addrlen = 16
+	default:
+		return fmt.Errorf("ip prefix route length %d is incorrect", len(data))
+	}
+	er.RD = getRouteDistinguisher(data)
+	er.ESI.DecodeFromBytes(data[8:18])
+	er.ETag = binary.BigEndian.Uint32(data[18:22])
+	er.IPPrefixLength = data[22]
+	if int(er.IPPrefixLength) > 8*addrlen {
+		return fmt.Errorf("invalid ip prefix length %d", er.IPPrefixLength)
+	}
+	data = data[23:]
+	er.IPPrefix = net.IP(data[:addrlen])
+	er.GWIPAddress = net.IP(data[addrlen : 2*addrlen])
+	er.Label = decodeEVPNLabel(data[2*addrlen:])
+	return nil
+}
+
+func (er *EVPNIPPrefixRoute) Serialize() ([]byte, error) {
+	buf, err := er.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	ebuf, _ := er.ESI.Serialize()
+	buf = append(buf, ebuf...)
+	tbuf := make([]byte, 5)
+	binary.BigEndian.PutUint32(tbuf, er.ETag)
+	tbuf[4] = er.IPPrefixLength
+	buf = append(buf, tbuf...)
+	if prefix := er.IPPrefix.To4(); prefix != nil {
+		buf = append(buf, prefix...)
+		gw := er.GWIPAddress.To4()
+		if gw == nil {
+			gw = net.IPv4zero.To4()
+		}
+		buf = append(buf, gw...)
+	} else {
+		buf = append(buf, er.IPPrefix.To16()...)
+		gw := er.GWIPAddress.To16()
+		if gw == nil {
+			gw = net.IPv6unspecified
+		}
+		buf = append(buf, gw...)
+	}
+	return append(buf, serializeEVPNLabel(er.Label)...), nil
+}
+
+func (er *EVPNIPPrefixRoute) String() string {
+	return fmt.Sprintf("[type:prefix][rd:%s][etag:%d][prefix:%s/%d]", er.RD, er.ETag, er.IPPrefix, er.IPPrefixLength)
+}
+
+// EVPNUnknownRoute is a route type other than 2, 3 and 5. RFC 7432
+// requires the routes of an unknown type to be ignored, the route is kept
+// opaque so that the NLRI following it can be decoded.
+type EVPNUnknownRoute struct {
+	Value []byte
+}
+
+func (er *EVPNUnknownRoute) DecodeFromBytes(data []byte) error {
+	er.Value = data
+	return nil
+}
+
+func (er *EVPNUnknownRoute) Serialize() ([]byte, error) {
+	return er.Value, nil
+}
+
+func (er *EVPNUnknownRoute) String() string {
+	return fmt.Sprintf("[type:unknown][len:%d]", len(er.Value))
+}
+
+// EVPNNLRI is the NLRI of the L2VPN EVPN route family (RFC 7432), the
+// route types other than 2, 3 and 5 are decoded as an EVPNUnknownRoute.
+type EVPNNLRI struct {
+	RouteType     uint8
+	Length        uint8
+	RouteTypeData EVPNRouteTypeInterface
+}
+
+func (n *EVPNNLRI) DecodeFromBytes(data []byte) error {
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
+	if len(data) < 2 {
+		return NewMessageError(eCode, eSubCode, nil, "evpn nlri is short")
+	}
+	n.RouteType = data[0]
+	n.Length = data[1]
+	data = data[2:]
+	if len(data) < int(n.Length) {
+		return NewMessageError(eCode, eSubCode, nil, "evpn nlri length is incorrect")
+	}
+	switch n.RouteType {
+	case EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT:
+		n.RouteTypeData = &EVPNMacIPAdvertisementRoute{}
+	case EVPN_INCLUSIVE_MULTICAST_ETHERNET_TAG:
+		n.RouteTypeData = &EVPNMulticastEthernetTagRoute{}
+	case EVPN_IP_PREFIX:
+		n.RouteTypeData = &EVPNIPPrefixRoute{}
+	default:
+		n.RouteTypeData = &EVPNUnknownRoute{}
+	}
+	if err := n.RouteTypeData.DecodeFromBytes(data[:n.Length]); err != nil {
+		return NewMessageError(eCode, eSubCode, nil, err.Error())
+	}
+	return nil
+}
+
+func (n *EVPNNLRI) Serialize() ([]byte, error) {
+	buf, err := n.RouteTypeData.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	n.Length = uint8(len(buf))
+	return append([]byte{n.RouteType, n.Length}, buf...), nil
+}
+
+func (n *EVPNNLRI) AFI() uint16 {
+	return AFI_L2VPN
+}
+
+func (n *EVPNNLRI) SAFI() uint8 {
+	return SAFI_EVPN
+}
+
+func (n *EVPNNLRI) Len() int {
+	return int(n.Length) + 2
+}
+
+func (n *EVPNNLRI) String() string {
+	return n.RouteTypeData.String()
+}
+
+func NewEVPNNLRI(routeType uint8, routeTypeData EVPNRouteTypeInterface) *EVPNNLRI {
+	n := &EVPNNLRI{
+		RouteType:     routeType,
+		RouteTypeData: routeTypeData,
+	}
+	buf, _ := routeTypeData.Serialize()
+	n.Length = uint8(len(buf))
+	return n
+}
+
+// VPLSNLRI is the NLRI of the L2VPN VPLS route family (RFC 4761), it
+// advertises a block of BlockSize labels starting at LabelBase: the site
+// VEID of the VPLS sends its traffic to the advertising site with the
+// label LabelBase + VEID - BlockOffset.
+type VPLSNLRI struct {
+	RD          RouteDistinguisherInterface
+	VEID        uint16
+	BlockOffset uint16
+	BlockSize   uint16
+	LabelBase   uint32
+}
+
+const vplsNLRILength = 17
+
+func (n *VPLSNLRI) DecodeFromBytes(data []byte) error {
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
+	if len(data) < 2 {
+		return NewMessageError(eCode, eSubCode, nil, "vpls nlri is short")
+	}
+	if binary.BigEndian.Uint16(data[0:2]) != vplsNLRILength || len(data) < vplsNLRILength+2 {
+		return NewMessageError(eCode, eSubCode, nil, "vpls nlri length is incorrect")
+	}
+	data = data[2:]
+	n.RD = getRouteDistinguisher(data)
+	n.VEID = binary.BigEndian.Uint16(data[8:10])
+	n.BlockOffset = binary.BigEndian.Uint16(data[10:12])
+	n.BlockSize = binary.BigEndian.Uint16(data[12:14])
+	// the label base is a 20 bit label followed by the bottom of stack bit
+	n.LabelBase = decodeEVPNLabel(data[14:17]) >> 4
+	return nil
+}
+
+func (n *VPLSNLRI) Serialize() ([]byte, error) {
+	buf := make([]byte, 2, vplsNLRILength+2)
+	binary.BigEndian.PutUint16(buf, vplsNLRILength)
+	rbuf, err := n.RD.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, rbuf...)
+	vbuf := make([]byte, 6)
+	binary.BigEndian.PutUint16(vbuf[0:2], n.VEID)
+	binary.BigEndian.PutUint16(vbuf[2:4], n.BlockOffset)
+	binary.BigEndian.PutUint16(vbuf[4:6], n.BlockSize)
+	buf = append(buf, vbuf...)
+	return append(buf, serializeEVPNLabel(n.LabelBase<<4|1)...), nil
+}
+
+func (n *VPLSNLRI) AFI() uint16 {
+	return AFI_L2VPN
+}
+
+func (n *VPLSNLRI) SAFI() uint8 {
+	return SAFI_VPLS
+}
+
+func (n *VPLSNLRI) Len() int {
+	return vplsNLRILength + 2
+}
+
+// the route key, the block size and the label base aren't part of it
+func (n *VPLSNLRI) String() string {
+	return fmt.Sprintf("[rd:%s][ve-id:%d][block-offset:%d]", n.RD, n.VEID, n.BlockOffset)
+}
+
+func NewVPLSNLRI(rd RouteDistinguisherInterface, veID, blockOffset, blockSize uint16, labelBase uint32) *VPLSNLRI {
+	return &VPLSNLRI{
+		RD:          rd,
+		VEID:        veID,
+		BlockOffset: blockOffset,
+		BlockSize:   blockSize,
+		LabelBase:   labelBase,
+	}
+}
+
+// FlowSpec component types, RFC 8955 and RFC 8956
+const (
+	FLOW_SPEC_TYPE_DST_PREFIX = 1
+	FLOW_SPEC_TYPE_SRC_PREFIX = 2
+	// the next header of IPv6
+	FLOW_SPEC_TYPE_IP_PROTO   = 3
+	FLOW_SPEC_TYPE_PORT       = 4
+	FLOW_SPEC_TYPE_DST_PORT   = 5
+	FLOW_SPEC_TYPE_SRC_PORT   = 6
+	FLOW_SPEC_TYPE_ICMP_TYPE  = 7
+	FLOW_SPEC_TYPE_ICMP_CODE  = 8
+	FLOW_SPEC_TYPE_TCP_FLAG   = 9
+	FLOW_SPEC_TYPE_PKT_LEN    = 10
+	FLOW_SPEC_TYPE_DSCP       = 11
+	FLOW_SPEC_TYPE_FRAGMENT   = 12
+	FLOW_SPEC_TYPE_FLOW_LABEL = 13
+)
+
+var flowSpecTypeNames = map[uint8]string{
+	FLOW_SPEC_TYPE_DST_PREFIX: "destination",
+	FLOW_SPEC_TYPE_SRC_PREFIX: "source",
+	FLOW_SPEC_TYPE_IP_PROTO:   "protocol",
+	FLOW_SPEC_TYPE_PORT:       "port",
+	FLOW_SPEC_TYPE_DST_PORT:   "destination-port",
+	FLOW_SPEC_TYPE_SRC_PORT:   "source-port",
+	FLOW_SPEC_TYPE_ICMP_TYPE:  "icmp-type",
+	FLOW_SPEC_TYPE_ICMP_CODE:  "icmp-code",
+	FLOW_SPEC_TYPE_TCP_FLAG:   "tcp-flags",
+	FLOW_SPEC_TYPE_PKT_LEN:    "packet-length",
+	FLOW_SPEC_TYPE_DSCP:       "dscp",
+	FLOW_SPEC_TYPE_FRAGMENT:   "fragment",
+	FLOW_SPEC_TYPE_FLOW_LABEL: "flow-label",
+}
+
+// bits of the operator byte of the numeric and bitmask components
+const (
+	FLOW_SPEC_OP_END      = 0x80
+	FLOW_SPEC_OP_AND      = 0x40
+	FLOW_SPEC_OP_LEN_MASK = 0x30
+	// numeric operators
+	FLOW_SPEC_OP_LT = 0x04
+	FLOW_SPEC_OP_GT = 0x02
+	FLOW_SPEC_OP_EQ = 0x01
+	// bitmask operators
+	FLOW_SPEC_OP_NOT   = 0x02
+	FLOW_SPEC_OP_MATCH = 0x01
+)
+
+var flowSpecNumericOpNames = map[uint8]string{
+	0:                                 "false",
+	FLOW_SPEC_OP_EQ:                   "==",
+	FLOW_SPEC_OP_GT:                   ">",
+	FLOW_SPEC_OP_GT | FLOW_SPEC_OP_EQ: ">=",
+	FLOW_SPEC_OP_LT:                   "<",
+	FLOW_SPEC_OP_LT | FLOW_SPEC_OP_EQ: "<=",
+	FLOW_SPEC_OP_LT | FLOW_SPEC_OP_GT: "!=",
+	FLOW_SPEC_OP_LT | FLOW_SPEC_OP_GT | FLOW_SPEC_OP_EQ: "true",
+}
+
+var flowSpecTcpFlagNames = map[uint64]string{
+	0x01: "fin",
+	0x02: "syn",
+	0x04: "rst",
+	0x08: "push",
+	0x10: "ack",
+	0x20: "urgent",
+	0x40: "ece",
+	0x80: "cwr",
+}
+
+var flowSpecFragmentNames = map[uint64]string{
+	0x01: "dont-fragment",
+	0x02: "is-fragment",
+	0x04: "first-fragment",
+	0x08: "last-fragment",
+}
+
+var flowSpecProtocolNames = map[uint64]string{
+	1:  "icmp",
+	6:  "tcp",
+	17: "udp",
+	47: "gre",
+	58: "icmpv6",
+}
+
+type FlowSpecComponentInterface interface {
+	DecodeFromBytes(data []byte) error
+	Serialize() ([]byte, error)
+	Len() int
+	Type() uint8
+	String() string
+}
+
+// FlowSpecPrefix is the destination or source prefix component. The
+// IPv6 prefixes (RFC 8956) match the bits from Offset to PrefixLength
+// of the address, only these bits are encoded.
+type FlowSpecPrefix struct {
+	ComponentType uint8
+	AFI           uint16
+	PrefixLength  uint8
+	Offset        uint8
+	Prefix        net.IP
+}
+
+// shiftBitsLeft returns the bits of buf from offset, padded with zeroes.
+func shiftBitsLeft(buf []byte, offset int) []byte {
+	shifted := make([]byte, len(buf))
+	for i := range shifted {
+		bit := i*8 + offset
+		if bit/8 < len(buf) {
+			shifted[i] = buf[bit/8] << uint(bit%8)
+		}
+		if bit%8 != 0 && bit/8+1 < len(buf) {
+			shifted[i] |= buf[bit/8+1] >> uint(8-bit%8)
+		}
+	}
+	return shifted
+}
+
+// shiftBitsRight returns the bits of buf moved to offset in a buffer of
+// size bytes.
+func shiftBitsRight(buf []byte, offset int, size int) []byte {
+	shifted := make([]byte, size)
+	for i, b := range buf {
+		bit := i*8 + offset
+		if bit/8 < size {
+			shifted[bit/8] |= b >> uint(bit%8)
+		}
+		if bit%8 != 0 && bit/8+1 < size {
+			shifted[bit/8+1] |= b << uint(8-bit%8)
+		}
+	}
+	return shifted
+}
+
+func (p *FlowSpecPrefix) DecodeFromBytes(data []byte) error {
+	hlen := 2
+	if p.AFI == AFI_IP6 {
+		hlen = 3
+	}
+	if len(data) < hlen {
+		return fmt.Errorf("flowspec prefix component is short")
+	}
+	p.ComponentType = data[0]
+	p.PrefixLength = data[1]
+	addrlen := 4
+	if p.AFI == AFI_IP6 {
+		p.Offset = data[2]
+		addrlen = 16
+	}
+	if int(p.PrefixLength) > addrlen*8 || p.Offset > p.PrefixLength {
+		return fmt.Errorf("invalid flowspec prefix length %d", p.PrefixLength)
+	}
+	plen := (int(p.PrefixLength-p.Offset) + 7) / 8
+	if len(data) < hlen+plen {
+		return fmt.Errorf("flowspec prefix component is short")
+	}
+	p.Prefix = shiftBitsRight(data[hlen:hlen+plen], int(p.Offset), addrlen)
+	return nil
+}
+
+func (p *FlowSpecPrefix) Serialize() ([]byte, error) {
+	buf := []byte{p.ComponentType, p.PrefixLength}
+	prefix := p.Prefix.To4()
+	if p.AFI == AFI_IP6 {
+		buf = append(buf, p.Offset)
+		prefix = p.Prefix.To16()
+	}
+	if prefix == nil || int(p.PrefixLength) > len(prefix)*8 || p.Offset > p.PrefixLength {
+		return nil, fmt.Errorf("invalid flowspec prefix %s/%d", p.Prefix, p.PrefixLength)
+	}
+	plen := (int(p.PrefixLength-p.Offset) + 7) / 8
+	pattern := shiftBitsLeft(prefix, int(p.Offset))[:plen]
+	// clear the bits after the prefix length
+	if bits := int(p.PrefixLength-p.Offset) % 8; bits != 0 {
+		pattern[plen-1] &= 0xff << uint(8-bits)
+	}
+	return append(buf, pattern...), nil
+}
+
+func (p *FlowSpecPrefix) Len() int {
+	hlen := 2
+	if p.AFI == AFI_IP6 {
+		hlen = 3
+	}
+	return hlen + (int(p.PrefixLength-p.Offset)+7)/8
+}
+
+func (p *FlowSpecPrefix) Type() uint8 {
+	return p.ComponentType
+}
+
+// String returns "destination 10.0.0.0/24", the IPv6 prefixes with an
+// offset are "source ::1:0:0:0/64/32".
+func (p *FlowSpecPrefix) String() string {
+	if p.Offset != 0 {
+		return fmt.Sprintf("%s %s/%d/%d", flowSpecTypeNames[p.ComponentType], p.Prefix, p.PrefixLength, p.Offset)
+	}
+	return fmt.Sprintf("%s %s/%d", flowSpecTypeNames[p.ComponentType], p.Prefix, p.PrefixLength)
+}
+
+func NewFlowSpecPrefix(componentType uint8, afi uint16, length uint8, offset uint8, prefix string) *FlowSpecPrefix {
+	return &FlowSpecPrefix{
+		ComponentType: componentType,
+		AFI:           afi,
+		PrefixLength:  length,
+		Offset:        offset,
+		Prefix:        net.ParseIP(prefix),
+	}
+}
+
+// FlowSpecComponentItem is an operator and value pair, the end of list
+// and length bits of Op are set when it is serialized.
+type FlowSpecComponentItem struct {
+	Op    uint8
+	Value uint64
+}
+
+func (i *FlowSpecComponentItem) valueLength() int {
+	length := 1 << ((i.Op & FLOW_SPEC_OP_LEN_MASK) >> 4)
+	for length < 8 && i.Value >= 1<<uint(length*8) {
+		length *= 2
+	}
+	return length
+}
+
+func (i *FlowSpecComponentItem) serialize(end bool) []byte {
+	length := i.valueLength()
+	op := i.Op &^ (FLOW_SPEC_OP_END | FLOW_SPEC_OP_LEN_MASK)
+	switch length {
+	case 2:
+		op |= 0x10
+	case 4:
+		op |= 0x20
+	case 8:
+		op |= 0x30
+	}
+	if end {
+		op |= FLOW_SPEC_OP_END
+	}
+	buf := make([]byte, 9)
+	buf[0] = op
+	binary.BigEndian.PutUint64(buf[1:], i.Value)
+	return append(buf[:1], buf[9-length:]...)
+}
+
+func isFlowSpecBitmask(componentType uint8) bool {
+	return componentType == FLOW_SPEC_TYPE_TCP_FLAG || componentType == FLOW_SPEC_TYPE_FRAGMENT
+}
+
+func flowSpecBitmaskNames(componentType uint8) map[uint64]string {
+	if componentType == FLOW_SPEC_TYPE_TCP_FLAG {
+		return flowSpecTcpFlagNames
+	}
+	return flowSpecFragmentNames
+}
+
+func (i *FlowSpecComponentItem) string(componentType uint8) string {
+	if isFlowSpecBitmask(componentType) {
+		s := ""
+		if i.Op&FLOW_SPEC_OP_NOT != 0 {
+			s += "!"
+		}
+		if i.Op&FLOW_SPEC_OP_MATCH != 0 {
+			s += "="
+		}
+		names := flowSpecBitmaskNames(componentType)
+		flags := make([]string, 0)
+		for bit := uint64(1); bit <= i.Value && bit != 0; bit <<= 1 {
+			if i.Value&bit == 0 {
+				continue
+			}
+			name, found := names[bit]
+			if !found {
+				return s + strconv.FormatUint(i.Value, 10)
+			}
+			flags = append(flags, name)
+		}
+		if len(flags) == 0 {
+			return s + "0"
+		}
+		return s + strings.Join(flags, "|")
+	}
+	op := flowSpecNumericOpNames[i.Op&(FLOW_SPEC_OP_LT|FLOW_SPEC_OP_GT|FLOW_SPEC_OP_EQ)]
+	if op == "true" || op == "false" {
+		return op
+	}
+	if name, found := flowSpecProtocolNames[i.Value]; found && componentType == FLOW_SPEC_TYPE_IP_PROTO {
+		return op + name
+	}
+	return op + strconv.FormatUint(i.Value, 10)
+}
+
+// FlowSpecComponent is a component matching numeric values, such as the
+// ports, or bitmasks, the TCP flags and the fragment bits, with a list
+// of items. The items are ORed, an item with the AND bit is ANDed with
+// the previous one.
+type FlowSpecComponent struct {
+	ComponentType uint8
+	Items         []*FlowSpecComponentItem
+}
+
+func (c *FlowSpecComponent) DecodeFromBytes(data []byte) error {
+	if len(data) < 1 {
+		return fmt.Errorf("flowspec component is short")
+	}
+	c.ComponentType = data[0]
+	data = data[1:]
+	c.Items = make([]*FlowSpecComponentItem, 0)
+	for {
+		if len(data) < 1 {
+			return fmt.Errorf("flowspec component %d has no end of list", c.ComponentType)
+		}
+		op := data[0]
+		length := 1 << ((op & FLOW_SPEC_OP_LEN_MASK) >> 4)
+		if len(data) < 1+length {
+			return fmt.Errorf("flowspec component %d is short", c.ComponentType)
+		}
+		buf := make([]byte, 8)
+		copy(buf[8-length:], data[1:1+length])
+		c.Items = append(c.Items, &FlowSpecComponentItem{
+			Op:    op &^ FLOW_SPEC_OP_END,
+			Value: binary.BigEndian.Uint64(buf),
+		})
+		data = data[1+length:]
+		if op&FLOW_SPEC_OP_END != 0 {
+			return nil
+		}
+	}
+}
+
+func (c *FlowSpecComponent) Serialize() ([]byte, error) {
+	if len(c.Items) == 0 {
+		return nil, fmt.Errorf("flowspec component %d has no item", c.ComponentType)
+	}
+	buf := []byte{c.ComponentType}
+	for i, item := range c.Items {
+		buf = append(buf, item.serialize(i == len(c.Items)-1)...)
+	}
+	return buf, nil
+}
+
+func (c *FlowSpecComponent) Len() int {
+	length := 1
+	for _, item := range c.Items {
+		length += 1 + item.valueLength()
+	}
+	return length
+}
+
+func (c *FlowSpecComponent) Type() uint8 {
+	return c.ComponentType
+}
+
+// String returns the items after the component name, the ANDed items
+// are joined with "&": "destination-port >=1024&<=2048 ==80".
+func (c *FlowSpecComponent) String() string {
+	s := flowSpecTypeNames[c.ComponentType]
+	for i, item := range c.Items {
+		if i > 0 && item.Op&FLOW_SPEC_OP_AND != 0 {
+			s += "&"
+		} else {
+			s += " "
+		}
+		s += item.string(c.ComponentType)
+	}
+	return s
+}
+
+func NewFlowSpecComponent(componentType uint8, items []*FlowSpecComponentItem) *FlowSpecComponent {
+	return &FlowSpecComponent{
+		ComponentType: componentType,
+		Items:         items,
+	}
+}
+
+// FlowSpecNLRI is the list of components of a flow specification, in
+// increasing component type order.
+type FlowSpecNLRI struct {
+	Value []FlowSpecComponentInterface
+	afi   uint16
+}
+
+func (n *FlowSpecNLRI) DecodeFromBytes(data []byte) error {
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
+	if len(data) < 1 {
+		return NewMessageError(eCode, eSubCode, nil, "flowspec nlri is short")
+	}
+	length := int(data[0])
+	data = data[1:]
+	if length >= 0xf0 {
+		if len(data) < 1 {
+			return NewMessageError(eCode, eSubCode, nil, "flowspec nlri is short")
+		}
+		length = (length&0x0f)<<8 | int(data[0])
+		data = data[1:]
+	}
+	if len(data) < length {
+		return NewMessageError(eCode, eSubCode, nil, "flowspec nlri length is incorrect")
+	}
+	data = data[:length]
+	n.Value = make([]FlowSpecComponentInterface, 0)
+	for len(data) > 0 {
+		t := data[0]
+		if len(n.Value) > 0 && t <= n.Value[len(n.Value)-1].Type() {
+			return NewMessageError(eCode, eSubCode, nil, "flowspec components are not in increasing type order")
+		}
+		var c FlowSpecComponentInterface
+		switch {
+		case t == FLOW_SPEC_TYPE_DST_PREFIX || t == FLOW_SPEC_TYPE_SRC_PREFIX:
+			c = &FlowSpecPrefix{AFI: n.afi}
+		case t == FLOW_SPEC_TYPE_FLOW_LABEL && n.afi != AFI_IP6:
+			return NewMessageError(eCode, eSubCode, nil, "flow label component in ipv4 flowspec")
+		case t >= FLOW_SPEC_TYPE_IP_PROTO && t <= FLOW_SPEC_TYPE_FLOW_LABEL:
+			c = &FlowSpecComponent{}
+		default:
+			return NewMessageError(eCode, eSubCode, nil, fmt.Sprintf("unknown flowspec component type %d", t))
+		}
+		if err := c.DecodeFromBytes(data); err != nil {
+			return NewMessageError(eCode, eSubCode, nil, err.Error())
+		}
+		n.Value = append(n.Value, c)
+		data = data[c.Len():]
+	}
+	return nil
+}
+
+func (n *FlowSpecNLRI) Serialize() ([]byte, error) {
+	buf := make([]byte, 0)
+	for _, c := range n.Value {
+		cbuf, err := c.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, cbuf...)
+	}
+	switch length := len(buf); {
+	case length < 0xf0:
+		return append([]byte{uint8(length)}, buf...), nil
+	case length <= 0xfff:
+		return append([]byte{0xf0 | uint8(length>>8), uint8(length)}, buf...), nil
+	}
+	return nil, fmt.Errorf("flowspec nlri is too long")
+}
+
+func (n *FlowSpecNLRI) AFI() uint16 {
+	return n.afi
+}
+
+func (n *FlowSpecNLRI) SAFI() uint8 {
+	return SAFI_FLOW_SPEC_UNICAST
+}
+
+func (n *FlowSpecNLRI) Len() int {
+	length := 0
+	for _, c := range n.Value {
+		length += c.Len()
+	}
+	if length < 0xf0 {
+		return length + 1
+	}
+	return length + 2
+}
+
+// String returns the components in the text form of
+// ParseFlowSpecComponents.
+func (n *FlowSpecNLRI) String() string {
+	l := make([]string, 0, len(n.Value))
+	for _, c := range n.Value {
+		l = append(l, c.String())
+	}
+	return strings.Join(l, " ")
+}
+
+func NewFlowSpecIPv4Unicast(value []FlowSpecComponentInterface) *FlowSpecNLRI {
+	return &FlowSpecNLRI{Value: value, afi: AFI_IP}
+}
+
+func NewFlowSpecIPv6Unicast(value []FlowSpecComponentInterface) *FlowSpecNLRI {
+	return &FlowSpecNLRI{Value: value, afi: AFI_IP6}
+}
+
+func parseFlowSpecPrefix(componentType uint8, afi uint16, s string) (FlowSpecComponentInterface, error) {
+	elems := strings.Split(s, "/")
+	ip := net.ParseIP(elems[0])
+	if ip == nil || (ip.To4() != nil) != (afi == AFI_IP) || len(elems) > 3 || afi == AFI_IP && len(elems) > 2 {
+		return nil, fmt.Errorf("invalid flowspec prefix %s", s)
+	}
+	length, offset := uint64(len(ip.To16())*8), uint64(0)
+	if afi == AFI_IP {
+		length = 32
+	}
+	var err error
+	if len(elems) > 1 {
+		if length, err = strconv.ParseUint(elems[1], 10, 8); err != nil {
+			return nil, fmt.Errorf("invalid flowspec prefix %s", s)
+		}
+	}
+	if len(elems) > 2 {
+		if offset, err = strconv.ParseUint(elems[2], 10, 8); err != nil {
+			return nil, fmt.Errorf("invalid flowspec prefix %s", s)
+		}
+	}
+	p := NewFlowSpecPrefix(componentType, afi, uint8(length), uint8(offset), elems[0])
+	if _, err := p.Serialize(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseFlowSpecNumericItem(componentType uint8, s string) (*FlowSpecComponentItem, error) {
+	item := &FlowSpecComponentItem{Op: FLOW_SPEC_OP_EQ}
+	// the longest operators first
+	for _, op := range []uint8{FLOW_SPEC_OP_LT | FLOW_SPEC_OP_GT | FLOW_SPEC_OP_EQ, 0, FLOW_SPEC_OP_GT | FLOW_SPEC_OP_EQ,
+		FLOW_SPEC_OP_LT | FLOW_SPEC_OP_EQ, FLOW_SPEC_OP_LT | FLOW_SPEC_OP_GT, FLOW_SPEC_OP_EQ, FLOW_SPEC_OP_GT, FLOW_SPEC_OP_LT} {
+		if name := flowSpecNumericOpNames[op]; strings.HasPrefix(s, name) {
+			item.Op = op
+			s = s[len(name):]
+			break
+		}
+	}
+	if s == "" && (item.Op == 0 || item.Op == FLOW_SPEC_OP_LT|FLOW_SPEC_OP_GT|FLOW_SPEC_OP_EQ) {
+		return item, nil
+	}
+	for v, name := range flowSpecProtocolNames {
+		if name == strings.ToLower(s) && componentType == FLOW_SPEC_TYPE_IP_PROTO {
+			item.Value = v
+			return item, nil
+		}
+	}
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid flowspec value %s", s)
+	}
+	item.Value = v
+	return item, nil
+}
+
+func parseFlowSpecBitmaskItem(componentType uint8, s string) (*FlowSpecComponentItem, error) {
+	item := &FlowSpecComponentItem{}
+	if strings.HasPrefix(s, "!") {
+		item.Op |= FLOW_SPEC_OP_NOT
+		s = s[1:]
+	}
+	if strings.HasPrefix(s, "=") {
+		item.Op |= FLOW_SPEC_OP_MATCH
+		s = s[1:]
+	}
+	if v, err := strconv.ParseUint(s, 10, 16); err == nil {
+		item.Value = v
+		return item, nil
+	}
+	names := flowSpecBitmaskNames(componentType)
+	for _, flag := range strings.Split(s, "|") {
+		found := false
+		for bit, name := range names {
+			if name == strings.ToLower(flag) {
+				item.Value |= bit
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("invalid flowspec flag %s", flag)
+		}
+	}
+	return item, nil
+}
+
+type flowSpecComponents []FlowSpecComponentInterface
+
+func (l flowSpecComponents) Len() int           { return len(l) }
+func (l flowSpecComponents) Less(i, j int) bool { return l[i].Type() < l[j].Type() }
+func (l flowSpecComponents) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// ParseFlowSpecComponents parses the components of a flow specification
+// of the IPv4 or IPv6 FlowSpec route family, in the text form returned
+// by the String method of FlowSpecNLRI: the component names, each
+// followed by its prefix or items, such as "destination 10.0.0.0/24
+// protocol ==tcp destination-port >=1024&<=2048 ==80 tcp-flags =syn
+// fragment !is-fragment". An item without operator is an equality.
+func ParseFlowSpecComponents(rf RouteFamily, s string) ([]FlowSpecComponentInterface, error) {
+	afi, safi := RouteFamilyToAfiSafi(rf)
+	if safi != SAFI_FLOW_SPEC_UNICAST {
+		return nil, fmt.Errorf("%s is not a flowspec route family", rf)
+	}
+	l := make([]FlowSpecComponentInterface, 0)
+	var c *FlowSpecComponent
+	for _, field := range strings.Fields(s) {
+		var componentType uint8
+		for t, name := range flowSpecTypeNames {
+			if name == strings.ToLower(field) {
+				componentType = t
+			}
+		}
+		if componentType == FLOW_SPEC_TYPE_FLOW_LABEL && afi != AFI_IP6 {
+			return nil, fmt.Errorf("flow label component in ipv4 flowspec")
+		}
+		switch {
+		case componentType == FLOW_SPEC_TYPE_DST_PREFIX || componentType == FLOW_SPEC_TYPE_SRC_PREFIX:
+			l = append(l, &FlowSpecPrefix{ComponentType: componentType})
+			c = nil
+		case componentType != 0:
+			c = NewFlowSpecComponent(componentType, []*FlowSpecComponentItem{})
+			l = append(l, c)
+		case len(l) == 0:
+			return nil, fmt.Errorf("unknown flowspec component %s", field)
+		case c == nil:
+			p := l[len(l)-1].(*FlowSpecPrefix)
+			if p.Prefix != nil {
+				return nil, fmt.Errorf("unknown flowspec component %s", field)
+			}
+			prefix, err := parseFlowSpecPrefix(p.ComponentType, afi, field)
+			if err != nil {
+				return nil, err
+			}
+			l[len(l)-1] = prefix
+		default:
+			for i, v := range strings.Split(field, "&") {
+				var item *FlowSpecComponentItem
+				var err error
+				if isFlowSpecBitmask(c.ComponentType) {
+					item, err = parseFlowSpecBitmaskItem(c.ComponentType, v)
+				} else {
+					item, err = parseFlowSpecNumericItem(c.ComponentType, v)
+				}
+				if err != nil {
+					return nil, err
+				}
+				if i > 0 {
+					item.Op |= FLOW_SPEC_OP_AND
+				}
+				c.Items = append(c.Items, item)
+			}
+		}
+	}
+	if len(l) == 0 {
+		return nil, fmt.Errorf("empty flowspec")
+	}
+	sort.Stable(flowSpecComponents(l))
+	for i, c := range l {
+		if i > 0 && c.Type() == l[i-1].Type() {
+			return nil, fmt.Errorf("duplicate flowspec component %s", flowSpecTypeNames[c.Type()])
+		}
+		if _, err := c.Serialize(); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// compareFlowSpecPrefix compares the bits the two prefixes have in
+// common, the more specific prefix comes first if they are equal.
+func compareFlowSpecPrefix(p1, p2 *FlowSpecPrefix) int {
+	if p1.Offset != p2.Offset {
+		return int(p1.Offset) - int(p2.Offset)
+	}
+	common := p1.PrefixLength
+	if p2.PrefixLength < common {
+		common = p2.PrefixLength
+	}
+	b1, b2 := p1.Prefix.To16(), p2.Prefix.To16()
+	if p1.AFI == AFI_IP {
+		b1, b2 = p1.Prefix.To4(), p2.Prefix.To4()
+	}
+	for i := 0; i < int(common); i++ {
+		bit1 := b1[i/8] >> uint(7-i%8) & 1
+		bit2 := b2[i/8] >> uint(7-i%8) & 1
+		if bit1 != bit2 {
+			return int(bit1) - int(bit2)
+		}
+	}
+	return int(p2.PrefixLength) - int(p1.PrefixLength)
+}
+
+// CompareFlowSpecNLRI orders the flow specifications by precedence (RFC
+// 8955 section 5.1), it returns a negative value if n1 takes precedence
+// over n2. A component takes precedence over a component of a higher
+// type. The lower prefix takes precedence, or the more specific one if
+// they are equal, and the other components are compared by their
+// encoding, the longer one taking precedence if one is a prefix of the
+// other. The longer list of components takes precedence if all the
+// components of the shorter one are equal.
+func CompareFlowSpecNLRI(n1, n2 *FlowSpecNLRI) int {
+	for i := 0; i < len(n1.Value) && i < len(n2.Value); i++ {
+		c1, c2 := n1.Value[i], n2.Value[i]
+		if c1.Type() != c2.Type() {
+			return int(c1.Type()) - int(c2.Type())
+		}
+		p1, y1 := c1.(*FlowSpecPrefix)
+		p2, y2 := c2.(*FlowSpecPrefix)
+		if y1 && y2 {
+			if r := compareFlowSpecPrefix(p1, p2); r != 0 {
+				return r
+			}
+			continue
+		}
+		b1, _ := c1.Serialize()
+		b2, _ := c2.Serialize()
+		common := len(b1)
+		if len(b2) < common {
+			common = len(b2)
+		}
+		if r := bytes.Compare(b1[:common], b2[:common]); r != 0 {
+			return r
+		}
+		if len(b1) != len(b2) {
+			return len(b2) - len(b1)
+		}
+	}
+	return len(n2.Value) - len(n1.Value)
+}
+
+func rfshift(afi uint16, safi uint8) RouteFamily {
+	return RouteFamily(int(afi)<<16 | int(safi))
+}
+
+// BGP-LS NLRI types, RFC 7752
+const (
+	LS_NLRI_TYPE_NODE        = 1
+	LS_NLRI_TYPE_LINK        = 2
+	LS_NLRI_TYPE_PREFIX_IPV4 = 3
+	LS_NLRI_TYPE_PREFIX_IPV6 = 4
+)
+
+var lsNLRITypeNames = map[uint16]string{
+	LS_NLRI_TYPE_NODE:        "node",
+	LS_NLRI_TYPE_LINK:        "link",
+	LS_NLRI_TYPE_PREFIX_IPV4: "prefix",
+	LS_NLRI_TYPE_PREFIX_IPV6: "prefix",
+}
+
+// LsProtocolID is the source of the link-state information of a BGP-LS
+// NLRI.
+type LsProtocolID uint8
+
+const (
+	LS_PROTOCOL_ISIS_L1 LsProtocolID = 1
+	LS_PROTOCOL_ISIS_L2 LsProtocolID = 2
+	LS_PROTOCOL_OSPF_V2 LsProtocolID = 3
+	LS_PROTOCOL_DIRECT  LsProtocolID = 4
+	LS_PROTOCOL_STATIC  LsProtocolID = 5
+	LS_PROTOCOL_OSPF_V3 LsProtocolID = 6
+	LS_PROTOCOL_BGP     LsProtocolID = 7
+)
+
+var lsProtocolIDNames = map[LsProtocolID]string{
+	LS_PROTOCOL_ISIS_L1: "isis-l1",
+	LS_PROTOCOL_ISIS_L2: "isis-l2",
+	LS_PROTOCOL_OSPF_V2: "ospfv2",
+	LS_PROTOCOL_DIRECT:  "direct",
+	LS_PROTOCOL_STATIC:  "static",
+	LS_PROTOCOL_OSPF_V3: "ospfv3",
+	LS_PROTOCOL_BGP:     "bgp",
+}
+
+func (id LsProtocolID) String() string {
+	if name, found := lsProtocolIDNames[id]; found {
+		return name
+	}
+	return strconv.Itoa(int(id))
+}
+
+// TLV types of the BGP-LS NLRI descriptors
+const (
+	LS_TLV_LOCAL_NODE_DESC     = 256
+	LS_TLV_REMOTE_NODE_DESC    = 257
+	LS_TLV_LINK_ID             = 258
+	LS_TLV_IPV4_INTERFACE_ADDR = 259
+	LS_TLV_IPV4_NEIGHBOR_ADDR  = 260
+	LS_TLV_IPV6_INTERFACE_ADDR = 261
+	LS_TLV_IPV6_NEIGHBOR_ADDR  = 262
+	LS_TLV_MULTI_TOPOLOGY_ID   = 263
+	LS_TLV_OSPF_ROUTE_TYPE     = 264
+	LS_TLV_IP_REACHABILITY     = 265
+	LS_TLV_AS                  = 512
+	LS_TLV_BGP_LS_ID           = 513
+	LS_TLV_OSPF_AREA_ID        = 514
+	LS_TLV_IGP_ROUTER_ID       = 515
+)
+
+// LsTLV is a TLV of the BGP-LS NLRI or attribute.
+type LsTLV struct {
+	Type  uint16
+	Value []byte
+}
+
+func decodeLsTLVs(data []byte) ([]*LsTLV, error) {
+	tlvs := make([]*LsTLV, 0)
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("bgp-ls tlv header is short")
+		}
+		l := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+l {
+			return nil, fmt.Errorf("bgp-ls tlv length is incorrect")
+		}
+		tlvs = append(tlvs, &LsTLV{
+			Type:  binary.BigEndian.Uint16(data[0:2]),
+			Value: data[4 : 4+l],
+		})
+		data = data[4+l:]
+	}
+	return tlvs, nil
+}
+
+func serializeLsTLVs(tlvs []*LsTLV) []byte {
+	buf := make([]byte, 0)
+	for _, tlv := range tlvs {
+		b := make([]byte, 4+len(tlv.Value))
+		binary.BigEndian.PutUint16(b[0:2], tlv.Type)
+		binary.BigEndian.PutUint16(b[2:4], uint16(len(tlv.Value)))
+		copy(b[4:], tlv.Value)
+		buf = append(buf, b...)
+	}
+	return buf
+}
+
+func newLsUint32TLV(t uint16, v uint32) *LsTLV {
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, v)
+	return &LsTLV{Type: t, Value: buf}
+}
+
+func lsTLVsString(tlvs []*LsTLV) []string {
+	l := make([]string, 0, len(tlvs))
+	for _, tlv := range tlvs {
+		l = append(l, fmt.Sprintf("tlv-%d:%x", tlv.Type, tlv.Value))
+	}
+	return l
+}
+
+// formatIGPRouterID returns the text form of the IGP router ID of a node
+// descriptor: an IPv4 address for OSPF, a system ID such as
+// "0000.0000.0001" for IS-IS, with the pseudonode ID, "-01", or the
+// address of the designated router interface, ":10.0.0.1", of the
+// pseudonodes.
+func formatIGPRouterID(id []byte) string {
+	switch len(id) {
+	case 4:
+		return net.IP(id).String()
+	case 6, 7:
+		s := fmt.Sprintf("%02x%02x.%02x%02x.%02x%02x", id[0], id[1], id[2], id[3], id[4], id[5])
+		if len(id) == 7 {
+			s += fmt.Sprintf("-%02x", id[6])
+		}
+		return s
+	case 8:
+		return fmt.Sprintf("%s:%s", net.IP(id[:4]), net.IP(id[4:]))
+	}
+	return fmt.Sprintf("%x", id)
+}
+
+// LsNodeDescriptor identifies the local or remote node of a BGP-LS
+// NLRI, the zero fields are absent.
+type LsNodeDescriptor struct {
+	Asn         uint32
+	BGPLsID     uint32
+	OspfAreaID  uint32
+	IGPRouterID []byte
+	// the sub-TLVs of other types
+	Other []*LsTLV
+}
+
+func (d *LsNodeDescriptor) decode(data []byte) error {
+	tlvs, err := decodeLsTLVs(data)
+	if err != nil {
+		return err
+	}
+	for _, tlv := range tlvs {
+		switch tlv.Type {
+		case LS_TLV_AS, LS_TLV_BGP_LS_ID, LS_TLV_OSPF_AREA_ID:
+			if len(tlv.Value) != 4 {
+				return fmt.Errorf("bgp-ls node descriptor tlv %d length is incorrect", tlv.Type)
+			}
+			v := binary.BigEndian.Uint32(tlv.Value)
+			switch tlv.Type {
+			case LS_TLV_AS:
+				d.Asn = v
+			case LS_TLV_BGP_LS_ID:
+				d.BGPLsID = v
+			case LS_TLV_OSPF_AREA_ID:
+				d.OspfAreaID = v
+			}
+		case LS_TLV_IGP_ROUTER_ID:
+			if l := len(tlv.Value); l < 4 || l > 8 || l == 5 {
+				return fmt.Errorf("bgp-ls igp router id length is incorrect")
+			}
+			d.IGPRouterID = tlv.Value
+		default:
+			d.Other = append(d.Other, tlv)
+		}
+	}
+	return nil
+}
+
+func (d *LsNodeDescriptor) serialize(t uint16) []byte {
+	tlvs := make([]*LsTLV, 0)
+	if d.Asn != 0 {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_AS, d.Asn))
+	}
+	if d.BGPLsID != 0 {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_BGP_LS_ID, d.BGPLsID))
+	}
+	if d.OspfAreaID != 0 {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_OSPF_AREA_ID, d.OspfAreaID))
+	}
+	if d.IGPRouterID != nil {
+		tlvs = append(tlvs, &LsTLV{Type: LS_TLV_IGP_ROUTER_ID, Value: d.IGPRouterID})
+	}
+	tlvs = append(tlvs, d.Other...)
+	return serializeLsTLVs([]*LsTLV{{Type: t, Value: serializeLsTLVs(tlvs)}})
+}
+
+// String returns the fields of the descriptor, "as:65000,router:10.0.0.1".
+func (d *LsNodeDescriptor) String() string {
+	l := make([]string, 0)
+	if d.Asn != 0 {
+		l = append(l, fmt.Sprintf("as:%d", d.Asn))
+	}
+	if d.BGPLsID != 0 {
+		l = append(l, fmt.Sprintf("bgp-ls-id:%d", d.BGPLsID))
+	}
+	if d.OspfAreaID != 0 {
+		l = append(l, fmt.Sprintf("area:%d", d.OspfAreaID))
+	}
+	if d.IGPRouterID != nil {
+		l = append(l, fmt.Sprintf("router:%s", formatIGPRouterID(d.IGPRouterID)))
+	}
+	return strings.Join(append(l, lsTLVsString(d.Other)...), ",")
+}
+
+// LsLinkDescriptor identifies a link between the local and remote nodes
+// of a BGP-LS link NLRI, the zero fields are absent.
+type LsLinkDescriptor struct {
+	LinkLocalID   uint32
+	LinkRemoteID  uint32
+	InterfaceAddr net.IP
+	NeighborAddr  net.IP
+	// the TLVs of other types, such as the multi-topology ID
+	Other []*LsTLV
+}
+
+func (d *LsLinkDescriptor) decode(tlvs []*LsTLV) error {
+	for _, tlv := range tlvs {
+		switch tlv.Type {
+		case LS_TLV_LINK_ID:
+			if len(tlv.Value) != 8 {
+				return fmt.Errorf("bgp-ls link id length is incorrect")
+			}
+			d.LinkLocalID = binary.BigEndian.Uint32(tlv.Value[0:4])
+			d.LinkRemoteID = binary.BigEndian.Uint32(tlv.Value[4:8])
+		case LS_TLV_IPV4_INTERFACE_ADDR, LS_TLV_IPV4_NEIGHBOR_ADDR, LS_TLV_IPV6_INTERFACE_ADDR, LS_TLV_IPV6_NEIGHBOR_ADDR:
+			addrlen := 4
+			if tlv.Type == LS_TLV_IPV6_INTERFACE_ADDR || tlv.Type == LS_TLV_IPV6_NEIGHBOR_ADDR {
+				addrlen = 16
+			}
+			if len(tlv.Value) != addrlen {
+				return fmt.Errorf("bgp-ls link address length is incorrect")
+			}
+			if tlv.Type == LS_TLV_IPV4_INTERFACE_ADDR || tlv.Type == LS_TLV_IPV6_INTERFACE_ADDR {
+				d.InterfaceAddr = net.IP(tlv.Value)
+			} else {
+				d.NeighborAddr = net.IP(tlv.Value)
+			}
+		default:
+			d.Other = append(d.Other, tlv)
+		}
+	}
+	return nil
+}
+
+func (d *LsLinkDescriptor) serialize() []byte {
+	tlvs := make([]*LsTLV, 0)
+	if d.LinkLocalID != 0 || d.LinkRemoteID != 0 {
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint32(buf[0:4], d.LinkLocalID)
+		binary.BigEndian.PutUint32(buf[4:8], d.LinkRemoteID)
+		tlvs = append(tlvs, &LsTLV{Type: LS_TLV_LINK_ID, Value: buf})
+	}
+	if d.InterfaceAddr != nil {
+		if ip := d.InterfaceAddr.To4(); ip != nil {
+			tlvs = append(tlvs, &LsTLV{Type: LS_TLV_IPV4_INTERFACE_ADDR, Value: ip})
+		} else {
+			tlvs = append(tlvs, &LsTLV{Type: LS_TLV_IPV6_INTERFACE_ADDR, Value: d.InterfaceAddr.To16()})
+		}
+	}
+	if d.NeighborAddr != nil {
+		if ip := d.NeighborAddr.To4(); ip != nil {
+			tlvs = append(tlvs, &LsTLV{Type: LS_TLV_IPV4_NEIGHBOR_ADDR, Value: ip})
+		} else {
+			tlvs = append(tlvs, &LsTLV{Type: LS_TLV_IPV6_NEIGHBOR_ADDR, Value: d.NeighborAddr.To16()})
+		}
+	}
+	return serializeLsTLVs(append(tlvs, d.Other...))
+}
+
+func (d *LsLinkDescriptor) String() string {
+	l := make([]string, 0)
+	if d.LinkLocalID != 0 || d.LinkRemoteID != 0 {
+		l = append(l, fmt.Sprintf("link-id:%d:%d", d.LinkLocalID, d.LinkRemoteID))
+	}
+	if d.InterfaceAddr != nil {
+		l = append(l, fmt.Sprintf("interface:%s", d.InterfaceAddr))
+	}
+	if d.NeighborAddr != nil {
+		l = append(l, fmt.Sprintf("neighbor:%s", d.NeighborAddr))
+	}
+	return strings.Join(append(l, lsTLVsString(d.Other)...), ",")
+}
+
+// LsPrefixDescriptor identifies a prefix of a BGP-LS prefix NLRI, the
+// OSPF route type is absent if it is zero.
+type LsPrefixDescriptor struct {
+	OspfRouteType uint8
+	PrefixLength  uint8
+	Prefix        net.IP
+	// the TLVs of other types, such as the multi-topology ID
+	Other []*LsTLV
 }
 
-func (r *LabelledIPAddrPrefix) AFI() uint16 {
-	return AFI_IP
+func (d *LsPrefixDescriptor) decode(tlvs []*LsTLV, addrlen int) error {
+	for _, tlv := range tlvs {
+		switch tlv.Type {
+		case LS_TLV_OSPF_ROUTE_TYPE:
+			if len(tlv.Value) != 1 {
+				return fmt.Errorf("bgp-ls ospf route type length is incorrect")
+			}
+			d.OspfRouteType = tlv.Value[0]
+		case LS_TLV_IP_REACHABILITY:
+			if len(tlv.Value) < 1 || int(tlv.Value[0]) > addrlen*8 || len(tlv.Value) != 1+(int(tlv.Value[0])+7)/8 {
+				return fmt.Errorf("bgp-ls ip reachability length is incorrect")
+			}
+			d.PrefixLength = tlv.Value[0]
+			d.Prefix = make(net.IP, addrlen)
+			copy(d.Prefix, tlv.Value[1:])
+		default:
+			d.Other = append(d.Other, tlv)
+		}
+	}
+	if d.Prefix == nil {
+		return fmt.Errorf("bgp-ls prefix nlri has no ip reachability")
+	}
+	return nil
 }
 
-func (r *LabelledIPAddrPrefix) SAFI() uint8 {
-	return SAFI_MPLS_LABEL
+func (d *LsPrefixDescriptor) serialize() []byte {
+	tlvs := make([]*LsTLV, 0)
+	if d.OspfRouteType != 0 {
+		tlvs = append(tlvs, &LsTLV{Type: LS_TLV_OSPF_ROUTE_TYPE, Value: []byte{d.OspfRouteType}})
+	}
+	prefix := d.Prefix.To4()
+	if prefix == nil {
+		prefix = d.Prefix.To16()
+	}
+	buf := append([]byte{d.PrefixLength}, prefix[:(d.PrefixLength+7)/8]...)
+	tlvs = append(tlvs, &LsTLV{Type: LS_TLV_IP_REACHABILITY, Value: buf})
+	return serializeLsTLVs(append(tlvs, d.Other...))
 }
 
-func (r *IPAddrPrefix) decodeNextHop(data []byte) net.IP {
-	if r.addrlen == 0 {
-		r.addrlen = 4
+func (d *LsPrefixDescriptor) String() string {
+	l := []string{fmt.Sprintf("prefix:%s/%d", d.Prefix, d.PrefixLength)}
+	if d.OspfRouteType != 0 {
+		l = append(l, fmt.Sprintf("ospf-route-type:%d", d.OspfRouteType))
 	}
-	var next net.IP = data[0:r.addrlen]
-	return next
+	return strings.Join(append(l, lsTLVsString(d.Other)...), ",")
 }
 
-func (r *LabelledVPNIPAddrPrefix) decodeNextHop(data []byte) net.IP {
-	// skip rd
-	var next net.IP = data[8 : 8+r.addrlen]
-	return next
+// LsNLRI is the node, link or prefix NLRI of the BGP-LS route family
+// (RFC 7752). RemoteNode and Link are set for the link NLRIs only and
+// Prefix for the prefix NLRIs only.
+type LsNLRI struct {
+	NLRIType   uint16
+	Length     uint16
+	ProtocolID LsProtocolID
+	Identifier uint64
+	LocalNode  LsNodeDescriptor
+	RemoteNode LsNodeDescriptor
+	Link       LsLinkDescriptor
+	Prefix     LsPrefixDescriptor
+	// the body of an NLRI of an unknown type, RFC 7752 requires it to be
+	// ignored
+	Value []byte
 }
 
-func (r *LabelledIPAddrPrefix) decodeNextHop(data []byte) net.IP {
-	var next net.IP = data[0:r.addrlen]
-	return next
+// IsUnknown returns true if the type of n is none of the node, link and
+// prefix NLRI types.
+func (n *LsNLRI) IsUnknown() bool {
+	_, found := lsNLRITypeNames[n.NLRIType]
+	return !found
 }
 
-func (l *LabelledIPAddrPrefix) DecodeFromBytes(data []byte) error {
-	l.Length = uint8(data[0])
-	data = data[1:]
-	l.Labels.DecodeFromBytes(data)
-	if int(l.Length)-8*(l.Labels.Len()) < 0 {
-		l.Labels.Labels = []uint32{}
+func (n *LsNLRI) DecodeFromBytes(data []byte) error {
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
+	if len(data) < 4 {
+		return NewMessageError(eCode, eSubCode, nil, "bgp-ls nlri is short")
 	}
-	restbits := int(l.Length) - 8*(l.Labels.Len())
-	data = data[l.Labels.Len():]
-	l.decodePrefix(data, uint8(restbits), l.addrlen)
-	return nil
-}
-
-func (l *LabelledIPAddrPrefix) Serialize() ([]byte, error) {
-	buf := make([]byte, 1)
-	buf[0] = l.Length
-	restbits := int(l.Length) - 8*(l.Labels.Len())
-	lbuf, err := l.Labels.Serialize()
+	n.NLRIType = binary.BigEndian.Uint16(data[0:2])
+	n.Length = binary.BigEndian.Uint16(data[2:4])
+	data = data[4:]
+	if len(data) < int(n.Length) {
+		return NewMessageError(eCode, eSubCode, nil, "bgp-ls nlri length is incorrect")
+	}
+	if n.IsUnknown() {
+		n.Value = data[:n.Length]
+		return nil
+	}
+	if n.Length < 9 {
+		return NewMessageError(eCode, eSubCode, nil, "bgp-ls nlri length is incorrect")
+	}
+	n.ProtocolID = LsProtocolID(data[0])
+	n.Identifier = binary.BigEndian.Uint64(data[1:9])
+	tlvs, err := decodeLsTLVs(data[9:n.Length])
 	if err != nil {
-		return nil, err
+		return NewMessageError(eCode, eSubCode, nil, err.Error())
+	}
+	if len(tlvs) < 1 || tlvs[0].Type != LS_TLV_LOCAL_NODE_DESC {
+		return NewMessageError(eCode, eSubCode, nil, "bgp-ls nlri has no local node descriptor")
+	}
+	err = n.LocalNode.decode(tlvs[0].Value)
+	tlvs = tlvs[1:]
+	switch n.NLRIType {
+	case LS_NLRI_TYPE_LINK:
+		if err == nil && (len(tlvs) < 1 || tlvs[0].Type != LS_TLV_REMOTE_NODE_DESC) {
+			err = fmt.Errorf("bgp-ls link nlri has no remote node descriptor")
+		}
+		if err == nil {
+			err = n.RemoteNode.decode(tlvs[0].Value)
+		}
+		if err == nil {
+			err = n.Link.decode(tlvs[1:])
+		}
+	case LS_NLRI_TYPE_PREFIX_IPV4:
+		if err == nil {
+			err = n.Prefix.decode(tlvs, net.IPv4len)
+		}
+	case LS_NLRI_TYPE_PREFIX_IPV6:
+		if err == nil {
+			err = n.Prefix.decode(tlvs, net.IPv6len)
+		}
+	default:
+		if err == nil && len(tlvs) > 0 {
+			err = fmt.Errorf("bgp-ls node nlri has unexpected tlvs")
+		}
 	}
-	buf = append(buf, lbuf...)
-	pbuf, err := l.serializePrefix(uint8(restbits))
 	if err != nil {
-		return nil, err
+		return NewMessageError(eCode, eSubCode, nil, err.Error())
 	}
-	buf = append(buf, pbuf...)
-	return buf, nil
+	return nil
 }
 
-func NewLabelledIPAddrPrefix(length uint8, prefix string, label Label) *LabelledIPAddrPrefix {
-	return &LabelledIPAddrPrefix{
-		IPAddrPrefixDefault{length + uint8(label.Len()*8), net.ParseIP(prefix)},
-		label,
-		4,
-	}
+func (n *LsNLRI) Serialize() ([]byte, error) {
+	if n.IsUnknown() {
+		buf := make([]byte, 4, 4+len(n.Value))
+		binary.BigEndian.PutUint16(buf[0:2], n.NLRIType)
+		n.Length = uint16(len(n.Value))
+		binary.BigEndian.PutUint16(buf[2:4], n.Length)
+		return append(buf, n.Value...), nil
+	}
+	buf := make([]byte, 13)
+	binary.BigEndian.PutUint16(buf[0:2], n.NLRIType)
+	buf[4] = uint8(n.ProtocolID)
+	binary.BigEndian.PutUint64(buf[5:13], n.Identifier)
+	buf = append(buf, n.LocalNode.serialize(LS_TLV_LOCAL_NODE_DESC)...)
+	switch n.NLRIType {
+	case LS_NLRI_TYPE_LINK:
+		buf = append(buf, n.RemoteNode.serialize(LS_TLV_REMOTE_NODE_DESC)...)
+		buf = append(buf, n.Link.serialize()...)
+	case LS_NLRI_TYPE_PREFIX_IPV4, LS_NLRI_TYPE_PREFIX_IPV6:
+		buf = append(buf, n.Prefix.serialize()...)
+	}
+	n.Length = uint16(len(buf) - 4)
+	binary.BigEndian.PutUint16(buf[2:4], n.Length)
+	return buf, nil
 }
 
-type LabelledIPv6AddrPrefix struct {
-	LabelledIPAddrPrefix
+func (n *LsNLRI) AFI() uint16 {
+	return AFI_LS
 }
 
-func NewLabelledIPv6AddrPrefix(length uint8, prefix string, label Label) *LabelledIPv6AddrPrefix {
-	return &LabelledIPv6AddrPrefix{
-		LabelledIPAddrPrefix{
-			IPAddrPrefixDefault{length + uint8(label.Len()*8), net.ParseIP(prefix)},
-			label,
-			16,
-		},
-	}
+func (n *LsNLRI) SAFI() uint8 {
+	return SAFI_LS
 }
 
-type RouteTargetMembershipNLRI struct {
-	AS          uint32
-	RouteTarget ExtendedCommunityInterface
+func (n *LsNLRI) Len() int {
+	return int(n.Length) + 4
 }
 
-func (n *RouteTargetMembershipNLRI) DecodeFromBytes(data []byte) error {
-	n.AS = binary.BigEndian.Uint32(data[0:4])
-	n.RouteTarget = parseExtended(data[4:])
-	return nil
+// NodeKey returns the text form of the local node, or of the remote
+// node if remote is true, with the protocol and the identifier of the
+// routing universe.
+func (n *LsNLRI) NodeKey(remote bool) string {
+	node := n.LocalNode
+	if remote {
+		node = n.RemoteNode
+	}
+	return fmt.Sprintf("[%s][id:%d][%s]", n.ProtocolID, n.Identifier, node.String())
 }
 
-func (n *RouteTargetMembershipNLRI) Serialize() ([]byte, error) {
-	buf := make([]byte, 4)
-	binary.BigEndian.PutUint32(buf, n.AS)
-	ebuf, err := n.RouteTarget.Serialize()
-	if err != nil {
-		return nil, err
+func (n *LsNLRI) String() string {
+	if n.IsUnknown() {
+		return fmt.Sprintf("[type:%d][len:%d]", n.NLRIType, n.Length)
+	}
+	s := fmt.Sprintf("[%s][%s][id:%d][local:%s]", lsNLRITypeNames[n.NLRIType], n.ProtocolID, n.Identifier, n.LocalNode.String())
+	switch n.NLRIType {
+	case LS_NLRI_TYPE_LINK:
+		s += fmt.Sprintf("[remote:%s][link:%s]", n.RemoteNode.String(), n.Link.String())
+	case LS_NLRI_TYPE_PREFIX_IPV4, LS_NLRI_TYPE_PREFIX_IPV6:
+		s += fmt.Sprintf("[%s]", n.Prefix.String())
 	}
-	return append(buf, ebuf...), nil
+	return s
 }
 
-func (n *RouteTargetMembershipNLRI) AFI() uint16 {
-	return AFI_IP
+func NewLsNodeNLRI(protocolID LsProtocolID, identifier uint64, local LsNodeDescriptor) *LsNLRI {
+	n := &LsNLRI{
+		NLRIType:   LS_NLRI_TYPE_NODE,
+		ProtocolID: protocolID,
+		Identifier: identifier,
+		LocalNode:  local,
+	}
+	n.Serialize()
+	return n
 }
 
-func (n *RouteTargetMembershipNLRI) SAFI() uint8 {
-	return SAFI_ROUTE_TARGET_CONSTRTAINS
+func NewLsLinkNLRI(protocolID LsProtocolID, identifier uint64, local LsNodeDescriptor, remote LsNodeDescriptor, link LsLinkDescriptor) *LsNLRI {
+	n := &LsNLRI{
+		NLRIType:   LS_NLRI_TYPE_LINK,
+		ProtocolID: protocolID,
+		Identifier: identifier,
+		LocalNode:  local,
+		RemoteNode: remote,
+		Link:       link,
+	}
+	n.Serialize()
+	return n
 }
 
-func (n *RouteTargetMembershipNLRI) Len() int { return 12 }
-
-func rfshift(afi uint16, safi uint8) RouteFamily {
-	return RouteFamily(int(afi)<<16 | int(safi))
+func NewLsPrefixNLRI(protocolID LsProtocolID, identifier uint64, local LsNodeDescriptor, prefix LsPrefixDescriptor) *LsNLRI {
+	n := &LsNLRI{
+		NLRIType:   LS_NLRI_TYPE_PREFIX_IPV4,
+		ProtocolID: protocolID,
+		Identifier: identifier,
+		LocalNode:  local,
+		Prefix:     prefix,
+	}
+	if prefix.Prefix.To4() == nil {
+		n.NLRIType = LS_NLRI_TYPE_PREFIX_IPV6
+	}
+	n.Serialize()
+	return n
 }
 
 type RouteFamily int
 
 const (
-	RF_IPv4_UC   RouteFamily = AFI_IP<<16 | SAFI_UNICAST
-	RF_IPv6_UC   RouteFamily = AFI_IP6<<16 | SAFI_UNICAST
-	RF_IPv4_VPN  RouteFamily = AFI_IP<<16 | SAFI_MPLS_VPN
-	RF_IPv6_VPN  RouteFamily = AFI_IP6<<16 | SAFI_MPLS_VPN
-	RF_IPv4_MPLS RouteFamily = AFI_IP<<16 | SAFI_MPLS_LABEL
-	RF_IPv6_MPLS RouteFamily = AFI_IP6<<16 | SAFI_MPLS_LABEL
-	RF_RTC_UC    RouteFamily = AFI_IP<<16 | SAFI_ROUTE_TARGET_CONSTRTAINS
+	RF_IPv4_UC    RouteFamily = AFI_IP<<16 | SAFI_UNICAST
+	RF_IPv6_UC    RouteFamily = AFI_IP6<<16 | SAFI_UNICAST
+	RF_IPv4_MC    RouteFamily = AFI_IP<<16 | SAFI_MULTICAST
+	RF_IPv6_MC    RouteFamily = AFI_IP6<<16 | SAFI_MULTICAST
+	RF_IPv4_VPN   RouteFamily = AFI_IP<<16 | SAFI_MPLS_VPN
+	RF_IPv6_VPN   RouteFamily = AFI_IP6<<16 | SAFI_MPLS_VPN
+	RF_IPv4_MPLS  RouteFamily = AFI_IP<<16 | SAFI_MPLS_LABEL
+	RF_IPv6_MPLS  RouteFamily = AFI_IP6<<16 | SAFI_MPLS_LABEL
+	RF_RTC_UC     RouteFamily = AFI_IP<<16 | SAFI_ROUTE_TARGET_CONSTRTAINS
+	RF_VPLS       RouteFamily = AFI_L2VPN<<16 | SAFI_VPLS
+	RF_EVPN       RouteFamily = AFI_L2VPN<<16 | SAFI_EVPN
+	RF_FS_IPv4_UC RouteFamily = AFI_IP<<16 | SAFI_FLOW_SPEC_UNICAST
+	RF_FS_IPv6_UC RouteFamily = AFI_IP6<<16 | SAFI_FLOW_SPEC_UNICAST
+	RF_LS         RouteFamily = AFI_LS<<16 | SAFI_LS
 )
 
+// AFI and SAFI of the route family.
+func RouteFamilyToAfiSafi(rf RouteFamily) (uint16, uint8) {
+	return uint16(int(rf) >> 16), uint8(int(rf) & 0xff)
+}
+
+var routeFamilyNames = map[RouteFamily]string{
+	RF_IPv4_UC:    "ipv4-unicast",
+	RF_IPv6_UC:    "ipv6-unicast",
+	RF_IPv4_MC:    "ipv4-multicast",
+	RF_IPv6_MC:    "ipv6-multicast",
+	RF_IPv4_VPN:   "l3vpn-ipv4-unicast",
+	RF_IPv6_VPN:   "l3vpn-ipv6-unicast",
+	RF_IPv4_MPLS:  "ipv4-labeled-unicast",
+	RF_IPv6_MPLS:  "ipv6-labeled-unicast",
+	RF_VPLS:       "l2vpn-vpls",
+	RF_EVPN:       "l2vpn-evpn",
+	RF_FS_IPv4_UC: "ipv4-flowspec",
+	RF_FS_IPv6_UC: "ipv6-flowspec",
+	RF_LS:         "link-state",
+}
+
+// GetRouteFamily returns the route family of its OpenConfig name such
+// as "ipv4-unicast" or "l3vpn-ipv4-unicast".
+func GetRouteFamily(name string) (RouteFamily, error) {
+	for rf, n := range routeFamilyNames {
+		if n == strings.ToLower(name) {
+			return rf, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported route family %s", name)
+}
+
 func routeFamilyPrefix(afi uint16, safi uint8) (prefix AddrPrefixInterface, err error) {
 	switch rfshift(afi, safi) {
 	case RF_IPv4_UC:
 		prefix = NewIPAddrPrefix(0, "")
 	case RF_IPv6_UC:
 		prefix = NewIPv6AddrPrefix(0, "")
+	case RF_IPv4_MC:
+		prefix = NewIPMulticastAddrPrefix(0, "")
+	case RF_IPv6_MC:
+		prefix = NewIPv6MulticastAddrPrefix(0, "")
 	case RF_IPv4_VPN:
 		prefix = NewLabelledVPNIPAddrPrefix(0, "", *NewLabel(), nil)
 	case RF_IPv6_VPN:
@@ -997,6 +2966,16 @@ func routeFamilyPrefix(afi uint16, safi uint8) (prefix AddrPrefixInterface, err
 		prefix = NewLabelledIPv6AddrPrefix(0, "", *NewLabel())
 	case RF_RTC_UC:
 		prefix = &RouteTargetMembershipNLRI{}
+	case RF_VPLS:
+		prefix = &VPLSNLRI{}
+	case RF_EVPN:
+		prefix = &EVPNNLRI{}
+	case RF_FS_IPv4_UC:
+		prefix = NewFlowSpecIPv4Unicast(nil)
+	case RF_FS_IPv6_UC:
+		prefix = NewFlowSpecIPv6Unicast(nil)
+	case RF_LS:
+		prefix = &LsNLRI{}
 	default:
 		return nil, errors.New("unknown route family")
 	}
@@ -1032,6 +3011,20 @@ const (
 	BGP_ATTR_TYPE_EXTENDED_COMMUNITIES
 	BGP_ATTR_TYPE_AS4_PATH
 	BGP_ATTR_TYPE_AS4_AGGREGATOR
+	_
+	_
+	_
+	_
+	_
+	_
+	_
+	BGP_ATTR_TYPE_AIGP
+	_
+	_
+	BGP_ATTR_TYPE_LS
+	_
+	_
+	BGP_ATTR_TYPE_LARGE_COMMUNITY
 )
 
 // NOTIFICATION Error Code  RFC 4271 4.5.
@@ -1121,14 +3114,17 @@ var pathAttrFlags map[BGPAttrType]uint8 = map[BGPAttrType]uint8{
 	BGP_ATTR_TYPE_EXTENDED_COMMUNITIES: BGP_ATTR_FLAG_TRANSITIVE | BGP_ATTR_FLAG_OPTIONAL,
 	BGP_ATTR_TYPE_AS4_PATH:             BGP_ATTR_FLAG_TRANSITIVE | BGP_ATTR_FLAG_OPTIONAL,
 	BGP_ATTR_TYPE_AS4_AGGREGATOR:       BGP_ATTR_FLAG_TRANSITIVE | BGP_ATTR_FLAG_OPTIONAL,
+	BGP_ATTR_TYPE_AIGP:                 BGP_ATTR_FLAG_OPTIONAL,
+	BGP_ATTR_TYPE_LS:                   BGP_ATTR_FLAG_OPTIONAL,
+	BGP_ATTR_TYPE_LARGE_COMMUNITY:      BGP_ATTR_FLAG_TRANSITIVE | BGP_ATTR_FLAG_OPTIONAL,
 }
 
 type PathAttributeInterface interface {
 	DecodeFromBytes([]byte) error
 	Serialize() ([]byte, error)
 	Len() int
-	getFlags() uint8
-	getType() BGPAttrType
+	GetFlags() uint8
+	GetType() BGPAttrType
 }
 
 type PathAttribute struct {
@@ -1148,11 +3144,11 @@ func (p *PathAttribute) Len() int {
 	return int(l)
 }
 
-func (p *PathAttribute) getFlags() uint8 {
+func (p *PathAttribute) GetFlags() uint8 {
 	return p.Flags
 }
 
-func (p *PathAttribute) getType() BGPAttrType {
+func (p *PathAttribute) GetType() BGPAttrType {
 	return p.Type
 }
 
@@ -1232,6 +3228,13 @@ func NewPathAttributeOrigin(value uint8) *PathAttributeOrigin {
 	}
 }
 
+const (
+	BGP_ASPATH_ATTR_TYPE_SET        = 1
+	BGP_ASPATH_ATTR_TYPE_SEQ        = 2
+	BGP_ASPATH_ATTR_TYPE_CONFED_SEQ = 3
+	BGP_ASPATH_ATTR_TYPE_CONFED_SET = 4
+)
+
 type AsPathParam struct {
 	Type uint8
 	Num  uint8
@@ -1715,6 +3718,15 @@ func NewPathAttributeAggregator(as interface{}, address string) *PathAttributeAg
 	}
 }
 
+// well-known communities, RFC 1997 and RFC 8326
+const (
+	COMMUNITY_INTERNET            uint32 = 0x00000000
+	COMMUNITY_GRACEFUL_SHUTDOWN   uint32 = 0xffff0000
+	COMMUNITY_NO_EXPORT           uint32 = 0xffffff01
+	COMMUNITY_NO_ADVERTISE        uint32 = 0xffffff02
+	COMMUNITY_NO_EXPORT_SUBCONFED uint32 = 0xffffff03
+)
+
 type PathAttributeCommunities struct {
 	PathAttribute
 	Value []uint32
@@ -1895,7 +3907,7 @@ func (p *PathAttributeMpReachNLRI) DecodeFromBytes(data []byte) error {
 	eSubCode := uint8(BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR)
 
 	value := p.PathAttribute.Value
-	if len(value) < 3 {
+	if len(value) < 4 {
 		return NewMessageError(eCode, eSubCode, value, "mpreach header length is short")
 	}
 	afi := binary.BigEndian.Uint16(value[0:2])
@@ -1912,7 +3924,7 @@ func (p *PathAttributeMpReachNLRI) DecodeFromBytes(data []byte) error {
 			offset = 8
 		}
 		addrlen := 4
-		if afi == AFI_IP6 {
+		if afi == AFI_IP6 || (afi == AFI_L2VPN || afi == AFI_LS) && len(nexthopbin) == offset+16 {
 			addrlen = 16
 		}
 		if len(nexthopbin) != offset+addrlen {
@@ -1947,7 +3959,8 @@ func (p *PathAttributeMpReachNLRI) Serialize() ([]byte, error) {
 	afi := p.Value[0].AFI()
 	safi := p.Value[0].SAFI()
 	nexthoplen := 4
-	if afi == AFI_IP6 {
+	// the EVPN and BGP-LS next hops are IPv4 or IPv6 addresses
+	if afi == AFI_IP6 || (afi == AFI_L2VPN || afi == AFI_LS) && p.Nexthop.To4() == nil {
 		nexthoplen = 16
 	}
 	offset := 0
@@ -1955,11 +3968,19 @@ func (p *PathAttributeMpReachNLRI) Serialize() ([]byte, error) {
 		offset = 8
 		nexthoplen += 8
 	}
+	// the flow specifications have no next hop
+	if safi == SAFI_FLOW_SPEC_UNICAST {
+		nexthoplen = 0
+	}
 	buf := make([]byte, 4+nexthoplen)
 	binary.BigEndian.PutUint16(buf[0:], afi)
 	buf[2] = safi
 	buf[3] = uint8(nexthoplen)
-	copy(buf[4+offset:], p.Nexthop)
+	if nexthoplen-offset == 4 {
+		copy(buf[4+offset:], p.Nexthop.To4())
+	} else {
+		copy(buf[4+offset:], p.Nexthop.To16())
+	}
 	buf = append(buf, make([]byte, 1)...)
 	for _, prefix := range p.Value {
 		pbuf, err := prefix.Serialize()
@@ -2065,6 +4086,54 @@ func NewPathAttributeMpUnreachNLRI(nlri []AddrPrefixInterface) *PathAttributeMpU
 
 type ExtendedCommunityInterface interface {
 	Serialize() ([]byte, error)
+	String() string
+}
+
+// extended community types, RFC 4360 and RFC 7153
+const (
+	EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC     = 0x00
+	EC_TYPE_TRANSITIVE_IP4_SPECIFIC              = 0x01
+	EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC    = 0x02
+	EC_TYPE_TRANSITIVE_OPAQUE                    = 0x03
+	EC_TYPE_EVPN                                 = 0x06
+	EC_TYPE_NON_TRANSITIVE_TWO_OCTET_AS_SPECIFIC = 0x40
+	EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL      = 0x80
+)
+
+// extended community sub-types of the transitive AS and IPv4 address
+// specific types, RFC 4360
+const (
+	EC_SUBTYPE_ROUTE_TARGET = 0x02
+	EC_SUBTYPE_ROUTE_ORIGIN = 0x03
+)
+
+const (
+	// non-transitive two-octet AS specific, draft-ietf-idr-link-bandwidth
+	EC_SUBTYPE_LINK_BANDWIDTH = 0x04
+	// transitive opaque, RFC 9012
+	EC_SUBTYPE_COLOR         = 0x0b
+	EC_SUBTYPE_ENCAPSULATION = 0x0c
+	// EVPN, RFC 7432 and RFC 9135
+	EC_SUBTYPE_MAC_MOBILITY = 0x00
+	EC_SUBTYPE_ROUTER_MAC   = 0x03
+	// FlowSpec traffic actions, RFC 8955
+	EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE   = 0x06
+	EC_SUBTYPE_FLOWSPEC_REDIRECT       = 0x08
+	EC_SUBTYPE_FLOWSPEC_TRAFFIC_REMARK = 0x09
+	// Layer2 Info, RFC 4761
+	EC_SUBTYPE_LAYER2_INFO = 0x0a
+)
+
+var extendedSubTypeNames = map[uint8]string{
+	EC_SUBTYPE_ROUTE_TARGET: "rt",
+	EC_SUBTYPE_ROUTE_ORIGIN: "soo",
+}
+
+// extendedHexString is the text form of extended communities without a
+// more specific one.
+func extendedHexString(e ExtendedCommunityInterface) string {
+	buf, _ := e.Serialize()
+	return fmt.Sprintf("0x%016x", binary.BigEndian.Uint64(buf))
 }
 
 type TwoOctetAsSpecificExtended struct {
@@ -2082,6 +4151,13 @@ func (e *TwoOctetAsSpecificExtended) Serialize() ([]byte, error) {
 	return buf, nil
 }
 
+func (e *TwoOctetAsSpecificExtended) String() string {
+	if name, found := extendedSubTypeNames[e.SubType]; found {
+		return fmt.Sprintf("%s:%d:%d", name, e.AS, e.LocalAdmin)
+	}
+	return extendedHexString(e)
+}
+
 type IPv4AddressSpecificExtended struct {
 	SubType    uint8
 	IPv4       net.IP
@@ -2097,6 +4173,13 @@ func (e *IPv4AddressSpecificExtended) Serialize() ([]byte, error) {
 	return buf, nil
 }
 
+func (e *IPv4AddressSpecificExtended) String() string {
+	if name, found := extendedSubTypeNames[e.SubType]; found {
+		return fmt.Sprintf("%s:%s:%d", name, e.IPv4, e.LocalAdmin)
+	}
+	return extendedHexString(e)
+}
+
 type FourOctetAsSpecificExtended struct {
 	SubType    uint8
 	AS         uint32
@@ -2112,17 +4195,263 @@ func (e *FourOctetAsSpecificExtended) Serialize() ([]byte, error) {
 	return buf, nil
 }
 
+// String marks an AS which would fit in two octets with a "L" suffix,
+// "rt:65000L:100", to tell it from TwoOctetAsSpecificExtended.
+func (e *FourOctetAsSpecificExtended) String() string {
+	if name, found := extendedSubTypeNames[e.SubType]; found {
+		if e.AS <= math.MaxUint16 {
+			return fmt.Sprintf("%s:%dL:%d", name, e.AS, e.LocalAdmin)
+		}
+		return fmt.Sprintf("%s:%d:%d", name, e.AS, e.LocalAdmin)
+	}
+	return extendedHexString(e)
+}
+
 type OpaqueExtended struct {
 	Value []byte
 }
 
 func (e *OpaqueExtended) Serialize() ([]byte, error) {
 	buf := make([]byte, 8)
-	buf[0] = 0x03
-	copy(buf[1:], e.Value)
+	buf[0] = 0x03
+	copy(buf[1:], e.Value)
+	return buf, nil
+}
+
+func (e *OpaqueExtended) String() string {
+	return extendedHexString(e)
+}
+
+// LinkBandwidthExtended carries the bandwidth of the link to the
+// neighbor AS in bytes per second.
+type LinkBandwidthExtended struct {
+	AS        uint16
+	Bandwidth float32
+}
+
+func (e *LinkBandwidthExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_NON_TRANSITIVE_TWO_OCTET_AS_SPECIFIC
+	buf[1] = EC_SUBTYPE_LINK_BANDWIDTH
+	binary.BigEndian.PutUint16(buf[2:], e.AS)
+	binary.BigEndian.PutUint32(buf[4:], math.Float32bits(e.Bandwidth))
+	return buf, nil
+}
+
+func (e *LinkBandwidthExtended) String() string {
+	return fmt.Sprintf("lb:%d:%s", e.AS, strconv.FormatFloat(float64(e.Bandwidth), 'f', -1, 32))
+}
+
+type TunnelType uint16
+
+// tunnel types of the encapsulation extended community, RFC 9012
+const (
+	TUNNEL_TYPE_L2TPV3      TunnelType = 1
+	TUNNEL_TYPE_GRE         TunnelType = 2
+	TUNNEL_TYPE_IP_IN_IP    TunnelType = 7
+	TUNNEL_TYPE_VXLAN       TunnelType = 8
+	TUNNEL_TYPE_NVGRE       TunnelType = 9
+	TUNNEL_TYPE_MPLS        TunnelType = 10
+	TUNNEL_TYPE_MPLS_IN_GRE TunnelType = 11
+	TUNNEL_TYPE_VXLAN_GPE   TunnelType = 12
+	TUNNEL_TYPE_MPLS_IN_UDP TunnelType = 13
+	TUNNEL_TYPE_GENEVE      TunnelType = 19
+)
+
+var tunnelTypeNames = map[TunnelType]string{
+	TUNNEL_TYPE_L2TPV3:      "l2tpv3",
+	TUNNEL_TYPE_GRE:         "gre",
+	TUNNEL_TYPE_IP_IN_IP:    "ip-in-ip",
+	TUNNEL_TYPE_VXLAN:       "vxlan",
+	TUNNEL_TYPE_NVGRE:       "nvgre",
+	TUNNEL_TYPE_MPLS:        "mpls",
+	TUNNEL_TYPE_MPLS_IN_GRE: "mpls-in-gre",
+	TUNNEL_TYPE_VXLAN_GPE:   "vxlan-gpe",
+	TUNNEL_TYPE_MPLS_IN_UDP: "mpls-in-udp",
+	TUNNEL_TYPE_GENEVE:      "geneve",
+}
+
+func (t TunnelType) String() string {
+	if name, found := tunnelTypeNames[t]; found {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
+
+type EncapExtended struct {
+	TunnelType TunnelType
+}
+
+func (e *EncapExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_TRANSITIVE_OPAQUE
+	buf[1] = EC_SUBTYPE_ENCAPSULATION
+	binary.BigEndian.PutUint16(buf[6:], uint16(e.TunnelType))
+	return buf, nil
+}
+
+func (e *EncapExtended) String() string {
+	return fmt.Sprintf("encap:%s", e.TunnelType)
+}
+
+type ColorExtended struct {
+	Flags uint16
+	Color uint32
+}
+
+func (e *ColorExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_TRANSITIVE_OPAQUE
+	buf[1] = EC_SUBTYPE_COLOR
+	binary.BigEndian.PutUint16(buf[2:], e.Flags)
+	binary.BigEndian.PutUint32(buf[4:], e.Color)
+	return buf, nil
+}
+
+// String returns "color:<color>", or "color:<flags>:<color>" if any flag
+// is set.
+func (e *ColorExtended) String() string {
+	if e.Flags != 0 {
+		return fmt.Sprintf("color:%d:%d", e.Flags, e.Color)
+	}
+	return fmt.Sprintf("color:%d", e.Color)
+}
+
+type MacMobilityExtended struct {
+	Sticky   bool
+	Sequence uint32
+}
+
+func (e *MacMobilityExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_EVPN
+	buf[1] = EC_SUBTYPE_MAC_MOBILITY
+	if e.Sticky {
+		buf[2] = 0x01
+	}
+	binary.BigEndian.PutUint32(buf[4:], e.Sequence)
+	return buf, nil
+}
+
+func (e *MacMobilityExtended) String() string {
+	if e.Sticky {
+		return fmt.Sprintf("mac-mobility:%d:sticky", e.Sequence)
+	}
+	return fmt.Sprintf("mac-mobility:%d", e.Sequence)
+}
+
+type RouterMacExtended struct {
+	Mac net.HardwareAddr
+}
+
+func (e *RouterMacExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_EVPN
+	buf[1] = EC_SUBTYPE_ROUTER_MAC
+	copy(buf[2:], e.Mac)
+	return buf, nil
+}
+
+func (e *RouterMacExtended) String() string {
+	return fmt.Sprintf("router-mac:%s", e.Mac)
+}
+
+// TrafficRateExtended limits the traffic matching a flow specification
+// to Rate bytes per second, a zero rate discards it.
+type TrafficRateExtended struct {
+	AS   uint16
+	Rate float32
+}
+
+func (e *TrafficRateExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL
+	buf[1] = EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE
+	binary.BigEndian.PutUint16(buf[2:], e.AS)
+	binary.BigEndian.PutUint32(buf[4:], math.Float32bits(e.Rate))
+	return buf, nil
+}
+
+// String returns "rate-limit:<rate>", or "rate-limit:<as>:<rate>" if the
+// AS is set.
+func (e *TrafficRateExtended) String() string {
+	rate := strconv.FormatFloat(float64(e.Rate), 'f', -1, 32)
+	if e.AS != 0 {
+		return fmt.Sprintf("rate-limit:%d:%s", e.AS, rate)
+	}
+	return fmt.Sprintf("rate-limit:%s", rate)
+}
+
+// RedirectTwoOctetAsSpecificExtended redirects the traffic matching a
+// flow specification to the VRF importing the route target.
+type RedirectTwoOctetAsSpecificExtended struct {
+	AS         uint16
+	LocalAdmin uint32
+}
+
+func (e *RedirectTwoOctetAsSpecificExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL
+	buf[1] = EC_SUBTYPE_FLOWSPEC_REDIRECT
+	binary.BigEndian.PutUint16(buf[2:], e.AS)
+	binary.BigEndian.PutUint32(buf[4:], e.LocalAdmin)
+	return buf, nil
+}
+
+func (e *RedirectTwoOctetAsSpecificExtended) String() string {
+	return fmt.Sprintf("redirect:%d:%d", e.AS, e.LocalAdmin)
+}
+
+// TrafficRemarkExtended sets the DSCP of the traffic matching a flow
+// specification.
+type TrafficRemarkExtended struct {
+	DSCP uint8
+}
+
+func (e *TrafficRemarkExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL
+	buf[1] = EC_SUBTYPE_FLOWSPEC_TRAFFIC_REMARK
+	buf[7] = e.DSCP
+	return buf, nil
+}
+
+func (e *TrafficRemarkExtended) String() string {
+	return fmt.Sprintf("mark:%d", e.DSCP)
+}
+
+// encapsulation type and control flags of the Layer2 Info extended
+// community, RFC 4761
+const (
+	LAYER2_ENCAP_TYPE_VPLS = 19
+
+	LAYER2_CONTROL_FLAG_SEQUENCED    = 0x01
+	LAYER2_CONTROL_FLAG_CONTROL_WORD = 0x02
+)
+
+// Layer2InfoExtended goes along the VPLS routes, the sites of a VPLS
+// can only be connected if their MTUs are the same.
+type Layer2InfoExtended struct {
+	EncapType    uint8
+	ControlFlags uint8
+	MTU          uint16
+}
+
+func (e *Layer2InfoExtended) Serialize() ([]byte, error) {
+	buf := make([]byte, 8)
+	buf[0] = EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL
+	buf[1] = EC_SUBTYPE_LAYER2_INFO
+	buf[2] = e.EncapType
+	buf[3] = e.ControlFlags
+	binary.BigEndian.PutUint16(buf[4:], e.MTU)
 	return buf, nil
 }
 
+// String returns "l2info:<encap type>:<control flags>:<mtu>".
+func (e *Layer2InfoExtended) String() string {
+	return fmt.Sprintf("l2info:%d:%d:%d", e.EncapType, e.ControlFlags, e.MTU)
+}
+
 type UnknownExtended struct {
 	Type  BGPAttrType
 	Value []byte
@@ -2135,36 +4464,246 @@ func (e *UnknownExtended) Serialize() ([]byte, error) {
 	return buf, nil
 }
 
+func (e *UnknownExtended) String() string {
+	return extendedHexString(e)
+}
+
+func parseAsSpecificExtended(subType uint8, s string) (ExtendedCommunityInterface, error) {
+	elems := strings.Split(s, ":")
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid extended community value %s", s)
+	}
+	if ip := net.ParseIP(elems[0]).To4(); ip != nil {
+		localAdmin, err := strconv.ParseUint(elems[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extended community value %s", s)
+		}
+		return &IPv4AddressSpecificExtended{SubType: subType, IPv4: ip, LocalAdmin: uint16(localAdmin)}, nil
+	}
+	four := strings.HasSuffix(elems[0], "L")
+	as, err := strconv.ParseUint(strings.TrimSuffix(elems[0], "L"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community value %s", s)
+	}
+	if four || as > math.MaxUint16 {
+		localAdmin, err := strconv.ParseUint(elems[1], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extended community value %s", s)
+		}
+		return &FourOctetAsSpecificExtended{SubType: subType, AS: uint32(as), LocalAdmin: uint16(localAdmin)}, nil
+	}
+	localAdmin, err := strconv.ParseUint(elems[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community value %s", s)
+	}
+	return &TwoOctetAsSpecificExtended{SubType: subType, AS: uint16(as), LocalAdmin: uint32(localAdmin)}, nil
+}
+
+func parseExtendedValue(name, value string) (ExtendedCommunityInterface, error) {
+	elems := strings.Split(value, ":")
+	switch name {
+	case "rt":
+		return parseAsSpecificExtended(EC_SUBTYPE_ROUTE_TARGET, value)
+	case "soo":
+		return parseAsSpecificExtended(EC_SUBTYPE_ROUTE_ORIGIN, value)
+	case "lb":
+		if len(elems) == 2 {
+			as, err1 := strconv.ParseUint(elems[0], 10, 16)
+			bw, err2 := strconv.ParseFloat(elems[1], 32)
+			if err1 == nil && err2 == nil && bw >= 0 {
+				return &LinkBandwidthExtended{AS: uint16(as), Bandwidth: float32(bw)}, nil
+			}
+		}
+	case "encap":
+		for t, n := range tunnelTypeNames {
+			if n == strings.ToLower(value) {
+				return &EncapExtended{TunnelType: t}, nil
+			}
+		}
+		if t, err := strconv.ParseUint(value, 10, 16); err == nil {
+			return &EncapExtended{TunnelType: TunnelType(t)}, nil
+		}
+	case "color":
+		if len(elems) == 1 {
+			elems = []string{"0", elems[0]}
+		}
+		if len(elems) == 2 {
+			flags, err1 := strconv.ParseUint(elems[0], 10, 16)
+			color, err2 := strconv.ParseUint(elems[1], 10, 32)
+			if err1 == nil && err2 == nil {
+				return &ColorExtended{Flags: uint16(flags), Color: uint32(color)}, nil
+			}
+		}
+	case "mac-mobility":
+		seq, err := strconv.ParseUint(elems[0], 10, 32)
+		switch {
+		case err != nil:
+		case len(elems) == 1:
+			return &MacMobilityExtended{Sequence: uint32(seq)}, nil
+		case len(elems) == 2 && strings.ToLower(elems[1]) == "sticky":
+			return &MacMobilityExtended{Sticky: true, Sequence: uint32(seq)}, nil
+		}
+	case "router-mac":
+		if mac, err := net.ParseMAC(value); err == nil && len(mac) == 6 {
+			return &RouterMacExtended{Mac: mac}, nil
+		}
+	case "rate-limit":
+		if len(elems) == 1 {
+			elems = []string{"0", elems[0]}
+		}
+		if len(elems) == 2 {
+			as, err1 := strconv.ParseUint(elems[0], 10, 16)
+			rate, err2 := strconv.ParseFloat(elems[1], 32)
+			if err1 == nil && err2 == nil && rate >= 0 {
+				return &TrafficRateExtended{AS: uint16(as), Rate: float32(rate)}, nil
+			}
+		}
+	case "redirect":
+		if len(elems) == 2 {
+			as, err1 := strconv.ParseUint(elems[0], 10, 16)
+			localAdmin, err2 := strconv.ParseUint(elems[1], 10, 32)
+			if err1 == nil && err2 == nil {
+				return &RedirectTwoOctetAsSpecificExtended{AS: uint16(as), LocalAdmin: uint32(localAdmin)}, nil
+			}
+		}
+	case "mark":
+		if dscp, err := strconv.ParseUint(value, 10, 8); err == nil && dscp < 64 {
+			return &TrafficRemarkExtended{DSCP: uint8(dscp)}, nil
+		}
+	case "l2info":
+		if len(elems) == 3 {
+			encap, err1 := strconv.ParseUint(elems[0], 10, 8)
+			flags, err2 := strconv.ParseUint(elems[1], 10, 8)
+			mtu, err3 := strconv.ParseUint(elems[2], 10, 16)
+			if err1 == nil && err2 == nil && err3 == nil {
+				return &Layer2InfoExtended{EncapType: uint8(encap), ControlFlags: uint8(flags), MTU: uint16(mtu)}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("invalid extended community value %s", value)
+}
+
+// ParseExtendedCommunity parses the text form returned by the String
+// method of the extended communities: "rt:65000:100",
+// "rt:4200000000:100", "rt:65000L:100", "soo:10.0.0.1:100",
+// "lb:65000:125000000", "encap:vxlan", "color:100",
+// "mac-mobility:1:sticky", "router-mac:00:11:22:33:44:55",
+// "rate-limit:125000", "redirect:65000:100", "mark:46",
+// "l2info:19:0:1500" or the
+// "0x0003000000000000" hex form of any extended community.
+func ParseExtendedCommunity(s string) (ExtendedCommunityInterface, error) {
+	if strings.HasPrefix(strings.ToLower(s), "0x") {
+		v, err := strconv.ParseUint(s[2:], 16, 64)
+		if err != nil || len(s) != 18 {
+			return nil, fmt.Errorf("invalid extended community %s", s)
+		}
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, v)
+		return parseExtended(buf), nil
+	}
+	elems := strings.SplitN(s, ":", 2)
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid extended community %s", s)
+	}
+	e, err := parseExtendedValue(strings.ToLower(elems[0]), elems[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid extended community %s", s)
+	}
+	return e, nil
+}
+
 type PathAttributeExtendedCommunities struct {
 	PathAttribute
 	Value []ExtendedCommunityInterface
 }
 
+// parseExtended decodes an extended community. The types and sub-types
+// without a specific decoding, including the non-transitive variants of
+// the AS and IPv4 address specific ones, are kept as OpaqueExtended or
+// UnknownExtended so that they serialize back to the same bytes.
 func parseExtended(data []byte) ExtendedCommunityInterface {
-	typehigh := data[0] & ^uint8(0x40)
-	switch typehigh {
-	case 0:
+	switch data[0] {
+	case EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC:
 		e := &TwoOctetAsSpecificExtended{}
 		e.SubType = data[1]
 		e.AS = binary.BigEndian.Uint16(data[2:4])
 		e.LocalAdmin = binary.BigEndian.Uint32(data[4:8])
 		return e
-	case 1:
+	case EC_TYPE_TRANSITIVE_IP4_SPECIFIC:
 		e := &IPv4AddressSpecificExtended{}
 		e.SubType = data[1]
 		e.IPv4 = data[2:6]
 		e.LocalAdmin = binary.BigEndian.Uint16(data[6:8])
 		return e
-	case 2:
+	case EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC:
 		e := &FourOctetAsSpecificExtended{}
 		e.SubType = data[1]
 		e.AS = binary.BigEndian.Uint32(data[2:6])
 		e.LocalAdmin = binary.BigEndian.Uint16(data[6:8])
 		return e
-	case 3:
+	case EC_TYPE_TRANSITIVE_OPAQUE:
+		switch data[1] {
+		case EC_SUBTYPE_COLOR:
+			e := &ColorExtended{}
+			e.Flags = binary.BigEndian.Uint16(data[2:4])
+			e.Color = binary.BigEndian.Uint32(data[4:8])
+			return e
+		case EC_SUBTYPE_ENCAPSULATION:
+			if binary.BigEndian.Uint32(data[2:6]) == 0 {
+				e := &EncapExtended{}
+				e.TunnelType = TunnelType(binary.BigEndian.Uint16(data[6:8]))
+				return e
+			}
+		}
 		e := &OpaqueExtended{}
 		e.Value = data[1:8]
 		return e
+	case EC_TYPE_EVPN:
+		switch data[1] {
+		case EC_SUBTYPE_MAC_MOBILITY:
+			if data[2]&^0x01 == 0 && data[3] == 0 {
+				e := &MacMobilityExtended{}
+				e.Sticky = data[2]&0x01 != 0
+				e.Sequence = binary.BigEndian.Uint32(data[4:8])
+				return e
+			}
+		case EC_SUBTYPE_ROUTER_MAC:
+			e := &RouterMacExtended{}
+			e.Mac = net.HardwareAddr(data[2:8])
+			return e
+		}
+	case EC_TYPE_NON_TRANSITIVE_TWO_OCTET_AS_SPECIFIC:
+		if data[1] == EC_SUBTYPE_LINK_BANDWIDTH {
+			e := &LinkBandwidthExtended{}
+			e.AS = binary.BigEndian.Uint16(data[2:4])
+			e.Bandwidth = math.Float32frombits(binary.BigEndian.Uint32(data[4:8]))
+			return e
+		}
+	case EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL:
+		switch data[1] {
+		case EC_SUBTYPE_FLOWSPEC_TRAFFIC_RATE:
+			e := &TrafficRateExtended{}
+			e.AS = binary.BigEndian.Uint16(data[2:4])
+			e.Rate = math.Float32frombits(binary.BigEndian.Uint32(data[4:8]))
+			return e
+		case EC_SUBTYPE_FLOWSPEC_REDIRECT:
+			e := &RedirectTwoOctetAsSpecificExtended{}
+			e.AS = binary.BigEndian.Uint16(data[2:4])
+			e.LocalAdmin = binary.BigEndian.Uint32(data[4:8])
+			return e
+		case EC_SUBTYPE_FLOWSPEC_TRAFFIC_REMARK:
+			if bytes.Equal(data[2:7], make([]byte, 5)) && data[7] < 64 {
+				e := &TrafficRemarkExtended{}
+				e.DSCP = data[7]
+				return e
+			}
+		case EC_SUBTYPE_LAYER2_INFO:
+			e := &Layer2InfoExtended{}
+			e.EncapType = data[2]
+			e.ControlFlags = data[3]
+			e.MTU = binary.BigEndian.Uint16(data[4:6])
+			return e
+		}
 	}
 	e := &UnknownExtended{}
 	e.Type = BGPAttrType(data[0])
@@ -2204,6 +4743,20 @@ func (p *PathAttributeExtendedCommunities) Serialize() ([]byte, error) {
 	return p.PathAttribute.Serialize()
 }
 
+func (p *PathAttributeExtendedCommunities) MarshalJSON() ([]byte, error) {
+	value := make([]string, len(p.Value))
+	for i, e := range p.Value {
+		value[i] = e.String()
+	}
+	return json.Marshal(struct {
+		Type  string
+		Value []string
+	}{
+		Type:  p.Type.String(),
+		Value: value,
+	})
+}
+
 func NewPathAttributeExtendedCommunities(value []ExtendedCommunityInterface) *PathAttributeExtendedCommunities {
 	t := BGP_ATTR_TYPE_EXTENDED_COMMUNITIES
 	return &PathAttributeExtendedCommunities{
@@ -2323,8 +4876,422 @@ func NewPathAttributeAs4Aggregator(as uint32, address string) *PathAttributeAs4A
 		},
 		Value: PathAttributeAggregatorParam{
 			AS:      as,
-			Address: net.ParseIP(address),
+			Address: net.ParseIP(address).To4(),
+		},
+	}
+}
+
+type AigpTLVType uint8
+
+const (
+	_ AigpTLVType = iota
+	AIGP_TLV_IGP_METRIC
+)
+
+type AigpTLV struct {
+	Type  AigpTLVType
+	Value []byte
+}
+
+type PathAttributeAigp struct {
+	PathAttribute
+	Value []AigpTLV
+}
+
+func (p *PathAttributeAigp) DecodeFromBytes(data []byte) error {
+	err := p.PathAttribute.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_OPTIONAL_ATTRIBUTE_ERROR)
+	value := p.PathAttribute.Value
+	p.Value = make([]AigpTLV, 0)
+	for len(value) > 0 {
+		if len(value) < 3 {
+			return NewMessageError(eCode, eSubCode, nil, "AIGP TLV header length is short")
+		}
+		t := AigpTLVType(value[0])
+		l := int(binary.BigEndian.Uint16(value[1:3]))
+		if l < 3 || len(value) < l {
+			return NewMessageError(eCode, eSubCode, nil, "AIGP TLV length is incorrect")
+		}
+		if t == AIGP_TLV_IGP_METRIC && l != 11 {
+			return NewMessageError(eCode, eSubCode, nil, "AIGP metric TLV length is incorrect")
+		}
+		p.Value = append(p.Value, AigpTLV{
+			Type:  t,
+			Value: value[3:l],
+		})
+		value = value[l:]
+	}
+	return nil
+}
+
+func (p *PathAttributeAigp) Serialize() ([]byte, error) {
+	buf := make([]byte, 0)
+	for _, tlv := range p.Value {
+		b := make([]byte, 3+len(tlv.Value))
+		b[0] = uint8(tlv.Type)
+		binary.BigEndian.PutUint16(b[1:3], uint16(len(b)))
+		copy(b[3:], tlv.Value)
+		buf = append(buf, b...)
+	}
+	p.PathAttribute.Value = buf
+	return p.PathAttribute.Serialize()
+}
+
+// Metric returns the value of the first AIGP metric TLV, further
+// metric TLVs are ignored as specified in RFC 7311.
+func (p *PathAttributeAigp) Metric() (uint64, bool) {
+	for _, tlv := range p.Value {
+		if tlv.Type == AIGP_TLV_IGP_METRIC && len(tlv.Value) == 8 {
+			return binary.BigEndian.Uint64(tlv.Value), true
+		}
+	}
+	return 0, false
+}
+
+func (p *PathAttributeAigp) MarshalJSON() ([]byte, error) {
+	metric, _ := p.Metric()
+	return json.Marshal(struct {
+		Type   string
+		Metric uint64
+	}{
+		Type:   p.Type.String(),
+		Metric: metric,
+	})
+}
+
+func NewPathAttributeAigp(metric uint64) *PathAttributeAigp {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, metric)
+	t := BGP_ATTR_TYPE_AIGP
+	return &PathAttributeAigp{
+		PathAttribute: PathAttribute{
+			Flags: pathAttrFlags[t],
+			Type:  t,
+		},
+		Value: []AigpTLV{{
+			Type:  AIGP_TLV_IGP_METRIC,
+			Value: buf,
+		}},
+	}
+}
+
+// TLV types of the BGP-LS attribute
+const (
+	LS_TLV_NODE_NAME                = 1026
+	LS_TLV_LOCAL_IPV4_ROUTER_ID     = 1028
+	LS_TLV_LOCAL_IPV6_ROUTER_ID     = 1029
+	LS_TLV_REMOTE_IPV4_ROUTER_ID    = 1030
+	LS_TLV_REMOTE_IPV6_ROUTER_ID    = 1031
+	LS_TLV_ADMIN_GROUP              = 1088
+	LS_TLV_MAX_LINK_BANDWIDTH       = 1089
+	LS_TLV_MAX_RESERVABLE_BANDWIDTH = 1090
+	LS_TLV_UNRESERVED_BANDWIDTH     = 1091
+	LS_TLV_TE_DEFAULT_METRIC        = 1092
+	LS_TLV_IGP_METRIC               = 1095
+	LS_TLV_LINK_NAME                = 1098
+	LS_TLV_IGP_FLAGS                = 1152
+	LS_TLV_PREFIX_METRIC            = 1155
+)
+
+// LsAttributes holds the values of the known TLVs of a BGP-LS
+// attribute, the absent ones are nil or empty. The bandwidths are in
+// bytes per second.
+type LsAttributes struct {
+	NodeName               string    `json:"node_name,omitempty"`
+	LocalRouterID          net.IP    `json:"local_router_id,omitempty"`
+	RemoteRouterID         net.IP    `json:"remote_router_id,omitempty"`
+	AdminGroup             *uint32   `json:"admin_group,omitempty"`
+	MaxLinkBandwidth       *float32  `json:"max_link_bandwidth,omitempty"`
+	MaxReservableBandwidth *float32  `json:"max_reservable_bandwidth,omitempty"`
+	UnreservedBandwidth    []float32 `json:"unreserved_bandwidth,omitempty"`
+	TEDefaultMetric        *uint32   `json:"te_default_metric,omitempty"`
+	IGPMetric              *uint32   `json:"igp_metric,omitempty"`
+	LinkName               string    `json:"link_name,omitempty"`
+	PrefixMetric           *uint32   `json:"prefix_metric,omitempty"`
+}
+
+// PathAttributeLs is the BGP-LS attribute (RFC 7752) carrying the
+// properties of the node, link or prefix of a BGP-LS route.
+type PathAttributeLs struct {
+	PathAttribute
+	Value []*LsTLV
+}
+
+func (p *PathAttributeLs) DecodeFromBytes(data []byte) error {
+	err := p.PathAttribute.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+	eSubCode := uint8(BGP_ERROR_SUB_OPTIONAL_ATTRIBUTE_ERROR)
+	p.Value, err = decodeLsTLVs(p.PathAttribute.Value)
+	if err == nil {
+		_, err = p.Attributes()
+	}
+	if err != nil {
+		return NewMessageError(eCode, eSubCode, nil, err.Error())
+	}
+	return nil
+}
+
+func (p *PathAttributeLs) Serialize() ([]byte, error) {
+	p.PathAttribute.Value = serializeLsTLVs(p.Value)
+	return p.PathAttribute.Serialize()
+}
+
+// Attributes returns the values of the known TLVs, the unknown ones are
+// ignored.
+func (p *PathAttributeLs) Attributes() (*LsAttributes, error) {
+	a := &LsAttributes{}
+	uint32Value := func(tlv *LsTLV) (*uint32, error) {
+		if len(tlv.Value) != 4 {
+			return nil, fmt.Errorf("bgp-ls attribute tlv %d length is incorrect", tlv.Type)
+		}
+		v := binary.BigEndian.Uint32(tlv.Value)
+		return &v, nil
+	}
+	float32Value := func(b []byte) *float32 {
+		v := math.Float32frombits(binary.BigEndian.Uint32(b))
+		return &v
+	}
+	var err error
+	for _, tlv := range p.Value {
+		switch tlv.Type {
+		case LS_TLV_NODE_NAME:
+			a.NodeName = string(tlv.Value)
+		case LS_TLV_LINK_NAME:
+			a.LinkName = string(tlv.Value)
+		case LS_TLV_LOCAL_IPV4_ROUTER_ID, LS_TLV_LOCAL_IPV6_ROUTER_ID, LS_TLV_REMOTE_IPV4_ROUTER_ID, LS_TLV_REMOTE_IPV6_ROUTER_ID:
+			addrlen := net.IPv4len
+			if tlv.Type == LS_TLV_LOCAL_IPV6_ROUTER_ID || tlv.Type == LS_TLV_REMOTE_IPV6_ROUTER_ID {
+				addrlen = net.IPv6len
+			}
+			if len(tlv.Value) != addrlen {
+				return nil, fmt.Errorf("bgp-ls router id length is incorrect")
+			}
+			if tlv.Type == LS_TLV_LOCAL_IPV4_ROUTER_ID || tlv.Type == LS_TLV_LOCAL_IPV6_ROUTER_ID {
+				a.LocalRouterID = net.IP(tlv.Value)
+			} else {
+				a.RemoteRouterID = net.IP(tlv.Value)
+			}
+		case LS_TLV_ADMIN_GROUP:
+			a.AdminGroup, err = uint32Value(tlv)
+		case LS_TLV_TE_DEFAULT_METRIC:
+			a.TEDefaultMetric, err = uint32Value(tlv)
+		case LS_TLV_PREFIX_METRIC:
+			a.PrefixMetric, err = uint32Value(tlv)
+		case LS_TLV_MAX_LINK_BANDWIDTH, LS_TLV_MAX_RESERVABLE_BANDWIDTH:
+			if len(tlv.Value) != 4 {
+				return nil, fmt.Errorf("bgp-ls bandwidth length is incorrect")
+			}
+			if tlv.Type == LS_TLV_MAX_LINK_BANDWIDTH {
+				a.MaxLinkBandwidth = float32Value(tlv.Value)
+			} else {
+				a.MaxReservableBandwidth = float32Value(tlv.Value)
+			}
+		case LS_TLV_UNRESERVED_BANDWIDTH:
+			if len(tlv.Value) != 32 {
+				return nil, fmt.Errorf("bgp-ls unreserved bandwidth length is incorrect")
+			}
+			a.UnreservedBandwidth = make([]float32, 0, 8)
+			for i := 0; i < 32; i += 4 {
+				a.UnreservedBandwidth = append(a.UnreservedBandwidth, *float32Value(tlv.Value[i:]))
+			}
+		case LS_TLV_IGP_METRIC:
+			// the IS-IS narrow metrics are 1 byte long, the wide ones
+			// and the OSPF metrics 3 and 2 bytes
+			if len(tlv.Value) < 1 || len(tlv.Value) > 3 {
+				return nil, fmt.Errorf("bgp-ls igp metric length is incorrect")
+			}
+			var v uint32
+			for _, b := range tlv.Value {
+				v = v<<8 | uint32(b)
+			}
+			if len(tlv.Value) == 1 {
+				v &= 0x3f
+			}
+			a.IGPMetric = &v
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+func (p *PathAttributeLs) MarshalJSON() ([]byte, error) {
+	a, _ := p.Attributes()
+	return json.Marshal(struct {
+		Type  string
+		Value *LsAttributes
+	}{
+		Type:  p.Type.String(),
+		Value: a,
+	})
+}
+
+func NewPathAttributeLs(a *LsAttributes) *PathAttributeLs {
+	tlvs := make([]*LsTLV, 0)
+	if a.NodeName != "" {
+		tlvs = append(tlvs, &LsTLV{Type: LS_TLV_NODE_NAME, Value: []byte(a.NodeName)})
+	}
+	routerID := func(ip net.IP, v4 uint16, v6 uint16) {
+		if ip == nil {
+			return
+		}
+		if ip.To4() != nil {
+			tlvs = append(tlvs, &LsTLV{Type: v4, Value: ip.To4()})
+		} else {
+			tlvs = append(tlvs, &LsTLV{Type: v6, Value: ip.To16()})
+		}
+	}
+	routerID(a.LocalRouterID, LS_TLV_LOCAL_IPV4_ROUTER_ID, LS_TLV_LOCAL_IPV6_ROUTER_ID)
+	routerID(a.RemoteRouterID, LS_TLV_REMOTE_IPV4_ROUTER_ID, LS_TLV_REMOTE_IPV6_ROUTER_ID)
+	if a.AdminGroup != nil {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_ADMIN_GROUP, *a.AdminGroup))
+	}
+	if a.MaxLinkBandwidth != nil {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_MAX_LINK_BANDWIDTH, math.Float32bits(*a.MaxLinkBandwidth)))
+	}
+	if a.MaxReservableBandwidth != nil {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_MAX_RESERVABLE_BANDWIDTH, math.Float32bits(*a.MaxReservableBandwidth)))
+	}
+	if len(a.UnreservedBandwidth) == 8 {
+		buf := make([]byte, 32)
+		for i, bw := range a.UnreservedBandwidth {
+			binary.BigEndian.PutUint32(buf[i*4:], math.Float32bits(bw))
+		}
+		tlvs = append(tlvs, &LsTLV{Type: LS_TLV_UNRESERVED_BANDWIDTH, Value: buf})
+	}
+	if a.TEDefaultMetric != nil {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_TE_DEFAULT_METRIC, *a.TEDefaultMetric))
+	}
+	if a.IGPMetric != nil {
+		buf := make([]byte, 4)
+		binary.BigEndian.PutUint32(buf, *a.IGPMetric)
+		if *a.IGPMetric > 0xffff {
+			buf = buf[1:]
+		} else {
+			buf = buf[2:]
+		}
+		tlvs = append(tlvs, &LsTLV{Type: LS_TLV_IGP_METRIC, Value: buf})
+	}
+	if a.LinkName != "" {
+		tlvs = append(tlvs, &LsTLV{Type: LS_TLV_LINK_NAME, Value: []byte(a.LinkName)})
+	}
+	if a.PrefixMetric != nil {
+		tlvs = append(tlvs, newLsUint32TLV(LS_TLV_PREFIX_METRIC, *a.PrefixMetric))
+	}
+	t := BGP_ATTR_TYPE_LS
+	return &PathAttributeLs{
+		PathAttribute: PathAttribute{
+			Flags: pathAttrFlags[t],
+			Type:  t,
+		},
+		Value: tlvs,
+	}
+}
+
+// LargeCommunity is a large community, RFC 8092.
+type LargeCommunity struct {
+	ASN        uint32
+	LocalData1 uint32
+	LocalData2 uint32
+}
+
+func (c *LargeCommunity) String() string {
+	return fmt.Sprintf("%d:%d:%d", c.ASN, c.LocalData1, c.LocalData2)
+}
+
+// ParseLargeCommunity parses the "asn:x:y" form of a large community.
+func ParseLargeCommunity(s string) (*LargeCommunity, error) {
+	elems := strings.Split(s, ":")
+	if len(elems) != 3 {
+		return nil, fmt.Errorf("invalid large community %s", s)
+	}
+	v := make([]uint32, 3)
+	for i, e := range elems {
+		n, err := strconv.ParseUint(e, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid large community %s", s)
+		}
+		v[i] = uint32(n)
+	}
+	return &LargeCommunity{ASN: v[0], LocalData1: v[1], LocalData2: v[2]}, nil
+}
+
+type PathAttributeLargeCommunities struct {
+	PathAttribute
+	Value []*LargeCommunity
+}
+
+// DecodeFromBytes decodes a LARGE_COMMUNITY attribute. Duplicated large
+// communities are removed as required by RFC 8092.
+func (p *PathAttributeLargeCommunities) DecodeFromBytes(data []byte) error {
+	err := p.PathAttribute.DecodeFromBytes(data)
+	if err != nil {
+		return err
+	}
+	value := p.PathAttribute.Value
+	if len(value) == 0 || len(value)%12 != 0 {
+		eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
+		eSubCode := uint8(BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR)
+		return NewMessageError(eCode, eSubCode, nil, "large communities length isn't correct")
+	}
+	p.Value = make([]*LargeCommunity, 0, len(value)/12)
+	seen := make(map[LargeCommunity]bool)
+	for len(value) >= 12 {
+		c := &LargeCommunity{
+			ASN:        binary.BigEndian.Uint32(value[0:4]),
+			LocalData1: binary.BigEndian.Uint32(value[4:8]),
+			LocalData2: binary.BigEndian.Uint32(value[8:12]),
+		}
+		if !seen[*c] {
+			seen[*c] = true
+			p.Value = append(p.Value, c)
+		}
+		value = value[12:]
+	}
+	return nil
+}
+
+func (p *PathAttributeLargeCommunities) Serialize() ([]byte, error) {
+	buf := make([]byte, len(p.Value)*12)
+	for i, c := range p.Value {
+		binary.BigEndian.PutUint32(buf[i*12:], c.ASN)
+		binary.BigEndian.PutUint32(buf[i*12+4:], c.LocalData1)
+		binary.BigEndian.PutUint32(buf[i*12+8:], c.LocalData2)
+	}
+	p.PathAttribute.Value = buf
+	return p.PathAttribute.Serialize()
+}
+
+func (p *PathAttributeLargeCommunities) MarshalJSON() ([]byte, error) {
+	value := make([]string, len(p.Value))
+	for i, c := range p.Value {
+		value[i] = c.String()
+	}
+	return json.Marshal(struct {
+		Type  string
+		Value []string
+	}{
+		Type:  p.Type.String(),
+		Value: value,
+	})
+}
+
+func NewPathAttributeLargeCommunities(value []*LargeCommunity) *PathAttributeLargeCommunities {
+	t := BGP_ATTR_TYPE_LARGE_COMMUNITY
+	return &PathAttributeLargeCommunities{
+		PathAttribute: PathAttribute{
+			Flags: pathAttrFlags[t],
+			Type:  t,
 		},
+		Value: value,
 	}
 }
 
@@ -2370,6 +5337,12 @@ func getPathAttribute(data []byte) (PathAttributeInterface, error) {
 		return &PathAttributeAs4Path{}, nil
 	case BGP_ATTR_TYPE_AS4_AGGREGATOR:
 		return &PathAttributeAs4Aggregator{}, nil
+	case BGP_ATTR_TYPE_AIGP:
+		return &PathAttributeAigp{}, nil
+	case BGP_ATTR_TYPE_LS:
+		return &PathAttributeLs{}, nil
+	case BGP_ATTR_TYPE_LARGE_COMMUNITY:
+		return &PathAttributeLargeCommunities{}, nil
 	}
 	return &PathAttributeUnknown{}, nil
 }
@@ -2446,22 +5419,42 @@ func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
 		return e
 	}
 
-	for pathlen := msg.TotalPathAttributeLen; pathlen > 0; {
-		p, err := getPathAttribute(data)
-		if err != nil {
-			return err
+	// RFC 7606: malformed attributes are handled once the NLRI is decoded
+	// unless they make the NLRI unusable, attributes which can't be
+	// decoded are left out of PathAttributes.
+	var attrErr *MessageError
+	overrun := false
+	attrData := data[:msg.TotalPathAttributeLen]
+	for len(attrData) > 0 {
+		l := len(attrData) + 1
+		if attrData[0]&BGP_ATTR_FLAG_EXTENDED_LENGTH != 0 {
+			if len(attrData) >= 4 {
+				l = 4 + int(binary.BigEndian.Uint16(attrData[2:4]))
+			}
+		} else if len(attrData) >= 3 {
+			l = 3 + int(attrData[2])
 		}
-		err = p.DecodeFromBytes(data)
-		if err != nil {
-			return err
+		if l > len(attrData) {
+			// the rest of the attributes can't be located
+			attrErr = withErrorHandling(NewMessageError(eCode, BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR, attrData, "attribute length is short"), ERROR_HANDLING_TREAT_AS_WITHDRAW)
+			overrun = true
+			break
 		}
-		pathlen -= uint16(p.Len())
-		if len(data) < p.Len() {
-			return NewMessageError(eCode, BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR, data, "attribute length is short")
+		p, _ := getPathAttribute(attrData)
+		if err := p.DecodeFromBytes(attrData[:l]); err != nil {
+			e := withErrorHandling(err, attributeErrorHandling(BGPAttrType(attrData[1])))
+			if e.ErrorHandling > ERROR_HANDLING_TREAT_AS_WITHDRAW {
+				return e
+			}
+			if attrErr == nil || e.ErrorHandling > attrErr.ErrorHandling {
+				attrErr = e
+			}
+		} else {
+			msg.PathAttributes = append(msg.PathAttributes, p)
 		}
-		data = data[p.Len():]
-		msg.PathAttributes = append(msg.PathAttributes, p)
+		attrData = attrData[l:]
 	}
+	data = data[msg.TotalPathAttributeLen:]
 
 	for restlen := len(data); restlen > 0; {
 		n := NLRInfo{}
@@ -2477,6 +5470,15 @@ func (msg *BGPUpdate) DecodeFromBytes(data []byte) error {
 		msg.NLRI = append(msg.NLRI, n)
 	}
 
+	// The attributes which can't be located may carry the MP_REACH_NLRI
+	// or MP_UNREACH_NLRI of the update. Treat-as-withdraw can't do without
+	// them when the update has nothing else to withdraw, RFC 7606.
+	if overrun && len(msg.WithdrawnRoutes) == 0 && len(msg.NLRI) == 0 {
+		attrErr.ErrorHandling = ERROR_HANDLING_SESSION_RESET
+	}
+	if attrErr != nil {
+		return attrErr
+	}
 	return nil
 }
 
@@ -2664,6 +5666,10 @@ func parseBody(h *BGPHeader, data []byte) (*BGPMessage, error) {
 	}
 	err := msg.Body.DecodeFromBytes(data)
 	if err != nil {
+		// the message is usable despite the error, RFC 7606
+		if e, y := err.(*MessageError); y && e.ErrorHandling <= ERROR_HANDLING_TREAT_AS_WITHDRAW {
+			return msg, err
+		}
 		return nil, err
 	}
 	return msg, nil
@@ -2697,20 +5703,82 @@ func (msg *BGPMessage) Serialize() ([]byte, error) {
 	return append(h, b...), nil
 }
 
+// ErrorHandling is the way an error in an UPDATE message is handled,
+// RFC 7606. The values are ordered by severity.
+type ErrorHandling int
+
+const (
+	ERROR_HANDLING_NONE ErrorHandling = iota
+	ERROR_HANDLING_ATTRIBUTE_DISCARD
+	ERROR_HANDLING_TREAT_AS_WITHDRAW
+	ERROR_HANDLING_AFISAFI_DISABLE
+	ERROR_HANDLING_SESSION_RESET
+)
+
+func (e ErrorHandling) String() string {
+	switch e {
+	case ERROR_HANDLING_NONE:
+		return "none"
+	case ERROR_HANDLING_ATTRIBUTE_DISCARD:
+		return "attribute-discard"
+	case ERROR_HANDLING_TREAT_AS_WITHDRAW:
+		return "treat-as-withdraw"
+	case ERROR_HANDLING_AFISAFI_DISABLE:
+		return "afi-safi-disable"
+	case ERROR_HANDLING_SESSION_RESET:
+		return "session-reset"
+	}
+	return fmt.Sprintf("ErrorHandling(%d)", int(e))
+}
+
 type MessageError struct {
-	TypeCode    uint8
-	SubTypeCode uint8
-	Data        []byte
-	Message     string
+	TypeCode      uint8
+	SubTypeCode   uint8
+	Data          []byte
+	Message       string
+	ErrorHandling ErrorHandling
 }
 
+// NewMessageError returns an error which resets the session.
 func NewMessageError(typeCode, subTypeCode uint8, data []byte, msg string) error {
+	return NewMessageErrorWithErrorHandling(typeCode, subTypeCode, data, msg, ERROR_HANDLING_SESSION_RESET)
+}
+
+func NewMessageErrorWithErrorHandling(typeCode, subTypeCode uint8, data []byte, msg string, handling ErrorHandling) error {
 	return &MessageError{
-		TypeCode:    typeCode,
-		SubTypeCode: subTypeCode,
-		Data:        data,
-		Message:     msg,
+		TypeCode:      typeCode,
+		SubTypeCode:   subTypeCode,
+		Data:          data,
+		Message:       msg,
+		ErrorHandling: handling,
+	}
+}
+
+// withErrorHandling returns err as a MessageError handled with handling.
+func withErrorHandling(err error, handling ErrorHandling) *MessageError {
+	e, y := err.(*MessageError)
+	if !y {
+		e = &MessageError{
+			TypeCode:    BGP_ERROR_UPDATE_MESSAGE_ERROR,
+			SubTypeCode: BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST,
+			Message:     err.Error(),
+		}
+	}
+	e.ErrorHandling = handling
+	return e
+}
+
+// attributeErrorHandling returns how a malformed attribute of type t is
+// handled, RFC 7606 section 7 and the RFCs of the newer attributes.
+func attributeErrorHandling(t BGPAttrType) ErrorHandling {
+	switch t {
+	case BGP_ATTR_TYPE_ATOMIC_AGGREGATE, BGP_ATTR_TYPE_AGGREGATOR, BGP_ATTR_TYPE_AS4_PATH,
+		BGP_ATTR_TYPE_AS4_AGGREGATOR, BGP_ATTR_TYPE_AIGP, BGP_ATTR_TYPE_LS:
+		return ERROR_HANDLING_ATTRIBUTE_DISCARD
+	case BGP_ATTR_TYPE_MP_REACH_NLRI, BGP_ATTR_TYPE_MP_UNREACH_NLRI:
+		return ERROR_HANDLING_AFISAFI_DISABLE
 	}
+	return ERROR_HANDLING_TREAT_AS_WITHDRAW
 }
 
 func (e *MessageError) Error() string {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
 	"net"
+	"strings"
 	"testing"
 )
 
@@ -99,6 +100,8 @@ func update() *BGPMessage {
 		NewPathAttributeExtendedCommunities(ecommunities),
 		NewPathAttributeAs4Path(aspath3),
 		NewPathAttributeAs4Aggregator(10000, "112.22.2.1"),
+		NewPathAttributeAigp(1000),
+		NewPathAttributeLargeCommunities([]*LargeCommunity{{ASN: 4200000000, LocalData1: 1, LocalData2: 2}}),
 		NewPathAttributeMpReachNLRI("112.22.2.0", mp_nlri),
 		NewPathAttributeMpReachNLRI("1023::", mp_nlri2),
 		NewPathAttributeMpReachNLRI("fe80::", mp_nlri3),
@@ -144,3 +147,543 @@ func Test_IPAddrPrefixString(t *testing.T) {
 	ipv6 = NewIPv6AddrPrefix(18, "3343:faba:3903::0")
 	assert.Equal(t, "3343:faba:3903::/18", ipv6.String())
 }
+
+func Test_PathAttributeAigp(t *testing.T) {
+	buf, _ := NewPathAttributeAigp(1 << 40).Serialize()
+	p := &PathAttributeAigp{}
+	assert.Nil(t, p.DecodeFromBytes(buf))
+	metric, found := p.Metric()
+	assert.True(t, found)
+	assert.Equal(t, uint64(1<<40), metric)
+
+	// the metric TLV must carry an 8 byte value
+	buf = []byte{BGP_ATTR_FLAG_OPTIONAL, byte(BGP_ATTR_TYPE_AIGP), 7, byte(AIGP_TLV_IGP_METRIC), 0, 7, 0, 0, 0, 1}
+	assert.NotNil(t, (&PathAttributeAigp{}).DecodeFromBytes(buf))
+}
+
+func Test_PathAttributeLargeCommunities(t *testing.T) {
+	c1 := &LargeCommunity{ASN: 4200000000, LocalData1: 1, LocalData2: 2}
+	c2 := &LargeCommunity{ASN: 65000, LocalData1: 0, LocalData2: 4294967295}
+	buf, _ := NewPathAttributeLargeCommunities([]*LargeCommunity{c1, c2, c1}).Serialize()
+	p := &PathAttributeLargeCommunities{}
+	assert.Nil(t, p.DecodeFromBytes(buf))
+	// duplicates are removed
+	assert.Equal(t, []*LargeCommunity{c1, c2}, p.Value)
+	assert.Equal(t, "65000:0:4294967295", p.Value[1].String())
+
+	// the length must be a non-zero multiple of 12
+	buf = []byte{BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE, byte(BGP_ATTR_TYPE_LARGE_COMMUNITY), 8, 0, 0, 0, 1, 0, 0, 0, 2}
+	assert.NotNil(t, (&PathAttributeLargeCommunities{}).DecodeFromBytes(buf))
+	buf = []byte{BGP_ATTR_FLAG_OPTIONAL | BGP_ATTR_FLAG_TRANSITIVE, byte(BGP_ATTR_TYPE_LARGE_COMMUNITY), 0}
+	assert.NotNil(t, (&PathAttributeLargeCommunities{}).DecodeFromBytes(buf))
+}
+
+func Test_ParseLargeCommunity(t *testing.T) {
+	c, err := ParseLargeCommunity("4200000000:1:2")
+	assert.Nil(t, err)
+	assert.Equal(t, &LargeCommunity{ASN: 4200000000, LocalData1: 1, LocalData2: 2}, c)
+	for _, s := range []string{"1:2", "1:2:3:4", "4294967296:1:2", "a:b:c", "-1:2:3"} {
+		_, err := ParseLargeCommunity(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func Test_ExtendedCommunityString(t *testing.T) {
+	for s, e := range map[string]ExtendedCommunityInterface{
+		"rt:65000:100":                 &TwoOctetAsSpecificExtended{SubType: EC_SUBTYPE_ROUTE_TARGET, AS: 65000, LocalAdmin: 100},
+		"rt:4200000000:100":            &FourOctetAsSpecificExtended{SubType: EC_SUBTYPE_ROUTE_TARGET, AS: 4200000000, LocalAdmin: 100},
+		"rt:65000L:100":                &FourOctetAsSpecificExtended{SubType: EC_SUBTYPE_ROUTE_TARGET, AS: 65000, LocalAdmin: 100},
+		"soo:10.0.0.1:100":             &IPv4AddressSpecificExtended{SubType: EC_SUBTYPE_ROUTE_ORIGIN, IPv4: net.ParseIP("10.0.0.1").To4(), LocalAdmin: 100},
+		"lb:65000:125000000":           &LinkBandwidthExtended{AS: 65000, Bandwidth: 125000000},
+		"encap:vxlan":                  &EncapExtended{TunnelType: TUNNEL_TYPE_VXLAN},
+		"encap:100":                    &EncapExtended{TunnelType: 100},
+		"color:100":                    &ColorExtended{Color: 100},
+		"color:1:100":                  &ColorExtended{Flags: 1, Color: 100},
+		"mac-mobility:5":               &MacMobilityExtended{Sequence: 5},
+		"mac-mobility:5:sticky":        &MacMobilityExtended{Sticky: true, Sequence: 5},
+		"router-mac:00:11:22:33:44:55": &RouterMacExtended{Mac: net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}},
+		"0x0001020304050607":           &UnknownExtended{Type: 0, Value: []byte{1, 2, 3, 4, 5, 6, 7}},
+		"0x4102c0a800010064":           &UnknownExtended{Type: 0x41, Value: []byte{2, 192, 168, 0, 1, 0, 0x64}},
+	} {
+		assert.Equal(t, s, e.String())
+		buf, _ := e.Serialize()
+		assert.Equal(t, s, parseExtended(buf).String())
+		if s[:2] == "0x" {
+			continue
+		}
+		v, err := ParseExtendedCommunity(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, e, v, s)
+	}
+
+	// the hex form is accepted for any extended community
+	e, err := ParseExtendedCommunity("0x0002fde800000064")
+	assert.Nil(t, err)
+	assert.Equal(t, "rt:65000:100", e.String())
+
+	for _, s := range []string{"rt:65000", "foo:65000:100", "soo:10.0.0.1:65536", "rt:4200000000:65536",
+		"lb:65000:-1", "encap:foo", "color:1:2:3", "mac-mobility:1:foo", "router-mac:00:11", "0x00", "65000:100"} {
+		_, err := ParseExtendedCommunity(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func Test_PathAttributeExtendedCommunities(t *testing.T) {
+	ecommunities := []ExtendedCommunityInterface{
+		&TwoOctetAsSpecificExtended{SubType: EC_SUBTYPE_ROUTE_TARGET, AS: 65000, LocalAdmin: 100},
+		&LinkBandwidthExtended{AS: 65000, Bandwidth: 1250},
+		&EncapExtended{TunnelType: TUNNEL_TYPE_VXLAN},
+		&RouterMacExtended{Mac: net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}},
+	}
+	buf, _ := NewPathAttributeExtendedCommunities(ecommunities).Serialize()
+	p := &PathAttributeExtendedCommunities{}
+	assert.Nil(t, p.DecodeFromBytes(buf))
+	assert.Equal(t, ecommunities, p.Value)
+
+	j, _ := p.MarshalJSON()
+	assert.Equal(t, `{"Type":"BGP_ATTR_TYPE_EXTENDED_COMMUNITIES","Value":["rt:65000:100","lb:65000:1250","encap:vxlan","router-mac:00:11:22:33:44:55"]}`, string(j))
+}
+
+func Test_RouteDistinguisherString(t *testing.T) {
+	for s, rd := range map[string]RouteDistinguisherInterface{
+		"65000:100":         NewRouteDistinguisherTwoOctetAS(65000, 100),
+		"10.0.0.1:100":      NewRouteDistinguisherIPAddressAS("10.0.0.1", 100),
+		"4200000000L:100":   NewRouteDistinguisherFourOctetAS(4200000000, 100),
+		"65000L:100":        NewRouteDistinguisherFourOctetAS(65000, 100),
+		"65000:4294967295":  NewRouteDistinguisherTwoOctetAS(65000, 4294967295),
+		"10.0.0.1:65535":    NewRouteDistinguisherIPAddressAS("10.0.0.1", 65535),
+		"4200000000L:65535": NewRouteDistinguisherFourOctetAS(4200000000, 65535),
+	} {
+		assert.Equal(t, s, rd.String())
+		buf, _ := rd.Serialize()
+		assert.Equal(t, s, getRouteDistinguisher(buf).String())
+		v, err := ParseRouteDistinguisher(s)
+		assert.Nil(t, err, s)
+		assert.Equal(t, s, v.String())
+	}
+
+	// four octet AS numbers don't need the suffix
+	rd, err := ParseRouteDistinguisher("4200000000:100")
+	assert.Nil(t, err)
+	assert.Equal(t, NewRouteDistinguisherFourOctetAS(4200000000, 100), rd)
+
+	for _, s := range []string{"65000", "65000:100:1", "10.0.0.1:65536", "4200000000:65536", "a:1", "65000:-1"} {
+		_, err := ParseRouteDistinguisher(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func Test_LabelledVPNIPAddrPrefix(t *testing.T) {
+	rd := NewRouteDistinguisherTwoOctetAS(65000, 100)
+	p := NewLabelledVPNIPAddrPrefix(24, "10.0.0.0", *NewLabel(1048575, 16), rd)
+	assert.Equal(t, "65000:100:10.0.0.0/24", p.String())
+	assert.Equal(t, uint8(24), p.IPPrefixLen())
+	buf, _ := p.Serialize()
+	q := NewLabelledVPNIPAddrPrefix(0, "", *NewLabel(), nil)
+	assert.Nil(t, q.DecodeFromBytes(buf))
+	assert.Equal(t, []uint32{1048575, 16}, q.Labels.Labels)
+	assert.Equal(t, p.String(), q.String())
+
+	p6 := NewLabelledVPNIPv6AddrPrefix(64, "2001:db8:1::", *NewLabel(100), rd)
+	assert.Equal(t, "65000:100:2001:db8:1::/64", p6.String())
+	buf, _ = p6.Serialize()
+	q6 := NewLabelledVPNIPv6AddrPrefix(0, "", *NewLabel(), nil)
+	assert.Nil(t, q6.DecodeFromBytes(buf))
+	assert.Equal(t, p6.String(), q6.String())
+
+	// withdrawals may carry the 0x800000 label in place of the stack
+	buf = append([]byte{24 + 88, 0x80, 0, 0}, buf[4:12]...)
+	buf = append(buf, 10, 0, 0)
+	assert.Nil(t, q.DecodeFromBytes(buf))
+	assert.Equal(t, "65000:100:10.0.0.0/24", q.String())
+
+	// the route distinguisher must be complete
+	assert.NotNil(t, q.DecodeFromBytes([]byte{88, 0, 0, 1, 0, 0}))
+}
+
+func Test_LabelledIPAddrPrefix(t *testing.T) {
+	p := NewLabelledIPAddrPrefix(24, "10.0.0.0", *NewLabel(100))
+	assert.Equal(t, "10.0.0.0/24", p.String())
+	assert.Equal(t, uint8(24), p.IPPrefixLen())
+	buf, _ := p.Serialize()
+	q := NewLabelledIPAddrPrefix(0, "", *NewLabel())
+	assert.Nil(t, q.DecodeFromBytes(buf))
+	assert.Equal(t, []uint32{100}, q.Labels.Labels)
+	assert.Equal(t, p.String(), q.String())
+
+	p6 := NewLabelledIPv6AddrPrefix(64, "2001:db8:1::", *NewLabel(3))
+	assert.Equal(t, uint16(AFI_IP6), p6.AFI())
+	buf, _ = NewPathAttributeMpReachNLRI("2001:db8::1", []AddrPrefixInterface{p6}).Serialize()
+	mp := &PathAttributeMpReachNLRI{}
+	assert.Nil(t, mp.DecodeFromBytes(buf))
+	assert.Equal(t, "2001:db8:1::/64", mp.Value[0].String())
+	assert.Equal(t, []uint32{3}, mp.Value[0].(*LabelledIPv6AddrPrefix).Labels.Labels)
+
+	// the prefix can't be longer than the address
+	assert.NotNil(t, q.DecodeFromBytes([]byte{24 + 40, 0, 1, 1, 10, 0, 0, 0, 0}))
+	assert.NotNil(t, q.DecodeFromBytes([]byte{}))
+}
+
+func Test_MpReachNLRINexthop(t *testing.T) {
+	rd := NewRouteDistinguisherTwoOctetAS(65000, 100)
+	for _, nexthop := range []string{"10.0.0.1", "2001:db8::1"} {
+		var nlri AddrPrefixInterface = NewLabelledVPNIPAddrPrefix(24, "10.0.0.0", *NewLabel(16), rd)
+		if net.ParseIP(nexthop).To4() == nil {
+			nlri = NewLabelledVPNIPv6AddrPrefix(64, "2001:db8:1::", *NewLabel(16), rd)
+		}
+		buf, _ := NewPathAttributeMpReachNLRI(nexthop, []AddrPrefixInterface{nlri}).Serialize()
+		p := &PathAttributeMpReachNLRI{}
+		assert.Nil(t, p.DecodeFromBytes(buf))
+		assert.Equal(t, nexthop, p.Nexthop.String())
+		assert.Equal(t, nlri.String(), p.Value[0].String())
+	}
+}
+
+func Test_GetRouteFamily(t *testing.T) {
+	rf, err := GetRouteFamily("l3vpn-ipv4-unicast")
+	assert.Nil(t, err)
+	assert.Equal(t, RF_IPv4_VPN, rf)
+	afi, safi := RouteFamilyToAfiSafi(rf)
+	assert.Equal(t, uint16(AFI_IP), afi)
+	assert.Equal(t, uint8(SAFI_MPLS_VPN), safi)
+	rf, err = GetRouteFamily("ipv6-labeled-unicast")
+	assert.Nil(t, err)
+	assert.Equal(t, RF_IPv6_MPLS, rf)
+	_, err = GetRouteFamily("foo")
+	assert.NotNil(t, err)
+}
+
+func Test_MulticastAddrPrefix(t *testing.T) {
+	rf, err := GetRouteFamily("ipv4-multicast")
+	assert.Nil(t, err)
+	assert.Equal(t, RF_IPv4_MC, rf)
+	assert.Equal(t, "RF_IPv4_MC", rf.String())
+	assert.Equal(t, "RF_IPv6_MC", RF_IPv6_MC.String())
+	assert.Equal(t, "RF_IPv6_UC", RF_IPv6_UC.String())
+
+	nexthops := map[string]AddrPrefixInterface{
+		"10.0.0.1":    NewIPMulticastAddrPrefix(16, "232.1.0.0"),
+		"2001:db8::1": NewIPv6MulticastAddrPrefix(32, "2001:db8::"),
+	}
+	for nexthop, nlri := range nexthops {
+		buf, _ := NewPathAttributeMpReachNLRI(nexthop, []AddrPrefixInterface{nlri}).Serialize()
+		p := &PathAttributeMpReachNLRI{}
+		assert.Nil(t, p.DecodeFromBytes(buf))
+		assert.Equal(t, nexthop, p.Nexthop.String())
+		assert.Equal(t, uint8(SAFI_MULTICAST), p.Value[0].SAFI())
+		assert.Equal(t, nlri.AFI(), p.Value[0].AFI())
+		assert.Equal(t, nlri.String(), p.Value[0].String())
+	}
+}
+
+func Test_RouteTargetMembershipNLRI(t *testing.T) {
+	rt, _ := ParseExtendedCommunity("rt:65000:100")
+	n1 := NewRouteTargetMembershipNLRI(65001, rt)
+	buf, err := n1.Serialize()
+	assert.Nil(t, err)
+	assert.Equal(t, 13, len(buf))
+	assert.Equal(t, uint8(96), buf[0])
+	n2 := &RouteTargetMembershipNLRI{}
+	assert.Nil(t, n2.DecodeFromBytes(buf))
+	assert.Equal(t, n1.Len(), n2.Len())
+	assert.Equal(t, "65001:rt:65000:100", n2.String())
+
+	// the default route target
+	n1 = NewRouteTargetMembershipNLRI(0, nil)
+	buf, _ = n1.Serialize()
+	assert.Equal(t, []byte{0}, buf)
+	assert.Nil(t, n2.DecodeFromBytes(buf))
+	assert.Nil(t, n2.RouteTarget)
+	assert.Equal(t, 1, n2.Len())
+
+	assert.NotNil(t, n2.DecodeFromBytes([]byte{96, 0, 0}))
+	assert.NotNil(t, n2.DecodeFromBytes([]byte{24, 0, 0, 1}))
+	assert.NotNil(t, n2.DecodeFromBytes([]byte{97, 0, 0, 0, 1, 0, 2, 0xfd, 0xe8, 0, 0, 0, 100, 0}))
+
+	// the origin AS alone
+	buf = []byte{32, 0, 0, 0xfd, 0xe9}
+	assert.Nil(t, n2.DecodeFromBytes(buf))
+	assert.Equal(t, uint32(65001), n2.AS)
+	assert.Nil(t, n2.RouteTarget)
+	assert.Equal(t, "65001:*", n2.String())
+	b, _ := n2.Serialize()
+	assert.Equal(t, buf, b)
+
+	// a route target prefix, the bits past the prefix are cleared
+	buf = []byte{60, 0, 0, 0xfd, 0xe9, 0, 2, 0xfd, 0xef}
+	assert.Nil(t, n2.DecodeFromBytes(buf))
+	assert.Equal(t, 9, n2.Len())
+	b, _ = n2.Serialize()
+	assert.Equal(t, []byte{60, 0, 0, 0xfd, 0xe9, 0, 2, 0xfd, 0xe0}, b)
+	assert.NotNil(t, n2.DecodeFromBytes(buf[:8]))
+}
+
+func Test_EVPNNLRI(t *testing.T) {
+	rd := NewRouteDistinguisherIPAddressAS("10.0.0.1", 100)
+	esi, err := ParseEthernetSegmentIdentifier("00:11:22:33:44:55:66:77:88:99")
+	assert.Nil(t, err)
+	mac, _ := net.ParseMAC("02:42:ac:11:00:02")
+	routes := []*EVPNNLRI{
+		NewEVPNNLRI(EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT, &EVPNMacIPAdvertisementRoute{
+			RD:         rd,
+			ESI:        *esi,
+			ETag:       10,
+			MacAddress: mac,
+			IPAddress:  net.ParseIP("172.17.0.2"),
+			Labels:     []uint32{10010},
+		}),
+		NewEVPNNLRI(EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT, &EVPNMacIPAdvertisementRoute{
+			RD:         rd,
+			MacAddress: mac,
+			Labels:     []uint32{0xffffff, 16},
+		}),
+		NewEVPNNLRI(EVPN_INCLUSIVE_MULTICAST_ETHERNET_TAG, &EVPNMulticastEthernetTagRoute{
+			RD:        rd,
+			IPAddress: net.ParseIP("2001:db8::1"),
+		}),
+		NewEVPNNLRI(EVPN_IP_PREFIX, &EVPNIPPrefixRoute{
+			RD:             rd,
+			IPPrefixLength: 24,
+			IPPrefix:       net.ParseIP("172.17.0.0"),
+			Label:          10010,
+		}),
+		NewEVPNNLRI(EVPN_IP_PREFIX, &EVPNIPPrefixRoute{
+			RD:             rd,
+			IPPrefixLength: 64,
+			IPPrefix:       net.ParseIP("2001:db8:1::"),
+			GWIPAddress:    net.ParseIP("2001:db8::1"),
+		}),
+	}
+	for _, n1 := range routes {
+		buf, err := n1.Serialize()
+		assert.Nil(t, err)
+		assert.Equal(t, n1.Len(), len(buf))
+		n2 := &EVPNNLRI{}
+		assert.Nil(t, n2.DecodeFromBytes(buf))
+		assert.Equal(t, n1.String(), n2.String())
+		buf2, _ := n2.Serialize()
+		assert.Equal(t, buf, buf2)
+	}
+	assert.Equal(t, "[type:macadv][rd:10.0.0.1:100][etag:10][mac:02:42:ac:11:00:02][ip:172.17.0.2]", routes[0].String())
+	assert.Equal(t, "00:11:22:33:44:55:66:77:88:99", routes[0].RouteTypeData.(*EVPNMacIPAdvertisementRoute).ESI.String())
+	assert.Equal(t, "[type:prefix][rd:10.0.0.1:100][etag:0][prefix:172.17.0.0/24]", routes[3].String())
+
+	// the next hop of the EVPN routes may be IPv4 or IPv6
+	for _, nexthop := range []string{"10.0.0.1", "2001:db8::1"} {
+		buf, _ := NewPathAttributeMpReachNLRI(nexthop, []AddrPrefixInterface{routes[0]}).Serialize()
+		p := &PathAttributeMpReachNLRI{}
+		assert.Nil(t, p.DecodeFromBytes(buf))
+		assert.Equal(t, nexthop, p.Nexthop.String())
+		assert.Equal(t, routes[0].String(), p.Value[0].String())
+	}
+
+	// the routes of an unknown type are skipped by their length
+	n := &EVPNNLRI{}
+	assert.Nil(t, n.DecodeFromBytes([]byte{1, 2, 0xaa, 0xbb, 2}))
+	assert.Equal(t, 4, n.Len())
+	assert.Equal(t, "[type:unknown][len:2]", n.String())
+	buf, _ := n.Serialize()
+	assert.Equal(t, []byte{1, 2, 0xaa, 0xbb}, buf)
+	assert.NotNil(t, n.DecodeFromBytes([]byte{1, 2, 0}))
+	assert.NotNil(t, n.DecodeFromBytes([]byte{2, 40, 0}))
+	_, err = ParseEthernetSegmentIdentifier("00:11")
+	assert.NotNil(t, err)
+	assert.Equal(t, "RF_EVPN", RF_EVPN.String())
+}
+
+func Test_VPLSNLRI(t *testing.T) {
+	rd := NewRouteDistinguisherTwoOctetAS(65000, 100)
+	n1 := NewVPLSNLRI(rd, 2, 1, 8, 1000)
+	buf, err := n1.Serialize()
+	assert.Nil(t, err)
+	assert.Equal(t, n1.Len(), len(buf))
+	// the label base carries the bottom of stack bit
+	assert.Equal(t, []byte{0x00, 0x3e, 0x81}, buf[16:19])
+	n2 := &VPLSNLRI{}
+	assert.Nil(t, n2.DecodeFromBytes(buf))
+	assert.Equal(t, "[rd:65000:100][ve-id:2][block-offset:1]", n2.String())
+	assert.Equal(t, uint16(8), n2.BlockSize)
+	assert.Equal(t, uint32(1000), n2.LabelBase)
+	buf2, _ := n2.Serialize()
+	assert.Equal(t, buf, buf2)
+
+	mpreach, _ := NewPathAttributeMpReachNLRI("10.0.0.1", []AddrPrefixInterface{n1}).Serialize()
+	p := &PathAttributeMpReachNLRI{}
+	assert.Nil(t, p.DecodeFromBytes(mpreach))
+	assert.Equal(t, "10.0.0.1", p.Nexthop.String())
+	assert.Equal(t, n1.String(), p.Value[0].String())
+
+	assert.NotNil(t, n2.DecodeFromBytes(buf[:10]))
+	assert.NotNil(t, n2.DecodeFromBytes(append([]byte{0, 16}, buf[2:]...)))
+	assert.Equal(t, "RF_VPLS", RF_VPLS.String())
+	rf, _ := GetRouteFamily("l2vpn-vpls")
+	assert.Equal(t, RF_VPLS, rf)
+
+	e, err := ParseExtendedCommunity("l2info:19:2:1500")
+	assert.Nil(t, err)
+	assert.Equal(t, &Layer2InfoExtended{EncapType: LAYER2_ENCAP_TYPE_VPLS, ControlFlags: LAYER2_CONTROL_FLAG_CONTROL_WORD, MTU: 1500}, e)
+	ebuf, _ := e.Serialize()
+	assert.Equal(t, []byte{0x80, 0x0a, 19, 2, 0x05, 0xdc, 0, 0}, ebuf)
+	assert.Equal(t, e, parseExtended(ebuf))
+	_, err = ParseExtendedCommunity("l2info:19:1500")
+	assert.NotNil(t, err)
+}
+
+func Test_FlowSpecNLRI(t *testing.T) {
+	// "packets to 192.0.2.0/24 and TCP port 25" of RFC 8955
+	l, err := ParseFlowSpecComponents(RF_FS_IPv4_UC, "port 25 protocol tcp destination 192.0.2.0/24")
+	assert.Nil(t, err)
+	n1 := NewFlowSpecIPv4Unicast(l)
+	buf, err := n1.Serialize()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x0b, 0x01, 0x18, 0xc0, 0x00, 0x02, 0x03, 0x81, 0x06, 0x04, 0x81, 0x19}, buf)
+	assert.Equal(t, "destination 192.0.2.0/24 protocol ==tcp port ==25", n1.String())
+
+	for _, s := range []string{
+		"destination 10.0.0.0/8 source 192.168.1.1/32 destination-port >=1024&<=2048 ==80 tcp-flags =syn|ack !rst packet-length <=1000 dscp ==46 fragment is-fragment",
+		"source 2001:db8::/32 protocol ==udp flow-label ==100000",
+		"destination 0:0:1::/64/32 icmp-type true",
+	} {
+		rf := RF_FS_IPv4_UC
+		if strings.Contains(s, ":") {
+			rf = RF_FS_IPv6_UC
+		}
+		l, err := ParseFlowSpecComponents(rf, s)
+		assert.Nil(t, err)
+		n1 := NewFlowSpecIPv4Unicast(l)
+		n2 := NewFlowSpecIPv4Unicast(nil)
+		if rf == RF_FS_IPv6_UC {
+			n1 = NewFlowSpecIPv6Unicast(l)
+			n2 = NewFlowSpecIPv6Unicast(nil)
+		}
+		assert.Equal(t, s, n1.String())
+		buf, err := n1.Serialize()
+		assert.Nil(t, err)
+		assert.Equal(t, n1.Len(), len(buf))
+		assert.Nil(t, n2.DecodeFromBytes(buf))
+		assert.Equal(t, s, n2.String())
+	}
+
+	// flow specifications have no next hop
+	buf, _ = NewPathAttributeMpReachNLRI("", []AddrPrefixInterface{n1}).Serialize()
+	p := &PathAttributeMpReachNLRI{}
+	assert.Nil(t, p.DecodeFromBytes(buf))
+	assert.Nil(t, p.Nexthop)
+	assert.Equal(t, n1.String(), p.Value[0].String())
+
+	for _, s := range []string{"", "port", "destination 2001:db8::/32", "port 1 port 2", "tcp-flags foo", "flow-label 1"} {
+		_, err := ParseFlowSpecComponents(RF_FS_IPv4_UC, s)
+		assert.NotNil(t, err, s)
+	}
+	// the components must be in increasing type order
+	n := NewFlowSpecIPv4Unicast(nil)
+	assert.NotNil(t, n.DecodeFromBytes([]byte{0x06, 0x03, 0x81, 0x06, 0x01, 0x08, 0x0a}))
+	assert.Equal(t, "RF_FS_IPv4_UC", RF_FS_IPv4_UC.String())
+	assert.Equal(t, "RF_FS_IPv6_UC", RF_FS_IPv6_UC.String())
+}
+
+func Test_CompareFlowSpecNLRI(t *testing.T) {
+	parse := func(s string) *FlowSpecNLRI {
+		l, _ := ParseFlowSpecComponents(RF_FS_IPv4_UC, s)
+		return NewFlowSpecIPv4Unicast(l)
+	}
+	// the flow specifications in decreasing precedence
+	l := []*FlowSpecNLRI{
+		parse("destination 10.0.0.0/24 protocol ==tcp"),
+		parse("destination 10.0.0.0/24"),
+		parse("destination 10.0.0.0/16"),
+		parse("destination 10.1.0.0/24"),
+		parse("destination 10.1.0.0/24"),
+		parse("protocol ==6 port ==80"),
+		parse("protocol ==17"),
+		parse("port ==80"),
+	}
+	for i := 0; i < len(l)-1; i++ {
+		assert.True(t, CompareFlowSpecNLRI(l[i], l[i+1]) <= 0, l[i].String())
+		assert.True(t, CompareFlowSpecNLRI(l[i+1], l[i]) >= 0, l[i].String())
+	}
+	assert.Equal(t, 0, CompareFlowSpecNLRI(l[3], l[4]))
+}
+
+func Test_FlowSpecExtended(t *testing.T) {
+	for _, s := range []string{"rate-limit:0", "rate-limit:65000:125000", "redirect:65000:100", "mark:46"} {
+		e, err := ParseExtendedCommunity(s)
+		assert.Nil(t, err)
+		buf, _ := e.Serialize()
+		assert.Equal(t, uint8(EC_TYPE_GENERIC_TRANSITIVE_EXPERIMENTAL), buf[0])
+		assert.Equal(t, s, parseExtended(buf).String())
+	}
+	_, err := ParseExtendedCommunity("mark:64")
+	assert.NotNil(t, err)
+}
+
+func Test_LsNLRI(t *testing.T) {
+	local := LsNodeDescriptor{Asn: 65000, IGPRouterID: net.ParseIP("10.0.0.1").To4()}
+	remote := LsNodeDescriptor{Asn: 65000, IGPRouterID: []byte{0, 0, 0, 0, 0, 2, 1}}
+	link := LsLinkDescriptor{InterfaceAddr: net.ParseIP("192.168.0.1"), NeighborAddr: net.ParseIP("192.168.0.2")}
+	prefix := LsPrefixDescriptor{Prefix: net.ParseIP("2001:db8::"), PrefixLength: 32}
+	for _, n1 := range []*LsNLRI{
+		NewLsNodeNLRI(LS_PROTOCOL_OSPF_V2, 0, local),
+		NewLsLinkNLRI(LS_PROTOCOL_ISIS_L2, 1, local, remote, link),
+		NewLsPrefixNLRI(LS_PROTOCOL_OSPF_V3, 0, local, prefix),
+	} {
+		buf, err := n1.Serialize()
+		assert.Nil(t, err)
+		assert.Equal(t, n1.Len(), len(buf))
+		n2 := &LsNLRI{}
+		assert.Nil(t, n2.DecodeFromBytes(buf))
+		assert.Equal(t, n1.String(), n2.String())
+	}
+	n := NewLsLinkNLRI(LS_PROTOCOL_ISIS_L2, 1, local, remote, link)
+	assert.Equal(t, "[link][isis-l2][id:1][local:as:65000,router:10.0.0.1][remote:as:65000,router:0000.0000.0002-01][link:interface:192.168.0.1,neighbor:192.168.0.2]", n.String())
+	assert.Equal(t, "[isis-l2][id:1][as:65000,router:0000.0000.0002-01]", n.NodeKey(true))
+
+	// the link nlris need the remote node descriptor
+	buf, _ := NewLsNodeNLRI(LS_PROTOCOL_OSPF_V2, 0, local).Serialize()
+	buf[1] = LS_NLRI_TYPE_LINK
+	assert.NotNil(t, (&LsNLRI{}).DecodeFromBytes(buf))
+
+	buf, _ = NewPathAttributeMpReachNLRI("2001:db8::1", []AddrPrefixInterface{n}).Serialize()
+	p := &PathAttributeMpReachNLRI{}
+	assert.Nil(t, p.DecodeFromBytes(buf))
+	assert.Equal(t, "2001:db8::1", p.Nexthop.String())
+	assert.Equal(t, RF_LS, rfshift(p.Value[0].AFI(), p.Value[0].SAFI()))
+
+	// the nlris of an unknown type are skipped by their length
+	u := &LsNLRI{}
+	assert.Nil(t, u.DecodeFromBytes([]byte{0, 6, 0, 2, 0xaa, 0xbb, 0}))
+	assert.True(t, u.IsUnknown())
+	assert.Equal(t, 6, u.Len())
+	assert.Equal(t, "[type:6][len:2]", u.String())
+	buf, _ = u.Serialize()
+	assert.Equal(t, []byte{0, 6, 0, 2, 0xaa, 0xbb}, buf)
+	assert.NotNil(t, u.DecodeFromBytes([]byte{0, 6, 0, 2, 0xaa}))
+}
+
+func Test_PathAttributeLs(t *testing.T) {
+	metric := uint32(10)
+	bandwidth := float32(1.25e9)
+	a1 := &LsAttributes{
+		NodeName:            "r1",
+		LocalRouterID:       net.ParseIP("10.0.0.1").To4(),
+		MaxLinkBandwidth:    &bandwidth,
+		UnreservedBandwidth: []float32{bandwidth, bandwidth, bandwidth, bandwidth, 0, 0, 0, 0},
+		IGPMetric:           &metric,
+	}
+	buf, err := NewPathAttributeLs(a1).Serialize()
+	assert.Nil(t, err)
+	p, err := getPathAttribute(buf)
+	assert.Nil(t, err)
+	assert.Nil(t, p.DecodeFromBytes(buf))
+	a2, err := p.(*PathAttributeLs).Attributes()
+	assert.Nil(t, err)
+	assert.Equal(t, a1, a2)
+	assert.Equal(t, "BGP_ATTR_TYPE_LS", p.(*PathAttributeLs).Type.String())
+
+	// malformed BGP-LS attributes are discarded
+	p = NewPathAttributeLs(&LsAttributes{})
+	p.(*PathAttributeLs).Value = []*LsTLV{{Type: LS_TLV_IGP_METRIC, Value: []byte{0, 0, 0, 10}}}
+	buf, _ = p.Serialize()
+	assert.NotNil(t, p.DecodeFromBytes(buf))
+	assert.Equal(t, ERROR_HANDLING_ATTRIBUTE_DISCARD, attributeErrorHandling(BGP_ATTR_TYPE_LS))
+}
@@ -36,6 +36,9 @@ const (
 const (
 	_BGPAttrType_name_0 = "BGP_ATTR_TYPE_ORIGINBGP_ATTR_TYPE_AS_PATHBGP_ATTR_TYPE_NEXT_HOPBGP_ATTR_TYPE_MULTI_EXIT_DISCBGP_ATTR_TYPE_LOCAL_PREFBGP_ATTR_TYPE_ATOMIC_AGGREGATEBGP_ATTR_TYPE_AGGREGATORBGP_ATTR_TYPE_COMMUNITIESBGP_ATTR_TYPE_ORIGINATOR_IDBGP_ATTR_TYPE_CLUSTER_LIST"
 	_BGPAttrType_name_1 = "BGP_ATTR_TYPE_MP_REACH_NLRIBGP_ATTR_TYPE_MP_UNREACH_NLRIBGP_ATTR_TYPE_EXTENDED_COMMUNITIESBGP_ATTR_TYPE_AS4_PATHBGP_ATTR_TYPE_AS4_AGGREGATOR"
+	_BGPAttrType_name_2 = "BGP_ATTR_TYPE_AIGP"
+	_BGPAttrType_name_3 = "BGP_ATTR_TYPE_LS"
+	_BGPAttrType_name_4 = "BGP_ATTR_TYPE_LARGE_COMMUNITY"
 )
 
 var (
@@ -51,6 +54,12 @@ func (i BGPAttrType) String() string {
 	case 14 <= i && i <= 18:
 		i -= 14
 		return _BGPAttrType_name_1[_BGPAttrType_index_1[i]:_BGPAttrType_index_1[i+1]]
+	case i == 26:
+		return _BGPAttrType_name_2
+	case i == 29:
+		return _BGPAttrType_name_3
+	case i == 32:
+		return _BGPAttrType_name_4
 	default:
 		return fmt.Sprintf("BGPAttrType(%d)", i)
 	}
@@ -69,41 +78,60 @@ func (i FSMState) String() string {
 }
 
 const (
-	_RouteFamily_name_0 = "RF_IPv4_UC"
-	_RouteFamily_name_1 = "RF_IPv4_MPLS"
-	_RouteFamily_name_2 = "RF_IPv4_VPN"
-	_RouteFamily_name_3 = "RF_RTC_UC"
-	_RouteFamily_name_4 = "RF_IPv6_UC"
-	_RouteFamily_name_5 = "RF_IPv6_MPLS"
-	_RouteFamily_name_6 = "RF_IPv6_VPN"
+	_RouteFamily_name_0  = "RF_IPv4_UCRF_IPv4_MC"
+	_RouteFamily_name_1  = "RF_IPv4_MPLS"
+	_RouteFamily_name_2  = "RF_IPv4_VPN"
+	_RouteFamily_name_3  = "RF_RTC_UCRF_FS_IPv4_UC"
+	_RouteFamily_name_4  = "RF_IPv6_UCRF_IPv6_MC"
+	_RouteFamily_name_5  = "RF_IPv6_MPLS"
+	_RouteFamily_name_6  = "RF_IPv6_VPN"
+	_RouteFamily_name_7  = "RF_FS_IPv6_UC"
+	_RouteFamily_name_8  = "RF_VPLS"
+	_RouteFamily_name_9  = "RF_EVPN"
+	_RouteFamily_name_10 = "RF_LS"
 )
 
 var (
-	_RouteFamily_index_0 = [...]uint8{0, 10}
-	_RouteFamily_index_1 = [...]uint8{0, 12}
-	_RouteFamily_index_2 = [...]uint8{0, 11}
-	_RouteFamily_index_3 = [...]uint8{0, 9}
-	_RouteFamily_index_4 = [...]uint8{0, 10}
-	_RouteFamily_index_5 = [...]uint8{0, 12}
-	_RouteFamily_index_6 = [...]uint8{0, 11}
+	_RouteFamily_index_0  = [...]uint8{0, 10, 20}
+	_RouteFamily_index_1  = [...]uint8{0, 12}
+	_RouteFamily_index_2  = [...]uint8{0, 11}
+	_RouteFamily_index_3  = [...]uint8{0, 9, 22}
+	_RouteFamily_index_4  = [...]uint8{0, 10, 20}
+	_RouteFamily_index_5  = [...]uint8{0, 12}
+	_RouteFamily_index_6  = [...]uint8{0, 11}
+	_RouteFamily_index_7  = [...]uint8{0, 13}
+	_RouteFamily_index_8  = [...]uint8{0, 7}
+	_RouteFamily_index_9  = [...]uint8{0, 7}
+	_RouteFamily_index_10 = [...]uint8{0, 5}
 )
 
 func (i RouteFamily) String() string {
 	switch {
-	case i == 65537:
-		return _RouteFamily_name_0
+	case 65537 <= i && i <= 65538:
+		i -= 65537
+		return _RouteFamily_name_0[_RouteFamily_index_0[i]:_RouteFamily_index_0[i+1]]
 	case i == 65540:
 		return _RouteFamily_name_1
 	case i == 65664:
 		return _RouteFamily_name_2
-	case i == 65668:
-		return _RouteFamily_name_3
-	case i == 131073:
-		return _RouteFamily_name_4
+	case 65668 <= i && i <= 65669:
+		i -= 65668
+		return _RouteFamily_name_3[_RouteFamily_index_3[i]:_RouteFamily_index_3[i+1]]
+	case 131073 <= i && i <= 131074:
+		i -= 131073
+		return _RouteFamily_name_4[_RouteFamily_index_4[i]:_RouteFamily_index_4[i+1]]
 	case i == 131076:
 		return _RouteFamily_name_5
 	case i == 131200:
 		return _RouteFamily_name_6
+	case i == 131205:
+		return _RouteFamily_name_7
+	case i == 1638465:
+		return _RouteFamily_name_8
+	case i == 1638470:
+		return _RouteFamily_name_9
+	case i == 1074004039:
+		return _RouteFamily_name_10
 	default:
 		return fmt.Sprintf("RouteFamily(%d)", i)
 	}
@@ -7,57 +7,82 @@ import (
 	"strconv"
 )
 
-// Validator for BGPUpdate
+// Validator for BGPUpdate. Following the revised error handling of
+// RFC 7606, the validation goes on after an error and the most severe
+// error is returned, its ErrorHandling tells how m has to be handled.
+// The attributes to be discarded, including the repeated occurrences of
+// an attribute, are removed from m.
 func ValidateUpdateMsg(m *BGPUpdate) (bool, error) {
 	eCode := uint8(BGP_ERROR_UPDATE_MESSAGE_ERROR)
 	eSubCodeAttrList := uint8(BGP_ERROR_SUB_MALFORMED_ATTRIBUTE_LIST)
 	eSubCodeFlagsError := uint8(BGP_ERROR_SUB_ATTRIBUTE_FLAGS_ERROR)
 	eSubCodeMissing := uint8(BGP_ERROR_SUB_MISSING_WELL_KNOWN_ATTRIBUTE)
 
+	var worst *MessageError
+	report := func(err error) {
+		e := err.(*MessageError)
+		if worst == nil || e.ErrorHandling > worst.ErrorHandling {
+			worst = e
+		}
+	}
+
 	seen := make(map[BGPAttrType]PathAttributeInterface)
+	attrs := make([]PathAttributeInterface, 0, len(m.PathAttributes))
 	// check path attribute
 	for _, a := range m.PathAttributes {
 
-		// check attribute flags
-		ok, eMsg := ValidateFlags(a.getType(), a.getFlags())
-		if !ok {
-			data, _ := a.Serialize()
-			return false, NewMessageError(eCode, eSubCodeFlagsError, data, eMsg)
-		}
-
 		// check duplication
-		if _, ok := seen[a.getType()]; !ok {
-			seen[a.getType()] = a
-		} else {
-			eMsg := "the path attribute apears twice. Type : " + strconv.Itoa(int(a.getType()))
-			return false, NewMessageError(eCode, eSubCodeAttrList, nil, eMsg)
+		if _, ok := seen[a.GetType()]; ok {
+			eMsg := "the path attribute apears twice. Type : " + strconv.Itoa(int(a.GetType()))
+			handling := ERROR_HANDLING_ATTRIBUTE_DISCARD
+			if a.GetType() == BGP_ATTR_TYPE_MP_REACH_NLRI || a.GetType() == BGP_ATTR_TYPE_MP_UNREACH_NLRI {
+				handling = ERROR_HANDLING_SESSION_RESET
+			}
+			report(NewMessageErrorWithErrorHandling(eCode, eSubCodeAttrList, nil, eMsg, handling))
+			continue
 		}
+		seen[a.GetType()] = a
 
-		// check specific path attribute
-		ok, e := ValidateAttribute(a)
+		// check attribute flags
+		ok, eMsg := ValidateFlags(a.GetType(), a.GetFlags())
 		if !ok {
-			return false, e
+			data, _ := a.Serialize()
+			handling := attributeErrorHandling(a.GetType())
+			report(NewMessageErrorWithErrorHandling(eCode, eSubCodeFlagsError, data, eMsg, handling))
+			if handling == ERROR_HANDLING_ATTRIBUTE_DISCARD {
+				continue
+			}
+		} else if ok, e := ValidateAttribute(a); !ok {
+			// check specific path attribute
+			report(e)
+			if e.(*MessageError).ErrorHandling == ERROR_HANDLING_ATTRIBUTE_DISCARD {
+				continue
+			}
 		}
+		attrs = append(attrs, a)
 	}
+	m.PathAttributes = attrs
 
+	// check the existence of well-known mandatory attributes, NEXT_HOP
+	// isn't used by the routes in MP_REACH_NLRI
+	var mandatory []BGPAttrType
 	if len(m.NLRI) > 0 {
-		// check the existence of well-known mandatory attributes
-		exist := func(attrs []BGPAttrType) (bool, BGPAttrType) {
-			for _, attr := range attrs {
-				_, ok := seen[attr]
-				if !ok {
-					return false, attr
-				}
-			}
-			return true, 0
-		}
-		mandatory := []BGPAttrType{BGP_ATTR_TYPE_ORIGIN, BGP_ATTR_TYPE_AS_PATH, BGP_ATTR_TYPE_NEXT_HOP}
-		if ok, t := exist(mandatory); !ok {
+		mandatory = []BGPAttrType{BGP_ATTR_TYPE_ORIGIN, BGP_ATTR_TYPE_AS_PATH, BGP_ATTR_TYPE_NEXT_HOP}
+	} else if _, ok := seen[BGP_ATTR_TYPE_MP_REACH_NLRI]; ok {
+		mandatory = []BGPAttrType{BGP_ATTR_TYPE_ORIGIN, BGP_ATTR_TYPE_AS_PATH}
+	}
+	for _, t := range mandatory {
+		if _, ok := seen[t]; !ok {
 			eMsg := "well-known mandatory attributes are not present. type : " + strconv.Itoa(int(t))
 			data := []byte{byte(t)}
-			return false, NewMessageError(eCode, eSubCodeMissing, data, eMsg)
+			report(NewMessageErrorWithErrorHandling(eCode, eSubCodeMissing, data, eMsg, ERROR_HANDLING_TREAT_AS_WITHDRAW))
+			break
 		}
 	}
+
+	if worst != nil {
+		return false, worst
+	}
 	return true, nil
 }
 
@@ -67,16 +92,28 @@ func ValidateAttribute(a PathAttributeInterface) (bool, error) {
 	eSubCodeBadOrigin := uint8(BGP_ERROR_SUB_INVALID_ORIGIN_ATTRIBUTE)
 	eSubCodeBadNextHop := uint8(BGP_ERROR_SUB_INVALID_NEXT_HOP_ATTRIBUTE)
 	eSubCodeUnknown := uint8(BGP_ERROR_SUB_UNRECOGNIZED_WELL_KNOWN_ATTRIBUTE)
+	eSubCodeLength := uint8(BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR)
 
 	switch p := a.(type) {
 	case *PathAttributeOrigin:
+		if len(p.Value) != 1 {
+			data, _ := a.Serialize()
+			eMsg := "invalid origin attribute length : " + strconv.Itoa(len(p.Value))
+			return false, NewMessageErrorWithErrorHandling(eCode, eSubCodeLength, data, eMsg, ERROR_HANDLING_TREAT_AS_WITHDRAW)
+		}
 		v := uint8(p.Value[0])
 		if v != configuration.BGP_ORIGIN_ATTR_TYPE_IGP &&
 			v != configuration.BGP_ORIGIN_ATTR_TYPE_EGP &&
 			v != configuration.BGP_ORIGIN_ATTR_TYPE_INCOMPLETE {
 			data, _ := a.Serialize()
 			eMsg := "invalid origin attribute. value : " + strconv.Itoa(int(v))
-			return false, NewMessageError(eCode, eSubCodeBadOrigin, data, eMsg)
+			return false, NewMessageErrorWithErrorHandling(eCode, eSubCodeBadOrigin, data, eMsg, ERROR_HANDLING_TREAT_AS_WITHDRAW)
+		}
+	case *PathAttributeAtomicAggregate:
+		if len(p.Value) != 0 {
+			data, _ := a.Serialize()
+			eMsg := "invalid atomic aggregate attribute length : " + strconv.Itoa(len(p.Value))
+			return false, NewMessageErrorWithErrorHandling(eCode, eSubCodeLength, data, eMsg, ERROR_HANDLING_ATTRIBUTE_DISCARD)
 		}
 	case *PathAttributeNextHop:
 
@@ -94,10 +131,10 @@ func ValidateAttribute(a PathAttributeInterface) (bool, error) {
 		if p.Value.IsLoopback() || isZero(p.Value) || isClassDorE(p.Value) {
 			eMsg := "invalid nexthop address"
 			data, _ := a.Serialize()
-			return false, NewMessageError(eCode, eSubCodeBadNextHop, data, eMsg)
+			return false, NewMessageErrorWithErrorHandling(eCode, eSubCodeBadNextHop, data, eMsg, ERROR_HANDLING_TREAT_AS_WITHDRAW)
 		}
 	case *PathAttributeUnknown:
-		if p.getFlags()&BGP_ATTR_FLAG_OPTIONAL == 0 {
+		if p.GetFlags()&BGP_ATTR_FLAG_OPTIONAL == 0 {
 			eMsg := "unrecognized well-known attribute"
 			data, _ := a.Serialize()
 			return false, NewMessageError(eCode, eSubCodeUnknown, data, eMsg)
@@ -131,9 +168,11 @@ func ValidateFlags(t BGPAttrType, flags uint8) (bool, string) {
 		return false, eMsg
 	}
 
-	// check flags are correct
+	// check flags are correct, the partial bit of an optional transitive
+	// attribute and the extended length bit may be set
 	if f, ok := pathAttrFlags[t]; ok {
-		if f != flags {
+		mask := ^uint8(BGP_ATTR_FLAG_EXTENDED_LENGTH | BGP_ATTR_FLAG_PARTIAL)
+		if f&mask != flags&mask {
 			eMsg := "flags are invalid. attribtue type : " + strconv.Itoa(int(t))
 			return false, eMsg
 		}
@@ -141,11 +180,17 @@ func ValidateFlags(t BGPAttrType, flags uint8) (bool, string) {
 	return true, ""
 }
 
+// ValidateBGPMessage validates m, the path attributes of an UPDATE
+// included, see ValidateUpdateMsg.
 func ValidateBGPMessage(m *BGPMessage) error {
 	if m.Header.Len > BGP_MAX_MESSAGE_LENGTH {
 		buf := make([]byte, 2)
 		binary.BigEndian.PutUint16(buf, m.Header.Len)
 		return NewMessageError(BGP_ERROR_MESSAGE_HEADER_ERROR, BGP_ERROR_SUB_BAD_MESSAGE_LENGTH, buf, "too long length")
 	}
+	if u, y := m.Body.(*BGPUpdate); y {
+		_, err := ValidateUpdateMsg(u)
+		return err
+	}
 	return nil
 }
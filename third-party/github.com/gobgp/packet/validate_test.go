@@ -247,3 +247,111 @@ func Test_Validate_unrecognized_well_known(t *testing.T) {
 	assert.Equal(BGP_ERROR_SUB_UNRECOGNIZED_WELL_KNOWN_ATTRIBUTE, e.SubTypeCode)
 	assert.Equal(unknownBytes, e.Data)
 }
+
+func Test_Validate_error_handling(t *testing.T) {
+	assert := assert.New(t)
+
+	// a repeated attribute is discarded
+	message := bgpupdate().Body.(*BGPUpdate)
+	message.PathAttributes = append(message.PathAttributes, NewPathAttributeOrigin(2))
+	res, err := ValidateUpdateMsg(message)
+	assert.Equal(false, res)
+	assert.Equal(ERROR_HANDLING_ATTRIBUTE_DISCARD, err.(*MessageError).ErrorHandling)
+	assert.Equal(3, len(message.PathAttributes))
+	assert.Equal(uint8(1), message.PathAttributes[0].(*PathAttributeOrigin).Value[0])
+
+	// the partial bit of an optional transitive attribute is valid
+	message = bgpupdate().Body.(*BGPUpdate)
+	communities := NewPathAttributeCommunities([]uint32{100})
+	communities.Flags |= BGP_ATTR_FLAG_PARTIAL
+	message.PathAttributes = append(message.PathAttributes, communities)
+	res, err = ValidateUpdateMsg(message)
+	assert.Equal(true, res)
+	assert.NoError(err)
+
+	// the most severe error is returned
+	message = bgpupdate().Body.(*BGPUpdate)
+	message.PathAttributes[0] = &PathAttributeOrigin{PathAttribute{Flags: BGP_ATTR_FLAG_TRANSITIVE, Type: BGP_ATTR_TYPE_ORIGIN, Value: []byte{5}}}
+	unknown := &PathAttributeUnknown{}
+	unknown.DecodeFromBytes([]byte{BGP_ATTR_FLAG_TRANSITIVE, 30, 1, 1})
+	message.PathAttributes = append(message.PathAttributes, unknown)
+	_, err = ValidateUpdateMsg(message)
+	e := err.(*MessageError)
+	assert.Equal(BGP_ERROR_SUB_UNRECOGNIZED_WELL_KNOWN_ATTRIBUTE, e.SubTypeCode)
+	assert.Equal(ERROR_HANDLING_SESSION_RESET, e.ErrorHandling)
+
+	// an origin without value doesn't panic
+	message = bgpupdate().Body.(*BGPUpdate)
+	message.PathAttributes[0] = &PathAttributeOrigin{PathAttribute{Flags: BGP_ATTR_FLAG_TRANSITIVE, Type: BGP_ATTR_TYPE_ORIGIN}}
+	_, err = ValidateUpdateMsg(message)
+	e = err.(*MessageError)
+	assert.Equal(BGP_ERROR_SUB_ATTRIBUTE_LENGTH_ERROR, e.SubTypeCode)
+	assert.Equal(ERROR_HANDLING_TREAT_AS_WITHDRAW, e.ErrorHandling)
+}
+
+func Test_ValidateBGPMessage_update(t *testing.T) {
+	assert := assert.New(t)
+	m := bgpupdate()
+	assert.NoError(ValidateBGPMessage(m))
+
+	// the path attributes of an update are validated
+	m.Body.(*BGPUpdate).PathAttributes[0] = &PathAttributeOrigin{PathAttribute{Flags: BGP_ATTR_FLAG_TRANSITIVE, Type: BGP_ATTR_TYPE_ORIGIN, Value: []byte{5}}}
+	err := ValidateBGPMessage(m)
+	assert.Equal(ERROR_HANDLING_TREAT_AS_WITHDRAW, err.(*MessageError).ErrorHandling)
+}
+
+func updateBody(attrs []byte, nlri []byte) []byte {
+	body := []byte{0, 0, byte(len(attrs) >> 8), byte(len(attrs))}
+	body = append(body, attrs...)
+	return append(body, nlri...)
+}
+
+func Test_Decode_malformed_attributes(t *testing.T) {
+	assert := assert.New(t)
+	h := &BGPHeader{Type: BGP_MSG_UPDATE}
+	attrs := []byte{
+		0x40, 1, 1, 0, // ORIGIN
+		0x40, 2, 0, // AS_PATH
+		0x40, 3, 4, 192, 168, 1, 1, // NEXT_HOP
+	}
+	nlri := []byte{24, 10, 10, 10}
+
+	// a malformed AS4_AGGREGATOR is discarded
+	body := updateBody(append(append([]byte{}, attrs...), 0xc0, 18, 1, 0), nlri)
+	m, err := ParseBGPBody(h, body)
+	assert.Equal(ERROR_HANDLING_ATTRIBUTE_DISCARD, err.(*MessageError).ErrorHandling)
+	update := m.Body.(*BGPUpdate)
+	assert.Equal(3, len(update.PathAttributes))
+	assert.Equal(1, len(update.NLRI))
+
+	// a malformed LOCAL_PREF makes the routes withdrawn
+	body = updateBody(append(append([]byte{}, attrs...), 0x40, 5, 1, 0), nlri)
+	m, err = ParseBGPBody(h, body)
+	assert.Equal(ERROR_HANDLING_TREAT_AS_WITHDRAW, err.(*MessageError).ErrorHandling)
+	assert.Equal(1, len(m.Body.(*BGPUpdate).NLRI))
+
+	// an attribute overrunning the path attributes
+	body = updateBody(append(append([]byte{}, attrs...), 0x40, 5, 8, 0), nlri)
+	m, err = ParseBGPBody(h, body)
+	assert.Equal(ERROR_HANDLING_TREAT_AS_WITHDRAW, err.(*MessageError).ErrorHandling)
+	assert.Equal(3, len(m.Body.(*BGPUpdate).PathAttributes))
+	assert.Equal(1, len(m.Body.(*BGPUpdate).NLRI))
+
+	// without NLRI the overrun attributes may be the MP_REACH_NLRI
+	body = updateBody(append(append([]byte{}, attrs...), 0x40, 5, 8, 0), nil)
+	m, err = ParseBGPBody(h, body)
+	assert.Nil(m)
+	assert.Equal(ERROR_HANDLING_SESSION_RESET, err.(*MessageError).ErrorHandling)
+
+	// a malformed MP_REACH_NLRI disables the address family
+	body = updateBody(append(append([]byte{}, attrs...), 0x80, 14, 1, 0), nlri)
+	m, err = ParseBGPBody(h, body)
+	assert.Nil(m)
+	assert.Equal(ERROR_HANDLING_AFISAFI_DISABLE, err.(*MessageError).ErrorHandling)
+
+	// a malformed NLRI resets the session
+	body = updateBody(attrs, []byte{24, 10})
+	m, err = ParseBGPBody(h, body)
+	assert.Nil(m)
+	assert.Error(err)
+}
@@ -0,0 +1,444 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/json"
+	"fmt"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Aggregate is a configured aggregate prefix (RFC 4271 section 9.2.2.2).
+// It's advertised while the Loc-RIB holds a more specific prefix, a
+// contributor, and withdrawn with the last one. With SummaryOnly the
+// contributors aren't advertised along with it, with AsSet the AS
+// numbers of the contributors are kept in an AS_SET.
+type Aggregate struct {
+	Prefix      *net.IPNet
+	SummaryOnly bool
+	AsSet       bool
+	Nexthop     net.IP
+	// the best path of each contributor by prefix
+	contributors map[string]Path
+	// the advertised aggregate, nil while it's inactive
+	path Path
+}
+
+func NewAggregate(prefix string, summaryOnly, asSet bool, nexthop net.IP) (*Aggregate, error) {
+	_, n, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregate prefix %s", prefix)
+	}
+	if nexthop == nil {
+		return nil, fmt.Errorf("aggregate %s needs a next hop", prefix)
+	}
+	if (nexthop.To4() == nil) != (n.IP.To4() == nil) {
+		return nil, fmt.Errorf("next hop %s of aggregate %s is of another address family", nexthop, prefix)
+	}
+	return &Aggregate{
+		Prefix:       n,
+		SummaryOnly:  summaryOnly,
+		AsSet:        asSet,
+		Nexthop:      nexthop,
+		contributors: make(map[string]Path),
+	}, nil
+}
+
+func (aggregate *Aggregate) routeFamily() bgp.RouteFamily {
+	if aggregate.Prefix.IP.To4() != nil {
+		return bgp.RF_IPv4_UC
+	}
+	return bgp.RF_IPv6_UC
+}
+
+// contains returns whether the prefix of path is strictly more specific
+// than the aggregate.
+func (aggregate *Aggregate) contains(path Path) bool {
+	if path.GetRouteFamily() != aggregate.routeFamily() {
+		return false
+	}
+	ip, n, err := net.ParseCIDR(path.GetPrefix())
+	if err != nil {
+		return false
+	}
+	ones, _ := n.Mask.Size()
+	aggregateOnes, _ := aggregate.Prefix.Mask.Size()
+	return ones > aggregateOnes && aggregate.Prefix.Contains(ip)
+}
+
+// IsActive returns whether the aggregate has contributors and is
+// advertised.
+func (aggregate *Aggregate) IsActive() bool {
+	return aggregate.path != nil
+}
+
+// pathAsSegments returns the segments of the AS_PATH of path with 4
+// octet AS numbers.
+func pathAsSegments(path Path) []asSegment {
+	segs := make([]asSegment, 0)
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	if attr == nil {
+		return segs
+	}
+	for _, param := range attr.(*bgp.PathAttributeAsPath).Value {
+		switch p := param.(type) {
+		case *bgp.AsPathParam:
+			as := make([]uint32, len(p.AS))
+			for i, v := range p.AS {
+				as[i] = uint32(v)
+			}
+			segs = append(segs, asSegment{p.Type, as})
+		case *bgp.As4PathParam:
+			segs = append(segs, asSegment{p.Type, p.AS})
+		}
+	}
+	return segs
+}
+
+// leadingSequence returns the AS numbers of the AS_SEQUENCE segments at
+// the head of segs.
+func leadingSequence(segs []asSegment) []uint32 {
+	as := make([]uint32, 0)
+	for _, seg := range segs {
+		if seg.segType != bgp.BGP_ASPATH_ATTR_TYPE_SEQ {
+			break
+		}
+		as = append(as, seg.as...)
+	}
+	return as
+}
+
+type asNumbers []uint32
+
+func (l asNumbers) Len() int           { return len(l) }
+func (l asNumbers) Less(i, j int) bool { return l[i] < l[j] }
+func (l asNumbers) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// asPath returns the AS_PATH of the aggregate of contributors, and
+// whether AS numbers of the contributors are left out of it. Without
+// AsSet the AS_PATH is empty, with it the AS_SEQUENCE the contributors
+// have in common is followed by an AS_SET of the other AS numbers.
+func (aggregate *Aggregate) asPath(contributors []Path) ([]bgp.AsPathParamInterface, bool) {
+	params := []bgp.AsPathParamInterface{}
+	segsList := make([][]asSegment, 0, len(contributors))
+	for _, path := range contributors {
+		segsList = append(segsList, pathAsSegments(path))
+	}
+	if !aggregate.AsSet {
+		for _, segs := range segsList {
+			for _, seg := range segs {
+				if len(seg.as) > 0 {
+					return params, true
+				}
+			}
+		}
+		return params, false
+	}
+
+	common := leadingSequence(segsList[0])
+	for _, segs := range segsList[1:] {
+		seq := leadingSequence(segs)
+		i := 0
+		for i < len(common) && i < len(seq) && common[i] == seq[i] {
+			i++
+		}
+		common = common[:i]
+	}
+
+	seen := make(map[uint32]bool)
+	set := make([]uint32, 0)
+	for _, segs := range segsList {
+		skip := len(common)
+		for _, seg := range segs {
+			// confederation segments don't leave the confederation
+			if seg.segType == bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SEQ || seg.segType == bgp.BGP_ASPATH_ATTR_TYPE_CONFED_SET {
+				continue
+			}
+			for _, as := range seg.as {
+				if skip > 0 {
+					skip--
+					continue
+				}
+				if !seen[as] {
+					seen[as] = true
+					set = append(set, as)
+				}
+			}
+		}
+	}
+	sort.Sort(asNumbers(set))
+
+	if len(common) > 0 {
+		params = append(params, bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, common))
+	}
+	if len(set) > 0 {
+		params = append(params, bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SET, set))
+	}
+	return params, false
+}
+
+func (aggregate *Aggregate) nlri() bgp.AddrPrefixInterface {
+	ones, _ := aggregate.Prefix.Mask.Size()
+	if aggregate.routeFamily() == bgp.RF_IPv4_UC {
+		return bgp.NewNLRInfo(uint8(ones), aggregate.Prefix.IP.String())
+	}
+	return bgp.NewIPv6AddrPrefix(uint8(ones), aggregate.Prefix.IP.String())
+}
+
+// createPath builds the aggregate of its contributors. The ORIGIN is the
+// least preferred one of the contributors, ATOMIC_AGGREGATE is set when
+// a contributor has it or when AS numbers are left out of the AS_PATH,
+// and AGGREGATOR is the local AS and router ID.
+func (aggregate *Aggregate) createPath(localAsn uint32, routerId net.IP) Path {
+	prefixes := make([]string, 0, len(aggregate.contributors))
+	for prefix := range aggregate.contributors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	contributors := make([]Path, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		contributors = append(contributors, aggregate.contributors[prefix])
+	}
+
+	// IGP, EGP and INCOMPLETE, in the order of preference
+	origin := uint8(0)
+	atomic := false
+	for _, path := range contributors {
+		if _, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_ORIGIN); attr != nil {
+			if v := attr.(*bgp.PathAttributeOrigin).Value[0]; v > origin {
+				origin = v
+			}
+		}
+		if _, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_ATOMIC_AGGREGATE); attr != nil {
+			atomic = true
+		}
+	}
+	params, lost := aggregate.asPath(contributors)
+
+	nlri := aggregate.nlri()
+	pattrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(origin),
+		bgp.NewPathAttributeAsPath(params),
+	}
+	if aggregate.routeFamily() == bgp.RF_IPv4_UC {
+		pattrs = append(pattrs, bgp.NewPathAttributeNextHop(aggregate.Nexthop.String()))
+	} else {
+		pattrs = append(pattrs, bgp.NewPathAttributeMpReachNLRI(aggregate.Nexthop.String(), []bgp.AddrPrefixInterface{nlri}))
+	}
+	if atomic || lost {
+		pattrs = append(pattrs, bgp.NewPathAttributeAtomicAggregate())
+	}
+	pattrs = append(pattrs, bgp.NewPathAttributeAggregator(localAsn, routerId.String()))
+	return CreatePath(nil, nlri, pattrs, false)
+}
+
+// update builds the aggregate again after its contributors changed and
+// returns the path to advertise, or the withdrawal of the aggregate when
+// the last contributor is gone. It returns nil if nothing changed.
+func (aggregate *Aggregate) update(localAsn uint32, routerId net.IP) Path {
+	if len(aggregate.contributors) == 0 {
+		if aggregate.path == nil {
+			return nil
+		}
+		withdrawal := aggregate.path.Clone(true)
+		aggregate.path = nil
+		return withdrawal
+	}
+	path := aggregate.createPath(localAsn, routerId)
+	if aggregate.path != nil && isSamePathAttrs(aggregate.path.GetPathAttrs(), path.GetPathAttrs()) {
+		return nil
+	}
+	aggregate.path = path
+	return path
+}
+
+func (aggregate *Aggregate) MarshalJSON() ([]byte, error) {
+	contributors := make([]string, 0, len(aggregate.contributors))
+	for prefix := range aggregate.contributors {
+		contributors = append(contributors, prefix)
+	}
+	sort.Strings(contributors)
+	return json.Marshal(struct {
+		Prefix       string   `json:"prefix"`
+		SummaryOnly  bool     `json:"summary_only"`
+		AsSet        bool     `json:"as_set"`
+		Nexthop      net.IP   `json:"nexthop"`
+		Active       bool     `json:"active"`
+		Contributors []string `json:"contributors"`
+		Path         Path     `json:"path,omitempty"`
+	}{
+		Prefix:       aggregate.Prefix.String(),
+		SummaryOnly:  aggregate.SummaryOnly,
+		AsSet:        aggregate.AsSet,
+		Nexthop:      aggregate.Nexthop,
+		Active:       aggregate.IsActive(),
+		Contributors: contributors,
+		Path:         aggregate.path,
+	})
+}
+
+// AggregateManager holds the configured aggregates and the Loc-RIB of
+// the unicast paths received from the neighbors they are built from.
+// The neighbors ask it from their own goroutines which paths the
+// summary-only aggregates suppress.
+type AggregateManager struct {
+	Aggregates map[string]*Aggregate
+	rib        *TableManager
+	localAsn   uint32
+	routerId   net.IP
+	mu         sync.RWMutex
+}
+
+func NewAggregateManager(localAsn uint32, routerId net.IP) *AggregateManager {
+	rib := NewTableManager()
+	rib.SetLocalAsn(localAsn)
+	return &AggregateManager{
+		Aggregates: make(map[string]*Aggregate),
+		rib:        rib,
+		localAsn:   localAsn,
+		routerId:   routerId,
+	}
+}
+
+// AddAggregate adds the aggregate of prefix, nexthop defaults to the
+// router ID for an IPv4 aggregate.
+func (manager *AggregateManager) AddAggregate(prefix string, summaryOnly, asSet bool, nexthop net.IP) (*Aggregate, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	if nexthop == nil {
+		if ip, _, err := net.ParseCIDR(prefix); err == nil && ip.To4() != nil {
+			nexthop = manager.routerId
+		}
+	}
+	aggregate, err := NewAggregate(prefix, summaryOnly, asSet, nexthop)
+	if err != nil {
+		return nil, err
+	}
+	key := aggregate.Prefix.String()
+	if _, found := manager.Aggregates[key]; found {
+		return nil, fmt.Errorf("aggregate %s already exists", key)
+	}
+	for _, path := range manager.rib.GetBestPathList(aggregate.routeFamily()) {
+		if aggregate.contains(path) {
+			aggregate.contributors[path.GetPrefix()] = path
+		}
+	}
+	aggregate.update(manager.localAsn, manager.routerId)
+	manager.Aggregates[key] = aggregate
+	return aggregate, nil
+}
+
+// GetPathList returns the paths of the active aggregates.
+func (manager *AggregateManager) GetPathList() []Path {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	pathList := make([]Path, 0)
+	for _, aggregate := range manager.Aggregates {
+		if aggregate.path != nil {
+			pathList = append(pathList, aggregate.path)
+		}
+	}
+	return pathList
+}
+
+// update applies the best path changes of the Loc-RIB to the
+// contributors and returns the aggregates to advertise or withdraw.
+func (manager *AggregateManager) update(pList []Path, wList []Path) []Path {
+	changed := make(map[*Aggregate]bool)
+	for _, aggregate := range manager.Aggregates {
+		for _, path := range pList {
+			if aggregate.contains(path) {
+				aggregate.contributors[path.GetPrefix()] = path
+				changed[aggregate] = true
+			}
+		}
+		for _, path := range wList {
+			if aggregate.contains(path) {
+				delete(aggregate.contributors, path.GetPrefix())
+				changed[aggregate] = true
+			}
+		}
+	}
+	pathList := make([]Path, 0)
+	for aggregate := range changed {
+		if path := aggregate.update(manager.localAsn, manager.routerId); path != nil {
+			pathList = append(pathList, path)
+		}
+	}
+	return pathList
+}
+
+// ProcessPaths puts the paths received from the neighbors into the
+// Loc-RIB and returns the aggregates to advertise or withdraw.
+func (manager *AggregateManager) ProcessPaths(pathList []Path) []Path {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	pList, wList, _ := manager.rib.ProcessPaths(pathList)
+	return manager.update(pList, wList)
+}
+
+func (manager *AggregateManager) DeletePathsforPeer(peerInfo *PeerInfo) []Path {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	pList, wList, _ := manager.rib.DeletePathsforPeer(peerInfo)
+	return manager.update(pList, wList)
+}
+
+// IsSuppressed returns whether path is a contributor of an active
+// summary-only aggregate, and so isn't advertised.
+func (manager *AggregateManager) IsSuppressed(path Path) bool {
+	if manager == nil {
+		return false
+	}
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	for _, aggregate := range manager.Aggregates {
+		if aggregate.SummaryOnly && aggregate.path != nil && aggregate.contains(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSummaryOnly returns whether path is for the prefix of a summary-only
+// aggregate.
+func (manager *AggregateManager) IsSummaryOnly(path Path) bool {
+	if manager == nil {
+		return false
+	}
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	aggregate, found := manager.Aggregates[path.GetPrefix()]
+	return found && aggregate.SummaryOnly
+}
+
+func (manager *AggregateManager) MarshalJSON() ([]byte, error) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	keys := make([]string, 0, len(manager.Aggregates))
+	for key := range manager.Aggregates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	aggregates := make([]*Aggregate, 0, len(keys))
+	for _, key := range keys {
+		aggregates = append(aggregates, manager.Aggregates[key])
+	}
+	return json.Marshal(aggregates)
+}
@@ -0,0 +1,187 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+func aggregateContributor(peer *PeerInfo, prefix string, length uint8, origin uint8, as []uint32, withdraw bool) Path {
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(origin),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{bgp.NewAs4PathParam(bgp.BGP_ASPATH_ATTR_TYPE_SEQ, as)}),
+		bgp.NewPathAttributeNextHop(peer.Address.String()),
+	}
+	return CreatePath(peer, bgp.NewNLRInfo(length, prefix), pathAttributes, withdraw)
+}
+
+func TestAggregateAddAggregate(t *testing.T) {
+	manager := NewAggregateManager(65000, net.ParseIP("10.0.0.1").To4())
+	a, err := manager.AddAggregate("10.1.0.0/16", false, false, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "10.0.0.1", a.Nexthop.String())
+	assert.False(t, a.IsActive())
+
+	_, err = manager.AddAggregate("10.1.0.0/16", true, false, nil)
+	assert.NotNil(t, err)
+	_, err = manager.AddAggregate("2001:db8::/32", false, false, nil)
+	assert.NotNil(t, err)
+	_, err = manager.AddAggregate("2001:db8::/32", false, false, net.ParseIP("10.0.0.1"))
+	assert.NotNil(t, err)
+	_, err = manager.AddAggregate("2001:db8::/32", false, false, net.ParseIP("2001:db8::1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(manager.GetPathList()))
+}
+
+func TestAggregateContributors(t *testing.T) {
+	manager := NewAggregateManager(65000, net.ParseIP("10.0.0.1").To4())
+	a, _ := manager.AddAggregate("10.1.0.0/16", true, false, nil)
+	peer1 := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_IPv4_UC}
+	peer2 := &PeerInfo{AS: 65002, ID: net.ParseIP("10.0.0.3").To4(), Address: net.ParseIP("10.0.0.3"), RF: bgp.RF_IPv4_UC}
+
+	// the aggregate prefix itself and prefixes outside of it don't count
+	pathList := manager.ProcessPaths([]Path{
+		aggregateContributor(peer1, "10.1.0.0", 16, 0, []uint32{65001}, false),
+		aggregateContributor(peer1, "10.2.1.0", 24, 0, []uint32{65001}, false),
+	})
+	assert.Equal(t, 0, len(pathList))
+	assert.False(t, a.IsActive())
+
+	contributor := aggregateContributor(peer1, "10.1.1.0", 24, 0, []uint32{65001}, false)
+	assert.False(t, manager.IsSuppressed(contributor))
+	pathList = manager.ProcessPaths([]Path{contributor})
+	assert.Equal(t, 1, len(pathList))
+	assert.True(t, a.IsActive())
+	assert.False(t, pathList[0].IsWithdraw())
+	assert.Equal(t, "10.1.0.0/16", pathList[0].GetPrefix())
+	assert.Equal(t, "10.0.0.1", pathList[0].GetNexthop().String())
+	assert.True(t, manager.IsSuppressed(contributor))
+	assert.False(t, manager.IsSuppressed(aggregateContributor(peer1, "10.2.1.0", 24, 0, []uint32{65001}, false)))
+	assert.False(t, manager.IsSuppressed(pathList[0]))
+
+	// another contributor only changes the aggregate if its attributes
+	// change
+	pathList = manager.ProcessPaths([]Path{aggregateContributor(peer2, "10.1.2.0", 24, 0, []uint32{65002}, false)})
+	assert.Equal(t, 0, len(pathList))
+	pathList = manager.ProcessPaths([]Path{aggregateContributor(peer2, "10.1.3.0", 24, 2, []uint32{65002}, false)})
+	assert.Equal(t, 1, len(pathList))
+	_, attr := pathList[0].GetPathAttr(bgp.BGP_ATTR_TYPE_ORIGIN)
+	assert.Equal(t, uint8(2), attr.(*bgp.PathAttributeOrigin).Value[0])
+
+	// the aggregate is withdrawn with the last contributor
+	pathList = manager.ProcessPaths([]Path{aggregateContributor(peer1, "10.1.1.0", 24, 0, []uint32{65001}, true)})
+	assert.Equal(t, 0, len(pathList))
+	pathList = manager.DeletePathsforPeer(peer2)
+	assert.Equal(t, 1, len(pathList))
+	assert.True(t, pathList[0].IsWithdraw())
+	assert.Equal(t, "10.1.0.0/16", pathList[0].GetPrefix())
+	assert.False(t, a.IsActive())
+	assert.False(t, manager.IsSuppressed(contributor))
+	assert.Equal(t, 0, len(manager.GetPathList()))
+}
+
+func TestAggregateAttributes(t *testing.T) {
+	manager := NewAggregateManager(70000, net.ParseIP("10.0.0.1").To4())
+	manager.AddAggregate("10.1.0.0/16", false, false, nil)
+	manager.AddAggregate("10.0.0.0/8", false, true, nil)
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_IPv4_UC}
+	manager.ProcessPaths([]Path{
+		aggregateContributor(peer, "10.1.1.0", 24, 0, []uint32{65001, 65010, 65020}, false),
+		aggregateContributor(peer, "10.1.2.0", 24, 1, []uint32{65001, 65011, 65020}, false),
+	})
+
+	aggregates := map[string]Path{}
+	for _, path := range manager.GetPathList() {
+		aggregates[path.GetPrefix()] = path
+	}
+	assert.Equal(t, 2, len(aggregates))
+
+	// without as-set the AS numbers of the contributors are lost
+	path := aggregates["10.1.0.0/16"]
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	assert.Equal(t, 0, len(attr.(*bgp.PathAttributeAsPath).Value))
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_ATOMIC_AGGREGATE)
+	assert.NotNil(t, attr)
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_ORIGIN)
+	assert.Equal(t, uint8(1), attr.(*bgp.PathAttributeOrigin).Value[0])
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_AGGREGATOR)
+	assert.Equal(t, uint32(70000), attr.(*bgp.PathAttributeAggregator).Value.AS)
+	assert.Equal(t, "10.0.0.1", attr.(*bgp.PathAttributeAggregator).Value.Address.String())
+
+	// with as-set the common AS_SEQUENCE is followed by an AS_SET
+	path = aggregates["10.0.0.0/8"]
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	params := attr.(*bgp.PathAttributeAsPath).Value
+	assert.Equal(t, 2, len(params))
+	assert.Equal(t, uint8(bgp.BGP_ASPATH_ATTR_TYPE_SEQ), params[0].(*bgp.As4PathParam).Type)
+	assert.Equal(t, []uint32{65001}, params[0].(*bgp.As4PathParam).AS)
+	assert.Equal(t, uint8(bgp.BGP_ASPATH_ATTR_TYPE_SET), params[1].(*bgp.As4PathParam).Type)
+	assert.Equal(t, []uint32{65010, 65011, 65020}, params[1].(*bgp.As4PathParam).AS)
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_ATOMIC_AGGREGATE)
+	assert.Nil(t, attr)
+
+	// the attributes survive an update message to a 2 octet AS neighbor
+	msgs := CreateUpdateMsgFromPaths([]Path{path})
+	assert.Equal(t, 1, len(msgs))
+	body := msgs[0].Body.(*bgp.BGPUpdate)
+	UpdatePathAttrs2ByteAs(body)
+	buf, _ := msgs[0].Serialize()
+	msg, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+	var aggregator *bgp.PathAttributeAggregator
+	var as4Aggregator *bgp.PathAttributeAs4Aggregator
+	for _, a := range msg.Body.(*bgp.BGPUpdate).PathAttributes {
+		switch a := a.(type) {
+		case *bgp.PathAttributeAggregator:
+			aggregator = a
+		case *bgp.PathAttributeAs4Aggregator:
+			as4Aggregator = a
+		}
+	}
+	assert.Equal(t, uint32(bgp.AS_TRANS), aggregator.Value.AS)
+	assert.Equal(t, uint32(70000), as4Aggregator.Value.AS)
+	assert.Equal(t, "10.0.0.1", as4Aggregator.Value.Address.String())
+}
+
+func TestAggregateIPv6(t *testing.T) {
+	manager := NewAggregateManager(65000, net.ParseIP("10.0.0.1").To4())
+	a, _ := manager.AddAggregate("2001:db8::/32", true, false, net.ParseIP("2001:db8::1"))
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("2001:db8:ffff::2"), RF: bgp.RF_IPv6_UC}
+	nlri := bgp.NewIPv6AddrPrefix(48, "2001:db8:1::")
+	contributor := CreatePath(peer, nlri, []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI("2001:db8:ffff::2", []bgp.AddrPrefixInterface{nlri}),
+	}, false)
+
+	pathList := manager.ProcessPaths([]Path{contributor})
+	assert.Equal(t, 1, len(pathList))
+	assert.Equal(t, bgp.RF_IPv6_UC, pathList[0].GetRouteFamily())
+	assert.Equal(t, "2001:db8::/32", pathList[0].GetPrefix())
+	assert.Equal(t, "2001:db8::1", pathList[0].GetNexthop().String())
+	// no AS number of the contributors is lost
+	_, attr := pathList[0].GetPathAttr(bgp.BGP_ATTR_TYPE_ATOMIC_AGGREGATE)
+	assert.Nil(t, attr)
+	assert.True(t, manager.IsSuppressed(contributor))
+
+	pathList = manager.ProcessPaths([]Path{contributor.Clone(true)})
+	assert.Equal(t, 1, len(pathList))
+	assert.True(t, pathList[0].IsWithdraw())
+	assert.False(t, a.IsActive())
+}
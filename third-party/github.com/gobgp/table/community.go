@@ -0,0 +1,75 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+)
+
+type NeighborScope int
+
+const (
+	// a neighbor in the local AS
+	NEIGHBOR_SCOPE_INTERNAL NeighborScope = iota
+	// a neighbor in another member AS of the local confederation
+	NEIGHBOR_SCOPE_CONFED
+	// a neighbor outside the local AS and confederation
+	NEIGHBOR_SCOPE_EXTERNAL
+)
+
+func HasCommunity(path Path, community uint32) bool {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_COMMUNITIES)
+	if attr == nil {
+		return false
+	}
+	for _, c := range attr.(*bgp.PathAttributeCommunities).Value {
+		if c == community {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdvertisable returns false if the well-known communities of path,
+// RFC 1997, don't allow advertising it to a neighbor of scope.
+func IsAdvertisable(path Path, scope NeighborScope) bool {
+	switch {
+	case HasCommunity(path, bgp.COMMUNITY_NO_ADVERTISE):
+		return false
+	case HasCommunity(path, bgp.COMMUNITY_NO_EXPORT):
+		return scope != NEIGHBOR_SCOPE_EXTERNAL
+	case HasCommunity(path, bgp.COMMUNITY_NO_EXPORT_SUBCONFED):
+		return scope == NEIGHBOR_SCOPE_INTERNAL
+	}
+	return true
+}
+
+// UpdatePathGracefulShutdown returns a copy of path with LOCAL_PREF set
+// to 0 if it carries the GRACEFUL_SHUTDOWN community, RFC 8326, and path
+// itself otherwise.
+func UpdatePathGracefulShutdown(path Path) Path {
+	if path.IsWithdraw() || !HasCommunity(path, bgp.COMMUNITY_GRACEFUL_SHUTDOWN) {
+		return path
+	}
+	pattrs := make([]bgp.PathAttributeInterface, 0, len(path.GetPathAttrs())+1)
+	for _, a := range path.GetPathAttrs() {
+		if _, y := a.(*bgp.PathAttributeLocalPref); !y {
+			pattrs = append(pattrs, a)
+		}
+	}
+	pattrs = append(pattrs, bgp.NewPathAttributeLocalPref(0))
+	return path.CloneWithPathAttrs(pattrs)
+}
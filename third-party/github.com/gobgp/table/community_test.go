@@ -0,0 +1,73 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"testing"
+)
+
+func communityPath(routerId string, communities []uint32) Path {
+	path := nexthopPath(65001, routerId, []uint32{65001}, "192.168.0.1")
+	if communities == nil {
+		return path
+	}
+	pattrs := append(path.GetPathAttrs(), bgp.NewPathAttributeCommunities(communities))
+	return path.CloneWithPathAttrs(pattrs)
+}
+
+func TestIsAdvertisable(t *testing.T) {
+	scopes := []NeighborScope{NEIGHBOR_SCOPE_INTERNAL, NEIGHBOR_SCOPE_CONFED, NEIGHBOR_SCOPE_EXTERNAL}
+	for _, c := range []struct {
+		communities []uint32
+		expected    []bool
+	}{
+		{nil, []bool{true, true, true}},
+		{[]uint32{65000<<16 | 100}, []bool{true, true, true}},
+		{[]uint32{bgp.COMMUNITY_NO_ADVERTISE}, []bool{false, false, false}},
+		{[]uint32{bgp.COMMUNITY_NO_EXPORT}, []bool{true, true, false}},
+		{[]uint32{bgp.COMMUNITY_NO_EXPORT_SUBCONFED}, []bool{true, false, false}},
+		{[]uint32{bgp.COMMUNITY_NO_EXPORT, bgp.COMMUNITY_NO_ADVERTISE}, []bool{false, false, false}},
+	} {
+		path := communityPath("10.0.0.2", c.communities)
+		for i, scope := range scopes {
+			assert.Equal(t, c.expected[i], IsAdvertisable(path, scope), "%v to scope %d", c.communities, scope)
+		}
+	}
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	path := communityPath("10.0.0.2", []uint32{65000<<16 | 100})
+	assert.Equal(t, path, UpdatePathGracefulShutdown(path))
+
+	path = communityPath("10.0.0.2", []uint32{bgp.COMMUNITY_GRACEFUL_SHUTDOWN})
+	gshut := UpdatePathGracefulShutdown(path)
+	_, attr := gshut.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	assert.Equal(t, uint32(0), attr.(*bgp.PathAttributeLocalPref).Value)
+	_, attr = path.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+	assert.Nil(t, attr)
+
+	// a path without LOCAL_PREF is preferred over a graceful shutdown path
+	other := communityPath("10.0.0.3", nil)
+	options := configuration.RouteSelectionOptionsType{}
+	best, reason := bestOf(options, gshut, other)
+	assert.Equal(t, other, best)
+	assert.Equal(t, BPR_LOCAL_PREF, reason)
+	best, _ = bestOf(options, other, gshut)
+	assert.Equal(t, other, best)
+}
@@ -0,0 +1,186 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/json"
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"math"
+	"time"
+)
+
+// penalties defined in RFC 2439
+const (
+	DAMPING_PENALTY_WITHDRAW    = 1000
+	DAMPING_PENALTY_ATTR_CHANGE = 500
+)
+
+type DampingConfig struct {
+	HalfLife          time.Duration
+	ReuseThreshold    float64
+	SuppressThreshold float64
+	MaxSuppressTime   time.Duration
+}
+
+// maxPenalty is the penalty ceiling, a route at the ceiling decays to
+// the reuse threshold in exactly MaxSuppressTime.
+func (c *DampingConfig) maxPenalty() float64 {
+	return c.ReuseThreshold * math.Pow(2, c.MaxSuppressTime.Seconds()/c.HalfLife.Seconds())
+}
+
+type DampingEntry struct {
+	prefix       string
+	penalty      float64
+	flaps        int
+	suppressed   bool
+	suppressedAt time.Time
+	lastUpdate   time.Time
+}
+
+func (e *DampingEntry) decay(config *DampingConfig, now time.Time) {
+	elapsed := now.Sub(e.lastUpdate)
+	if elapsed > 0 {
+		e.penalty = e.penalty * math.Pow(2, -elapsed.Seconds()/config.HalfLife.Seconds())
+	}
+	e.lastUpdate = now
+}
+
+type Damping struct {
+	config  DampingConfig
+	entries map[bgp.RouteFamily]map[string]*DampingEntry
+}
+
+func NewDamping(config DampingConfig) *Damping {
+	d := &Damping{
+		config:  config,
+		entries: make(map[bgp.RouteFamily]map[string]*DampingEntry),
+	}
+	return d
+}
+
+// update charges the penalty for the change from old to path and returns
+// the path to pass to the Loc-RIB, or nil if the prefix is suppressed.
+func (d *Damping) update(old *ReceivedRoute, path Path, now time.Time) Path {
+	rf := path.GetRouteFamily()
+	key := path.GetPrefix()
+	e, found := d.entries[rf][key]
+	if found {
+		e.decay(&d.config, now)
+	}
+
+	var penalty float64
+	if path.IsWithdraw() {
+		if old != nil {
+			penalty = DAMPING_PENALTY_WITHDRAW
+		}
+	} else if old != nil && !isSamePathAttrs(old.path.GetPathAttrs(), path.GetPathAttrs()) {
+		penalty = DAMPING_PENALTY_ATTR_CHANGE
+	}
+
+	if penalty > 0 {
+		if !found {
+			e = &DampingEntry{
+				prefix:     key,
+				lastUpdate: now,
+			}
+			if _, y := d.entries[rf]; !y {
+				d.entries[rf] = make(map[string]*DampingEntry)
+			}
+			d.entries[rf][key] = e
+		}
+		e.flaps++
+		e.penalty = math.Min(e.penalty+penalty, d.config.maxPenalty())
+	}
+
+	if e == nil {
+		return path
+	}
+	if e.suppressed {
+		return nil
+	}
+	if e.penalty >= d.config.SuppressThreshold {
+		log.Infof("route flap damping: suppressing %s, penalty %.0f", key, e.penalty)
+		e.suppressed = true
+		e.suppressedAt = now
+		// the last advertised path has to be removed from the Loc-RIB
+		if path.IsWithdraw() {
+			return path
+		}
+		return path.Clone(true)
+	}
+	return path
+}
+
+// reuse returns the prefixes whose suppression ended and forgets the
+// entries whose penalty has decayed below half the reuse threshold.
+func (d *Damping) reuse(rf bgp.RouteFamily, now time.Time) []string {
+	prefixes := make([]string, 0)
+	for key, e := range d.entries[rf] {
+		e.decay(&d.config, now)
+		if e.suppressed && (e.penalty < d.config.ReuseThreshold || now.Sub(e.suppressedAt) >= d.config.MaxSuppressTime) {
+			log.Infof("route flap damping: reusing %s, penalty %.0f", key, e.penalty)
+			e.suppressed = false
+			prefixes = append(prefixes, key)
+		}
+		if !e.suppressed && e.penalty < d.config.ReuseThreshold/2 {
+			delete(d.entries[rf], key)
+		}
+	}
+	return prefixes
+}
+
+func (d *Damping) isSuppressed(rf bgp.RouteFamily, key string) bool {
+	e, found := d.entries[rf][key]
+	return found && e.suppressed
+}
+
+func (d *Damping) clear(rf bgp.RouteFamily, key string) []string {
+	prefixes := make([]string, 0)
+	for k, e := range d.entries[rf] {
+		if key != "" && k != key {
+			continue
+		}
+		if e.suppressed {
+			prefixes = append(prefixes, k)
+		}
+		delete(d.entries[rf], k)
+	}
+	return prefixes
+}
+
+func (e *DampingEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Prefix     string  `json:"prefix"`
+		Penalty    float64 `json:"penalty"`
+		Flaps      int     `json:"flaps"`
+		Suppressed bool    `json:"suppressed"`
+	}{
+		Prefix:     e.prefix,
+		Penalty:    math.Floor(e.penalty),
+		Flaps:      e.flaps,
+		Suppressed: e.suppressed,
+	})
+}
+
+func (d *Damping) entryList(rf bgp.RouteFamily, now time.Time) []*DampingEntry {
+	entries := make([]*DampingEntry, 0, len(d.entries[rf]))
+	for _, e := range d.entries[rf] {
+		e.decay(&d.config, now)
+		entries = append(entries, e)
+	}
+	return entries
+}
@@ -0,0 +1,152 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+	"time"
+)
+
+func dampingConfig() DampingConfig {
+	return DampingConfig{
+		HalfLife:          15 * time.Minute,
+		ReuseThreshold:    750,
+		SuppressThreshold: 2000,
+		MaxSuppressTime:   time.Hour,
+	}
+}
+
+func dampingPath(as []uint16, withdraw bool) Path {
+	m := updateMsg1(as).Body.(*bgp.BGPUpdate)
+	if withdraw {
+		w := bgp.WithdrawnRoute{IPAddrPrefix: m.NLRI[0].IPAddrPrefix}
+		return CreatePath(peerR1(), &w, m.PathAttributes, true)
+	}
+	return CreatePath(peerR1(), &m.NLRI[0], m.PathAttributes, false)
+}
+
+// flap the prefix count times starting from an announced path
+func flap(d *Damping, count int, now time.Time) Path {
+	var last Path
+	old := NewReceivedRoute(dampingPath([]uint16{65001}, false), false)
+	for i := 0; i < count; i++ {
+		last = d.update(old, dampingPath(nil, true), now)
+		old = nil
+		d.update(old, dampingPath([]uint16{65001}, false), now)
+		old = NewReceivedRoute(dampingPath([]uint16{65001}, false), false)
+	}
+	return last
+}
+
+func TestDampingSuppress(t *testing.T) {
+	d := NewDamping(dampingConfig())
+	now := time.Now()
+
+	p := flap(d, 1, now)
+	assert.NotNil(t, p)
+	assert.False(t, d.isSuppressed(bgp.RF_IPv4_UC, "10.10.10.0/24"))
+
+	p = flap(d, 1, now)
+	assert.NotNil(t, p)
+	assert.True(t, p.IsWithdraw())
+	assert.True(t, d.isSuppressed(bgp.RF_IPv4_UC, "10.10.10.0/24"))
+
+	// announcements of a suppressed prefix never reach the Loc-RIB
+	p = d.update(nil, dampingPath([]uint16{65001}, false), now)
+	assert.Nil(t, p)
+}
+
+func TestDampingAttributeChange(t *testing.T) {
+	d := NewDamping(dampingConfig())
+	now := time.Now()
+	old := NewReceivedRoute(dampingPath([]uint16{65001}, false), false)
+
+	p := d.update(old, dampingPath([]uint16{65001}, false), now)
+	assert.NotNil(t, p)
+	assert.Equal(t, len(d.entries[bgp.RF_IPv4_UC]), 0)
+
+	p = d.update(old, dampingPath([]uint16{65002}, false), now)
+	assert.NotNil(t, p)
+	assert.Equal(t, d.entries[bgp.RF_IPv4_UC]["10.10.10.0/24"].penalty, float64(DAMPING_PENALTY_ATTR_CHANGE))
+}
+
+func TestDampingReuse(t *testing.T) {
+	d := NewDamping(dampingConfig())
+	now := time.Now()
+	flap(d, 2, now)
+	assert.True(t, d.isSuppressed(bgp.RF_IPv4_UC, "10.10.10.0/24"))
+
+	// 2000 decays to 1000 after one half-life
+	assert.Equal(t, len(d.reuse(bgp.RF_IPv4_UC, now.Add(15*time.Minute))), 0)
+	assert.True(t, d.isSuppressed(bgp.RF_IPv4_UC, "10.10.10.0/24"))
+
+	prefixes := d.reuse(bgp.RF_IPv4_UC, now.Add(25*time.Minute))
+	assert.Equal(t, prefixes, []string{"10.10.10.0/24"})
+	assert.False(t, d.isSuppressed(bgp.RF_IPv4_UC, "10.10.10.0/24"))
+
+	// the entry is forgotten below half the reuse threshold
+	d.reuse(bgp.RF_IPv4_UC, now.Add(40*time.Minute))
+	assert.Equal(t, len(d.entries[bgp.RF_IPv4_UC]), 0)
+}
+
+func TestDampingMaxPenalty(t *testing.T) {
+	d := NewDamping(dampingConfig())
+	now := time.Now()
+	flap(d, 20, now)
+	e := d.entries[bgp.RF_IPv4_UC]["10.10.10.0/24"]
+	assert.Equal(t, e.penalty, float64(12000))
+
+	prefixes := d.reuse(bgp.RF_IPv4_UC, now.Add(time.Hour))
+	assert.Equal(t, prefixes, []string{"10.10.10.0/24"})
+}
+
+func TestDampingClear(t *testing.T) {
+	adj := NewAdjRib()
+	adj.EnableDamping(dampingConfig())
+	adj.UpdateIn([]Path{dampingPath([]uint16{65001}, false)})
+	now := time.Now()
+	flap(adj.damping, 2, now)
+	assert.Equal(t, len(adj.FilterDampened(adj.GetInPathList(bgp.RF_IPv4_UC))), 0)
+
+	pathList := adj.ClearDampened(bgp.RF_IPv4_UC, "")
+	assert.Equal(t, len(pathList), 1)
+	assert.Equal(t, len(adj.GetDampenedList(bgp.RF_IPv4_UC)), 0)
+	assert.Equal(t, len(adj.FilterDampened(adj.GetInPathList(bgp.RF_IPv4_UC))), 1)
+}
+
+func TestDampingLabeledUnicast(t *testing.T) {
+	adj := NewAdjRib()
+	adj.EnableDamping(dampingConfig())
+	peer := &PeerInfo{AS: 65001, Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_IPv4_MPLS}
+	nlri := bgp.NewLabelledIPAddrPrefix(24, "10.20.0.0", *bgp.NewLabel(100))
+	pattrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI("10.0.0.2", []bgp.AddrPrefixInterface{nlri}),
+	}
+	for i := 0; i < 3; i++ {
+		announce := adj.Dampen([]Path{CreatePath(peer, nlri, pattrs, false)})
+		adj.UpdateIn(announce)
+		withdraw := adj.Dampen([]Path{CreatePath(peer, nlri, pattrs, true)})
+		adj.UpdateIn(withdraw)
+		assert.Equal(t, len(withdraw), 1)
+	}
+	assert.Equal(t, len(adj.GetDampenedList(bgp.RF_IPv4_MPLS)), 1)
+	assert.Equal(t, len(adj.Dampen([]Path{CreatePath(peer, nlri, pattrs, false)})), 0)
+}
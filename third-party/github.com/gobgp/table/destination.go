@@ -16,10 +16,12 @@
 package table
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	"github.com/gopher-net/gopher-net/configuration"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
 	"net"
 	"reflect"
@@ -32,14 +34,19 @@ const (
 	BPR_HIGHEST_WEIGHT     = "Highest Weight"
 	BPR_LOCAL_PREF         = "Local Pref"
 	BPR_LOCAL_ORIGIN       = "Local Origin"
+	BPR_AIGP               = "AIGP"
 	BPR_ASPATH             = "AS Path"
 	BPR_ORIGIN             = "Origin"
 	BPR_MED                = "MED"
 	BPR_ASN                = "ASN"
 	BPR_IGP_COST           = "IGP Cost"
+	BPR_OLDER              = "Older Path"
 	BPR_ROUTER_ID          = "Router ID"
 )
 
+// local preference of paths without LOCAL_PREF
+const DEFAULT_LOCAL_PREF = 100
+
 type PeerInfo struct {
 	AS      uint32
 	ID      net.IP
@@ -49,7 +56,7 @@ type PeerInfo struct {
 }
 
 type Destination interface {
-	Calculate(localAsn uint32) (Path, string, error)
+	Calculate(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker) (Path, string, error)
 	getRouteFamily() bgp.RouteFamily
 	setRouteFamily(ROUTE_FAMILY bgp.RouteFamily)
 	GetNlri() bgp.AddrPrefixInterface
@@ -58,6 +65,9 @@ type Destination interface {
 	setBestPathReason(string)
 	GetBestPath() Path
 	setBestPath(path Path)
+	GetMultiPathList() []Path
+	Explain(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker) *BestPathExplanation
+	calculateMultiPath(bestPath Path, localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker, config configuration.UseMultiplePathsType) bool
 	getOldBestPath() Path
 	setOldBestPath(path Path)
 	getKnownPathList() []Path
@@ -79,6 +89,7 @@ type DestinationDefault struct {
 	bestPath       Path
 	bestPathReason string
 	oldBestPath    Path
+	multiPathList  []Path
 }
 
 func NewDestinationDefault(nlri bgp.AddrPrefixInterface) *DestinationDefault {
@@ -91,18 +102,25 @@ func NewDestinationDefault(nlri bgp.AddrPrefixInterface) *DestinationDefault {
 	destination.bestPath = nil
 	destination.bestPathReason = ""
 	destination.oldBestPath = nil
+	destination.multiPathList = make([]Path, 0)
 	return destination
 }
 
-func (dd *DestinationDefault) MarshalJSON() ([]byte, error) {
-	prefix := dd.GetNlri().(*bgp.NLRInfo).Prefix
+func (dd *DestinationDefault) setPathFlags() {
 	for _, p := range dd.knownPathList {
-		if p == dd.GetBestPath() {
-			p.setBest(true)
-		} else {
-			p.setBest(false)
+		p.setBest(p == dd.GetBestPath())
+		p.setMultiPath(false)
+		for _, m := range dd.multiPathList {
+			if p == m {
+				p.setMultiPath(true)
+			}
 		}
 	}
+}
+
+func (dd *DestinationDefault) MarshalJSON() ([]byte, error) {
+	prefix := dd.GetNlri().(*bgp.NLRInfo).Prefix
+	dd.setPathFlags()
 	return json.Marshal(struct {
 		Prefix string
 		Paths  []Path
@@ -144,6 +162,10 @@ func (dd *DestinationDefault) setBestPath(path Path) {
 	dd.bestPath = path
 }
 
+func (dd *DestinationDefault) GetMultiPathList() []Path {
+	return dd.multiPathList
+}
+
 func (dd *DestinationDefault) getOldBestPath() Path {
 	return dd.oldBestPath
 }
@@ -197,7 +219,7 @@ func (dd *DestinationDefault) validatePath(path Path) {
 //
 // Modifies destination's state related to stored paths. Removes withdrawn
 // paths from known paths. Also, adds new paths to known paths.
-func (dest *DestinationDefault) Calculate(localAsn uint32) (Path, string, error) {
+func (dest *DestinationDefault) Calculate(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker) (Path, string, error) {
 
 	// First remove the withdrawn paths.
 	// Note: If we want to support multiple paths per destination we may
@@ -233,7 +255,7 @@ func (dest *DestinationDefault) Calculate(localAsn uint32) (Path, string, error)
 	}
 
 	// Compute new best path
-	currentBestPath, reason, e := dest.computeKnownBestPath(localAsn)
+	currentBestPath, reason, e := dest.computeKnownBestPath(localAsn, options, nexthops, nil)
 	if e != nil {
 		log.Error(e)
 	}
@@ -312,7 +334,7 @@ func (dest *DestinationDefault) removeWithdrawls() {
 	}
 }
 
-func (dest *DestinationDefault) computeKnownBestPath(localAsn uint32) (Path, string, error) {
+func (dest *DestinationDefault) computeKnownBestPath(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker, record comparisonRecorder) (Path, string, error) {
 
 	//	"""Computes the best path among known paths.
 	//
@@ -323,21 +345,73 @@ func (dest *DestinationDefault) computeKnownBestPath(localAsn uint32) (Path, str
 
 	log.Debugf("computeKnownBestPath known pathlist: %d", len(dest.knownPathList))
 
+	if !options.DeterministicMed {
+		currentBestPath, bestPathReason := selectBestPath(localAsn, options, nexthops, dest.knownPathList, record)
+		return currentBestPath, bestPathReason, nil
+	}
+
+	// Deterministic MED: select the best path of each group of paths
+	// received from the same neighbor AS first, so that MED is only
+	// compared among paths where it is meaningful, then select the best
+	// of the group winners.
+	asList := make([]uint32, 0)
+	groups := make(map[uint32][]Path)
+	for _, path := range dest.knownPathList {
+		as := getNeighborAs(localAsn, path)
+		if _, found := groups[as]; !found {
+			asList = append(asList, as)
+		}
+		groups[as] = append(groups[as], path)
+	}
+	winners := make([]Path, 0, len(asList))
+	for _, as := range asList {
+		winner, _ := selectBestPath(localAsn, options, nexthops, groups[as], record)
+		winners = append(winners, winner)
+	}
+	currentBestPath, bestPathReason := selectBestPath(localAsn, options, nexthops, winners, record)
+	return currentBestPath, bestPathReason, nil
+}
+
+func selectBestPath(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker, pathList []Path, record comparisonRecorder) (Path, string) {
 	// We pick the first path as current best path. This helps in breaking
 	// tie between two new paths learned in one cycle for which best-path
 	// calculation steps lead to tie.
-	currentBestPath := dest.knownPathList[0]
+	currentBestPath := pathList[0]
 	bestPathReason := BPR_ONLY_PATH
-	for _, nextPath := range dest.knownPathList[1:] {
+	for _, nextPath := range pathList[1:] {
 		// Compare next path with current best path.
-		// TODO make interface to get Local AS number
-		newBestPath, reason := computeBestPath(localAsn, currentBestPath, nextPath)
+		newBestPath, reason, steps := computeBestPathSteps(localAsn, options, nexthops, currentBestPath, nextPath)
+		if record != nil {
+			record(currentBestPath, nextPath, newBestPath, reason, steps)
+		}
 		bestPathReason = reason
 		if newBestPath != nil {
 			currentBestPath = newBestPath
 		}
 	}
-	return currentBestPath, bestPathReason, nil
+	return currentBestPath, bestPathReason
+}
+
+// getNeighborAs returns the AS the path was received from, that is the
+// leftmost AS of the AS_PATH, or localAsn if the AS_PATH is empty.
+func getNeighborAs(localAsn uint32, path Path) uint32 {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	if attr == nil {
+		return localAsn
+	}
+	for _, param := range attr.(*bgp.PathAttributeAsPath).Value {
+		switch p := param.(type) {
+		case *bgp.As4PathParam:
+			if p.Type == bgp.BGP_ASPATH_ATTR_TYPE_SEQ && len(p.AS) > 0 {
+				return p.AS[0]
+			}
+		case *bgp.AsPathParam:
+			if p.Type == bgp.BGP_ASPATH_ATTR_TYPE_SEQ && len(p.AS) > 0 {
+				return uint32(p.AS[0])
+			}
+		}
+	}
+	return localAsn
 }
 
 func (dest *DestinationDefault) removeOldPaths() {
@@ -376,6 +450,88 @@ func (dest *DestinationDefault) removeOldPaths() {
 	dest.knownPathList = knownPaths
 }
 
+// Computes the set of paths that are as good as the best path for load
+// sharing. The best path is always the first one of the set.
+//
+// Returns true if the set has changed.
+func (dest *DestinationDefault) calculateMultiPath(bestPath Path, localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker, config configuration.UseMultiplePathsType) bool {
+	multiPathList := make([]Path, 0)
+	if bestPath != nil {
+		multiPathList = append(multiPathList, bestPath)
+
+		isEbgp := func(path Path) bool {
+			return path.getSource() != nil && path.getSource().AS != localAsn
+		}
+		maxPaths := config.Ibgp.MaximumPaths
+		if isEbgp(bestPath) {
+			maxPaths = config.Ebgp.MaximumPaths
+		}
+		// eiBGP multipath mixes eBGP and iBGP paths
+		eibgp := config.Eibgp.MaximumPaths > 1
+		if eibgp {
+			maxPaths = config.Eibgp.MaximumPaths
+		}
+
+		for _, path := range dest.knownPathList {
+			if uint32(len(multiPathList)) >= maxPaths {
+				break
+			}
+			if path == bestPath {
+				continue
+			}
+			if !eibgp && isEbgp(path) != isEbgp(bestPath) {
+				continue
+			}
+			relax := config.Ebgp.AllowMultipleAs && isEbgp(path) && isEbgp(bestPath)
+			if !isMultiPathEqual(localAsn, options, nexthops, bestPath, path, relax) {
+				continue
+			}
+			multiPathList = append(multiPathList, path)
+		}
+	}
+
+	changed := len(multiPathList) != len(dest.multiPathList)
+	if !changed {
+		for i, path := range multiPathList {
+			if dest.multiPathList[i] != path {
+				changed = true
+				break
+			}
+		}
+	}
+	dest.multiPathList = multiPathList
+	return changed
+}
+
+// Two paths are equal for multipath if no enabled best path step but
+// the eBGP/iBGP check, the age and the router ID can tell them apart.
+// Unless relax is set they must also have the same AS_PATH, otherwise the
+// same AS_PATH length is enough.
+func isMultiPathEqual(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker, path1, path2 Path, relax bool) bool {
+	if compareByReachableNexthop(nexthops, path1, path2) != nil ||
+		compareByHighestWeight(path1, path2) != nil ||
+		compareByLocalPref(path1, path2) != nil ||
+		compareByLocalOrigin(path1, path2) != nil ||
+		(options.EnableAigp && compareByAigp(path1, path2) != nil) ||
+		(!options.IgnoreAsPathLength && compareByASPath(path1, path2) != nil) ||
+		compareByOrigin(path1, path2) != nil ||
+		compareByMED(localAsn, options, path1, path2) != nil ||
+		compareByIGPCost(nexthops, path1, path2) != nil {
+		return false
+	}
+	if relax {
+		return true
+	}
+	_, attribute1 := path1.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	_, attribute2 := path2.GetPathAttr(bgp.BGP_ATTR_TYPE_AS_PATH)
+	if attribute1 == nil || attribute2 == nil {
+		return attribute1 == attribute2
+	}
+	b1, _ := attribute1.Serialize()
+	b2, _ := attribute2.Serialize()
+	return bytes.Equal(b1, b2)
+}
+
 func deleteAt(list []Path, pos int) ([]Path, bool) {
 	if list != nil {
 		list = append(list[:pos], list[pos+1:]...)
@@ -396,12 +552,21 @@ func removeWithPath(list []Path, path Path) ([]Path, bool) {
 	return list, false
 }
 
-func computeBestPath(localAsn uint32, path1, path2 Path) (Path, string) {
+func computeBestPath(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker, path1, path2 Path) (Path, string) {
+	bestPath, bestPathReason, _ := computeBestPathSteps(localAsn, options, nexthops, path1, path2)
+	return bestPath, bestPathReason
+}
+
+// computeBestPathSteps is computeBestPath that also returns the rules
+// evaluated in order. All but the last one were ties.
+func computeBestPathSteps(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker, path1, path2 Path) (Path, string, []string) {
 
 	//Compares given paths and returns best path.
 	//
 	//Parameters:
 	//	-`localAsn`: asn of local bgpspeaker
+	//	-`options`: route selection options
+	//	-`nexthops`: next hop reachability and IGP metrics
 	//	-`path1`: first path to compare
 	//	-`path2`: second path to compare
 	//
@@ -412,16 +577,22 @@ func computeBestPath(localAsn uint32, path1, path2 Path) (Path, string) {
 	//	local preference value.
 	//	4.  Prefer locally originated routes (network routes, redistributed
 	//	routes, or aggregated routes) over received routes.
-	//	5.  Select the route with the shortest AS-path length.
-	//	6.  If all paths have the same AS-path length, select the path based
+	//	5.  Select the route with the lowest AIGP metric if enable-aigp is
+	//	set.
+	//	6.  Select the route with the shortest AS-path length, unless
+	//	ignore-as-path-length is set.
+	//	7.  If all paths have the same AS-path length, select the path based
 	//	on origin: IGP is preferred over EGP; EGP is preferred over
 	//	Incomplete.
-	//	7.  If the origins are the same, select the path with lowest MED
-	//	value.
-	//	8.  If the paths have the same MED values, select the path learned
+	//	8.  If the origins are the same, select the path with lowest MED
+	//	value. MED is only compared between paths from the same neighbor AS
+	//	unless always-compare-med is set.
+	//	9.  If the paths have the same MED values, select the path learned
 	//	via EBGP over one learned via IBGP.
-	//	9.  Select the route with the lowest IGP cost to the next hop.
-	//	10. Select the route received from the peer with the lowest BGP
+	//	10. Select the route with the lowest IGP cost to the next hop.
+	//	11. If both paths are eBGP paths, select the oldest one, unless
+	//	external-compare-router-id is set.
+	//	12. Select the route received from the peer with the lowest BGP
 	//	router ID.
 	//
 	//	Returns None if best-path among given paths cannot be computed else best
@@ -431,69 +602,96 @@ func computeBestPath(localAsn uint32, path1, path2 Path) (Path, string) {
 
 	var bestPath Path
 	bestPathReason := BPR_UNKNOWN
+	steps := make([]string, 0)
 
 	// Follow best path calculation algorithm steps.
 	// compare by reachability
 	if bestPath == nil {
-		bestPath = compareByReachableNexthop(path1, path2)
+		bestPath = compareByReachableNexthop(nexthops, path1, path2)
 		bestPathReason = BPR_REACHABLE_NEXT_HOP
+		steps = append(steps, BPR_REACHABLE_NEXT_HOP)
 	}
 
 	if bestPath == nil {
 		bestPath = compareByHighestWeight(path1, path2)
 		bestPathReason = BPR_HIGHEST_WEIGHT
+		steps = append(steps, BPR_HIGHEST_WEIGHT)
 	}
 
 	if bestPath == nil {
 		bestPath = compareByLocalPref(path1, path2)
 		bestPathReason = BPR_LOCAL_PREF
+		steps = append(steps, BPR_LOCAL_PREF)
 	}
 	if bestPath == nil {
 		bestPath = compareByLocalOrigin(path1, path2)
 		bestPathReason = BPR_LOCAL_ORIGIN
+		steps = append(steps, BPR_LOCAL_ORIGIN)
 	}
-	if bestPath == nil {
+	if bestPath == nil && options.EnableAigp {
+		bestPath = compareByAigp(path1, path2)
+		bestPathReason = BPR_AIGP
+		steps = append(steps, BPR_AIGP)
+	}
+	if bestPath == nil && !options.IgnoreAsPathLength {
 		bestPath = compareByASPath(path1, path2)
 		bestPathReason = BPR_ASPATH
+		steps = append(steps, BPR_ASPATH)
 	}
 	if bestPath == nil {
 		bestPath = compareByOrigin(path1, path2)
 		bestPathReason = BPR_ORIGIN
+		steps = append(steps, BPR_ORIGIN)
 	}
 	if bestPath == nil {
-		bestPath = compareByMED(path1, path2)
+		bestPath = compareByMED(localAsn, options, path1, path2)
 		bestPathReason = BPR_MED
+		steps = append(steps, BPR_MED)
 	}
 	if bestPath == nil {
 		bestPath = compareByASNumber(localAsn, path1, path2)
 		bestPathReason = BPR_ASN
+		steps = append(steps, BPR_ASN)
 	}
 	if bestPath == nil {
-		bestPath = compareByIGPCost(path1, path2)
+		bestPath = compareByIGPCost(nexthops, path1, path2)
 		bestPathReason = BPR_IGP_COST
+		steps = append(steps, BPR_IGP_COST)
+	}
+	if bestPath == nil {
+		bestPath = compareByAge(localAsn, options, path1, path2)
+		bestPathReason = BPR_OLDER
+		steps = append(steps, BPR_OLDER)
 	}
 	if bestPath == nil {
 		var e error = nil
-		bestPath, e = compareByRouterID(localAsn, path1, path2)
+		bestPath, e = compareByRouterID(localAsn, options, path1, path2)
 		if e != nil {
 			log.Error(e)
 		}
 		bestPathReason = BPR_ROUTER_ID
+		steps = append(steps, BPR_ROUTER_ID)
 	}
 	if bestPath == nil {
 		bestPathReason = BPR_UNKNOWN
 	}
 
-	return bestPath, bestPathReason
+	return bestPath, bestPathReason, steps
 }
 
-func compareByReachableNexthop(path1, path2 Path) Path {
+func compareByReachableNexthop(nexthops *NexthopTracker, path1, path2 Path) Path {
 	//	Compares given paths and selects best path based on reachable next-hop.
 	//
 	//	If no path matches this criteria, return None.
-	//  However RouteServer doesn't need to check reachability, so return nil.
 	log.Debugf("enter compareByReachableNexthop")
 	log.Debugf("path1: %s, path2: %s", path1, path2)
+	reachable1 := nexthops.isReachable(path1)
+	reachable2 := nexthops.isReachable(path2)
+	if reachable1 && !reachable2 {
+		return path1
+	} else if !reachable1 && reachable2 {
+		return path2
+	}
 	return nil
 }
 
@@ -519,15 +717,15 @@ func compareByLocalPref(path1, path2 Path) Path {
 	//
 	//	# Default local-pref values is 100
 	log.Debugf("enter compareByLocalPref")
-	_, attribute1 := path1.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
-	_, attribute2 := path2.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
-
-	if attribute1 == nil || attribute2 == nil {
-		return nil
+	localPref := func(path Path) uint32 {
+		_, attribute := path.GetPathAttr(bgp.BGP_ATTR_TYPE_LOCAL_PREF)
+		if attribute == nil {
+			return DEFAULT_LOCAL_PREF
+		}
+		return attribute.(*bgp.PathAttributeLocalPref).Value
 	}
-
-	localPref1 := attribute1.(*bgp.PathAttributeLocalPref).Value
-	localPref2 := attribute2.(*bgp.PathAttributeLocalPref).Value
+	localPref1 := localPref(path1)
+	localPref2 := localPref(path2)
 
 	// Highest local-preference value is preferred.
 	if localPref1 > localPref2 {
@@ -539,6 +737,33 @@ func compareByLocalPref(path1, path2 Path) Path {
 	}
 }
 
+func compareByAigp(path1, path2 Path) Path {
+	//	Selects a path with the lowest AIGP metric.
+	//
+	//	A path without the AIGP attribute is treated as if it had the
+	//	largest possible metric (RFC 7311 4.1). If we cannot decide, we
+	//	return None.
+	log.Debugf("enter compareByAigp")
+	aigp := func(path Path) (uint64, bool) {
+		_, attribute := path.GetPathAttr(bgp.BGP_ATTR_TYPE_AIGP)
+		if attribute == nil {
+			return 0, false
+		}
+		return attribute.(*bgp.PathAttributeAigp).Metric()
+	}
+	metric1, found1 := aigp(path1)
+	metric2, found2 := aigp(path2)
+
+	if !found1 && !found2 {
+		return nil
+	} else if !found2 || (found1 && metric1 < metric2) {
+		return path1
+	} else if !found1 || metric2 < metric1 {
+		return path2
+	}
+	return nil
+}
+
 func compareByLocalOrigin(path1, path2 Path) Path {
 
 	// """Select locally originating path as best path.
@@ -630,16 +855,19 @@ func compareByOrigin(path1, path2 Path) Path {
 	}
 }
 
-func compareByMED(path1, path2 Path) Path {
+func compareByMED(localAsn uint32, options configuration.RouteSelectionOptionsType, path1, path2 Path) Path {
 	//	Select the path based with lowest MED value.
 	//
 	//	If both paths have same MED, return None.
 	//	By default, a route that arrives with no MED value is treated as if it
 	//	had a MED of 0, the most preferred value.
 	//	RFC says lower MED is preferred over higher MED value.
-	//  compare MED among not only same AS path but also all path,
-	//  like bgp always-compare-med
+	//  MED is compared among paths from the same neighbor AS only,
+	//  unless always-compare-med is set.
 	log.Debugf("enter compareByMED")
+	if !options.AlwaysCompareMed && getNeighborAs(localAsn, path1) != getNeighborAs(localAsn, path2) {
+		return nil
+	}
 	getMed := func(path Path) uint32 {
 		_, attribute := path.GetPathAttr(bgp.BGP_ATTR_TYPE_MULTI_EXIT_DISC)
 		if attribute == nil {
@@ -693,21 +921,54 @@ func compareByASNumber(localAsn uint32, path1, path2 Path) Path {
 	return nil
 }
 
-func compareByIGPCost(path1, path2 Path) Path {
+func compareByIGPCost(nexthops *NexthopTracker, path1, path2 Path) Path {
 	//	Select the route with the lowest IGP cost to the next hop.
 	//
 	//	Return None if igp cost is same.
-	// Currently BGPS has no concept of IGP and IGP cost.
+	//	The cost is the metric of the route the next hop resolves over.
 	log.Debugf("enter compareByIGPCost")
 	log.Debugf("path1: %s, path2: %s", path1, path2)
+	cost1 := nexthops.getMetric(path1)
+	cost2 := nexthops.getMetric(path2)
+	if cost1 < cost2 {
+		return path1
+	} else if cost1 > cost2 {
+		return path2
+	}
+	return nil
+}
+
+func compareByAge(localAsn uint32, options configuration.RouteSelectionOptionsType, path1, path2 Path) Path {
+	//	Select the path that was received first if both paths are eBGP
+	//	paths, so that the best path does not flap on router ID changes.
+	//	RFC: http://tools.ietf.org/html/rfc5004
+	//	Return None if external-compare-router-id is set.
+	log.Debugf("enter compareByAge")
+	if options.ExternalCompareRouterId {
+		return nil
+	}
+	isEbgp := func(path Path) bool {
+		return path.getSource() != nil && path.getSource().AS != localAsn
+	}
+	if !isEbgp(path1) || !isEbgp(path2) {
+		return nil
+	}
+	t1 := path1.getTimestamp()
+	t2 := path2.getTimestamp()
+	if t1.Before(t2) {
+		return path1
+	} else if t2.Before(t1) {
+		return path2
+	}
 	return nil
 }
 
-func compareByRouterID(localAsn uint32, path1, path2 Path) (Path, error) {
+func compareByRouterID(localAsn uint32, options configuration.RouteSelectionOptionsType, path1, path2 Path) (Path, error) {
 	//	Select the route received from the peer with the lowest BGP router ID.
 	//
 	//	If both paths are eBGP paths, then we do not do any tie breaking, i.e we do
-	//	not pick best-path based on this criteria.
+	//	not pick best-path based on this criteria, unless
+	//	external-compare-router-id is set.
 	//	RFC: http://tools.ietf.org/html/rfc5004
 	//	We pick best path between two iBGP paths as usual.
 	log.Debugf("enter compareByRouterID")
@@ -744,7 +1005,7 @@ func compareByRouterID(localAsn uint32, path1, path2 Path) (Path, error) {
 	isEbgp2 := asn2 != localAsn
 	// If both paths are from eBGP peers, then according to RFC we need
 	// not tie break using router id.
-	if isEbgp1 && isEbgp2 {
+	if isEbgp1 && isEbgp2 && !options.ExternalCompareRouterId {
 		return nil, nil
 	}
 
@@ -857,6 +1118,7 @@ func (ipv6d *IPv6Destination) GetPrefix() net.IP {
 
 func (ipv6d *IPv6Destination) MarshalJSON() ([]byte, error) {
 	prefix := ipv6d.GetNlri().(*bgp.IPv6AddrPrefix).Prefix
+	ipv6d.setPathFlags()
 	return json.Marshal(struct {
 		Prefix string
 		Paths  []Path
@@ -865,3 +1127,263 @@ func (ipv6d *IPv6Destination) MarshalJSON() ([]byte, error) {
 		Paths:  ipv6d.knownPathList,
 	})
 }
+
+type MulticastDestination struct {
+	*DestinationDefault
+}
+
+func NewMulticastDestination(nlri bgp.AddrPrefixInterface, rf bgp.RouteFamily) *MulticastDestination {
+	multicastDestination := &MulticastDestination{}
+	multicastDestination.DestinationDefault = NewDestinationDefault(nlri)
+	multicastDestination.DestinationDefault.ROUTE_FAMILY = rf
+	return multicastDestination
+}
+
+func (mcd *MulticastDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", mcd.nlri.String())
+}
+
+func (mcd *MulticastDestination) MarshalJSON() ([]byte, error) {
+	mcd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: mcd.nlri.String(),
+		Paths:  mcd.knownPathList,
+	})
+}
+
+type IPv4VPNDestination struct {
+	*DestinationDefault
+}
+
+func NewIPv4VPNDestination(nlri bgp.AddrPrefixInterface) *IPv4VPNDestination {
+	ipv4VPNDestination := &IPv4VPNDestination{}
+	ipv4VPNDestination.DestinationDefault = NewDestinationDefault(nlri)
+	ipv4VPNDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_IPv4_VPN
+	return ipv4VPNDestination
+}
+
+func (ipv4vpnd *IPv4VPNDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", ipv4vpnd.nlri.String())
+}
+
+func (ipv4vpnd *IPv4VPNDestination) MarshalJSON() ([]byte, error) {
+	ipv4vpnd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: ipv4vpnd.nlri.String(),
+		Paths:  ipv4vpnd.knownPathList,
+	})
+}
+
+type IPv6VPNDestination struct {
+	*DestinationDefault
+}
+
+func NewIPv6VPNDestination(nlri bgp.AddrPrefixInterface) *IPv6VPNDestination {
+	ipv6VPNDestination := &IPv6VPNDestination{}
+	ipv6VPNDestination.DestinationDefault = NewDestinationDefault(nlri)
+	ipv6VPNDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_IPv6_VPN
+	return ipv6VPNDestination
+}
+
+func (ipv6vpnd *IPv6VPNDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", ipv6vpnd.nlri.String())
+}
+
+func (ipv6vpnd *IPv6VPNDestination) MarshalJSON() ([]byte, error) {
+	ipv6vpnd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: ipv6vpnd.nlri.String(),
+		Paths:  ipv6vpnd.knownPathList,
+	})
+}
+
+type RouteTargetDestination struct {
+	*DestinationDefault
+}
+
+func NewRouteTargetDestination(nlri bgp.AddrPrefixInterface) *RouteTargetDestination {
+	routeTargetDestination := &RouteTargetDestination{}
+	routeTargetDestination.DestinationDefault = NewDestinationDefault(nlri)
+	routeTargetDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_RTC_UC
+	return routeTargetDestination
+}
+
+func (rtd *RouteTargetDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", rtd.nlri.String())
+}
+
+func (rtd *RouteTargetDestination) MarshalJSON() ([]byte, error) {
+	rtd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: rtd.nlri.String(),
+		Paths:  rtd.knownPathList,
+	})
+}
+
+type IPv4MPLSDestination struct {
+	*DestinationDefault
+}
+
+func NewIPv4MPLSDestination(nlri bgp.AddrPrefixInterface) *IPv4MPLSDestination {
+	ipv4MPLSDestination := &IPv4MPLSDestination{}
+	ipv4MPLSDestination.DestinationDefault = NewDestinationDefault(nlri)
+	ipv4MPLSDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_IPv4_MPLS
+	return ipv4MPLSDestination
+}
+
+func (ipv4mplsd *IPv4MPLSDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", ipv4mplsd.nlri.String())
+}
+
+func (ipv4mplsd *IPv4MPLSDestination) MarshalJSON() ([]byte, error) {
+	ipv4mplsd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: ipv4mplsd.nlri.String(),
+		Paths:  ipv4mplsd.knownPathList,
+	})
+}
+
+type IPv6MPLSDestination struct {
+	*DestinationDefault
+}
+
+func NewIPv6MPLSDestination(nlri bgp.AddrPrefixInterface) *IPv6MPLSDestination {
+	ipv6MPLSDestination := &IPv6MPLSDestination{}
+	ipv6MPLSDestination.DestinationDefault = NewDestinationDefault(nlri)
+	ipv6MPLSDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_IPv6_MPLS
+	return ipv6MPLSDestination
+}
+
+func (ipv6mplsd *IPv6MPLSDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", ipv6mplsd.nlri.String())
+}
+
+func (ipv6mplsd *IPv6MPLSDestination) MarshalJSON() ([]byte, error) {
+	ipv6mplsd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: ipv6mplsd.nlri.String(),
+		Paths:  ipv6mplsd.knownPathList,
+	})
+}
+
+type VPLSDestination struct {
+	*DestinationDefault
+}
+
+func NewVPLSDestination(nlri bgp.AddrPrefixInterface) *VPLSDestination {
+	vplsDestination := &VPLSDestination{}
+	vplsDestination.DestinationDefault = NewDestinationDefault(nlri)
+	vplsDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_VPLS
+	return vplsDestination
+}
+
+func (vplsd *VPLSDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", vplsd.nlri.String())
+}
+
+func (vplsd *VPLSDestination) MarshalJSON() ([]byte, error) {
+	vplsd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: vplsd.nlri.String(),
+		Paths:  vplsd.knownPathList,
+	})
+}
+
+type EVPNDestination struct {
+	*DestinationDefault
+}
+
+func NewEVPNDestination(nlri bgp.AddrPrefixInterface) *EVPNDestination {
+	evpnDestination := &EVPNDestination{}
+	evpnDestination.DestinationDefault = NewDestinationDefault(nlri)
+	evpnDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_EVPN
+	return evpnDestination
+}
+
+func (evpnd *EVPNDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", evpnd.nlri.String())
+}
+
+func (evpnd *EVPNDestination) MarshalJSON() ([]byte, error) {
+	evpnd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: evpnd.nlri.String(),
+		Paths:  evpnd.knownPathList,
+	})
+}
+
+type LsDestination struct {
+	*DestinationDefault
+}
+
+func NewLsDestination(nlri bgp.AddrPrefixInterface) *LsDestination {
+	lsDestination := &LsDestination{}
+	lsDestination.DestinationDefault = NewDestinationDefault(nlri)
+	lsDestination.DestinationDefault.ROUTE_FAMILY = bgp.RF_LS
+	return lsDestination
+}
+
+func (lsd *LsDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", lsd.nlri.String())
+}
+
+func (lsd *LsDestination) MarshalJSON() ([]byte, error) {
+	lsd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: lsd.nlri.String(),
+		Paths:  lsd.knownPathList,
+	})
+}
+
+type FlowSpecDestination struct {
+	*DestinationDefault
+}
+
+func NewFlowSpecDestination(nlri bgp.AddrPrefixInterface, rf bgp.RouteFamily) *FlowSpecDestination {
+	flowSpecDestination := &FlowSpecDestination{}
+	flowSpecDestination.DestinationDefault = NewDestinationDefault(nlri)
+	flowSpecDestination.DestinationDefault.ROUTE_FAMILY = rf
+	return flowSpecDestination
+}
+
+func (fsd *FlowSpecDestination) String() string {
+	return fmt.Sprintf("Destination NLRI: %s", fsd.nlri.String())
+}
+
+func (fsd *FlowSpecDestination) MarshalJSON() ([]byte, error) {
+	fsd.setPathFlags()
+	return json.Marshal(struct {
+		Prefix string
+		Paths  []Path
+	}{
+		Prefix: fsd.nlri.String(),
+		Paths:  fsd.knownPathList,
+	})
+}
@@ -17,8 +17,11 @@ package table
 
 import (
 	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	"github.com/gopher-net/gopher-net/configuration"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
 	"testing"
+	"time"
 )
 
 func TestDestinationNewIPv4(t *testing.T) {
@@ -104,7 +107,7 @@ func TestDestinationCalculate(t *testing.T) {
 	ipv4d.addNewPath(pathD[1])
 	ipv4d.addNewPath(pathD[2])
 	ipv4d.addWithdraw(pathD[2])
-	_, _, e := ipv4d.Calculate(uint32(100))
+	_, _, e := ipv4d.Calculate(uint32(100), configuration.RouteSelectionOptionsType{}, nil)
 	assert.Nil(t, e)
 }
 
@@ -195,3 +198,145 @@ func updateMsgD3() *bgp.BGPMessage {
 	withdrawnRoutes := []bgp.WithdrawnRoute{w1}
 	return bgp.NewBGPUpdateMessage(withdrawnRoutes, pathAttributes, nlri)
 }
+
+// create a path for 10.10.10.0/24 received from an eBGP peer at the given time
+func selectionPath(peerAs uint32, routerId string, ases []uint32, med uint32, age time.Duration) Path {
+	peer := &PeerInfo{
+		AS:      peerAs,
+		ID:      net.ParseIP(routerId).To4(),
+		LocalID: net.ParseIP("10.0.0.1").To4(),
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute(ases),
+		bgp.NewPathAttributeNextHop("192.168.50.1"),
+		bgp.NewPathAttributeMultiExitDisc(med),
+	}
+	nlri := bgp.NewNLRInfo(24, "10.10.10.0")
+	path := CreatePath(peer, nlri, pathAttributes, false)
+	path.(*IPv4Path).timestamp = time.Now().Add(-age)
+	return path
+}
+
+func bestOf(options configuration.RouteSelectionOptionsType, pathList ...Path) (Path, string) {
+	dest := NewIPv4Destination(pathList[0].GetNlri())
+	dest.setKnownPathList(pathList)
+	best, reason, _ := dest.computeKnownBestPath(65500, options, nil, nil)
+	return best, reason
+}
+
+func TestDestinationSelectionMed(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	path1 := selectionPath(65000, "10.0.0.2", []uint32{65000}, 100, time.Second)
+	path2 := selectionPath(65100, "10.0.0.3", []uint32{65100}, 0, 2*time.Second)
+
+	// MED is not compared between different neighbor ASes
+	best, reason := bestOf(options, path1, path2)
+	assert.Equal(t, path2, best)
+	assert.Equal(t, BPR_OLDER, reason)
+
+	options.AlwaysCompareMed = true
+	path1 = selectionPath(65000, "10.0.0.2", []uint32{65000}, 0, time.Second)
+	path2 = selectionPath(65100, "10.0.0.3", []uint32{65100}, 100, 2*time.Second)
+	best, reason = bestOf(options, path1, path2)
+	assert.Equal(t, path1, best)
+	assert.Equal(t, BPR_MED, reason)
+}
+
+func TestDestinationSelectionIgnoreAsPathLength(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	path1 := selectionPath(65000, "10.0.0.2", []uint32{65000, 65200}, 0, time.Second)
+	path2 := selectionPath(65100, "10.0.0.3", []uint32{65100}, 0, 0)
+
+	best, reason := bestOf(options, path1, path2)
+	assert.Equal(t, path2, best)
+	assert.Equal(t, BPR_ASPATH, reason)
+
+	options.IgnoreAsPathLength = true
+	best, reason = bestOf(options, path1, path2)
+	assert.Equal(t, path1, best)
+	assert.Equal(t, BPR_OLDER, reason)
+}
+
+func TestDestinationSelectionExternalRouterId(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	path1 := selectionPath(65000, "10.0.0.3", []uint32{65000}, 0, time.Second)
+	path2 := selectionPath(65100, "10.0.0.2", []uint32{65100}, 0, 0)
+
+	// the oldest eBGP path wins regardless of the order
+	best, _ := bestOf(options, path1, path2)
+	assert.Equal(t, path1, best)
+	best, _ = bestOf(options, path2, path1)
+	assert.Equal(t, path1, best)
+
+	options.ExternalCompareRouterId = true
+	best, reason := bestOf(options, path1, path2)
+	assert.Equal(t, path2, best)
+	assert.Equal(t, BPR_ROUTER_ID, reason)
+}
+
+func TestDestinationSelectionOlderClone(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	path1 := selectionPath(65000, "10.0.0.3", []uint32{65000}, 0, time.Second)
+	path2 := selectionPath(65100, "10.0.0.2", []uint32{65100}, 0, 0)
+
+	// a path rewritten by a policy is as old as the received one
+	clone := path1.CloneWithPathAttrs(path1.GetPathAttrs())
+	assert.Equal(t, path1.getTimestamp(), clone.getTimestamp())
+	best, reason := bestOf(options, path2, clone)
+	assert.Equal(t, clone, best)
+	assert.Equal(t, BPR_OLDER, reason)
+
+	assert.Equal(t, path1.getTimestamp(), path1.Clone(true).getTimestamp())
+}
+
+func TestDestinationSelectionDeterministicMed(t *testing.T) {
+	// pathA and pathC come from the same neighbor AS, pathC has the
+	// lower MED. pathA is the oldest path, pathC the newest.
+	pathA := selectionPath(65000, "10.0.0.2", []uint32{65000}, 200, 3*time.Second)
+	pathB := selectionPath(65100, "10.0.0.3", []uint32{65100}, 100, 2*time.Second)
+	pathC := selectionPath(65000, "10.0.0.4", []uint32{65000}, 100, time.Second)
+
+	// without deterministic MED the result depends on the order
+	options := configuration.RouteSelectionOptionsType{}
+	best1, _ := bestOf(options, pathA, pathB, pathC)
+	best2, _ := bestOf(options, pathC, pathB, pathA)
+	assert.Equal(t, pathC, best1)
+	assert.Equal(t, pathA, best2)
+
+	options.DeterministicMed = true
+	orders := [][]Path{
+		{pathA, pathB, pathC},
+		{pathA, pathC, pathB},
+		{pathB, pathA, pathC},
+		{pathB, pathC, pathA},
+		{pathC, pathA, pathB},
+		{pathC, pathB, pathA},
+	}
+	for _, order := range orders {
+		best, _ := bestOf(options, order...)
+		assert.Equal(t, pathB, best)
+	}
+}
+
+func TestDestinationSelectionAigp(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	path1 := selectionPath(65000, "10.0.0.2", []uint32{65000, 65001}, 0, time.Second)
+	path2 := selectionPath(65000, "10.0.0.3", []uint32{65000, 65001, 65002}, 0, 2*time.Second)
+	path2.(*IPv4Path).pathAttrs = append(path2.GetPathAttrs(), bgp.NewPathAttributeAigp(10))
+
+	best, reason := bestOf(options, path1, path2)
+	assert.Equal(t, path1, best)
+	assert.Equal(t, BPR_ASPATH, reason)
+
+	// AIGP is compared before the AS path length
+	options.EnableAigp = true
+	best, reason = bestOf(options, path1, path2)
+	assert.Equal(t, path2, best)
+	assert.Equal(t, BPR_AIGP, reason)
+
+	path1.(*IPv4Path).pathAttrs = append(path1.GetPathAttrs(), bgp.NewPathAttributeAigp(5))
+	best, reason = bestOf(options, path1, path2)
+	assert.Equal(t, path1, best)
+	assert.Equal(t, BPR_AIGP, reason)
+}
@@ -0,0 +1,171 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/json"
+	"fmt"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"sort"
+)
+
+// EVPNMacIPRoute is a host, such as a local container, advertised in a
+// MAC/IP advertisement route of the VXLAN segment Vni.
+type EVPNMacIPRoute struct {
+	Rd             bgp.RouteDistinguisherInterface
+	Esi            bgp.EthernetSegmentIdentifier
+	ETag           uint32
+	Mac            net.HardwareAddr
+	IP             net.IP
+	Vni            uint32
+	ExtCommunities []bgp.ExtendedCommunityInterface
+}
+
+func (r *EVPNMacIPRoute) nlri() *bgp.EVPNNLRI {
+	return bgp.NewEVPNNLRI(bgp.EVPN_ROUTE_TYPE_MAC_IP_ADVERTISEMENT, &bgp.EVPNMacIPAdvertisementRoute{
+		RD:         r.Rd,
+		ESI:        r.Esi,
+		ETag:       r.ETag,
+		MacAddress: r.Mac,
+		IPAddress:  r.IP,
+		Labels:     []uint32{r.Vni},
+	})
+}
+
+// evpnPathAttrs returns the attributes of a locally originated EVPN
+// path, the VXLAN encapsulation is added to the extended communities.
+func evpnPathAttrs(nlri *bgp.EVPNNLRI, nexthop net.IP, ecommunities []bgp.ExtendedCommunityInterface) []bgp.PathAttributeInterface {
+	encap, _ := bgp.ParseExtendedCommunity("encap:vxlan")
+	if !hasExtendedCommunity(ecommunities, encap) {
+		ecommunities = append(append([]bgp.ExtendedCommunityInterface{}, ecommunities...), encap)
+	}
+	return []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeExtendedCommunities(ecommunities),
+		bgp.NewPathAttributeMpReachNLRI(nexthop.String(), []bgp.AddrPrefixInterface{nlri}),
+	}
+}
+
+// the inclusive multicast route of an ethernet tag and the number of
+// local MAC/IP routes using it
+type evpnMulticast struct {
+	path  Path
+	count int
+}
+
+// EVPNManager holds the EVPN routes originated for the local hosts. The
+// routes of the hosts are MAC/IP advertisement routes, each route
+// distinguisher and ethernet tag of them is advertised in an inclusive
+// multicast route so that the other VTEPs flood the broadcast, unknown
+// unicast and multicast traffic to this one, the next hop.
+type EVPNManager struct {
+	nexthop net.IP
+	// local MAC/IP advertisement paths by route key
+	local     map[string]Path
+	multicast map[string]*evpnMulticast
+}
+
+func NewEVPNManager(nexthop net.IP) *EVPNManager {
+	return &EVPNManager{
+		nexthop:   nexthop,
+		local:     make(map[string]Path),
+		multicast: make(map[string]*evpnMulticast),
+	}
+}
+
+func (manager *EVPNManager) multicastNlri(rd bgp.RouteDistinguisherInterface, etag uint32) *bgp.EVPNNLRI {
+	return bgp.NewEVPNNLRI(bgp.EVPN_INCLUSIVE_MULTICAST_ETHERNET_TAG, &bgp.EVPNMulticastEthernetTagRoute{
+		RD:        rd,
+		ETag:      etag,
+		IPAddress: manager.nexthop,
+	})
+}
+
+// AddMacIPRoute originates the MAC/IP advertisement route of a local
+// host, it replaces the route of the same MAC and IP address. The paths
+// to advertise are returned, with the inclusive multicast route of the
+// ethernet tag if it is new.
+func (manager *EVPNManager) AddMacIPRoute(r *EVPNMacIPRoute) []Path {
+	nlri := r.nlri()
+	pathList := make([]Path, 0, 2)
+	key := nlri.String()
+	if _, found := manager.local[key]; !found {
+		mnlri := manager.multicastNlri(r.Rd, r.ETag)
+		m, found := manager.multicast[mnlri.String()]
+		if !found {
+			m = &evpnMulticast{
+				path: CreatePath(nil, mnlri, evpnPathAttrs(mnlri, manager.nexthop, r.ExtCommunities), false),
+			}
+			manager.multicast[mnlri.String()] = m
+			pathList = append(pathList, m.path)
+		}
+		m.count++
+	}
+	path := CreatePath(nil, nlri, evpnPathAttrs(nlri, manager.nexthop, r.ExtCommunities), false)
+	manager.local[key] = path
+	return append(pathList, path)
+}
+
+// DeleteMacIPRoute withdraws the MAC/IP advertisement route of a local
+// host, and the inclusive multicast route of its ethernet tag with the
+// last of its routes.
+func (manager *EVPNManager) DeleteMacIPRoute(r *EVPNMacIPRoute) ([]Path, error) {
+	key := r.nlri().String()
+	path, found := manager.local[key]
+	if !found {
+		return nil, fmt.Errorf("evpn route %s does not exist", key)
+	}
+	delete(manager.local, key)
+	pathList := []Path{path.Clone(true)}
+	mkey := manager.multicastNlri(r.Rd, r.ETag).String()
+	m := manager.multicast[mkey]
+	m.count--
+	if m.count == 0 {
+		delete(manager.multicast, mkey)
+		pathList = append(pathList, m.path.Clone(true))
+	}
+	return pathList, nil
+}
+
+// GetPathList returns the paths of all the local routes.
+func (manager *EVPNManager) GetPathList() []Path {
+	pathList := make([]Path, 0, len(manager.multicast)+len(manager.local))
+	for _, m := range manager.multicast {
+		pathList = append(pathList, m.path)
+	}
+	for _, path := range manager.local {
+		pathList = append(pathList, path)
+	}
+	return pathList
+}
+
+func (manager *EVPNManager) MarshalJSON() ([]byte, error) {
+	pathList := manager.GetPathList()
+	keys := make([]string, 0, len(pathList))
+	paths := make(map[string]Path, len(pathList))
+	for _, path := range pathList {
+		keys = append(keys, path.GetPrefix())
+		paths[path.GetPrefix()] = path
+	}
+	sort.Strings(keys)
+	l := make([]Path, 0, len(keys))
+	for _, key := range keys {
+		l = append(l, paths[key])
+	}
+	return json.Marshal(l)
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+func macIPRoute(mac string, ip string) *EVPNMacIPRoute {
+	hw, _ := net.ParseMAC(mac)
+	return &EVPNMacIPRoute{
+		Rd:   bgp.NewRouteDistinguisherTwoOctetAS(65000, 100),
+		ETag: 0,
+		Mac:  hw,
+		IP:   net.ParseIP(ip).To4(),
+		Vni:  10100,
+	}
+}
+
+func TestEVPNManager(t *testing.T) {
+	manager := NewEVPNManager(net.ParseIP("10.0.0.1").To4())
+
+	// the first host brings the inclusive multicast route of its tag
+	pathList := manager.AddMacIPRoute(macIPRoute("02:42:ac:11:00:02", "172.17.0.2"))
+	assert.Equal(t, 2, len(pathList))
+	assert.Equal(t, bgp.RF_EVPN, pathList[0].GetRouteFamily())
+	assert.Equal(t, "[type:multicast][rd:65000:100][etag:0][ip:10.0.0.1]", pathList[0].GetPrefix())
+	assert.Equal(t, "10.0.0.1", pathList[1].GetNexthop().String())
+	pathList = manager.AddMacIPRoute(macIPRoute("02:42:ac:11:00:03", "172.17.0.3"))
+	assert.Equal(t, 1, len(pathList))
+	assert.Equal(t, 3, len(manager.GetPathList()))
+
+	_, err := manager.DeleteMacIPRoute(macIPRoute("02:42:ac:11:00:04", "172.17.0.4"))
+	assert.NotNil(t, err)
+	pathList, _ = manager.DeleteMacIPRoute(macIPRoute("02:42:ac:11:00:02", "172.17.0.2"))
+	assert.Equal(t, 1, len(pathList))
+	assert.True(t, pathList[0].IsWithdraw())
+	pathList, _ = manager.DeleteMacIPRoute(macIPRoute("02:42:ac:11:00:03", "172.17.0.3"))
+	assert.Equal(t, 2, len(pathList))
+	assert.True(t, pathList[1].IsWithdraw())
+	assert.Equal(t, 0, len(manager.GetPathList()))
+}
+
+func TestEVPNTable(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_EVPN}
+	manager := NewEVPNManager(net.ParseIP("10.0.0.2").To4())
+	msgs := CreateUpdateMsgFromPaths(manager.AddMacIPRoute(macIPRoute("02:42:ac:11:00:02", "172.17.0.2")))
+	assert.Equal(t, 2, len(msgs))
+
+	tm := NewTableManager()
+	for _, m := range msgs {
+		buf, _ := m.Serialize()
+		msg, err := bgp.ParseBGPMessage(buf)
+		assert.Nil(t, err)
+		tm.ProcessPaths(NewProcessMessage(msg, peer).ToPathList())
+	}
+	assert.Equal(t, 2, len(tm.Tables[bgp.RF_EVPN].GetDestinations()))
+	pathList := tm.GetBestPathList(bgp.RF_EVPN)
+	assert.Equal(t, 2, len(pathList))
+	for _, path := range pathList {
+		assert.Equal(t, "10.0.0.2", path.GetNexthop().String())
+	}
+	_, found := tm.Tables[bgp.RF_EVPN].GetDestinations()["[type:macadv][rd:65000:100][etag:0][mac:02:42:ac:11:00:02][ip:172.17.0.2]"]
+	assert.True(t, found)
+}
+
+func TestEVPNUnknownRouteType(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_EVPN}
+	// an ethernet auto-discovery route, type 1, and a MAC/IP route
+	ead := bgp.NewEVPNNLRI(1, &bgp.EVPNUnknownRoute{Value: make([]byte, 25)})
+	macadv := macIPRoute("02:42:ac:11:00:02", "172.17.0.2").nlri()
+	pathAttributes := evpnPathAttrs(macadv, net.ParseIP("10.0.0.2"), nil)
+	pathAttributes[3] = bgp.NewPathAttributeMpReachNLRI("10.0.0.2", []bgp.AddrPrefixInterface{ead, macadv})
+	buf, _ := bgp.NewBGPUpdateMessage(nil, pathAttributes, nil).Serialize()
+
+	msg, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+	pathList := NewProcessMessage(msg, peer).ToPathList()
+	assert.Equal(t, 1, len(pathList))
+	assert.Equal(t, macadv.String(), pathList[0].GetPrefix())
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"fmt"
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+)
+
+type comparisonRecorder func(path1, path2, bestPath Path, reason string, steps []string)
+
+type ExplainedPath struct {
+	Source *PeerInfo
+	Path   Path
+}
+
+// A pairwise comparison made by the best path selection. Path1, Path2
+// and Winner are indexes into BestPathExplanation.Paths, Winner is -1 if
+// the comparison was a tie and Path1 was kept. Steps lists the rules in
+// the order they were evaluated.
+type BestPathComparison struct {
+	Path1  int
+	Path2  int
+	Steps  []string
+	Winner int
+	Reason string
+}
+
+type BestPathExplanation struct {
+	Prefix      string
+	Paths       []*ExplainedPath
+	Comparisons []*BestPathComparison
+	BestPath    int
+	Reason      string
+}
+
+// Explain replays the best path selection among the known paths without
+// modifying the destination.
+func (dest *DestinationDefault) Explain(localAsn uint32, options configuration.RouteSelectionOptionsType, nexthops *NexthopTracker) *BestPathExplanation {
+	e := &BestPathExplanation{
+		Paths:       make([]*ExplainedPath, 0, len(dest.knownPathList)),
+		Comparisons: make([]*BestPathComparison, 0),
+		BestPath:    -1,
+		Reason:      BPR_UNKNOWN,
+	}
+	if len(dest.knownPathList) == 0 {
+		return e
+	}
+	e.Prefix = dest.knownPathList[0].GetPrefix()
+
+	index := make(map[Path]int)
+	for i, path := range dest.knownPathList {
+		index[path] = i
+		e.Paths = append(e.Paths, &ExplainedPath{
+			Source: path.getSource(),
+			Path:   path,
+		})
+	}
+
+	record := func(path1, path2, bestPath Path, reason string, steps []string) {
+		c := &BestPathComparison{
+			Path1:  index[path1],
+			Path2:  index[path2],
+			Steps:  steps,
+			Winner: -1,
+			Reason: reason,
+		}
+		if bestPath != nil {
+			c.Winner = index[bestPath]
+		}
+		e.Comparisons = append(e.Comparisons, c)
+	}
+	bestPath, reason, _ := dest.computeKnownBestPath(localAsn, options, nexthops, record)
+	if bestPath != nil {
+		e.BestPath = index[bestPath]
+		e.Reason = reason
+	}
+	return e
+}
+
+func (manager *TableManager) Explain(rf bgp.RouteFamily, prefix string) (*BestPathExplanation, error) {
+	t, found := manager.Tables[rf]
+	if !found {
+		return nil, fmt.Errorf("route family %s is not supported", rf)
+	}
+	dest := t.getDestination(prefix)
+	if dest == nil {
+		return nil, fmt.Errorf("destination %s is not found", prefix)
+	}
+	return dest.Explain(manager.localAsn, manager.selection, manager.nexthops), nil
+}
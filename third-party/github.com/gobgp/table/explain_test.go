@@ -0,0 +1,64 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"testing"
+	"time"
+)
+
+func TestExplainDestination(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	path1 := selectionPath(65000, "10.0.0.2", []uint32{65000, 65001}, 0, time.Second)
+	path2 := selectionPath(65100, "10.0.0.3", []uint32{65100}, 0, 2*time.Second)
+	dest := NewIPv4Destination(path1.GetNlri())
+	dest.setKnownPathList([]Path{path1, path2})
+
+	e := dest.Explain(65500, options, nil)
+	assert.Equal(t, "10.10.10.0/24", e.Prefix)
+	assert.Equal(t, 2, len(e.Paths))
+	assert.Equal(t, uint32(65100), e.Paths[1].Source.AS)
+	assert.Equal(t, 1, e.BestPath)
+	assert.Equal(t, BPR_ASPATH, e.Reason)
+
+	assert.Equal(t, 1, len(e.Comparisons))
+	c := e.Comparisons[0]
+	assert.Equal(t, 0, c.Path1)
+	assert.Equal(t, 1, c.Path2)
+	assert.Equal(t, 1, c.Winner)
+	assert.Equal(t, BPR_ASPATH, c.Reason)
+	assert.Equal(t, []string{BPR_REACHABLE_NEXT_HOP, BPR_HIGHEST_WEIGHT, BPR_LOCAL_PREF, BPR_LOCAL_ORIGIN, BPR_ASPATH}, c.Steps)
+
+	// explaining does not change the selected best path
+	assert.Nil(t, dest.GetBestPath())
+}
+
+func TestExplainTableManager(t *testing.T) {
+	tm := NewTableManager()
+	_, err := tm.Explain(bgp.RF_IPv4_UC, "10.10.10.0/24")
+	assert.NotNil(t, err)
+
+	path1 := selectionPath(65000, "10.0.0.2", []uint32{65000}, 0, time.Second)
+	tm.ProcessPaths([]Path{path1})
+	e, err := tm.Explain(bgp.RF_IPv4_UC, "10.10.10.0/24")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(e.Paths))
+	assert.Equal(t, 0, len(e.Comparisons))
+	assert.Equal(t, 0, e.BestPath)
+}
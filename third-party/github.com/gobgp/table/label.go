@@ -0,0 +1,256 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/json"
+	"fmt"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"sort"
+	"sync"
+)
+
+const (
+	// LABEL_IMPLICIT_NULL asks the upstream router to pop the label
+	// (RFC 3032).
+	LABEL_IMPLICIT_NULL = 3
+	// LABEL_MIN is the first local label, the lower values are reserved
+	// (RFC 3032).
+	LABEL_MIN = 16
+	LABEL_MAX = 1<<20 - 1
+)
+
+// LabelPool hands out the local labels, the VRFs and the labeled
+// unicast routes share it so that their labels don't collide.
+type LabelPool struct {
+	mu   sync.Mutex
+	next uint32
+	free []uint32
+}
+
+func NewLabelPool() *LabelPool {
+	return &LabelPool{next: LABEL_MIN}
+}
+
+// Allocate returns a free label, the released ones are reused first.
+func (pool *LabelPool) Allocate() (uint32, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if n := len(pool.free); n > 0 {
+		label := pool.free[n-1]
+		pool.free = pool.free[:n-1]
+		return label, nil
+	}
+	if pool.next > LABEL_MAX {
+		return 0, fmt.Errorf("no label is left")
+	}
+	label := pool.next
+	pool.next++
+	return label, nil
+}
+
+// AllocateBlock returns the first label of size consecutive free
+// labels, the released labels aren't reused for blocks.
+func (pool *LabelPool) AllocateBlock(size uint16) (uint32, error) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if size == 0 || pool.next+uint32(size)-1 > LABEL_MAX {
+		return 0, fmt.Errorf("no label block of size %d is left", size)
+	}
+	label := pool.next
+	pool.next += uint32(size)
+	return label, nil
+}
+
+func (pool *LabelPool) Release(label uint32) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	pool.free = append(pool.free, label)
+}
+
+type LabelAllocationMode int
+
+const (
+	// one local label for each prefix
+	LABEL_ALLOCATION_PER_PREFIX LabelAllocationMode = iota
+	// one local label for the prefixes sharing a next hop and its labels
+	LABEL_ALLOCATION_PER_NEXTHOP
+)
+
+// ParseLabelAllocationMode returns the mode of "per-prefix", the
+// default, or "per-nexthop".
+func ParseLabelAllocationMode(s string) (LabelAllocationMode, error) {
+	switch s {
+	case "", "per-prefix":
+		return LABEL_ALLOCATION_PER_PREFIX, nil
+	case "per-nexthop":
+		return LABEL_ALLOCATION_PER_NEXTHOP, nil
+	}
+	return LABEL_ALLOCATION_PER_PREFIX, fmt.Errorf("unknown label allocation mode %s", s)
+}
+
+const (
+	LABEL_ACTION_SWAP = "swap"
+	LABEL_ACTION_POP  = "pop"
+)
+
+// LabelBinding is an entry of the label forwarding table, the packets
+// arriving with the local Label have it swapped for OutLabels, or popped
+// if the next hop advertised no label or the implicit null label, and
+// are sent to Nexthop.
+type LabelBinding struct {
+	Label     uint32
+	Action    string
+	OutLabels []uint32
+	Nexthop   net.IP
+	Prefixes  []string
+}
+
+func (b *LabelBinding) addPrefix(prefix string) {
+	b.Prefixes = append(b.Prefixes, prefix)
+	sort.Strings(b.Prefixes)
+}
+
+func (b *LabelBinding) removePrefix(prefix string) {
+	for i, p := range b.Prefixes {
+		if p == prefix {
+			b.Prefixes = append(b.Prefixes[:i], b.Prefixes[i+1:]...)
+			return
+		}
+	}
+}
+
+// outLabels returns the labels the next hop of path expects, nil if it
+// expects none.
+func outLabels(path Path) []uint32 {
+	labels := path.GetLabels()
+	if len(labels) == 0 || len(labels) == 1 && labels[0] == LABEL_IMPLICIT_NULL {
+		return nil
+	}
+	return labels
+}
+
+type labelBindings []*LabelBinding
+
+func (l labelBindings) Len() int           { return len(l) }
+func (l labelBindings) Less(i, j int) bool { return l[i].Label < l[j].Label }
+func (l labelBindings) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+// LabelManager binds local labels to the best labeled unicast paths and
+// keeps the resulting label forwarding table.
+type LabelManager struct {
+	mode LabelAllocationMode
+	pool *LabelPool
+	// bindings by prefix or by next hop and labels
+	bindings map[string]*LabelBinding
+	// binding key of each prefix
+	prefixes map[string]string
+}
+
+func NewLabelManager(mode LabelAllocationMode, pool *LabelPool) *LabelManager {
+	return &LabelManager{
+		mode:     mode,
+		pool:     pool,
+		bindings: make(map[string]*LabelBinding),
+		prefixes: make(map[string]string),
+	}
+}
+
+func (manager *LabelManager) bindingKey(path Path) string {
+	if manager.mode == LABEL_ALLOCATION_PER_PREFIX {
+		return path.GetPrefix()
+	}
+	return fmt.Sprintf("%s %v", path.GetNexthop(), outLabels(path))
+}
+
+func (manager *LabelManager) unbind(prefix string) {
+	key, found := manager.prefixes[prefix]
+	if !found {
+		return
+	}
+	delete(manager.prefixes, prefix)
+	b := manager.bindings[key]
+	b.removePrefix(prefix)
+	if len(b.Prefixes) == 0 {
+		delete(manager.bindings, key)
+		manager.pool.Release(b.Label)
+	}
+}
+
+// Update binds the local labels to the new best paths of pathList, the
+// labels of the withdrawn prefixes are released once no prefix uses
+// them.
+func (manager *LabelManager) Update(pathList []Path) error {
+	for _, path := range pathList {
+		rf := path.GetRouteFamily()
+		if rf != bgp.RF_IPv4_MPLS && rf != bgp.RF_IPv6_MPLS {
+			continue
+		}
+		prefix := path.GetPrefix()
+		if path.IsWithdraw() {
+			manager.unbind(prefix)
+			continue
+		}
+		key := manager.bindingKey(path)
+		if manager.prefixes[prefix] != key {
+			manager.unbind(prefix)
+		}
+		b, found := manager.bindings[key]
+		if !found {
+			label, err := manager.pool.Allocate()
+			if err != nil {
+				return err
+			}
+			b = &LabelBinding{Label: label}
+			manager.bindings[key] = b
+		}
+		if _, found := manager.prefixes[prefix]; !found {
+			manager.prefixes[prefix] = key
+			b.addPrefix(prefix)
+		}
+		b.OutLabels = outLabels(path)
+		b.Nexthop = path.GetNexthop()
+		b.Action = LABEL_ACTION_POP
+		if len(b.OutLabels) > 0 {
+			b.Action = LABEL_ACTION_SWAP
+		}
+	}
+	return nil
+}
+
+// GetLabel returns the local label bound to prefix.
+func (manager *LabelManager) GetLabel(prefix string) (uint32, bool) {
+	key, found := manager.prefixes[prefix]
+	if !found {
+		return 0, false
+	}
+	return manager.bindings[key].Label, true
+}
+
+// GetBindings returns the label forwarding table sorted by local label.
+func (manager *LabelManager) GetBindings() []*LabelBinding {
+	l := make([]*LabelBinding, 0, len(manager.bindings))
+	for _, b := range manager.bindings {
+		l = append(l, b)
+	}
+	sort.Sort(labelBindings(l))
+	return l
+}
+
+func (manager *LabelManager) MarshalJSON() ([]byte, error) {
+	return json.Marshal(manager.GetBindings())
+}
@@ -0,0 +1,123 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+func labeledPath(peer *PeerInfo, prefix string, length uint8, nexthop string, labels ...uint32) Path {
+	var nlri bgp.AddrPrefixInterface = bgp.NewLabelledIPAddrPrefix(length, prefix, *bgp.NewLabel(labels...))
+	if net.ParseIP(prefix).To4() == nil {
+		nlri = bgp.NewLabelledIPv6AddrPrefix(length, prefix, *bgp.NewLabel(labels...))
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute([]uint32{peer.AS}),
+		bgp.NewPathAttributeMpReachNLRI(nexthop, []bgp.AddrPrefixInterface{nlri}),
+	}
+	return CreatePath(peer, nlri, pathAttributes, false)
+}
+
+func TestLabeledUnicastTable(t *testing.T) {
+	peer1 := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.1").To4(), Address: net.ParseIP("10.0.0.1"), RF: bgp.RF_IPv4_MPLS}
+	peer2 := &PeerInfo{AS: 65002, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_IPv4_MPLS}
+	path1 := labeledPath(peer1, "10.10.0.0", 24, "10.0.0.1", 100)
+	assert.Equal(t, bgp.RF_IPv4_MPLS, path1.GetRouteFamily())
+	assert.Equal(t, []uint32{100}, path1.GetLabels())
+	assert.Equal(t, "10.10.0.0/24", path1.GetPrefix())
+
+	// the paths of both neighbors end up in one destination
+	tm := NewTableManager()
+	tm.ProcessPaths([]Path{path1, labeledPath(peer2, "10.10.0.0", 24, "10.0.0.2", 200)})
+	assert.Equal(t, 1, len(tm.Tables[bgp.RF_IPv4_MPLS].GetDestinations()))
+	assert.Equal(t, 1, len(tm.GetBestPathList(bgp.RF_IPv4_MPLS)))
+
+	path6 := labeledPath(peer1, "2001:db8:1::", 64, "2001:db8::1", 300)
+	assert.Equal(t, bgp.RF_IPv6_MPLS, path6.GetRouteFamily())
+	msgs := CreateUpdateMsgFromPaths([]Path{path6})
+	assert.Equal(t, 1, len(msgs))
+	buf, _ := msgs[0].Serialize()
+	msg, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+	paths := NewProcessMessage(msg, peer1).ToPathList()
+	assert.Equal(t, 1, len(paths))
+	assert.Equal(t, "2001:db8:1::/64", paths[0].GetPrefix())
+	assert.Equal(t, []uint32{300}, paths[0].GetLabels())
+	assert.Equal(t, "2001:db8::1", paths[0].GetNexthop().String())
+}
+
+func TestLabelManagerPerPrefix(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.1").To4(), RF: bgp.RF_IPv4_MPLS}
+	manager := NewLabelManager(LABEL_ALLOCATION_PER_PREFIX, NewLabelPool())
+	path1 := labeledPath(peer, "10.10.0.0", 24, "10.0.0.1", 100)
+	path2 := labeledPath(peer, "10.20.0.0", 24, "10.0.0.1", LABEL_IMPLICIT_NULL)
+	assert.Nil(t, manager.Update([]Path{path1, path2}))
+
+	bindings := manager.GetBindings()
+	assert.Equal(t, 2, len(bindings))
+	assert.Equal(t, uint32(LABEL_MIN), bindings[0].Label)
+	assert.Equal(t, LABEL_ACTION_SWAP, bindings[0].Action)
+	assert.Equal(t, []uint32{100}, bindings[0].OutLabels)
+	assert.Equal(t, []string{"10.10.0.0/24"}, bindings[0].Prefixes)
+	assert.Equal(t, LABEL_ACTION_POP, bindings[1].Action)
+	assert.Nil(t, bindings[1].OutLabels)
+
+	// a new best path keeps the local label
+	assert.Nil(t, manager.Update([]Path{labeledPath(peer, "10.10.0.0", 24, "10.0.0.1", 101)}))
+	label, found := manager.GetLabel("10.10.0.0/24")
+	assert.True(t, found)
+	assert.Equal(t, uint32(LABEL_MIN), label)
+	assert.Equal(t, []uint32{101}, manager.GetBindings()[0].OutLabels)
+
+	// the label of a withdrawn prefix is reused
+	assert.Nil(t, manager.Update([]Path{path1.Clone(true)}))
+	_, found = manager.GetLabel("10.10.0.0/24")
+	assert.False(t, found)
+	assert.Nil(t, manager.Update([]Path{labeledPath(peer, "10.30.0.0", 24, "10.0.0.1", 300)}))
+	label, _ = manager.GetLabel("10.30.0.0/24")
+	assert.Equal(t, uint32(LABEL_MIN), label)
+}
+
+func TestLabelManagerPerNexthop(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.1").To4(), RF: bgp.RF_IPv4_MPLS}
+	manager := NewLabelManager(LABEL_ALLOCATION_PER_NEXTHOP, NewLabelPool())
+	path1 := labeledPath(peer, "10.10.0.0", 24, "10.0.0.1", LABEL_IMPLICIT_NULL)
+	path2 := labeledPath(peer, "10.20.0.0", 24, "10.0.0.1")
+	path3 := labeledPath(peer, "10.30.0.0", 24, "10.0.0.2", LABEL_IMPLICIT_NULL)
+	assert.Nil(t, manager.Update([]Path{path1, path2, path3}))
+
+	bindings := manager.GetBindings()
+	assert.Equal(t, 2, len(bindings))
+	assert.Equal(t, []string{"10.10.0.0/24", "10.20.0.0/24"}, bindings[0].Prefixes)
+	assert.Equal(t, "10.0.0.1", bindings[0].Nexthop.String())
+	assert.Equal(t, LABEL_ACTION_POP, bindings[0].Action)
+
+	// the prefix moves to the label of its new next hop
+	assert.Nil(t, manager.Update([]Path{labeledPath(peer, "10.10.0.0", 24, "10.0.0.2", LABEL_IMPLICIT_NULL)}))
+	bindings = manager.GetBindings()
+	assert.Equal(t, []string{"10.20.0.0/24"}, bindings[0].Prefixes)
+	assert.Equal(t, []string{"10.10.0.0/24", "10.30.0.0/24"}, bindings[1].Prefixes)
+
+	assert.Nil(t, manager.Update([]Path{path2.Clone(true)}))
+	assert.Equal(t, 1, len(manager.GetBindings()))
+
+	_, err := ParseLabelAllocationMode("per-vrf")
+	assert.NotNil(t, err)
+}
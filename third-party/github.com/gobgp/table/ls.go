@@ -0,0 +1,183 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/json"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"sort"
+)
+
+// LsNode is a node of the link-state topology, the key identifies it
+// in the links and prefixes.
+type LsNode struct {
+	Key        string            `json:"key"`
+	Protocol   string            `json:"protocol"`
+	Descriptor string            `json:"descriptor"`
+	Attributes *bgp.LsAttributes `json:"attributes"`
+}
+
+// LsLink is a unidirectional link between two nodes of the link-state
+// topology, Metric is its IGP metric.
+type LsLink struct {
+	Key           string            `json:"key"`
+	LocalNode     string            `json:"local_node"`
+	RemoteNode    string            `json:"remote_node"`
+	InterfaceAddr net.IP            `json:"interface_addr,omitempty"`
+	NeighborAddr  net.IP            `json:"neighbor_addr,omitempty"`
+	Metric        *uint32           `json:"metric,omitempty"`
+	Attributes    *bgp.LsAttributes `json:"attributes"`
+}
+
+// LsPrefix is a prefix reachable through a node of the link-state
+// topology.
+type LsPrefix struct {
+	Key        string            `json:"key"`
+	Node       string            `json:"node"`
+	Prefix     string            `json:"prefix"`
+	Metric     *uint32           `json:"metric,omitempty"`
+	Attributes *bgp.LsAttributes `json:"attributes"`
+}
+
+// LsTopology is the link-state topology built from the best paths of
+// the BGP-LS route family.
+type LsTopology struct {
+	nodes    map[string]*LsNode
+	links    map[string]*LsLink
+	prefixes map[string]*LsPrefix
+}
+
+func NewLsTopology() *LsTopology {
+	return &LsTopology{
+		nodes:    make(map[string]*LsNode),
+		links:    make(map[string]*LsLink),
+		prefixes: make(map[string]*LsPrefix),
+	}
+}
+
+func lsAttributes(path Path) *bgp.LsAttributes {
+	if _, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_LS); attr != nil {
+		if a, err := attr.(*bgp.PathAttributeLs).Attributes(); err == nil {
+			return a
+		}
+	}
+	return &bgp.LsAttributes{}
+}
+
+// Update applies the new best paths and the withdrawn ones of the
+// BGP-LS route family to the topology, the paths of the other route
+// families are ignored.
+func (topology *LsTopology) Update(pathList []Path) {
+	for _, path := range pathList {
+		nlri, ok := path.GetNlri().(*bgp.LsNLRI)
+		if !ok {
+			continue
+		}
+		key := nlri.String()
+		if nlri.NLRIType == bgp.LS_NLRI_TYPE_NODE {
+			key = nlri.NodeKey(false)
+		}
+		if path.IsWithdraw() {
+			delete(topology.nodes, key)
+			delete(topology.links, key)
+			delete(topology.prefixes, key)
+			continue
+		}
+		a := lsAttributes(path)
+		switch nlri.NLRIType {
+		case bgp.LS_NLRI_TYPE_NODE:
+			topology.nodes[key] = &LsNode{
+				Key:        key,
+				Protocol:   nlri.ProtocolID.String(),
+				Descriptor: nlri.LocalNode.String(),
+				Attributes: a,
+			}
+		case bgp.LS_NLRI_TYPE_LINK:
+			topology.links[key] = &LsLink{
+				Key:           key,
+				LocalNode:     nlri.NodeKey(false),
+				RemoteNode:    nlri.NodeKey(true),
+				InterfaceAddr: nlri.Link.InterfaceAddr,
+				NeighborAddr:  nlri.Link.NeighborAddr,
+				Metric:        a.IGPMetric,
+				Attributes:    a,
+			}
+		case bgp.LS_NLRI_TYPE_PREFIX_IPV4, bgp.LS_NLRI_TYPE_PREFIX_IPV6:
+			topology.prefixes[key] = &LsPrefix{
+				Key:        key,
+				Node:       nlri.NodeKey(false),
+				Prefix:     (&net.IPNet{IP: nlri.Prefix.Prefix, Mask: net.CIDRMask(int(nlri.Prefix.PrefixLength), len(nlri.Prefix.Prefix)*8)}).String(),
+				Metric:     a.PrefixMetric,
+				Attributes: a,
+			}
+		}
+	}
+}
+
+// GetNodes returns the nodes sorted by key.
+func (topology *LsTopology) GetNodes() []*LsNode {
+	keys := make([]string, 0, len(topology.nodes))
+	for key := range topology.nodes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	l := make([]*LsNode, 0, len(keys))
+	for _, key := range keys {
+		l = append(l, topology.nodes[key])
+	}
+	return l
+}
+
+// GetLinks returns the links sorted by key.
+func (topology *LsTopology) GetLinks() []*LsLink {
+	keys := make([]string, 0, len(topology.links))
+	for key := range topology.links {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	l := make([]*LsLink, 0, len(keys))
+	for _, key := range keys {
+		l = append(l, topology.links[key])
+	}
+	return l
+}
+
+// GetPrefixes returns the prefixes sorted by key.
+func (topology *LsTopology) GetPrefixes() []*LsPrefix {
+	keys := make([]string, 0, len(topology.prefixes))
+	for key := range topology.prefixes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	l := make([]*LsPrefix, 0, len(keys))
+	for _, key := range keys {
+		l = append(l, topology.prefixes[key])
+	}
+	return l
+}
+
+func (topology *LsTopology) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Nodes    []*LsNode   `json:"nodes"`
+		Links    []*LsLink   `json:"links"`
+		Prefixes []*LsPrefix `json:"prefixes"`
+	}{
+		Nodes:    topology.GetNodes(),
+		Links:    topology.GetLinks(),
+		Prefixes: topology.GetPrefixes(),
+	})
+}
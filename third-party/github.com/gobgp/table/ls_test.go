@@ -0,0 +1,98 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+func lsPath(peer *PeerInfo, nlri *bgp.LsNLRI, a *bgp.LsAttributes) Path {
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI(peer.Address.String(), []bgp.AddrPrefixInterface{nlri}),
+		bgp.NewPathAttributeLs(a),
+	}
+	return CreatePath(peer, nlri, pathAttributes, false)
+}
+
+func TestLsTopology(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_LS}
+	r1 := bgp.LsNodeDescriptor{Asn: 65000, IGPRouterID: net.ParseIP("10.0.0.1").To4()}
+	r2 := bgp.LsNodeDescriptor{Asn: 65000, IGPRouterID: net.ParseIP("10.0.0.3").To4()}
+	link := bgp.LsLinkDescriptor{InterfaceAddr: net.ParseIP("192.168.0.1").To4(), NeighborAddr: net.ParseIP("192.168.0.2").To4()}
+	metric := uint32(10)
+
+	tm := NewTableManager()
+	topology := NewLsTopology()
+	pathList := []Path{
+		lsPath(peer, bgp.NewLsNodeNLRI(bgp.LS_PROTOCOL_OSPF_V2, 0, r1), &bgp.LsAttributes{NodeName: "r1"}),
+		lsPath(peer, bgp.NewLsNodeNLRI(bgp.LS_PROTOCOL_OSPF_V2, 0, r2), &bgp.LsAttributes{NodeName: "r2"}),
+		lsPath(peer, bgp.NewLsLinkNLRI(bgp.LS_PROTOCOL_OSPF_V2, 0, r1, r2, link), &bgp.LsAttributes{IGPMetric: &metric}),
+		lsPath(peer, bgp.NewLsPrefixNLRI(bgp.LS_PROTOCOL_OSPF_V2, 0, r2, bgp.LsPrefixDescriptor{Prefix: net.ParseIP("172.16.0.0").To4(), PrefixLength: 16}), &bgp.LsAttributes{PrefixMetric: &metric}),
+	}
+	for _, m := range CreateUpdateMsgFromPaths(pathList) {
+		buf, _ := m.Serialize()
+		msg, err := bgp.ParseBGPMessage(buf)
+		assert.Nil(t, err)
+		pList, wList, _ := tm.ProcessPaths(NewProcessMessage(msg, peer).ToPathList())
+		topology.Update(append(pList, wList...))
+	}
+	assert.Equal(t, 4, len(tm.Tables[bgp.RF_LS].GetDestinations()))
+
+	nodes := topology.GetNodes()
+	assert.Equal(t, 2, len(nodes))
+	assert.Equal(t, "r1", nodes[0].Attributes.NodeName)
+	links := topology.GetLinks()
+	assert.Equal(t, 1, len(links))
+	assert.Equal(t, nodes[0].Key, links[0].LocalNode)
+	assert.Equal(t, nodes[1].Key, links[0].RemoteNode)
+	assert.Equal(t, uint32(10), *links[0].Metric)
+	prefixes := topology.GetPrefixes()
+	assert.Equal(t, 1, len(prefixes))
+	assert.Equal(t, "172.16.0.0/16", prefixes[0].Prefix)
+	assert.Equal(t, nodes[1].Key, prefixes[0].Node)
+
+	// the topology of a peer goes away with it
+	pList, wList, _ := tm.DeletePathsforPeer(peer)
+	topology.Update(append(pList, wList...))
+	assert.Equal(t, 0, len(topology.GetNodes()))
+	assert.Equal(t, 0, len(topology.GetLinks()))
+	assert.Equal(t, 0, len(topology.GetPrefixes()))
+}
+
+func TestLsUnknownNLRIType(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_LS}
+	r1 := bgp.LsNodeDescriptor{Asn: 65000, IGPRouterID: net.ParseIP("10.0.0.1").To4()}
+	node := bgp.NewLsNodeNLRI(bgp.LS_PROTOCOL_OSPF_V2, 0, r1)
+	// an SRv6 SID nlri, type 6, ahead of a node nlri
+	sid := &bgp.LsNLRI{NLRIType: 6, Value: make([]byte, 20)}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI("10.0.0.2", []bgp.AddrPrefixInterface{sid, node}),
+	}
+	buf, _ := bgp.NewBGPUpdateMessage(nil, pathAttributes, nil).Serialize()
+
+	msg, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+	pathList := NewProcessMessage(msg, peer).ToPathList()
+	assert.Equal(t, 1, len(pathList))
+	assert.Equal(t, node.String(), pathList[0].GetPrefix())
+}
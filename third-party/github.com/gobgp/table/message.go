@@ -20,22 +20,52 @@ import (
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
 )
 
+// updateAggregator2ByteAs encodes the AGGREGATOR at idx with a 2 octet
+// AS number, an AS number that doesn't fit is replaced with AS_TRANS and
+// carried in an AS4_AGGREGATOR instead (RFC 6793).
+func updateAggregator2ByteAs(msg *bgp.BGPUpdate, idx int) {
+	aggregator := msg.PathAttributes[idx].(*bgp.PathAttributeAggregator)
+	as, address := aggregator.Value.AS, aggregator.Value.Address.String()
+	if as <= (1<<16)-1 {
+		msg.PathAttributes[idx] = bgp.NewPathAttributeAggregator(uint16(as), address)
+		return
+	}
+	msg.PathAttributes[idx] = bgp.NewPathAttributeAggregator(uint16(bgp.AS_TRANS), address)
+	as4Aggregator := bgp.NewPathAttributeAs4Aggregator(as, address)
+	for i, attr := range msg.PathAttributes {
+		if _, y := attr.(*bgp.PathAttributeAs4Aggregator); y {
+			msg.PathAttributes[i] = as4Aggregator
+			return
+		}
+	}
+	msg.PathAttributes = append(msg.PathAttributes, as4Aggregator)
+}
+
 func UpdatePathAttrs2ByteAs(msg *bgp.BGPUpdate) error {
 	var asAttr *bgp.PathAttributeAsPath
 	idx := 0
+	aggregatorIdx := -1
 	for i, attr := range msg.PathAttributes {
 		switch attr.(type) {
 		case *bgp.PathAttributeAsPath:
 			asAttr = attr.(*bgp.PathAttributeAsPath)
 			idx = i
+		case *bgp.PathAttributeAggregator:
+			aggregatorIdx = i
 		}
 	}
 
-	if asAttr == nil {
+	if asAttr == nil && aggregatorIdx < 0 {
 		return nil
 	}
 
 	msg.PathAttributes = cloneAttrSlice(msg.PathAttributes)
+	if aggregatorIdx >= 0 {
+		updateAggregator2ByteAs(msg, aggregatorIdx)
+	}
+	if asAttr == nil {
+		return nil
+	}
 	asAttr = msg.PathAttributes[idx].(*bgp.PathAttributeAsPath)
 	as4pathParam := make([]*bgp.As4PathParam, 0)
 	newASparams := make([]bgp.AsPathParamInterface, len(asAttr.Value))
@@ -121,6 +151,237 @@ func UpdatePathAttrs4ByteAs(msg *bgp.BGPUpdate) error {
 	return nil
 }
 
+func isPrivateAs(as uint32) bool {
+	return (as >= 64512 && as <= 65534) || (as >= 4200000000 && as <= 4294967294)
+}
+
+type asSegment struct {
+	segType uint8
+	as      []uint32
+}
+
+// removePrivateAs drops the leading private AS numbers of the given
+// segments, or replaces each of them with localAs when replace is set.
+// It stops at the first public AS or non AS_SEQUENCE segment.
+func removePrivateAs(segs []asSegment, localAs, peerAs uint32, replace bool) ([]asSegment, bool) {
+	allPrivate := true
+	for _, seg := range segs {
+		for _, as := range seg.as {
+			if !isPrivateAs(as) {
+				allPrivate = false
+			}
+		}
+	}
+	// never empty an AS_PATH toward a private peer
+	if allPrivate && !replace && isPrivateAs(peerAs) {
+		return segs, false
+	}
+
+	changed := false
+	done := false
+	newSegs := make([]asSegment, 0, len(segs))
+	for _, seg := range segs {
+		if done || seg.segType != bgp.BGP_ASPATH_ATTR_TYPE_SEQ {
+			done = true
+			newSegs = append(newSegs, seg)
+			continue
+		}
+		newAs := make([]uint32, 0, len(seg.as))
+		for _, as := range seg.as {
+			if !done && isPrivateAs(as) {
+				changed = true
+				if replace {
+					newAs = append(newAs, localAs)
+				}
+				continue
+			}
+			done = true
+			newAs = append(newAs, as)
+		}
+		if len(newAs) > 0 {
+			newSegs = append(newSegs, asSegment{seg.segType, newAs})
+		}
+	}
+	return newSegs, changed
+}
+
+// UpdatePathAttrsRemovePrivateAs removes the private AS numbers at the
+// head of AS_PATH and AS4_PATH. With replace set they are rewritten to
+// localAs instead. The attributes of msg are never modified in place.
+func UpdatePathAttrsRemovePrivateAs(msg *bgp.BGPUpdate, localAs, peerAs uint32, replace bool) error {
+	cloned := false
+	for i, attr := range msg.PathAttributes {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeAsPath:
+			segs := make([]asSegment, 0, len(a.Value))
+			is2byte := false
+			for _, param := range a.Value {
+				switch p := param.(type) {
+				case *bgp.AsPathParam:
+					is2byte = true
+					as := make([]uint32, len(p.AS))
+					for j, v := range p.AS {
+						as[j] = uint32(v)
+					}
+					segs = append(segs, asSegment{p.Type, as})
+				case *bgp.As4PathParam:
+					segs = append(segs, asSegment{p.Type, p.AS})
+				}
+			}
+			newSegs, changed := removePrivateAs(segs, localAs, peerAs, replace)
+			if !changed {
+				continue
+			}
+			params := make([]bgp.AsPathParamInterface, 0, len(newSegs))
+			for _, seg := range newSegs {
+				if is2byte {
+					as := make([]uint16, len(seg.as))
+					for j, v := range seg.as {
+						if v > (1<<16)-1 {
+							as[j] = bgp.AS_TRANS
+						} else {
+							as[j] = uint16(v)
+						}
+					}
+					params = append(params, bgp.NewAsPathParam(seg.segType, as))
+				} else {
+					params = append(params, bgp.NewAs4PathParam(seg.segType, seg.as))
+				}
+			}
+			if !cloned {
+				msg.PathAttributes = cloneAttrSlice(msg.PathAttributes)
+				cloned = true
+			}
+			msg.PathAttributes[i] = bgp.NewPathAttributeAsPath(params)
+		case *bgp.PathAttributeAs4Path:
+			segs := make([]asSegment, 0, len(a.Value))
+			for _, p := range a.Value {
+				segs = append(segs, asSegment{p.Type, p.AS})
+			}
+			newSegs, changed := removePrivateAs(segs, localAs, peerAs, replace)
+			if !changed {
+				continue
+			}
+			params := make([]*bgp.As4PathParam, 0, len(newSegs))
+			for _, seg := range newSegs {
+				params = append(params, bgp.NewAs4PathParam(seg.segType, seg.as))
+			}
+			if !cloned {
+				msg.PathAttributes = cloneAttrSlice(msg.PathAttributes)
+				cloned = true
+			}
+			msg.PathAttributes[i] = bgp.NewPathAttributeAs4Path(params)
+		}
+	}
+	return nil
+}
+
+// UpdatePathAttrsRemoveAigp removes the AIGP attribute, which must not
+// cross the border of an AIGP administrative domain. The attributes of
+// msg are never modified in place.
+func UpdatePathAttrsRemoveAigp(msg *bgp.BGPUpdate) {
+	for i, attr := range msg.PathAttributes {
+		if _, y := attr.(*bgp.PathAttributeAigp); y {
+			attrs := make([]bgp.PathAttributeInterface, 0, len(msg.PathAttributes)-1)
+			attrs = append(attrs, msg.PathAttributes[:i]...)
+			msg.PathAttributes = append(attrs, msg.PathAttributes[i+1:]...)
+			return
+		}
+	}
+}
+
+// UpdatePathAttrsUnknown applies the RFC 4271 rules to the attributes
+// which weren't recognized when msg was received: optional transitive
+// ones are passed on with the Partial bit set and optional non-transitive
+// ones are dropped. The attributes of msg are never modified in place.
+func UpdatePathAttrsUnknown(msg *bgp.BGPUpdate) {
+	attrs := make([]bgp.PathAttributeInterface, 0, len(msg.PathAttributes))
+	changed := false
+	for _, attr := range msg.PathAttributes {
+		u, y := attr.(*bgp.PathAttributeUnknown)
+		switch {
+		case !y:
+			attrs = append(attrs, attr)
+		case u.Flags&bgp.BGP_ATTR_FLAG_TRANSITIVE == 0:
+			changed = true
+		case u.Flags&bgp.BGP_ATTR_FLAG_PARTIAL == 0:
+			partial := &bgp.PathAttributeUnknown{
+				PathAttribute: bgp.PathAttribute{
+					Flags:  u.Flags | bgp.BGP_ATTR_FLAG_PARTIAL,
+					Type:   u.Type,
+					Length: u.Length,
+					Value:  u.Value,
+				},
+			}
+			attrs = append(attrs, partial)
+			changed = true
+		default:
+			attrs = append(attrs, attr)
+		}
+	}
+	if changed {
+		msg.PathAttributes = attrs
+	}
+}
+
+// UpdatePathAttrsDiscard removes the attributes whose type code is in
+// types, like the attribute discard of RFC 7606. ORIGIN, AS_PATH,
+// NEXT_HOP, MP_REACH_NLRI and MP_UNREACH_NLRI are never removed as the
+// update can't be used without them. The attributes of msg are never
+// modified in place.
+func UpdatePathAttrsDiscard(msg *bgp.BGPUpdate, types []uint32) {
+	if len(types) == 0 {
+		return
+	}
+	discard := func(t bgp.BGPAttrType) bool {
+		switch t {
+		case bgp.BGP_ATTR_TYPE_ORIGIN, bgp.BGP_ATTR_TYPE_AS_PATH, bgp.BGP_ATTR_TYPE_NEXT_HOP,
+			bgp.BGP_ATTR_TYPE_MP_REACH_NLRI, bgp.BGP_ATTR_TYPE_MP_UNREACH_NLRI:
+			return false
+		}
+		for _, v := range types {
+			if uint32(t) == v {
+				return true
+			}
+		}
+		return false
+	}
+	attrs := make([]bgp.PathAttributeInterface, 0, len(msg.PathAttributes))
+	for _, attr := range msg.PathAttributes {
+		if !discard(attr.GetType()) {
+			attrs = append(attrs, attr)
+		}
+	}
+	if len(attrs) != len(msg.PathAttributes) {
+		msg.PathAttributes = attrs
+	}
+}
+
+// TreatAsWithdraw turns msg into the withdrawal of all the routes it
+// carries, the treat-as-withdraw error handling of RFC 7606.
+func TreatAsWithdraw(msg *bgp.BGPUpdate) {
+	withdrawn := make([]bgp.WithdrawnRoute, 0, len(msg.WithdrawnRoutes)+len(msg.NLRI))
+	withdrawn = append(withdrawn, msg.WithdrawnRoutes...)
+	for _, n := range msg.NLRI {
+		withdrawn = append(withdrawn, bgp.WithdrawnRoute{IPAddrPrefix: n.IPAddrPrefix})
+	}
+	var unreach []bgp.AddrPrefixInterface
+	for _, attr := range msg.PathAttributes {
+		switch a := attr.(type) {
+		case *bgp.PathAttributeMpReachNLRI:
+			unreach = append(unreach, a.Value...)
+		case *bgp.PathAttributeMpUnreachNLRI:
+			unreach = append(unreach, a.Value...)
+		}
+	}
+	msg.WithdrawnRoutes = withdrawn
+	msg.NLRI = []bgp.NLRInfo{}
+	msg.PathAttributes = []bgp.PathAttributeInterface{}
+	if len(unreach) > 0 {
+		msg.PathAttributes = append(msg.PathAttributes, bgp.NewPathAttributeMpUnreachNLRI(unreach))
+	}
+}
+
 func cloneAttrSlice(attrs []bgp.PathAttributeInterface) []bgp.PathAttributeInterface {
 	clonedAttrs := make([]bgp.PathAttributeInterface, 0)
 	clonedAttrs = append(clonedAttrs, attrs...)
@@ -179,10 +440,34 @@ func createUpdateMsgFromPath(path Path, msg *bgp.BGPMessage) *bgp.BGPMessage {
 				return bgp.NewBGPUpdateMessage([]bgp.WithdrawnRoute{}, clonedAttrs, []bgp.NLRInfo{})
 			}
 		}
+	} else if rf == bgp.RF_IPv4_MC || rf == bgp.RF_IPv6_MC ||
+		rf == bgp.RF_IPv4_VPN || rf == bgp.RF_IPv6_VPN || rf == bgp.RF_RTC_UC ||
+		rf == bgp.RF_IPv4_MPLS || rf == bgp.RF_IPv6_MPLS || rf == bgp.RF_VPLS || rf == bgp.RF_EVPN ||
+		rf == bgp.RF_FS_IPv4_UC || rf == bgp.RF_FS_IPv6_UC || rf == bgp.RF_LS {
+		return createMpUpdateMsgFromPath(path)
 	}
 	return nil
 }
 
+// createMpUpdateMsgFromPath carries the nlri of path alone in a
+// MP_REACH_NLRI or MP_UNREACH_NLRI attribute, a received MP_REACH_NLRI
+// may hold the NLRIs of other paths.
+func createMpUpdateMsgFromPath(path Path) *bgp.BGPMessage {
+	nlri := []bgp.AddrPrefixInterface{path.GetNlri()}
+	if path.IsWithdraw() {
+		unreach := bgp.NewPathAttributeMpUnreachNLRI(nlri)
+		return bgp.NewBGPUpdateMessage([]bgp.WithdrawnRoute{}, []bgp.PathAttributeInterface{unreach}, []bgp.NLRInfo{})
+	}
+	clonedAttrs := cloneAttrSlice(path.GetPathAttrs())
+	reach := bgp.NewPathAttributeMpReachNLRI(path.GetNexthop().String(), nlri)
+	if idx, _ := path.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI); idx < 0 {
+		clonedAttrs = append(clonedAttrs, reach)
+	} else {
+		clonedAttrs[idx] = reach
+	}
+	return bgp.NewBGPUpdateMessage([]bgp.WithdrawnRoute{}, clonedAttrs, []bgp.NLRInfo{})
+}
+
 func isSamePathAttrs(pList1 []bgp.PathAttributeInterface, pList2 []bgp.PathAttributeInterface) bool {
 	if len(pList1) != len(pList2) {
 		return false
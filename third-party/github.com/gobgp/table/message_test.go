@@ -18,6 +18,7 @@ package table
 import (
 	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
 	"reflect"
 	"testing"
 )
@@ -120,3 +121,126 @@ func TestAs4PathUnchanged(t *testing.T) {
 //		}
 //	}
 //}
+
+func TestRemovePrivateAsStopAtPublic(t *testing.T) {
+	as := []uint16{65001, 65002, 4000, 65003}
+	m := updateMsg1(as).Body.(*bgp.BGPUpdate)
+	orig := m.PathAttributes[1]
+	UpdatePathAttrsRemovePrivateAs(m, 100, 200, false)
+	attr := m.PathAttributes[1].(*bgp.PathAttributeAsPath)
+	assert.Equal(t, attr.Value[0].(*bgp.AsPathParam).AS, []uint16{4000, 65003})
+	// the original attribute is shared with the rib and must be untouched
+	assert.Equal(t, orig.(*bgp.PathAttributeAsPath).Value[0].(*bgp.AsPathParam).AS, as)
+
+	m = updateMsg1(as).Body.(*bgp.BGPUpdate)
+	UpdatePathAttrsRemovePrivateAs(m, 100, 200, true)
+	attr = m.PathAttributes[1].(*bgp.PathAttributeAsPath)
+	assert.Equal(t, attr.Value[0].(*bgp.AsPathParam).AS, []uint16{100, 100, 4000, 65003})
+}
+
+func TestRemovePrivateAs4Byte(t *testing.T) {
+	as4 := []uint32{4200000001, 65001, 400000, 4200000002}
+	m := updateMsg1([]uint16{}).Body.(*bgp.BGPUpdate)
+	aspathParam := []bgp.AsPathParamInterface{bgp.NewAs4PathParam(2, as4)}
+	m.PathAttributes[1] = bgp.NewPathAttributeAsPath(aspathParam)
+	m.PathAttributes = append(m.PathAttributes, bgp.NewPathAttributeAs4Path([]*bgp.As4PathParam{bgp.NewAs4PathParam(2, as4)}))
+	UpdatePathAttrsRemovePrivateAs(m, 100, 200, false)
+	attr := m.PathAttributes[1].(*bgp.PathAttributeAsPath)
+	assert.Equal(t, attr.Value[0].(*bgp.As4PathParam).AS, []uint32{400000, 4200000002})
+	attr4 := m.PathAttributes[4].(*bgp.PathAttributeAs4Path)
+	assert.Equal(t, attr4.Value[0].AS, []uint32{400000, 4200000002})
+}
+
+func TestRemovePrivateAsPrivatePeer(t *testing.T) {
+	as := []uint16{65001, 65002}
+	m := updateMsg1(as).Body.(*bgp.BGPUpdate)
+	UpdatePathAttrsRemovePrivateAs(m, 100, 65100, false)
+	attr := m.PathAttributes[1].(*bgp.PathAttributeAsPath)
+	assert.Equal(t, len(attr.Value), 1)
+	assert.Equal(t, attr.Value[0].(*bgp.AsPathParam).AS, as)
+
+	// toward a public peer the whole path can go
+	m = updateMsg1(as).Body.(*bgp.BGPUpdate)
+	UpdatePathAttrsRemovePrivateAs(m, 100, 200, false)
+	attr = m.PathAttributes[1].(*bgp.PathAttributeAsPath)
+	assert.Equal(t, len(attr.Value), 0)
+}
+
+func TestRemoveAigp(t *testing.T) {
+	m := updateMsg1([]uint16{65001}).Body.(*bgp.BGPUpdate)
+	m.PathAttributes = append(m.PathAttributes, bgp.NewPathAttributeAigp(100))
+	orig := m.PathAttributes
+	n := len(orig)
+	UpdatePathAttrsRemoveAigp(m)
+	assert.Equal(t, len(m.PathAttributes), n-1)
+	for _, attr := range m.PathAttributes {
+		_, y := attr.(*bgp.PathAttributeAigp)
+		assert.False(t, y)
+	}
+	// the original attributes are shared with the rib and must be untouched
+	assert.Equal(t, len(orig), n)
+	assert.IsType(t, &bgp.PathAttributeAigp{}, orig[n-1])
+}
+
+func TestUpdatePathAttrsUnknown(t *testing.T) {
+	unknown := func(flags uint8, t bgp.BGPAttrType) *bgp.PathAttributeUnknown {
+		return &bgp.PathAttributeUnknown{
+			PathAttribute: bgp.PathAttribute{Flags: flags, Type: t, Value: []byte{1, 2, 3, 4}},
+		}
+	}
+	m := updateMsg1([]uint16{65001}).Body.(*bgp.BGPUpdate)
+	m.PathAttributes = append(m.PathAttributes,
+		unknown(bgp.BGP_ATTR_FLAG_OPTIONAL|bgp.BGP_ATTR_FLAG_TRANSITIVE, 100),
+		unknown(bgp.BGP_ATTR_FLAG_OPTIONAL, 101),
+		unknown(bgp.BGP_ATTR_FLAG_OPTIONAL|bgp.BGP_ATTR_FLAG_TRANSITIVE|bgp.BGP_ATTR_FLAG_PARTIAL, 102))
+	orig := m.PathAttributes
+	n := len(orig)
+	UpdatePathAttrsUnknown(m)
+	assert.Equal(t, n-1, len(m.PathAttributes))
+	attr := m.PathAttributes[n-3].(*bgp.PathAttributeUnknown)
+	assert.Equal(t, bgp.BGPAttrType(100), attr.Type)
+	assert.Equal(t, uint8(bgp.BGP_ATTR_FLAG_OPTIONAL|bgp.BGP_ATTR_FLAG_TRANSITIVE|bgp.BGP_ATTR_FLAG_PARTIAL), attr.Flags)
+	assert.Equal(t, orig[n-1], m.PathAttributes[n-2])
+	// the original attributes are shared with the rib and must be untouched
+	assert.Equal(t, uint8(bgp.BGP_ATTR_FLAG_OPTIONAL|bgp.BGP_ATTR_FLAG_TRANSITIVE), orig[n-3].GetFlags())
+
+	// nothing to do without unknown attributes
+	m = updateMsg1([]uint16{65001}).Body.(*bgp.BGPUpdate)
+	orig = m.PathAttributes
+	UpdatePathAttrsUnknown(m)
+	assert.Equal(t, reflect.ValueOf(orig).Pointer(), reflect.ValueOf(m.PathAttributes).Pointer())
+}
+
+func TestUpdatePathAttrsDiscard(t *testing.T) {
+	m := updateMsg1([]uint16{65001}).Body.(*bgp.BGPUpdate)
+	m.PathAttributes = append(m.PathAttributes, bgp.NewPathAttributeCommunities([]uint32{1}))
+	orig := m.PathAttributes
+	n := len(orig)
+	UpdatePathAttrsDiscard(m, []uint32{uint32(bgp.BGP_ATTR_TYPE_COMMUNITIES), uint32(bgp.BGP_ATTR_TYPE_AS_PATH)})
+	// AS_PATH can't be discarded
+	assert.Equal(t, n-1, len(m.PathAttributes))
+	for _, attr := range m.PathAttributes {
+		assert.NotEqual(t, bgp.BGP_ATTR_TYPE_COMMUNITIES, attr.GetType())
+	}
+	assert.Equal(t, n, len(orig))
+	assert.IsType(t, &bgp.PathAttributeCommunities{}, orig[n-1])
+}
+
+func TestTreatAsWithdraw(t *testing.T) {
+	msg := updateMsg1([]uint16{65001})
+	m := msg.Body.(*bgp.BGPUpdate)
+	m.WithdrawnRoutes = []bgp.WithdrawnRoute{{IPAddrPrefix: *bgp.NewIPAddrPrefix(24, "10.20.0.0")}}
+	prefix := bgp.NewIPv6AddrPrefix(64, "2001:db8::")
+	m.PathAttributes = append(m.PathAttributes, bgp.NewPathAttributeMpReachNLRI("2001:db8::1", []bgp.AddrPrefixInterface{prefix}))
+	TreatAsWithdraw(m)
+
+	assert.Equal(t, 0, len(m.NLRI))
+	assert.Equal(t, 2, len(m.WithdrawnRoutes))
+	assert.Equal(t, 1, len(m.PathAttributes))
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4()}
+	pathList := NewProcessMessage(msg, peer).ToPathList()
+	assert.Equal(t, 3, len(pathList))
+	for _, path := range pathList {
+		assert.True(t, path.IsWithdraw())
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	"net"
+	"sync"
+)
+
+// NexthopResolver resolves BGP next hops against a routing table.
+type NexthopResolver interface {
+	// Resolve returns whether nexthop is reachable and the IGP metric
+	// of the route it is reachable through.
+	Resolve(nexthop net.IP) (bool, uint32)
+}
+
+type nexthopState struct {
+	reachable bool
+	metric    uint32
+}
+
+// NexthopTracker caches the resolution of the next hops of the paths in
+// a TableManager. A nil tracker treats every next hop as reachable with
+// metric 0.
+type NexthopTracker struct {
+	resolver NexthopResolver
+	states   map[string]nexthopState
+	mu       sync.Mutex
+}
+
+func NewNexthopTracker(resolver NexthopResolver) *NexthopTracker {
+	return &NexthopTracker{
+		resolver: resolver,
+		states:   make(map[string]nexthopState),
+	}
+}
+
+func (t *NexthopTracker) lookup(nexthop net.IP) nexthopState {
+	if t == nil || nexthop == nil {
+		return nexthopState{reachable: true}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := nexthop.String()
+	s, found := t.states[key]
+	if !found {
+		s.reachable, s.metric = t.resolver.Resolve(nexthop)
+		t.states[key] = s
+	}
+	return s
+}
+
+func (t *NexthopTracker) isReachable(path Path) bool {
+	return t.lookup(path.GetNexthop()).reachable
+}
+
+func (t *NexthopTracker) getMetric(path Path) uint32 {
+	return t.lookup(path.GetNexthop()).metric
+}
+
+// refresh resolves the next hops in used again, forgets the ones that
+// are no longer used and returns the ones whose resolution changed.
+func (t *NexthopTracker) refresh(used map[string]net.IP) []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changed := make([]string, 0)
+	for key := range t.states {
+		if _, found := used[key]; !found {
+			delete(t.states, key)
+		}
+	}
+	for key, nexthop := range used {
+		old, found := t.states[key]
+		if !found {
+			continue
+		}
+		var s nexthopState
+		s.reachable, s.metric = t.resolver.Resolve(nexthop)
+		if s != old {
+			log.Infof("next hop %s changed: reachable=%t, metric=%d", key, s.reachable, s.metric)
+			t.states[key] = s
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
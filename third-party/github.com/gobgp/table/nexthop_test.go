@@ -0,0 +1,112 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	"github.com/gopher-net/gopher-net/configuration"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+// resolves the next hops in the map, everything else is unreachable
+type testResolver map[string]uint32
+
+func (r testResolver) Resolve(nexthop net.IP) (bool, uint32) {
+	metric, found := r[nexthop.String()]
+	return found, metric
+}
+
+func nexthopPath(peerAs uint32, routerId string, ases []uint32, nexthop string) Path {
+	peer := &PeerInfo{
+		AS:      peerAs,
+		ID:      net.ParseIP(routerId).To4(),
+		LocalID: net.ParseIP("10.0.0.1").To4(),
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute(ases),
+		bgp.NewPathAttributeNextHop(nexthop),
+	}
+	nlri := bgp.NewNLRInfo(24, "10.10.10.0")
+	return CreatePath(peer, nlri, pathAttributes, false)
+}
+
+func TestNexthopReachable(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	nexthops := NewNexthopTracker(testResolver{"192.168.50.2": 10})
+	path1 := nexthopPath(65000, "10.0.0.2", []uint32{65000}, "192.168.50.1")
+	path2 := nexthopPath(65100, "10.0.0.3", []uint32{65100, 65101}, "192.168.50.2")
+
+	best, reason := computeBestPath(65500, options, nil, path1, path2)
+	assert.Equal(t, path1, best)
+	assert.Equal(t, BPR_ASPATH, reason)
+
+	best, reason = computeBestPath(65500, options, nexthops, path1, path2)
+	assert.Equal(t, path2, best)
+	assert.Equal(t, BPR_REACHABLE_NEXT_HOP, reason)
+}
+
+func TestNexthopIGPCost(t *testing.T) {
+	options := configuration.RouteSelectionOptionsType{}
+	nexthops := NewNexthopTracker(testResolver{"192.168.50.1": 20, "192.168.50.2": 10})
+	path1 := nexthopPath(65000, "10.0.0.2", []uint32{65000}, "192.168.50.1")
+	path2 := nexthopPath(65100, "10.0.0.3", []uint32{65100}, "192.168.50.2")
+
+	best, reason := computeBestPath(65500, options, nexthops, path1, path2)
+	assert.Equal(t, path2, best)
+	assert.Equal(t, BPR_IGP_COST, reason)
+}
+
+func TestNexthopRefresh(t *testing.T) {
+	resolver := testResolver{}
+	tm := NewTableManager()
+	tm.SetNexthopResolver(resolver)
+	path := nexthopPath(65000, "10.0.0.2", []uint32{65000}, "192.168.50.1")
+
+	// the only path is inactive and isn't advertised
+	bestPaths, lostPaths, _ := tm.ProcessPaths([]Path{path})
+	assert.Equal(t, 0, len(bestPaths))
+	assert.Equal(t, 0, len(lostPaths))
+
+	resolver["192.168.50.1"] = 10
+	bestPaths, lostPaths, _ = tm.RefreshNexthops()
+	assert.Equal(t, []Path{path}, bestPaths)
+	assert.Equal(t, 0, len(lostPaths))
+
+	// nothing changed
+	bestPaths, lostPaths, _ = tm.RefreshNexthops()
+	assert.Equal(t, 0, len(bestPaths))
+	assert.Equal(t, 0, len(lostPaths))
+
+	delete(resolver, "192.168.50.1")
+	bestPaths, lostPaths, _ = tm.RefreshNexthops()
+	assert.Equal(t, 0, len(bestPaths))
+	assert.Equal(t, 1, len(lostPaths))
+	assert.True(t, lostPaths[0].IsWithdraw())
+}
+
+func TestNexthopAdvertiseInactive(t *testing.T) {
+	tm := NewTableManager()
+	tm.SetNexthopResolver(testResolver{})
+	tm.SetRouteSelectionOptions(configuration.RouteSelectionOptionsType{
+		AdvertiseInactiveRoutes: true,
+	})
+	path := nexthopPath(65000, "10.0.0.2", []uint32{65000}, "192.168.50.1")
+	bestPaths, _, _ := tm.ProcessPaths([]Path{path})
+	assert.Equal(t, []Path{path}, bestPaths)
+}
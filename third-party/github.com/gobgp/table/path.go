@@ -22,6 +22,7 @@ import (
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
 	"net"
 	"reflect"
+	"time"
 )
 
 type Path interface {
@@ -37,10 +38,15 @@ type Path interface {
 	IsWithdraw() bool
 	GetNlri() bgp.AddrPrefixInterface
 	GetPrefix() string
+	GetLabels() []uint32
 	setMedSetByTargetNeighbor(medSetByTargetNeighbor bool)
 	getMedSetByTargetNeighbor() bool
-	clone(IsWithdraw bool) Path
+	Clone(IsWithdraw bool) Path
+	CloneWithPathAttrs(pattrs []bgp.PathAttributeInterface) Path
 	setBest(isBest bool)
+	setMultiPath(isMultiPath bool)
+	getTimestamp() time.Time
+	setTimestamp(timestamp time.Time)
 	MarshalJSON() ([]byte, error)
 }
 
@@ -53,6 +59,8 @@ type PathDefault struct {
 	pathAttrs              []bgp.PathAttributeInterface
 	medSetByTargetNeighbor bool
 	isBest                 bool
+	isMultiPath            bool
+	timestamp              time.Time
 }
 
 func NewPathDefault(rf bgp.RouteFamily, source *PeerInfo, nlri bgp.AddrPrefixInterface, nexthop net.IP, isWithdraw bool, pattrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *PathDefault {
@@ -71,6 +79,7 @@ func NewPathDefault(rf bgp.RouteFamily, source *PeerInfo, nlri bgp.AddrPrefixInt
 	path.withdraw = isWithdraw
 	path.medSetByTargetNeighbor = medSetByTargetNeighbor
 	path.isBest = false
+	path.timestamp = time.Now()
 	return path
 }
 
@@ -78,31 +87,57 @@ func (pd *PathDefault) setBest(isBest bool) {
 	pd.isBest = isBest
 }
 
+func (pd *PathDefault) getTimestamp() time.Time {
+	return pd.timestamp
+}
+
+func (pd *PathDefault) setTimestamp(timestamp time.Time) {
+	pd.timestamp = timestamp
+}
+
+func (pd *PathDefault) setMultiPath(isMultiPath bool) {
+	pd.isMultiPath = isMultiPath
+}
+
 func (pd *PathDefault) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Network string
-		Nexthop string
-		Attrs   []bgp.PathAttributeInterface
-		Best    string
+		Network   string
+		Nexthop   string
+		Attrs     []bgp.PathAttributeInterface
+		Best      string
+		MultiPath string
 	}{
-		Network: pd.GetPrefix(),
-		Nexthop: pd.nexthop.String(),
-		Attrs:   pd.GetPathAttrs(),
-		Best:    fmt.Sprint(pd.isBest),
+		Network:   pd.GetPrefix(),
+		Nexthop:   pd.nexthop.String(),
+		Attrs:     pd.GetPathAttrs(),
+		Best:      fmt.Sprint(pd.isBest),
+		MultiPath: fmt.Sprint(pd.isMultiPath),
 	})
 }
 
 // create new PathAttributes
-func (pd *PathDefault) clone(isWithdraw bool) Path {
+func (pd *PathDefault) Clone(isWithdraw bool) Path {
 	nlri := pd.nlri
 	if isWithdraw {
 		if pd.IsWithdraw() {
 			log.Fatal("Withdraw path is not supposed to be cloned")
-		} else {
-			nlri = &bgp.WithdrawnRoute{pd.nlri.(*bgp.NLRInfo).IPAddrPrefix}
+		} else if n, ok := pd.nlri.(*bgp.NLRInfo); ok {
+			nlri = &bgp.WithdrawnRoute{n.IPAddrPrefix}
 		}
 	}
-	return CreatePath(pd.source, nlri, pd.pathAttrs, isWithdraw)
+	path := CreatePath(pd.source, nlri, pd.pathAttrs, isWithdraw)
+	path.setTimestamp(pd.timestamp)
+	return path
+}
+
+// create new Path with the given PathAttributes, the PathAttributes of
+// the original are left untouched. The clone keeps the time the path was
+// received so that policies don't make it look newer to the best path
+// selection.
+func (pd *PathDefault) CloneWithPathAttrs(pattrs []bgp.PathAttributeInterface) Path {
+	path := CreatePath(pd.source, pd.nlri, pattrs, pd.withdraw)
+	path.setTimestamp(pd.timestamp)
+	return path
 }
 
 func (pd *PathDefault) GetRouteFamily() bgp.RouteFamily {
@@ -149,7 +184,7 @@ func (pd *PathDefault) GetPathAttrs() []bgp.PathAttributeInterface {
 }
 
 func (pd *PathDefault) GetPathAttr(pattrType bgp.BGPAttrType) (int, bgp.PathAttributeInterface) {
-	attrMap := [bgp.BGP_ATTR_TYPE_AS4_AGGREGATOR + 1]reflect.Type{}
+	attrMap := [bgp.BGP_ATTR_TYPE_LARGE_COMMUNITY + 1]reflect.Type{}
 	attrMap[bgp.BGP_ATTR_TYPE_ORIGIN] = reflect.TypeOf(&bgp.PathAttributeOrigin{})
 	attrMap[bgp.BGP_ATTR_TYPE_AS_PATH] = reflect.TypeOf(&bgp.PathAttributeAsPath{})
 	attrMap[bgp.BGP_ATTR_TYPE_NEXT_HOP] = reflect.TypeOf(&bgp.PathAttributeNextHop{})
@@ -165,6 +200,9 @@ func (pd *PathDefault) GetPathAttr(pattrType bgp.BGPAttrType) (int, bgp.PathAttr
 	attrMap[bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES] = reflect.TypeOf(&bgp.PathAttributeExtendedCommunities{})
 	attrMap[bgp.BGP_ATTR_TYPE_AS4_PATH] = reflect.TypeOf(&bgp.PathAttributeAs4Path{})
 	attrMap[bgp.BGP_ATTR_TYPE_AS4_AGGREGATOR] = reflect.TypeOf(&bgp.PathAttributeAs4Aggregator{})
+	attrMap[bgp.BGP_ATTR_TYPE_AIGP] = reflect.TypeOf(&bgp.PathAttributeAigp{})
+	attrMap[bgp.BGP_ATTR_TYPE_LS] = reflect.TypeOf(&bgp.PathAttributeLs{})
+	attrMap[bgp.BGP_ATTR_TYPE_LARGE_COMMUNITY] = reflect.TypeOf(&bgp.PathAttributeLargeCommunities{})
 
 	t := attrMap[pattrType]
 	for i, p := range pd.pathAttrs {
@@ -191,8 +229,23 @@ func (pi *PathDefault) GetPrefix() string {
 	case *bgp.WithdrawnRoute:
 		return nlri.IPAddrPrefix.IPAddrPrefixDefault.String()
 	}
-	log.Fatal()
-	return ""
+	return pi.nlri.String()
+}
+
+// GetLabels returns the label stack of a labeled unicast or VPN path,
+// nil for the other route families.
+func (pi *PathDefault) GetLabels() []uint32 {
+	switch nlri := pi.nlri.(type) {
+	case *bgp.LabelledIPAddrPrefix:
+		return nlri.Labels.Labels
+	case *bgp.LabelledIPv6AddrPrefix:
+		return nlri.Labels.Labels
+	case *bgp.LabelledVPNIPAddrPrefix:
+		return nlri.Labels.Labels
+	case *bgp.LabelledVPNIPv6AddrPrefix:
+		return nlri.Labels.Labels
+	}
+	return nil
 }
 
 // create Path object based on route family
@@ -209,6 +262,36 @@ func CreatePath(source *PeerInfo, nlri bgp.AddrPrefixInterface, attrs []bgp.Path
 	case bgp.RF_IPv6_UC:
 		log.Debugf("RouteFamily : %s", bgp.RF_IPv6_UC.String())
 		path = NewIPv6Path(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_IPv4_MC, bgp.RF_IPv6_MC:
+		log.Debugf("RouteFamily : %s", rf.String())
+		path = NewMulticastPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_IPv4_VPN:
+		log.Debugf("RouteFamily : %s", bgp.RF_IPv4_VPN.String())
+		path = NewIPv4VPNPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_IPv6_VPN:
+		log.Debugf("RouteFamily : %s", bgp.RF_IPv6_VPN.String())
+		path = NewIPv6VPNPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_RTC_UC:
+		log.Debugf("RouteFamily : %s", bgp.RF_RTC_UC.String())
+		path = NewRouteTargetPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_IPv4_MPLS:
+		log.Debugf("RouteFamily : %s", bgp.RF_IPv4_MPLS.String())
+		path = NewIPv4MPLSPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_IPv6_MPLS:
+		log.Debugf("RouteFamily : %s", bgp.RF_IPv6_MPLS.String())
+		path = NewIPv6MPLSPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_VPLS:
+		log.Debugf("RouteFamily : %s", bgp.RF_VPLS.String())
+		path = NewVPLSPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_EVPN:
+		log.Debugf("RouteFamily : %s", bgp.RF_EVPN.String())
+		path = NewEVPNPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_LS:
+		log.Debugf("RouteFamily : %s", bgp.RF_LS.String())
+		path = NewLsPath(source, nlri, isWithdraw, attrs, false)
+	case bgp.RF_FS_IPv4_UC, bgp.RF_FS_IPv6_UC:
+		log.Debugf("RouteFamily : %s", rf.String())
+		path = NewFlowSpecPath(source, nlri, isWithdraw, attrs, false)
 	}
 	return path
 }
@@ -251,7 +334,7 @@ func NewIPv6Path(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool
 	return ipv6Path
 }
 
-func (ipv6p *IPv6Path) clone(isWithdraw bool) Path {
+func (ipv6p *IPv6Path) Clone(isWithdraw bool) Path {
 	nlri := ipv6p.nlri
 	if isWithdraw {
 		if ipv6p.IsWithdraw() {
@@ -283,3 +366,239 @@ func (ipv6p *IPv6Path) String() string {
 	//str = str + fmt.Sprintf(" path attributes: %s, ", ipv6p.getPathAttributeMap())
 	return str
 }
+
+// MulticastPath is a route of the IPv4 or IPv6 multicast route family,
+// its next hop is the upstream of the multicast sources in the prefix.
+type MulticastPath struct {
+	*PathDefault
+}
+
+func NewMulticastPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *MulticastPath {
+	multicastPath := &MulticastPath{}
+	rf := bgp.RouteFamily(int(nlri.AFI())<<16 | int(nlri.SAFI()))
+	multicastPath.PathDefault = NewPathDefault(rf, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := multicastPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		multicastPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return multicastPath
+}
+
+// return MulticastPath's string representation
+func (mcp *MulticastPath) String() string {
+	str := fmt.Sprintf("MulticastPath Source: %v, ", mcp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", mcp.GetPrefix())
+	str = str + fmt.Sprintf(" nexthop: %s, ", mcp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", mcp.IsWithdraw())
+	return str
+}
+
+type IPv4VPNPath struct {
+	*PathDefault
+}
+
+func NewIPv4VPNPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *IPv4VPNPath {
+	ipv4VPNPath := &IPv4VPNPath{}
+	ipv4VPNPath.PathDefault = NewPathDefault(bgp.RF_IPv4_VPN, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := ipv4VPNPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		ipv4VPNPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return ipv4VPNPath
+}
+
+// return IPv4VPNPath's string representation
+func (ipv4vpnp *IPv4VPNPath) String() string {
+	str := fmt.Sprintf("IPv4VPNPath Source: %v, ", ipv4vpnp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", ipv4vpnp.GetPrefix())
+	str = str + fmt.Sprintf(" nexthop: %s, ", ipv4vpnp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", ipv4vpnp.IsWithdraw())
+	return str
+}
+
+type IPv6VPNPath struct {
+	*PathDefault
+}
+
+func NewIPv6VPNPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *IPv6VPNPath {
+	ipv6VPNPath := &IPv6VPNPath{}
+	ipv6VPNPath.PathDefault = NewPathDefault(bgp.RF_IPv6_VPN, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := ipv6VPNPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		ipv6VPNPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return ipv6VPNPath
+}
+
+// return IPv6VPNPath's string representation
+func (ipv6vpnp *IPv6VPNPath) String() string {
+	str := fmt.Sprintf("IPv6VPNPath Source: %v, ", ipv6vpnp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", ipv6vpnp.GetPrefix())
+	str = str + fmt.Sprintf(" nexthop: %s, ", ipv6vpnp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", ipv6vpnp.IsWithdraw())
+	return str
+}
+
+type RouteTargetPath struct {
+	*PathDefault
+}
+
+func NewRouteTargetPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *RouteTargetPath {
+	routeTargetPath := &RouteTargetPath{}
+	routeTargetPath.PathDefault = NewPathDefault(bgp.RF_RTC_UC, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := routeTargetPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		routeTargetPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return routeTargetPath
+}
+
+// return RouteTargetPath's string representation
+func (rtp *RouteTargetPath) String() string {
+	str := fmt.Sprintf("RouteTargetPath Source: %v, ", rtp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", rtp.GetPrefix())
+	str = str + fmt.Sprintf(" nexthop: %s, ", rtp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", rtp.IsWithdraw())
+	return str
+}
+
+type IPv4MPLSPath struct {
+	*PathDefault
+}
+
+func NewIPv4MPLSPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *IPv4MPLSPath {
+	ipv4MPLSPath := &IPv4MPLSPath{}
+	ipv4MPLSPath.PathDefault = NewPathDefault(bgp.RF_IPv4_MPLS, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := ipv4MPLSPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		ipv4MPLSPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return ipv4MPLSPath
+}
+
+// return IPv4MPLSPath's string representation
+func (ipv4mplsp *IPv4MPLSPath) String() string {
+	str := fmt.Sprintf("IPv4MPLSPath Source: %v, ", ipv4mplsp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", ipv4mplsp.GetPrefix())
+	str = str + fmt.Sprintf(" labels: %v, ", ipv4mplsp.GetLabels())
+	str = str + fmt.Sprintf(" nexthop: %s, ", ipv4mplsp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", ipv4mplsp.IsWithdraw())
+	return str
+}
+
+type IPv6MPLSPath struct {
+	*PathDefault
+}
+
+func NewIPv6MPLSPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *IPv6MPLSPath {
+	ipv6MPLSPath := &IPv6MPLSPath{}
+	ipv6MPLSPath.PathDefault = NewPathDefault(bgp.RF_IPv6_MPLS, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := ipv6MPLSPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		ipv6MPLSPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return ipv6MPLSPath
+}
+
+// return IPv6MPLSPath's string representation
+func (ipv6mplsp *IPv6MPLSPath) String() string {
+	str := fmt.Sprintf("IPv6MPLSPath Source: %v, ", ipv6mplsp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", ipv6mplsp.GetPrefix())
+	str = str + fmt.Sprintf(" labels: %v, ", ipv6mplsp.GetLabels())
+	str = str + fmt.Sprintf(" nexthop: %s, ", ipv6mplsp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", ipv6mplsp.IsWithdraw())
+	return str
+}
+
+// VPLSPath is the label block advertised by a site of a VPLS instance.
+type VPLSPath struct {
+	*PathDefault
+}
+
+func NewVPLSPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *VPLSPath {
+	vplsPath := &VPLSPath{}
+	vplsPath.PathDefault = NewPathDefault(bgp.RF_VPLS, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := vplsPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		vplsPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return vplsPath
+}
+
+// return VPLSPath's string representation
+func (vplsp *VPLSPath) String() string {
+	str := fmt.Sprintf("VPLSPath Source: %v, ", vplsp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", vplsp.GetPrefix())
+	str = str + fmt.Sprintf(" nexthop: %s, ", vplsp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", vplsp.IsWithdraw())
+	return str
+}
+
+type EVPNPath struct {
+	*PathDefault
+}
+
+func NewEVPNPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *EVPNPath {
+	evpnPath := &EVPNPath{}
+	evpnPath.PathDefault = NewPathDefault(bgp.RF_EVPN, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := evpnPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		evpnPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return evpnPath
+}
+
+// return EVPNPath's string representation
+func (evpnp *EVPNPath) String() string {
+	str := fmt.Sprintf("EVPNPath Source: %v, ", evpnp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", evpnp.GetPrefix())
+	str = str + fmt.Sprintf(" nexthop: %s, ", evpnp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", evpnp.IsWithdraw())
+	return str
+}
+
+// LsPath is a node, link or prefix of the BGP-LS route family, its
+// properties are in the BGP-LS attribute.
+type LsPath struct {
+	*PathDefault
+}
+
+func NewLsPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *LsPath {
+	lsPath := &LsPath{}
+	lsPath.PathDefault = NewPathDefault(bgp.RF_LS, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	if !isWithdraw {
+		_, mpattr := lsPath.GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+		lsPath.nexthop = mpattr.(*bgp.PathAttributeMpReachNLRI).Nexthop
+	}
+	return lsPath
+}
+
+// return LsPath's string representation
+func (lsp *LsPath) String() string {
+	str := fmt.Sprintf("LsPath Source: %v, ", lsp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", lsp.GetPrefix())
+	str = str + fmt.Sprintf(" nexthop: %s, ", lsp.GetNexthop().String())
+	str = str + fmt.Sprintf(" withdraw: %t, ", lsp.IsWithdraw())
+	return str
+}
+
+// FlowSpecPath is a flow specification and its traffic actions, it has
+// no next hop.
+type FlowSpecPath struct {
+	*PathDefault
+}
+
+func NewFlowSpecPath(source *PeerInfo, nlri bgp.AddrPrefixInterface, isWithdraw bool, attrs []bgp.PathAttributeInterface, medSetByTargetNeighbor bool) *FlowSpecPath {
+	flowSpecPath := &FlowSpecPath{}
+	rf := bgp.RouteFamily(int(nlri.AFI())<<16 | int(nlri.SAFI()))
+	flowSpecPath.PathDefault = NewPathDefault(rf, source, nlri, nil, isWithdraw, attrs, medSetByTargetNeighbor)
+	return flowSpecPath
+}
+
+// return FlowSpecPath's string representation
+func (fsp *FlowSpecPath) String() string {
+	str := fmt.Sprintf("FlowSpecPath Source: %v, ", fsp.getSource())
+	str = str + fmt.Sprintf(" NLRI: %s, ", fsp.GetPrefix())
+	str = str + fmt.Sprintf(" withdraw: %t, ", fsp.IsWithdraw())
+	return str
+}
@@ -0,0 +1,110 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+)
+
+// Route target constraint (RFC 4684): a neighbor advertises the route
+// targets it imports as route target membership paths and only the VPN
+// paths carrying one of them are sent to it.
+
+func isVpnRouteFamily(rf bgp.RouteFamily) bool {
+	return rf == bgp.RF_IPv4_VPN || rf == bgp.RF_IPv6_VPN
+}
+
+// matchRouteTarget returns true if the extended community e is a route
+// target covered by the prefix of the membership n.
+func matchRouteTarget(n *bgp.RouteTargetMembershipNLRI, e bgp.ExtendedCommunityInterface) bool {
+	buf, _ := e.Serialize()
+	switch buf[0] {
+	case bgp.EC_TYPE_TRANSITIVE_TWO_OCTET_AS_SPECIFIC, bgp.EC_TYPE_TRANSITIVE_IP4_SPECIFIC, bgp.EC_TYPE_TRANSITIVE_FOUR_OCTET_AS_SPECIFIC:
+	default:
+		return false
+	}
+	if buf[1] != bgp.EC_SUBTYPE_ROUTE_TARGET {
+		return false
+	}
+	prefix, _ := n.RouteTarget.Serialize()
+	bits := int(n.Length) - 32
+	for i := 0; i < bits; i += 8 {
+		mask := byte(0xff)
+		if bits-i < 8 {
+			mask <<= uint(8 - (bits - i))
+		}
+		if buf[i/8]&mask != prefix[i/8]&mask {
+			return false
+		}
+	}
+	return true
+}
+
+// isInterestingPath returns true if path carries a route target covered
+// by one of the memberships. The default membership and the ones of an
+// origin AS alone take all the paths.
+func isInterestingPath(path Path, memberships []Path) bool {
+	var extCommunities []bgp.ExtendedCommunityInterface
+	if _, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES); attr != nil {
+		extCommunities = attr.(*bgp.PathAttributeExtendedCommunities).Value
+	}
+	for _, m := range memberships {
+		n := m.GetNlri().(*bgp.RouteTargetMembershipNLRI)
+		if n.Length <= 32 {
+			return true
+		}
+		for _, e := range extCommunities {
+			if matchRouteTarget(n, e) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterUninterestingPaths drops the VPN paths of pathList which carry
+// none of the route targets of the memberships. The paths of the other
+// route families and the withdrawals are kept.
+func FilterUninterestingPaths(pathList []Path, memberships []Path) []Path {
+	filtered := make([]Path, 0, len(pathList))
+	for _, path := range pathList {
+		if path.IsWithdraw() || !isVpnRouteFamily(path.GetRouteFamily()) || isInterestingPath(path, memberships) {
+			filtered = append(filtered, path)
+		}
+	}
+	return filtered
+}
+
+// RtcMembershipChanges returns the VPN paths of pathList to advertise
+// and the ones to withdraw when the memberships of a neighbor change
+// from oldMemberships to newMemberships.
+func RtcMembershipChanges(pathList []Path, oldMemberships, newMemberships []Path) ([]Path, []Path) {
+	pList := make([]Path, 0)
+	wList := make([]Path, 0)
+	for _, path := range pathList {
+		if path.IsWithdraw() || !isVpnRouteFamily(path.GetRouteFamily()) {
+			continue
+		}
+		was := isInterestingPath(path, oldMemberships)
+		is := isInterestingPath(path, newMemberships)
+		if is && !was {
+			pList = append(pList, path)
+		} else if was && !is {
+			wList = append(wList, path.Clone(true))
+		}
+	}
+	return pList, wList
+}
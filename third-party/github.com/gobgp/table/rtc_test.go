@@ -0,0 +1,125 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+func rtcPath(peer *PeerInfo, rt string) Path {
+	var target bgp.ExtendedCommunityInterface
+	if rt != "" {
+		target, _ = bgp.ParseExtendedCommunity(rt)
+	}
+	nlri := bgp.NewRouteTargetMembershipNLRI(65001, target)
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute([]uint32{65001}),
+		bgp.NewPathAttributeMpReachNLRI("10.0.0.2", []bgp.AddrPrefixInterface{nlri}),
+	}
+	return CreatePath(peer, nlri, pathAttributes, false)
+}
+
+func TestRtcPathList(t *testing.T) {
+	manager := testVrfManager(t)
+	// red and blue import different route targets
+	pathList := manager.GetRtcPathList()
+	assert.Equal(t, 2, len(pathList))
+
+	msgs := CreateUpdateMsgFromPaths(pathList)
+	assert.Equal(t, 2, len(msgs))
+	prefixes := []string{}
+	for _, m := range msgs {
+		buf, _ := m.Serialize()
+		msg, err := bgp.ParseBGPMessage(buf)
+		assert.Nil(t, err)
+		paths := NewProcessMessage(msg, nil).ToPathList()
+		assert.Equal(t, 1, len(paths))
+		assert.Equal(t, bgp.RF_RTC_UC, paths[0].GetRouteFamily())
+		assert.Equal(t, "10.0.0.1", paths[0].GetNexthop().String())
+		prefixes = append(prefixes, paths[0].GetPrefix())
+	}
+	assert.Contains(t, prefixes, "65000:rt:65000:100")
+	assert.Contains(t, prefixes, "65000:rt:65000:200")
+
+	tm := NewTableManager()
+	best, _, _ := tm.ProcessPaths(pathList)
+	assert.Equal(t, 2, len(best))
+	assert.Equal(t, 2, len(tm.GetBestPathList(bgp.RF_RTC_UC)))
+}
+
+func TestRtcFilter(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), RF: bgp.RF_IPv4_VPN}
+	path100 := vpnPath(peer, "65001:1", "10.10.0.0", 24, []string{"rt:65000:100"})
+	path200 := vpnPath(peer, "65001:1", "10.20.0.0", 24, []string{"rt:65000:200", "soo:65000:1"})
+	unicast := CreatePath(peer, bgp.NewNLRInfo(24, "10.30.0.0"), []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute([]uint32{65001}),
+		bgp.NewPathAttributeNextHop("10.0.0.2"),
+	}, false)
+	pathList := []Path{path100, path200, unicast, path200.Clone(true)}
+
+	memberships := []Path{rtcPath(peer, "rt:65000:100")}
+	filtered := FilterUninterestingPaths(pathList, memberships)
+	assert.Equal(t, []Path{path100, unicast, pathList[3]}, filtered)
+
+	assert.Equal(t, 2, len(FilterUninterestingPaths(pathList, nil)))
+	assert.Equal(t, 4, len(FilterUninterestingPaths(pathList, []Path{rtcPath(peer, "")})))
+
+	pList, wList := RtcMembershipChanges(pathList, memberships, []Path{rtcPath(peer, "rt:65000:200")})
+	assert.Equal(t, []Path{path200}, pList)
+	assert.Equal(t, 1, len(wList))
+	assert.True(t, wList[0].IsWithdraw())
+	assert.Equal(t, path100.GetPrefix(), wList[0].GetPrefix())
+
+	pList, wList = RtcMembershipChanges(pathList, memberships, append(memberships, rtcPath(peer, "")))
+	assert.Equal(t, []Path{path200}, pList)
+	assert.Equal(t, 0, len(wList))
+}
+
+func TestRtcFilterPrefix(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), RF: bgp.RF_IPv4_VPN}
+	path100 := vpnPath(peer, "65001:1", "10.10.0.0", 24, []string{"rt:65000:100"})
+	path200 := vpnPath(peer, "65001:1", "10.20.0.0", 24, []string{"rt:65000:200"})
+	other := vpnPath(peer, "65001:1", "10.30.0.0", 24, []string{"rt:65100:100"})
+	soo := vpnPath(peer, "65001:1", "10.40.0.0", 24, []string{"soo:65000:100"})
+	pathList := []Path{path100, path200, other, soo}
+
+	membership := func(buf []byte) Path {
+		nlri := &bgp.RouteTargetMembershipNLRI{}
+		assert.Nil(t, nlri.DecodeFromBytes(buf))
+		return CreatePath(peer, nlri, []bgp.PathAttributeInterface{
+			bgp.NewPathAttributeOrigin(0),
+			createAsPathAttribute([]uint32{65001}),
+			bgp.NewPathAttributeMpReachNLRI("10.0.0.2", []bgp.AddrPrefixInterface{nlri}),
+		}, false)
+	}
+
+	// the route targets of AS 65000
+	filtered := FilterUninterestingPaths(pathList, []Path{membership([]byte{64, 0, 0, 0xfd, 0xe9, 0, 2, 0xfd, 0xe8})})
+	assert.Equal(t, []Path{path100, path200}, filtered)
+
+	// rt:65000:0 to rt:65000:127
+	filtered = FilterUninterestingPaths(pathList, []Path{membership([]byte{89, 0, 0, 0xfd, 0xe9, 0, 2, 0xfd, 0xe8, 0, 0, 0, 0})})
+	assert.Equal(t, []Path{path100}, filtered)
+
+	// the origin AS alone covers every route target
+	filtered = FilterUninterestingPaths(pathList, []Path{membership([]byte{32, 0, 0, 0xfd, 0xe9})})
+	assert.Equal(t, pathList, filtered)
+}
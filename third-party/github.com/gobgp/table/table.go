@@ -24,6 +24,7 @@ import (
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
 	"net"
 	"reflect"
+	"sort"
 )
 
 type Table interface {
@@ -112,23 +113,6 @@ func insert(table Table, path Path) Destination {
 	return dest
 }
 
-/*
-//Cleans table of any path that do not have any RT in common with interested_rts
-// Commented out because it is a VPN-related processing
-func (td *TableDefault) cleanUninterestingPaths(interested_rts) int  {
-	uninterestingDestCount = 0
-	for _, dest := range td.destinations {
-		addedWithdraw :=dest.withdrawUnintrestingPaths(interested_rts)
-		if addedWithdraw{
-			//need _signal_bus.dest_changed(dest)
-			uninterestingDestCount += 1
-		}
-	}
-	return uninterestingDestCount
-	// need content
-}
-*/
-
 func (td *TableDefault) DeleteDestByPeer(peerInfo *PeerInfo) []Destination {
 	changedDests := make([]Destination, 0)
 	for _, dest := range td.destinations {
@@ -291,3 +275,319 @@ func (ipv6t *IPv6Table) tableKey(nlri bgp.AddrPrefixInterface) string {
 	return addrPrefix.IPAddrPrefixDefault.String()
 
 }
+
+// MulticastTable holds the routes of the IPv4 or IPv6 multicast route
+// family, apart from the unicast ones, for the RPF checks of the
+// multicast traffic.
+type MulticastTable struct {
+	*TableDefault
+}
+
+func NewMulticastTable(scope_id int, rf bgp.RouteFamily) *MulticastTable {
+	multicastTable := &MulticastTable{}
+	multicastTable.TableDefault = NewTableDefault(scope_id)
+	multicastTable.TableDefault.ROUTE_FAMILY = rf
+	return multicastTable
+}
+
+//Creates destination
+//Implements interface
+func (mct *MulticastTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewMulticastDestination(nlri, mct.ROUTE_FAMILY)
+}
+
+//make tablekey
+//Implements interface
+func (mct *MulticastTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+// sortedTableMarshalJSON lists the destinations of a table whose keys
+// are not CIDRs, such as the VPN tables, sorted by their keys.
+func sortedTableMarshalJSON(destinations map[string]Destination) ([]byte, error) {
+	keys := make([]string, 0, len(destinations))
+	for key := range destinations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	destList := make([]Destination, 0, len(keys))
+	for _, key := range keys {
+		destList = append(destList, destinations[key])
+	}
+	return json.Marshal(struct {
+		Destinations []Destination
+	}{
+		Destinations: destList,
+	})
+}
+
+type IPv4VPNTable struct {
+	*TableDefault
+}
+
+func NewIPv4VPNTable(scope_id int) *IPv4VPNTable {
+	ipv4VPNTable := &IPv4VPNTable{}
+	ipv4VPNTable.TableDefault = NewTableDefault(scope_id)
+	ipv4VPNTable.TableDefault.ROUTE_FAMILY = bgp.RF_IPv4_VPN
+	return ipv4VPNTable
+}
+
+//Creates destination
+//Implements interface
+func (ipv4vpnt *IPv4VPNTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewIPv4VPNDestination(nlri)
+}
+
+//make tablekey, the route distinguisher and the prefix
+//Implements interface
+func (ipv4vpnt *IPv4VPNTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (ipv4vpnt *IPv4VPNTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(ipv4vpnt.destinations)
+}
+
+type IPv6VPNTable struct {
+	*TableDefault
+}
+
+func NewIPv6VPNTable(scope_id int) *IPv6VPNTable {
+	ipv6VPNTable := &IPv6VPNTable{}
+	ipv6VPNTable.TableDefault = NewTableDefault(scope_id)
+	ipv6VPNTable.TableDefault.ROUTE_FAMILY = bgp.RF_IPv6_VPN
+	return ipv6VPNTable
+}
+
+//Creates destination
+//Implements interface
+func (ipv6vpnt *IPv6VPNTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewIPv6VPNDestination(nlri)
+}
+
+//make tablekey, the route distinguisher and the prefix
+//Implements interface
+func (ipv6vpnt *IPv6VPNTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (ipv6vpnt *IPv6VPNTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(ipv6vpnt.destinations)
+}
+
+type RouteTargetTable struct {
+	*TableDefault
+}
+
+func NewRouteTargetTable(scope_id int) *RouteTargetTable {
+	routeTargetTable := &RouteTargetTable{}
+	routeTargetTable.TableDefault = NewTableDefault(scope_id)
+	routeTargetTable.TableDefault.ROUTE_FAMILY = bgp.RF_RTC_UC
+	return routeTargetTable
+}
+
+//Creates destination
+//Implements interface
+func (rtt *RouteTargetTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewRouteTargetDestination(nlri)
+}
+
+//make tablekey, the origin AS and the route target
+//Implements interface
+func (rtt *RouteTargetTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (rtt *RouteTargetTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(rtt.destinations)
+}
+
+type IPv4MPLSTable struct {
+	*TableDefault
+}
+
+func NewIPv4MPLSTable(scope_id int) *IPv4MPLSTable {
+	ipv4MPLSTable := &IPv4MPLSTable{}
+	ipv4MPLSTable.TableDefault = NewTableDefault(scope_id)
+	ipv4MPLSTable.TableDefault.ROUTE_FAMILY = bgp.RF_IPv4_MPLS
+	return ipv4MPLSTable
+}
+
+//Creates destination
+//Implements interface
+func (ipv4mplst *IPv4MPLSTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewIPv4MPLSDestination(nlri)
+}
+
+//make tablekey, the prefix without the labels which differ by neighbor
+//Implements interface
+func (ipv4mplst *IPv4MPLSTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (ipv4mplst *IPv4MPLSTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(ipv4mplst.destinations)
+}
+
+type IPv6MPLSTable struct {
+	*TableDefault
+}
+
+func NewIPv6MPLSTable(scope_id int) *IPv6MPLSTable {
+	ipv6MPLSTable := &IPv6MPLSTable{}
+	ipv6MPLSTable.TableDefault = NewTableDefault(scope_id)
+	ipv6MPLSTable.TableDefault.ROUTE_FAMILY = bgp.RF_IPv6_MPLS
+	return ipv6MPLSTable
+}
+
+//Creates destination
+//Implements interface
+func (ipv6mplst *IPv6MPLSTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewIPv6MPLSDestination(nlri)
+}
+
+//make tablekey, the prefix without the labels which differ by neighbor
+//Implements interface
+func (ipv6mplst *IPv6MPLSTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (ipv6mplst *IPv6MPLSTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(ipv6mplst.destinations)
+}
+
+// VPLSTable holds the label blocks of the sites of the VPLS instances.
+type VPLSTable struct {
+	*TableDefault
+}
+
+func NewVPLSTable(scope_id int) *VPLSTable {
+	vplsTable := &VPLSTable{}
+	vplsTable.TableDefault = NewTableDefault(scope_id)
+	vplsTable.TableDefault.ROUTE_FAMILY = bgp.RF_VPLS
+	return vplsTable
+}
+
+//Creates destination
+//Implements interface
+func (vplst *VPLSTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewVPLSDestination(nlri)
+}
+
+//make tablekey, the route distinguisher, the VE ID and the block offset
+//Implements interface
+func (vplst *VPLSTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (vplst *VPLSTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(vplst.destinations)
+}
+
+type EVPNTable struct {
+	*TableDefault
+}
+
+func NewEVPNTable(scope_id int) *EVPNTable {
+	evpnTable := &EVPNTable{}
+	evpnTable.TableDefault = NewTableDefault(scope_id)
+	evpnTable.TableDefault.ROUTE_FAMILY = bgp.RF_EVPN
+	return evpnTable
+}
+
+//Creates destination
+//Implements interface
+func (evpnt *EVPNTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewEVPNDestination(nlri)
+}
+
+//make tablekey, the route type and its key fields
+//Implements interface
+func (evpnt *EVPNTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (evpnt *EVPNTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(evpnt.destinations)
+}
+
+// LsTable holds the nodes, links and prefixes of the BGP-LS route
+// family.
+type LsTable struct {
+	*TableDefault
+}
+
+func NewLsTable(scope_id int) *LsTable {
+	lsTable := &LsTable{}
+	lsTable.TableDefault = NewTableDefault(scope_id)
+	lsTable.TableDefault.ROUTE_FAMILY = bgp.RF_LS
+	return lsTable
+}
+
+//Creates destination
+//Implements interface
+func (lst *LsTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewLsDestination(nlri)
+}
+
+//make tablekey, the nlri type, protocol and descriptors
+//Implements interface
+func (lst *LsTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+func (lst *LsTable) MarshalJSON() ([]byte, error) {
+	return sortedTableMarshalJSON(lst.destinations)
+}
+
+// FlowSpecTable holds the flow specifications of the IPv4 or IPv6
+// FlowSpec route family.
+type FlowSpecTable struct {
+	*TableDefault
+}
+
+func NewFlowSpecTable(scope_id int, rf bgp.RouteFamily) *FlowSpecTable {
+	flowSpecTable := &FlowSpecTable{}
+	flowSpecTable.TableDefault = NewTableDefault(scope_id)
+	flowSpecTable.TableDefault.ROUTE_FAMILY = rf
+	return flowSpecTable
+}
+
+//Creates destination
+//Implements interface
+func (fst *FlowSpecTable) createDest(nlri bgp.AddrPrefixInterface) Destination {
+	return NewFlowSpecDestination(nlri, fst.ROUTE_FAMILY)
+}
+
+//make tablekey, the components of the flow specification
+//Implements interface
+func (fst *FlowSpecTable) tableKey(nlri bgp.AddrPrefixInterface) string {
+	return nlri.String()
+}
+
+type flowSpecDestinations []Destination
+
+func (l flowSpecDestinations) Len() int { return len(l) }
+func (l flowSpecDestinations) Less(i, j int) bool {
+	return bgp.CompareFlowSpecNLRI(l[i].GetNlri().(*bgp.FlowSpecNLRI), l[j].GetNlri().(*bgp.FlowSpecNLRI)) < 0
+}
+func (l flowSpecDestinations) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// GetSortedDestinations returns the destinations in the order the flow
+// specifications are applied to the traffic (RFC 8955 section 5.1).
+func (fst *FlowSpecTable) GetSortedDestinations() []Destination {
+	l := make([]Destination, 0, len(fst.destinations))
+	for _, dest := range fst.destinations {
+		l = append(l, dest)
+	}
+	sort.Sort(flowSpecDestinations(l))
+	return l
+}
+
+func (fst *FlowSpecTable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Destinations []Destination
+	}{
+		Destinations: fst.GetSortedDestinations(),
+	})
+}
@@ -17,7 +17,9 @@ package table
 
 import (
 	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
+	"github.com/gopher-net/gopher-net/configuration"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
 	"time"
 )
 
@@ -61,6 +63,19 @@ func (p *ProcessMessage) withdraw2Path() []Path {
 	return pathList
 }
 
+// isUnknownNlri returns true for the NLRI decoded without being
+// understood, which are ignored.
+func isUnknownNlri(nlri bgp.AddrPrefixInterface) bool {
+	switch n := nlri.(type) {
+	case *bgp.EVPNNLRI:
+		_, unknown := n.RouteTypeData.(*bgp.EVPNUnknownRoute)
+		return unknown
+	case *bgp.LsNLRI:
+		return n.IsUnknown()
+	}
+	return false
+}
+
 func (p *ProcessMessage) mpreachNlri2Path() []Path {
 	updateMsg := p.innerMessage.Body.(*bgp.BGPUpdate)
 	pathAttributes := updateMsg.PathAttributes
@@ -78,6 +93,9 @@ func (p *ProcessMessage) mpreachNlri2Path() []Path {
 	for _, mp := range attrList {
 		nlri_info := mp.Value
 		for _, nlri := range nlri_info {
+			if isUnknownNlri(nlri) {
+				continue
+			}
 			path := CreatePath(p.fromPeer, nlri, pathAttributes, false)
 			pathList = append(pathList, path)
 		}
@@ -103,6 +121,9 @@ func (p *ProcessMessage) mpunreachNlri2Path() []Path {
 		nlri_info := mp.Value
 
 		for _, nlri := range nlri_info {
+			if isUnknownNlri(nlri) {
+				continue
+			}
 			path := CreatePath(p.fromPeer, nlri, pathAttributes, true)
 			pathList = append(pathList, path)
 		}
@@ -120,8 +141,12 @@ func (p *ProcessMessage) ToPathList() []Path {
 }
 
 type TableManager struct {
-	Tables   map[bgp.RouteFamily]Table
-	localAsn uint32
+	Tables           map[bgp.RouteFamily]Table
+	localAsn         uint32
+	selection        configuration.RouteSelectionOptionsType
+	multiPath        configuration.UseMultiplePathsType
+	multiPathUpdates []Destination
+	nexthops         *NexthopTracker
 }
 
 func NewTableManager() *TableManager {
@@ -129,9 +154,47 @@ func NewTableManager() *TableManager {
 	t.Tables = make(map[bgp.RouteFamily]Table)
 	t.Tables[bgp.RF_IPv4_UC] = NewIPv4Table(0)
 	t.Tables[bgp.RF_IPv6_UC] = NewIPv6Table(0)
+	t.Tables[bgp.RF_IPv4_MC] = NewMulticastTable(0, bgp.RF_IPv4_MC)
+	t.Tables[bgp.RF_IPv6_MC] = NewMulticastTable(0, bgp.RF_IPv6_MC)
+	t.Tables[bgp.RF_IPv4_VPN] = NewIPv4VPNTable(0)
+	t.Tables[bgp.RF_IPv6_VPN] = NewIPv6VPNTable(0)
+	t.Tables[bgp.RF_RTC_UC] = NewRouteTargetTable(0)
+	t.Tables[bgp.RF_IPv4_MPLS] = NewIPv4MPLSTable(0)
+	t.Tables[bgp.RF_IPv6_MPLS] = NewIPv6MPLSTable(0)
+	t.Tables[bgp.RF_VPLS] = NewVPLSTable(0)
+	t.Tables[bgp.RF_EVPN] = NewEVPNTable(0)
+	t.Tables[bgp.RF_FS_IPv4_UC] = NewFlowSpecTable(0, bgp.RF_FS_IPv4_UC)
+	t.Tables[bgp.RF_FS_IPv6_UC] = NewFlowSpecTable(0, bgp.RF_FS_IPv6_UC)
+	t.Tables[bgp.RF_LS] = NewLsTable(0)
 	return t
 }
 
+func (manager *TableManager) SetLocalAsn(localAsn uint32) {
+	manager.localAsn = localAsn
+}
+
+func (manager *TableManager) SetRouteSelectionOptions(options configuration.RouteSelectionOptionsType) {
+	manager.selection = options
+}
+
+func (manager *TableManager) SetUseMultiplePaths(config configuration.UseMultiplePathsType) {
+	manager.multiPath = config
+}
+
+// SetNexthopResolver enables next hop tracking, paths whose next hop
+// can't be resolved lose the best path selection.
+func (manager *TableManager) SetNexthopResolver(resolver NexthopResolver) {
+	manager.nexthops = NewNexthopTracker(resolver)
+}
+
+// GetMultiPathUpdates returns the destinations whose multipath set has
+// changed since the last call.
+func (manager *TableManager) GetMultiPathUpdates() []Destination {
+	updates := manager.multiPathUpdates
+	manager.multiPathUpdates = nil
+	return updates
+}
+
 func (manager *TableManager) calculate(destinationList []Destination) ([]Path, []Path, error) {
 	bestPaths := make([]Path, 0)
 	lostPaths := make([]Path, 0)
@@ -139,7 +202,7 @@ func (manager *TableManager) calculate(destinationList []Destination) ([]Path, [
 	for _, destination := range destinationList {
 		// compute best path
 		log.Debugf("new destination path: %v", destination.String())
-		newBestPath, reason, err := destination.Calculate(manager.localAsn)
+		newBestPath, reason, err := destination.Calculate(manager.localAsn, manager.selection, manager.nexthops)
 
 		log.Debugf("new best path: %v, reason=%v", newBestPath, reason)
 		if err != nil {
@@ -147,9 +210,23 @@ func (manager *TableManager) calculate(destinationList []Destination) ([]Path, [
 			continue
 		}
 
+		// a best path with an unreachable next hop is inactive, it is
+		// only advertised if advertise-inactive-routes is set
+		inactive := newBestPath != nil && !manager.nexthops.isReachable(newBestPath)
+		if inactive && !manager.selection.AdvertiseInactiveRoutes {
+			log.Debugf("best path is inactive: %v", newBestPath)
+			newBestPath = nil
+		} else {
+			inactive = false
+		}
+
 		destination.setBestPathReason(reason)
 		currentBestPath := destination.GetBestPath()
 
+		if destination.calculateMultiPath(newBestPath, manager.localAsn, manager.selection, manager.nexthops, manager.multiPath) {
+			manager.multiPathUpdates = append(manager.multiPathUpdates, destination)
+		}
+
 		if newBestPath != nil && currentBestPath == newBestPath {
 			// best path is not changed
 			log.Debug("best path is not changed")
@@ -158,13 +235,13 @@ func (manager *TableManager) calculate(destinationList []Destination) ([]Path, [
 
 		if newBestPath == nil {
 			log.Debug("best path is nil")
-			if len(destination.getKnownPathList()) == 0 {
+			if len(destination.getKnownPathList()) == 0 || inactive {
 				// create withdraw path
 				if currentBestPath != nil {
 					log.Debug("best path is lost")
 					p := destination.GetBestPath()
 					destination.setOldBestPath(p)
-					lostPaths = append(lostPaths, p.clone(true))
+					lostPaths = append(lostPaths, p.Clone(true))
 				}
 				destination.setBestPath(nil)
 			} else {
@@ -190,6 +267,54 @@ func (manager *TableManager) calculate(destinationList []Destination) ([]Path, [
 	return bestPaths, lostPaths, nil
 }
 
+// RefreshNexthops resolves the tracked next hops again and recomputes the
+// best path of the destinations whose next hop resolution has changed.
+func (manager *TableManager) RefreshNexthops() ([]Path, []Path, error) {
+	if manager.nexthops == nil {
+		return []Path{}, []Path{}, nil
+	}
+	used := make(map[string]net.IP)
+	users := make(map[string][]Destination)
+	for _, t := range manager.Tables {
+		for _, dest := range t.GetDestinations() {
+			for _, path := range dest.getKnownPathList() {
+				nexthop := path.GetNexthop()
+				if nexthop == nil {
+					continue
+				}
+				key := nexthop.String()
+				used[key] = nexthop
+				users[key] = append(users[key], dest)
+			}
+		}
+	}
+	destinationList := make([]Destination, 0)
+	seen := make(map[Destination]bool)
+	for _, key := range manager.nexthops.refresh(used) {
+		for _, dest := range users[key] {
+			if !seen[dest] {
+				seen[dest] = true
+				destinationList = append(destinationList, dest)
+			}
+		}
+	}
+	return manager.calculate(destinationList)
+}
+
+func (manager *TableManager) GetBestPathList(rf bgp.RouteFamily) []Path {
+	pathList := make([]Path, 0)
+	t, found := manager.Tables[rf]
+	if !found {
+		return pathList
+	}
+	for _, dest := range t.GetDestinations() {
+		if p := dest.GetBestPath(); p != nil {
+			pathList = append(pathList, p)
+		}
+	}
+	return pathList
+}
+
 func (manager *TableManager) DeletePathsforPeer(peerInfo *PeerInfo) ([]Path, []Path, error) {
 	destinationList := manager.Tables[peerInfo.RF].DeleteDestByPeer(peerInfo)
 	return manager.calculate(destinationList)
@@ -227,6 +352,7 @@ func (manager *TableManager) ProcessUpdate(fromPeer *PeerInfo, message *bgp.BGPM
 type AdjRib struct {
 	adjRibIn  map[bgp.RouteFamily]map[string]*ReceivedRoute
 	adjRibOut map[bgp.RouteFamily]map[string]*ReceivedRoute
+	damping   *Damping
 }
 
 func NewAdjRib() *AdjRib {
@@ -234,10 +360,10 @@ func NewAdjRib() *AdjRib {
 		adjRibIn:  make(map[bgp.RouteFamily]map[string]*ReceivedRoute),
 		adjRibOut: make(map[bgp.RouteFamily]map[string]*ReceivedRoute),
 	}
-	r.adjRibIn[bgp.RF_IPv4_UC] = make(map[string]*ReceivedRoute)
-	r.adjRibIn[bgp.RF_IPv6_UC] = make(map[string]*ReceivedRoute)
-	r.adjRibOut[bgp.RF_IPv4_UC] = make(map[string]*ReceivedRoute)
-	r.adjRibOut[bgp.RF_IPv6_UC] = make(map[string]*ReceivedRoute)
+	for _, rf := range []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC, bgp.RF_IPv4_MC, bgp.RF_IPv6_MC, bgp.RF_IPv4_VPN, bgp.RF_IPv6_VPN, bgp.RF_RTC_UC, bgp.RF_IPv4_MPLS, bgp.RF_IPv6_MPLS, bgp.RF_VPLS, bgp.RF_EVPN, bgp.RF_FS_IPv4_UC, bgp.RF_FS_IPv6_UC, bgp.RF_LS} {
+		r.adjRibIn[rf] = make(map[string]*ReceivedRoute)
+		r.adjRibOut[rf] = make(map[string]*ReceivedRoute)
+	}
 	return r
 }
 
@@ -281,6 +407,82 @@ func (adj *AdjRib) GetOutPathList(rf bgp.RouteFamily) []Path {
 	return adj.getPathList(adj.adjRibOut[rf])
 }
 
+// IsAdvertised returns true if a path for the prefix of path has been
+// sent to the neighbor.
+func (adj *AdjRib) IsAdvertised(path Path) bool {
+	_, found := adj.adjRibOut[path.GetRouteFamily()][path.GetPrefix()]
+	return found
+}
+
+func (adj *AdjRib) EnableDamping(config DampingConfig) {
+	adj.damping = NewDamping(config)
+}
+
+// Dampen charges the route flap penalties for pathList and returns the
+// paths that may go to the Loc-RIB. It has to be called before UpdateIn.
+func (adj *AdjRib) Dampen(pathList []Path) []Path {
+	if adj.damping == nil {
+		return pathList
+	}
+	now := time.Now()
+	newPathList := make([]Path, 0, len(pathList))
+	for _, path := range pathList {
+		old := adj.adjRibIn[path.GetRouteFamily()][path.GetPrefix()]
+		if p := adj.damping.update(old, path, now); p != nil {
+			newPathList = append(newPathList, p)
+		}
+	}
+	return newPathList
+}
+
+// FilterDampened drops the paths of suppressed prefixes from pathList.
+func (adj *AdjRib) FilterDampened(pathList []Path) []Path {
+	if adj.damping == nil {
+		return pathList
+	}
+	newPathList := make([]Path, 0, len(pathList))
+	for _, path := range pathList {
+		if !adj.damping.isSuppressed(path.GetRouteFamily(), path.GetPrefix()) {
+			newPathList = append(newPathList, path)
+		}
+	}
+	return newPathList
+}
+
+func (adj *AdjRib) reusedPathList(rf bgp.RouteFamily, prefixes []string) []Path {
+	pathList := make([]Path, 0, len(prefixes))
+	for _, key := range prefixes {
+		if rr, found := adj.adjRibIn[rf][key]; found {
+			pathList = append(pathList, rr.path)
+		}
+	}
+	return pathList
+}
+
+// ReuseDampened returns the paths whose suppression has ended.
+func (adj *AdjRib) ReuseDampened(rf bgp.RouteFamily) []Path {
+	if adj.damping == nil {
+		return []Path{}
+	}
+	return adj.reusedPathList(rf, adj.damping.reuse(rf, time.Now()))
+}
+
+// ClearDampened resets the damping state of prefix, or of all prefixes
+// when it is empty, and returns the paths that are no longer suppressed.
+func (adj *AdjRib) ClearDampened(rf bgp.RouteFamily, prefix string) []Path {
+	if adj.damping == nil {
+		return []Path{}
+	}
+	return adj.reusedPathList(rf, adj.damping.clear(rf, prefix))
+}
+
+func (adj *AdjRib) GetDampenedList(rf bgp.RouteFamily) []*DampingEntry {
+	if adj.damping == nil {
+		return []*DampingEntry{}
+	}
+	return adj.damping.entryList(rf, time.Now())
+}
+
 func (adj *AdjRib) GetInCount(rf bgp.RouteFamily) int {
 	return len(adj.adjRibIn[rf])
 }
@@ -19,6 +19,7 @@ import (
 	_ "fmt"
 	log "github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/Sirupsen/logrus"
 	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	"github.com/gopher-net/gopher-net/configuration"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
 	"net"
 	"os"
@@ -800,6 +801,8 @@ func TestProcessBGPUpdate_4_select_low_origin_ipv6(t *testing.T) {
 func TestProcessBGPUpdate_5_select_low_med_ipv4(t *testing.T) {
 
 	tm := NewTableManager()
+	// the paths come from different neighbor ASes
+	tm.selection.AlwaysCompareMed = true
 	var err error
 
 	// low origin message
@@ -884,6 +887,8 @@ func TestProcessBGPUpdate_5_select_low_med_ipv4(t *testing.T) {
 func TestProcessBGPUpdate_5_select_low_med_ipv6(t *testing.T) {
 
 	tm := NewTableManager()
+	// the paths come from different neighbor ASes
+	tm.selection.AlwaysCompareMed = true
 	var err error
 
 	origin1 := bgp.NewPathAttributeOrigin(0)
@@ -2184,6 +2189,120 @@ func TestProcessBGPUpdate_multiple_nlri_ipv6(t *testing.T) {
 
 }
 
+// test: equal cost eBGP paths from the same neighbor AS
+func TestProcessBGPUpdate_multipath_ebgp_ipv4(t *testing.T) {
+
+	tm := NewTableManager()
+	tm.localAsn = uint32(65500)
+	tm.multiPath.Ebgp.MaximumPaths = 2
+
+	_, _, err := tm.ProcessUpdate(peerR1(), update_multipath([]uint32{65000, 65200}, "192.168.50.1"))
+	assert.NoError(t, err)
+	dests := tm.GetMultiPathUpdates()
+	assert.Equal(t, 1, len(dests))
+	assert.Equal(t, 1, len(dests[0].GetMultiPathList()))
+
+	pList, _, err := tm.ProcessUpdate(peerR3(), update_multipath([]uint32{65000, 65200}, "192.168.100.1"))
+	assert.NoError(t, err)
+	// the best path does not change but the multipath set does
+	assert.Equal(t, 0, len(pList))
+	dests = tm.GetMultiPathUpdates()
+	assert.Equal(t, 1, len(dests))
+	assert.Equal(t, 2, len(dests[0].GetMultiPathList()))
+	assert.Equal(t, dests[0].GetBestPath(), dests[0].GetMultiPathList()[0])
+
+	// the maximum is honored
+	peer := peerR3()
+	peer.ID = net.ParseIP("10.0.0.4").To4()
+	_, _, err = tm.ProcessUpdate(peer, update_multipath([]uint32{65000, 65200}, "192.168.150.1"))
+	assert.NoError(t, err)
+	dest := tm.Tables[bgp.RF_IPv4_UC].GetDestinations()["10.10.10.0/24"]
+	assert.Equal(t, 2, len(dest.GetMultiPathList()))
+	assert.Equal(t, 0, len(tm.GetMultiPathUpdates()))
+}
+
+// test: eBGP paths with different AS_PATHs need allow-multiple-as
+func TestProcessBGPUpdate_multipath_relax_ipv4(t *testing.T) {
+
+	tm := NewTableManager()
+	tm.localAsn = uint32(65500)
+	tm.multiPath.Ebgp.MaximumPaths = 4
+
+	tm.ProcessUpdate(peerR1(), update_multipath([]uint32{65000, 65200}, "192.168.50.1"))
+	tm.ProcessUpdate(peerR2(), update_multipath([]uint32{65100, 65200}, "192.168.100.1"))
+	dest := tm.Tables[bgp.RF_IPv4_UC].GetDestinations()["10.10.10.0/24"]
+	assert.Equal(t, 1, len(dest.GetMultiPathList()))
+
+	tm = NewTableManager()
+	tm.localAsn = uint32(65500)
+	tm.multiPath.Ebgp.MaximumPaths = 4
+	tm.multiPath.Ebgp.AllowMultipleAs = true
+
+	peer2 := peerR2()
+	peer2.RF = bgp.RF_IPv4_UC
+	tm.ProcessUpdate(peerR1(), update_multipath([]uint32{65000, 65200}, "192.168.50.1"))
+	tm.ProcessUpdate(peer2, update_multipath([]uint32{65100, 65200}, "192.168.100.1"))
+	dest = tm.Tables[bgp.RF_IPv4_UC].GetDestinations()["10.10.10.0/24"]
+	assert.Equal(t, 2, len(dest.GetMultiPathList()))
+
+	// a longer AS_PATH is never equal cost
+	tm.ProcessUpdate(peerR3(), update_multipath([]uint32{65000, 65100, 65200}, "192.168.150.1"))
+	assert.Equal(t, 2, len(dest.GetMultiPathList()))
+
+	// the set shrinks when a member is lost
+	tm.DeletePathsforPeer(peer2)
+	assert.Equal(t, 1, len(dest.GetMultiPathList()))
+}
+
+// test: the AIGP and IGP cost steps of best path selection also apply to
+// the multipath set
+func TestProcessBGPUpdate_multipath_aigp_igp_cost_ipv4(t *testing.T) {
+
+	tm := NewTableManager()
+	tm.localAsn = uint32(65500)
+	tm.multiPath.Ebgp.MaximumPaths = 2
+	tm.SetNexthopResolver(testResolver{"192.168.50.1": 10, "192.168.100.1": 20})
+
+	tm.ProcessUpdate(peerR1(), update_multipath([]uint32{65000, 65200}, "192.168.50.1"))
+	tm.ProcessUpdate(peerR3(), update_multipath([]uint32{65000, 65200}, "192.168.100.1"))
+	dest := tm.Tables[bgp.RF_IPv4_UC].GetDestinations()["10.10.10.0/24"]
+	assert.Equal(t, 1, len(dest.GetMultiPathList()))
+	assert.Equal(t, "192.168.50.1", dest.GetMultiPathList()[0].GetNexthop().String())
+
+	tm = NewTableManager()
+	tm.localAsn = uint32(65500)
+	tm.multiPath.Ebgp.MaximumPaths = 2
+	tm.SetRouteSelectionOptions(configuration.RouteSelectionOptionsType{EnableAigp: true})
+
+	m := update_multipath([]uint32{65000, 65200}, "192.168.50.1")
+	body := m.Body.(*bgp.BGPUpdate)
+	body.PathAttributes = append(body.PathAttributes, bgp.NewPathAttributeAigp(10))
+	tm.ProcessUpdate(peerR1(), m)
+	m = update_multipath([]uint32{65000, 65200}, "192.168.100.1")
+	body = m.Body.(*bgp.BGPUpdate)
+	body.PathAttributes = append(body.PathAttributes, bgp.NewPathAttributeAigp(20))
+	tm.ProcessUpdate(peerR3(), m)
+	dest = tm.Tables[bgp.RF_IPv4_UC].GetDestinations()["10.10.10.0/24"]
+	assert.Equal(t, 1, len(dest.GetMultiPathList()))
+	assert.Equal(t, "192.168.50.1", dest.GetMultiPathList()[0].GetNexthop().String())
+}
+
+func update_multipath(ases []uint32, nexthop string) *bgp.BGPMessage {
+
+	origin := bgp.NewPathAttributeOrigin(0)
+	aspath := createAsPathAttribute(ases)
+	nexthopAttr := bgp.NewPathAttributeNextHop(nexthop)
+	med := bgp.NewPathAttributeMultiExitDisc(0)
+	localpref := bgp.NewPathAttributeLocalPref(100)
+
+	pathAttributes := []bgp.PathAttributeInterface{
+		origin, aspath, nexthopAttr, med, localpref,
+	}
+	nlri := []bgp.NLRInfo{*bgp.NewNLRInfo(24, "10.10.10.0")}
+	withdrawnRoutes := []bgp.WithdrawnRoute{}
+	return bgp.NewBGPUpdateMessage(withdrawnRoutes, pathAttributes, nlri)
+}
+
 func update_fromR1() *bgp.BGPMessage {
 
 	origin := bgp.NewPathAttributeOrigin(0)
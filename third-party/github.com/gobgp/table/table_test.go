@@ -18,6 +18,8 @@ package table
 import (
 	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
 	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"strings"
 	"testing"
 )
 
@@ -193,3 +195,81 @@ func updateMsgT3() *bgp.BGPMessage {
 	withdrawnRoutes := []bgp.WithdrawnRoute{w1}
 	return bgp.NewBGPUpdateMessage(withdrawnRoutes, pathAttributes, nlri)
 }
+
+func flowSpecPath(peer *PeerInfo, s string, actions ...string) Path {
+	l, _ := bgp.ParseFlowSpecComponents(bgp.RF_FS_IPv4_UC, s)
+	nlri := bgp.NewFlowSpecIPv4Unicast(l)
+	ecommunities := make([]bgp.ExtendedCommunityInterface, 0, len(actions))
+	for _, a := range actions {
+		e, _ := bgp.ParseExtendedCommunity(a)
+		ecommunities = append(ecommunities, e)
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute([]uint32{peer.AS}),
+		bgp.NewPathAttributeExtendedCommunities(ecommunities),
+		bgp.NewPathAttributeMpReachNLRI("", []bgp.AddrPrefixInterface{nlri}),
+	}
+	return CreatePath(peer, nlri, pathAttributes, false)
+}
+
+func TestFlowSpecTable(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.1").To4(), Address: net.ParseIP("10.0.0.1"), RF: bgp.RF_FS_IPv4_UC}
+	tm := NewTableManager()
+	tm.ProcessPaths([]Path{
+		flowSpecPath(peer, "protocol ==udp", "rate-limit:0"),
+		flowSpecPath(peer, "destination 10.0.0.0/16", "mark:0"),
+		flowSpecPath(peer, "destination 10.0.0.0/24 destination-port ==53", "rate-limit:125000"),
+	})
+	dests := tm.Tables[bgp.RF_FS_IPv4_UC].(*FlowSpecTable).GetSortedDestinations()
+	assert.Equal(t, 3, len(dests))
+	assert.Equal(t, "destination 10.0.0.0/24 destination-port ==53", dests[0].GetNlri().String())
+	assert.Equal(t, "destination 10.0.0.0/16", dests[1].GetNlri().String())
+	assert.Equal(t, "protocol ==udp", dests[2].GetNlri().String())
+
+	msgs := CreateUpdateMsgFromPaths([]Path{dests[2].GetBestPath()})
+	assert.Equal(t, 1, len(msgs))
+	buf, _ := msgs[0].Serialize()
+	msg, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+	paths := NewProcessMessage(msg, peer).ToPathList()
+	assert.Equal(t, 1, len(paths))
+	assert.Equal(t, "protocol ==udp", paths[0].GetPrefix())
+	assert.Nil(t, paths[0].GetNexthop())
+	_, attr := paths[0].GetPathAttr(bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES)
+	assert.Equal(t, "rate-limit:0", attr.(*bgp.PathAttributeExtendedCommunities).Value[0].String())
+
+	tm.ProcessPaths([]Path{paths[0].Clone(true)})
+	assert.Equal(t, 2, len(tm.Tables[bgp.RF_FS_IPv4_UC].GetDestinations()))
+}
+
+func TestMulticastTable(t *testing.T) {
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.1").To4(), Address: net.ParseIP("10.0.0.1"), RF: bgp.RF_IPv4_MC}
+	nlri := bgp.NewIPMulticastAddrPrefix(16, "10.10.0.0")
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute([]uint32{peer.AS}),
+		bgp.NewPathAttributeMpReachNLRI("10.0.0.1", []bgp.AddrPrefixInterface{nlri}),
+	}
+	msgs := CreateUpdateMsgFromPaths([]Path{CreatePath(peer, nlri, pathAttributes, false)})
+	assert.Equal(t, 1, len(msgs))
+	buf, _ := msgs[0].Serialize()
+	msg, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+
+	// the multicast routes stay out of the unicast table
+	tm := NewTableManager()
+	pList, _, _ := tm.ProcessPaths(NewProcessMessage(msg, peer).ToPathList())
+	assert.Equal(t, 1, len(pList))
+	assert.Equal(t, bgp.RF_IPv4_MC, pList[0].GetRouteFamily())
+	assert.Equal(t, "10.10.0.0/16", pList[0].GetPrefix())
+	assert.Equal(t, "10.0.0.1", pList[0].GetNexthop().String())
+	assert.Equal(t, 1, len(tm.Tables[bgp.RF_IPv4_MC].GetDestinations()))
+	assert.Equal(t, 0, len(tm.Tables[bgp.RF_IPv4_UC].GetDestinations()))
+	j, _ := tm.Tables[bgp.RF_IPv4_MC].MarshalJSON()
+	assert.True(t, strings.Contains(string(j), "10.10.0.0/16"))
+
+	_, wList, _ := tm.ProcessPaths([]Path{pList[0].Clone(true)})
+	assert.Equal(t, 1, len(wList))
+	assert.Equal(t, 0, len(tm.Tables[bgp.RF_IPv4_MC].GetDestinations()))
+}
@@ -0,0 +1,328 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/json"
+	"fmt"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"sort"
+)
+
+const (
+	// the label block of a VPLS instance covers the remote VE IDs from 1
+	// to its size
+	VPLS_BLOCK_OFFSET       = 1
+	VPLS_DEFAULT_BLOCK_SIZE = 8
+)
+
+// status of the pseudowire to a remote site of a VPLS instance
+const (
+	VPLS_SITE_UP              = "up"
+	VPLS_SITE_OUT_OF_RANGE    = "out-of-range"
+	VPLS_SITE_MTU_MISMATCH    = "mtu-mismatch"
+	VPLS_SITE_VE_ID_COLLISION = "ve-id-collision"
+)
+
+// VplsSite is a remote site of a VPLS instance. Its traffic arrives with
+// InLabel and the traffic to it is sent to Nexthop with OutLabel, the
+// labels are zero if the label blocks don't cover the VE IDs.
+type VplsSite struct {
+	VeId     uint16 `json:"ve_id"`
+	Rd       string `json:"rd"`
+	Nexthop  net.IP `json:"nexthop"`
+	Mtu      uint16 `json:"mtu"`
+	InLabel  uint32 `json:"in_label,omitempty"`
+	OutLabel uint32 `json:"out_label,omitempty"`
+	Status   string `json:"status"`
+}
+
+type vplsSites []*VplsSite
+
+func (l vplsSites) Len() int { return len(l) }
+func (l vplsSites) Less(i, j int) bool {
+	return l[i].VeId < l[j].VeId || l[i].VeId == l[j].VeId && l[i].Rd < l[j].Rd
+}
+func (l vplsSites) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// VplsInstance is the local site VeId of a VPLS (RFC 4761). Its label
+// block is advertised with the route distinguisher and the export route
+// targets, the label blocks received with one of the import route
+// targets are the remote sites of the VPLS.
+type VplsInstance struct {
+	Name        string
+	Rd          bgp.RouteDistinguisherInterface
+	ImportRt    []bgp.ExtendedCommunityInterface
+	ExportRt    []bgp.ExtendedCommunityInterface
+	VeId        uint16
+	BlockOffset uint16
+	BlockSize   uint16
+	LabelBase   uint32
+	Mtu         uint16
+	sites       []*VplsSite
+}
+
+func NewVplsInstance(name, rd string, importRt, exportRt []string, veId, blockSize, mtu uint16) (*VplsInstance, error) {
+	r, err := bgp.ParseRouteDistinguisher(rd)
+	if err != nil {
+		return nil, err
+	}
+	i, err := parseRouteTargets(importRt)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseRouteTargets(exportRt)
+	if err != nil {
+		return nil, err
+	}
+	if veId == 0 {
+		return nil, fmt.Errorf("ve id of vpls %s is not specified", name)
+	}
+	if blockSize == 0 {
+		blockSize = VPLS_DEFAULT_BLOCK_SIZE
+	}
+	return &VplsInstance{
+		Name:        name,
+		Rd:          r,
+		ImportRt:    i,
+		ExportRt:    e,
+		VeId:        veId,
+		BlockOffset: VPLS_BLOCK_OFFSET,
+		BlockSize:   blockSize,
+		Mtu:         mtu,
+		sites:       []*VplsSite{},
+	}, nil
+}
+
+func (instance *VplsInstance) nlri() *bgp.VPLSNLRI {
+	return bgp.NewVPLSNLRI(instance.Rd, instance.VeId, instance.BlockOffset, instance.BlockSize, instance.LabelBase)
+}
+
+// path returns the label block of the instance to advertise, nexthop is
+// the address the remote sites send their traffic to.
+func (instance *VplsInstance) path(nexthop net.IP) Path {
+	nlri := instance.nlri()
+	ecommunities := append([]bgp.ExtendedCommunityInterface{}, instance.ExportRt...)
+	ecommunities = append(ecommunities, &bgp.Layer2InfoExtended{
+		EncapType: bgp.LAYER2_ENCAP_TYPE_VPLS,
+		MTU:       instance.Mtu,
+	})
+	pattrs := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeMpReachNLRI(nexthop.String(), []bgp.AddrPrefixInterface{nlri}),
+		bgp.NewPathAttributeExtendedCommunities(ecommunities),
+	}
+	return CreatePath(nil, nlri, pattrs, false)
+}
+
+// layer2Mtu returns the MTU of the Layer2 Info extended community of
+// path, zero if it has none.
+func layer2Mtu(path Path) uint16 {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES)
+	if attr == nil {
+		return 0
+	}
+	for _, e := range attr.(*bgp.PathAttributeExtendedCommunities).Value {
+		if l2, y := e.(*bgp.Layer2InfoExtended); y {
+			return l2.MTU
+		}
+	}
+	return 0
+}
+
+// blockLabel returns the label of veId in the label block, zero if the
+// block doesn't cover it.
+func blockLabel(labelBase uint32, blockOffset, blockSize, veId uint16) uint32 {
+	if veId < blockOffset || uint32(veId) >= uint32(blockOffset)+uint32(blockSize) {
+		return 0
+	}
+	return labelBase + uint32(veId-blockOffset)
+}
+
+// site returns the remote site advertising the label block of path.
+func (instance *VplsInstance) site(path Path) *VplsSite {
+	nlri := path.GetNlri().(*bgp.VPLSNLRI)
+	s := &VplsSite{
+		VeId:     nlri.VEID,
+		Rd:       nlri.RD.String(),
+		Nexthop:  path.GetNexthop(),
+		Mtu:      layer2Mtu(path),
+		InLabel:  blockLabel(instance.LabelBase, instance.BlockOffset, instance.BlockSize, nlri.VEID),
+		OutLabel: blockLabel(nlri.LabelBase, nlri.BlockOffset, nlri.BlockSize, instance.VeId),
+	}
+	switch {
+	case s.VeId == instance.VeId:
+		s.Status = VPLS_SITE_VE_ID_COLLISION
+		s.InLabel, s.OutLabel = 0, 0
+	case s.InLabel == 0 || s.OutLabel == 0:
+		s.Status = VPLS_SITE_OUT_OF_RANGE
+	case s.Mtu != instance.Mtu:
+		s.Status = VPLS_SITE_MTU_MISMATCH
+	default:
+		s.Status = VPLS_SITE_UP
+	}
+	return s
+}
+
+// updateSites rebuilds the remote sites from the best VPLS paths. A site
+// may advertise several label blocks, the one covering the local VE ID
+// is used.
+func (instance *VplsInstance) updateSites(pathList []Path) {
+	sites := make(map[string]*VplsSite)
+	for _, path := range pathList {
+		nlri, ok := path.GetNlri().(*bgp.VPLSNLRI)
+		if !ok || !hasRouteTarget(path, instance.ImportRt) {
+			continue
+		}
+		if nlri.RD.String() == instance.Rd.String() && nlri.VEID == instance.VeId {
+			// the label block of the instance itself
+			continue
+		}
+		s := instance.site(path)
+		key := fmt.Sprintf("%s:%d", s.Rd, s.VeId)
+		if old, found := sites[key]; !found || old.OutLabel == 0 {
+			sites[key] = s
+		}
+	}
+	instance.sites = make([]*VplsSite, 0, len(sites))
+	for _, s := range sites {
+		instance.sites = append(instance.sites, s)
+	}
+	sort.Sort(vplsSites(instance.sites))
+}
+
+// GetSites returns the remote sites sorted by VE ID.
+func (instance *VplsInstance) GetSites() []*VplsSite {
+	return instance.sites
+}
+
+func (instance *VplsInstance) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name        string      `json:"name"`
+		Rd          string      `json:"rd"`
+		ImportRt    []string    `json:"import_rt"`
+		ExportRt    []string    `json:"export_rt"`
+		VeId        uint16      `json:"ve_id"`
+		BlockOffset uint16      `json:"block_offset"`
+		BlockSize   uint16      `json:"block_size"`
+		LabelBase   uint32      `json:"label_base"`
+		Mtu         uint16      `json:"mtu"`
+		Sites       []*VplsSite `json:"sites"`
+	}{
+		Name:        instance.Name,
+		Rd:          instance.Rd.String(),
+		ImportRt:    extendedCommunityStrings(instance.ImportRt),
+		ExportRt:    extendedCommunityStrings(instance.ExportRt),
+		VeId:        instance.VeId,
+		BlockOffset: instance.BlockOffset,
+		BlockSize:   instance.BlockSize,
+		LabelBase:   instance.LabelBase,
+		Mtu:         instance.Mtu,
+		Sites:       instance.sites,
+	})
+}
+
+// VplsManager holds the VPLS instances of the daemon, allocates their
+// label blocks and keeps the Loc-RIB of the VPLS routes received from
+// the neighbors.
+type VplsManager struct {
+	Instances map[string]*VplsInstance
+	rib       *TableManager
+	routerId  net.IP
+	labels    *LabelPool
+}
+
+func NewVplsManager(localAsn uint32, routerId net.IP, labels *LabelPool) *VplsManager {
+	rib := NewTableManager()
+	rib.SetLocalAsn(localAsn)
+	return &VplsManager{
+		Instances: make(map[string]*VplsInstance),
+		rib:       rib,
+		routerId:  routerId,
+		labels:    labels,
+	}
+}
+
+func (manager *VplsManager) AddInstance(name, rd string, importRt, exportRt []string, veId, blockSize, mtu uint16) (*VplsInstance, error) {
+	if _, found := manager.Instances[name]; found {
+		return nil, fmt.Errorf("vpls %s already exists", name)
+	}
+	instance, err := NewVplsInstance(name, rd, importRt, exportRt, veId, blockSize, mtu)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range manager.Instances {
+		if i.Rd.String() == instance.Rd.String() {
+			return nil, fmt.Errorf("route distinguisher %s of vpls %s is used by vpls %s", rd, name, i.Name)
+		}
+	}
+	instance.LabelBase, err = manager.labels.AllocateBlock(instance.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	instance.updateSites(manager.rib.GetBestPathList(bgp.RF_VPLS))
+	manager.Instances[name] = instance
+	return instance, nil
+}
+
+// GetPathList returns the label blocks of the instances, with the router
+// ID as next hop.
+func (manager *VplsManager) GetPathList() []Path {
+	pathList := make([]Path, 0, len(manager.Instances))
+	for _, instance := range manager.Instances {
+		pathList = append(pathList, instance.path(manager.routerId))
+	}
+	return pathList
+}
+
+func (manager *VplsManager) updateSites() {
+	pathList := manager.rib.GetBestPathList(bgp.RF_VPLS)
+	for _, instance := range manager.Instances {
+		instance.updateSites(pathList)
+	}
+}
+
+// ProcessPaths puts the VPLS paths received from the neighbors into the
+// Loc-RIB and updates the remote sites of the instances.
+func (manager *VplsManager) ProcessPaths(pathList []Path) {
+	manager.rib.ProcessPaths(pathList)
+	manager.updateSites()
+}
+
+func (manager *VplsManager) DeletePathsforPeer(peerInfo *PeerInfo) {
+	manager.rib.DeletePathsforPeer(peerInfo)
+	manager.updateSites()
+}
+
+// GetTable returns the Loc-RIB of the VPLS routes.
+func (manager *VplsManager) GetTable() Table {
+	return manager.rib.Tables[bgp.RF_VPLS]
+}
+
+func (manager *VplsManager) MarshalJSON() ([]byte, error) {
+	names := make([]string, 0, len(manager.Instances))
+	for name := range manager.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	instances := make([]*VplsInstance, 0, len(names))
+	for _, name := range names {
+		instances = append(instances, manager.Instances[name])
+	}
+	return json.Marshal(instances)
+}
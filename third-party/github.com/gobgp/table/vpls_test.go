@@ -0,0 +1,107 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+// receiveVplsPaths sends the label blocks of the instances of remote to
+// manager through update messages from peer.
+func receiveVplsPaths(t *testing.T, manager *VplsManager, remote *VplsManager, peer *PeerInfo) {
+	for _, m := range CreateUpdateMsgFromPaths(remote.GetPathList()) {
+		buf, _ := m.Serialize()
+		msg, err := bgp.ParseBGPMessage(buf)
+		assert.Nil(t, err)
+		manager.ProcessPaths(NewProcessMessage(msg, peer).ToPathList())
+	}
+}
+
+func TestVplsAddInstance(t *testing.T) {
+	manager := NewVplsManager(65000, net.ParseIP("10.0.0.1").To4(), NewLabelPool())
+	a, err := manager.AddInstance("a", "65000:1", []string{"65000:1"}, []string{"65000:1"}, 1, 0, 1500)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(VPLS_DEFAULT_BLOCK_SIZE), a.BlockSize)
+	assert.Equal(t, uint32(LABEL_MIN), a.LabelBase)
+	b, err := manager.AddInstance("b", "65000:2", []string{"65000:2"}, []string{"65000:2"}, 1, 16, 1500)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(LABEL_MIN+VPLS_DEFAULT_BLOCK_SIZE), b.LabelBase)
+
+	_, err = manager.AddInstance("a", "65000:3", nil, nil, 1, 0, 1500)
+	assert.NotNil(t, err)
+	_, err = manager.AddInstance("c", "65000:1", nil, nil, 1, 0, 1500)
+	assert.NotNil(t, err)
+	_, err = manager.AddInstance("c", "65000:3", nil, nil, 0, 0, 1500)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, len(manager.GetPathList()))
+}
+
+func TestVplsSites(t *testing.T) {
+	pe1 := NewVplsManager(65000, net.ParseIP("10.0.0.1").To4(), NewLabelPool())
+	local, _ := pe1.AddInstance("blue", "10.0.0.1:1", []string{"65000:1"}, []string{"65000:1"}, 1, 0, 1500)
+	peer := &PeerInfo{AS: 65000, ID: net.ParseIP("10.0.0.2").To4(), Address: net.ParseIP("10.0.0.2"), RF: bgp.RF_VPLS}
+
+	pe2 := NewVplsManager(65000, net.ParseIP("10.0.0.2").To4(), NewLabelPool())
+	pe2.labels.AllocateBlock(100)
+	remote, _ := pe2.AddInstance("blue", "10.0.0.2:1", []string{"65000:1"}, []string{"65000:1"}, 2, 0, 1500)
+	// a VPLS the local router doesn't take part in
+	pe2.AddInstance("red", "10.0.0.2:2", []string{"65000:2"}, []string{"65000:2"}, 2, 0, 1500)
+	receiveVplsPaths(t, pe1, pe2, peer)
+	assert.Equal(t, 2, len(pe1.GetTable().GetDestinations()))
+
+	sites := local.GetSites()
+	assert.Equal(t, 1, len(sites))
+	assert.Equal(t, uint16(2), sites[0].VeId)
+	assert.Equal(t, "10.0.0.2", sites[0].Nexthop.String())
+	assert.Equal(t, local.LabelBase+1, sites[0].InLabel)
+	assert.Equal(t, remote.LabelBase, sites[0].OutLabel)
+	assert.Equal(t, VPLS_SITE_UP, sites[0].Status)
+
+	// the sites must agree on the MTU
+	remote.Mtu = 9000
+	receiveVplsPaths(t, pe1, pe2, peer)
+	assert.Equal(t, VPLS_SITE_MTU_MISMATCH, local.GetSites()[0].Status)
+
+	pe1.DeletePathsforPeer(peer)
+	assert.Equal(t, 0, len(local.GetSites()))
+
+	// the label block of the remote site doesn't cover the local VE ID
+	remote.Mtu = 1500
+	remote.BlockOffset = 9
+	receiveVplsPaths(t, pe1, pe2, peer)
+	sites = local.GetSites()
+	assert.Equal(t, 1, len(sites))
+	assert.Equal(t, VPLS_SITE_OUT_OF_RANGE, sites[0].Status)
+	assert.Equal(t, uint32(0), sites[0].OutLabel)
+
+	// the site uses the label block covering the local VE ID
+	remote.BlockOffset = 1
+	receiveVplsPaths(t, pe1, pe2, peer)
+	sites = local.GetSites()
+	assert.Equal(t, 1, len(sites))
+	assert.Equal(t, VPLS_SITE_UP, sites[0].Status)
+	pe1.DeletePathsforPeer(peer)
+
+	// two sites can't have the same VE ID
+	remote.VeId = 1
+	receiveVplsPaths(t, pe1, pe2, peer)
+	sites = local.GetSites()
+	assert.Equal(t, 1, len(sites))
+	assert.Equal(t, VPLS_SITE_VE_ID_COLLISION, sites[0].Status)
+}
@@ -0,0 +1,383 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"encoding/json"
+	"fmt"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Vrf is a VPN routing and forwarding instance, it keeps the unicast
+// routes of one tenant apart from the others. VPN routes carrying one of
+// the import route targets are leaked into the VRF, and the routes
+// originated in the VRF are exported to the VPN tables with its route
+// distinguisher, label and export route targets.
+type Vrf struct {
+	Name     string
+	Rd       bgp.RouteDistinguisherInterface
+	ImportRt []bgp.ExtendedCommunityInterface
+	ExportRt []bgp.ExtendedCommunityInterface
+	Label    uint32
+	rib      *TableManager
+	// locally originated paths by prefix
+	local map[string]Path
+}
+
+// parseRouteTargets accepts route targets with or without the "rt:"
+// prefix of bgp.ParseExtendedCommunity.
+func parseRouteTargets(rts []string) ([]bgp.ExtendedCommunityInterface, error) {
+	l := make([]bgp.ExtendedCommunityInterface, 0, len(rts))
+	for _, s := range rts {
+		rt, err := bgp.ParseExtendedCommunity("rt:" + strings.TrimPrefix(s, "rt:"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid route target %s", s)
+		}
+		l = append(l, rt)
+	}
+	return l, nil
+}
+
+func NewVrf(name, rd string, importRt, exportRt []string, label uint32) (*Vrf, error) {
+	r, err := bgp.ParseRouteDistinguisher(rd)
+	if err != nil {
+		return nil, err
+	}
+	i, err := parseRouteTargets(importRt)
+	if err != nil {
+		return nil, err
+	}
+	e, err := parseRouteTargets(exportRt)
+	if err != nil {
+		return nil, err
+	}
+	return &Vrf{
+		Name:     name,
+		Rd:       r,
+		ImportRt: i,
+		ExportRt: e,
+		Label:    label,
+		rib:      NewTableManager(),
+		local:    make(map[string]Path),
+	}, nil
+}
+
+func hasExtendedCommunity(l []bgp.ExtendedCommunityInterface, e bgp.ExtendedCommunityInterface) bool {
+	for _, c := range l {
+		if c.String() == e.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRouteTarget returns true if path carries one of the route targets
+// of rts.
+func hasRouteTarget(path Path, rts []bgp.ExtendedCommunityInterface) bool {
+	_, attr := path.GetPathAttr(bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES)
+	if attr == nil {
+		return false
+	}
+	for _, e := range attr.(*bgp.PathAttributeExtendedCommunities).Value {
+		if hasExtendedCommunity(rts, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (vrf *Vrf) canImport(path Path) bool {
+	return hasRouteTarget(path, vrf.ImportRt)
+}
+
+// attributes of path without the ones carrying the NLRI and the next hop
+func routeAttrs(path Path) []bgp.PathAttributeInterface {
+	pattrs := make([]bgp.PathAttributeInterface, 0, len(path.GetPathAttrs())+1)
+	for _, a := range path.GetPathAttrs() {
+		switch a.(type) {
+		case *bgp.PathAttributeNextHop, *bgp.PathAttributeMpReachNLRI, *bgp.PathAttributeMpUnreachNLRI:
+			continue
+		}
+		pattrs = append(pattrs, a)
+	}
+	return pattrs
+}
+
+// toVrfPath strips the route distinguisher and the label from the VPN
+// path, the result is withdrawn if withdraw is set.
+func (vrf *Vrf) toVrfPath(path Path, withdraw bool) Path {
+	var vpn *bgp.LabelledVPNIPAddrPrefix
+	switch n := path.GetNlri().(type) {
+	case *bgp.LabelledVPNIPAddrPrefix:
+		vpn = n
+	case *bgp.LabelledVPNIPv6AddrPrefix:
+		vpn = &n.LabelledVPNIPAddrPrefix
+	}
+	pattrs := routeAttrs(path)
+	var nlri bgp.AddrPrefixInterface
+	if path.GetRouteFamily() == bgp.RF_IPv4_VPN {
+		prefix := bgp.NewIPAddrPrefix(vpn.IPPrefixLen(), vpn.Prefix.String())
+		if withdraw {
+			nlri = &bgp.WithdrawnRoute{IPAddrPrefix: *prefix}
+		} else {
+			nlri = &bgp.NLRInfo{IPAddrPrefix: *prefix}
+			pattrs = append(pattrs, bgp.NewPathAttributeNextHop(path.GetNexthop().String()))
+		}
+	} else {
+		nlri = bgp.NewIPv6AddrPrefix(vpn.IPPrefixLen(), vpn.Prefix.String())
+		if !withdraw {
+			pattrs = append(pattrs, bgp.NewPathAttributeMpReachNLRI(path.GetNexthop().String(), []bgp.AddrPrefixInterface{nlri}))
+		}
+	}
+	return CreatePath(path.getSource(), nlri, pattrs, withdraw)
+}
+
+// toVpnPath adds the route distinguisher and the label of the VRF to the
+// prefix of path and its export route targets to the attributes.
+func (vrf *Vrf) toVpnPath(path Path) Path {
+	label := *bgp.NewLabel(vrf.Label)
+	var nlri bgp.AddrPrefixInterface
+	switch n := path.GetNlri().(type) {
+	case *bgp.NLRInfo:
+		nlri = bgp.NewLabelledVPNIPAddrPrefix(n.Length, n.Prefix.String(), label, vrf.Rd)
+	case *bgp.WithdrawnRoute:
+		nlri = bgp.NewLabelledVPNIPAddrPrefix(n.Length, n.Prefix.String(), label, vrf.Rd)
+	case *bgp.IPv6AddrPrefix:
+		nlri = bgp.NewLabelledVPNIPv6AddrPrefix(n.Length, n.Prefix.String(), label, vrf.Rd)
+	}
+	if path.IsWithdraw() {
+		return CreatePath(path.getSource(), nlri, nil, true)
+	}
+	pattrs := make([]bgp.PathAttributeInterface, 0, len(path.GetPathAttrs())+2)
+	ecommunities := []bgp.ExtendedCommunityInterface{}
+	for _, a := range routeAttrs(path) {
+		if e, y := a.(*bgp.PathAttributeExtendedCommunities); y {
+			ecommunities = append(ecommunities, e.Value...)
+		} else {
+			pattrs = append(pattrs, a)
+		}
+	}
+	for _, rt := range vrf.ExportRt {
+		if !hasExtendedCommunity(ecommunities, rt) {
+			ecommunities = append(ecommunities, rt)
+		}
+	}
+	if len(ecommunities) > 0 {
+		pattrs = append(pattrs, bgp.NewPathAttributeExtendedCommunities(ecommunities))
+	}
+	pattrs = append(pattrs, bgp.NewPathAttributeMpReachNLRI(path.GetNexthop().String(), []bgp.AddrPrefixInterface{nlri}))
+	return CreatePath(path.getSource(), nlri, pattrs, false)
+}
+
+// Import leaks the VPN paths of pathList carrying one of the import
+// route targets into the VRF. The other paths are imported as
+// withdrawals so that the VRF drops them if their route targets have
+// changed, withdrawals don't carry route targets at all.
+func (vrf *Vrf) Import(pathList []Path) ([]Path, []Path, error) {
+	vrfPathList := make([]Path, 0, len(pathList))
+	for _, path := range pathList {
+		rf := path.GetRouteFamily()
+		if rf != bgp.RF_IPv4_VPN && rf != bgp.RF_IPv6_VPN {
+			continue
+		}
+		vrfPathList = append(vrfPathList, vrf.toVrfPath(path, path.IsWithdraw() || !vrf.canImport(path)))
+	}
+	return vrf.rib.ProcessPaths(vrfPathList)
+}
+
+// AddLocalPath puts a locally originated unicast path, or its
+// withdrawal, into the VRF and returns the VPN path to export.
+func (vrf *Vrf) AddLocalPath(path Path) Path {
+	if path.IsWithdraw() {
+		delete(vrf.local, path.GetPrefix())
+	} else {
+		vrf.local[path.GetPrefix()] = path
+	}
+	vrf.rib.ProcessPaths([]Path{path})
+	return vrf.toVpnPath(path)
+}
+
+// GetVpnPathList returns the VPN paths of route family rf exported by
+// the VRF.
+func (vrf *Vrf) GetVpnPathList(rf bgp.RouteFamily) []Path {
+	pathList := make([]Path, 0)
+	for _, path := range vrf.local {
+		vpn := vrf.toVpnPath(path)
+		if vpn.GetRouteFamily() == rf {
+			pathList = append(pathList, vpn)
+		}
+	}
+	return pathList
+}
+
+func (vrf *Vrf) DeletePathsforPeer(peerInfo *PeerInfo) ([]Path, []Path, error) {
+	destinationList := make([]Destination, 0)
+	for _, rf := range []bgp.RouteFamily{bgp.RF_IPv4_UC, bgp.RF_IPv6_UC} {
+		destinationList = append(destinationList, vrf.rib.Tables[rf].DeleteDestByPeer(peerInfo)...)
+	}
+	return vrf.rib.calculate(destinationList)
+}
+
+func (vrf *Vrf) GetBestPathList(rf bgp.RouteFamily) []Path {
+	return vrf.rib.GetBestPathList(rf)
+}
+
+func extendedCommunityStrings(l []bgp.ExtendedCommunityInterface) []string {
+	s := make([]string, 0, len(l))
+	for _, e := range l {
+		s = append(s, e.String())
+	}
+	return s
+}
+
+func (vrf *Vrf) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name     string
+		Rd       string
+		ImportRt []string
+		ExportRt []string
+		Label    uint32
+		IPv4     Table
+		IPv6     Table
+	}{
+		Name:     vrf.Name,
+		Rd:       vrf.Rd.String(),
+		ImportRt: extendedCommunityStrings(vrf.ImportRt),
+		ExportRt: extendedCommunityStrings(vrf.ExportRt),
+		Label:    vrf.Label,
+		IPv4:     vrf.rib.Tables[bgp.RF_IPv4_UC],
+		IPv6:     vrf.rib.Tables[bgp.RF_IPv6_UC],
+	})
+}
+
+// VrfManager holds the VRFs of the daemon and allocates their labels.
+type VrfManager struct {
+	Vrfs     map[string]*Vrf
+	localAsn uint32
+	routerId net.IP
+	labels   *LabelPool
+}
+
+func NewVrfManager(localAsn uint32, routerId net.IP, labels *LabelPool) *VrfManager {
+	return &VrfManager{
+		Vrfs:     make(map[string]*Vrf),
+		localAsn: localAsn,
+		routerId: routerId,
+		labels:   labels,
+	}
+}
+
+func (manager *VrfManager) AddVrf(name, rd string, importRt, exportRt []string) (*Vrf, error) {
+	if _, found := manager.Vrfs[name]; found {
+		return nil, fmt.Errorf("vrf %s already exists", name)
+	}
+	vrf, err := NewVrf(name, rd, importRt, exportRt, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range manager.Vrfs {
+		if v.Rd.String() == vrf.Rd.String() {
+			return nil, fmt.Errorf("route distinguisher %s of vrf %s is used by vrf %s", rd, name, v.Name)
+		}
+	}
+	vrf.Label, err = manager.labels.Allocate()
+	if err != nil {
+		return nil, err
+	}
+	vrf.rib.SetLocalAsn(manager.localAsn)
+	manager.Vrfs[name] = vrf
+	return vrf, nil
+}
+
+// Import leaks the VPN paths of pathList into the VRFs.
+func (manager *VrfManager) Import(pathList []Path) {
+	for _, vrf := range manager.Vrfs {
+		vrf.Import(pathList)
+	}
+}
+
+// AddLocalPath puts a locally originated unicast path into the VRF
+// name, leaks it into the other VRFs importing one of its export route
+// targets and returns the VPN path to advertise to the neighbors.
+func (manager *VrfManager) AddLocalPath(name string, path Path) (Path, error) {
+	vrf, found := manager.Vrfs[name]
+	if !found {
+		return nil, fmt.Errorf("vrf %s does not exist", name)
+	}
+	vpn := vrf.AddLocalPath(path)
+	for _, v := range manager.Vrfs {
+		if v != vrf {
+			v.Import([]Path{vpn})
+		}
+	}
+	return vpn, nil
+}
+
+// GetVpnPathList returns the VPN paths of route family rf exported by
+// all the VRFs.
+func (manager *VrfManager) GetVpnPathList(rf bgp.RouteFamily) []Path {
+	pathList := make([]Path, 0)
+	for _, vrf := range manager.Vrfs {
+		pathList = append(pathList, vrf.GetVpnPathList(rf)...)
+	}
+	return pathList
+}
+
+// GetRtcPathList returns the route target membership paths (RFC 4684)
+// of the route targets imported by the VRFs, with the router ID as next
+// hop.
+func (manager *VrfManager) GetRtcPathList() []Path {
+	pathList := make([]Path, 0)
+	targets := make([]bgp.ExtendedCommunityInterface, 0)
+	for _, vrf := range manager.Vrfs {
+		for _, rt := range vrf.ImportRt {
+			if hasExtendedCommunity(targets, rt) {
+				continue
+			}
+			targets = append(targets, rt)
+			nlri := bgp.NewRouteTargetMembershipNLRI(manager.localAsn, rt)
+			pattrs := []bgp.PathAttributeInterface{
+				bgp.NewPathAttributeOrigin(0),
+				bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+				bgp.NewPathAttributeMpReachNLRI(manager.routerId.String(), []bgp.AddrPrefixInterface{nlri}),
+			}
+			pathList = append(pathList, CreatePath(nil, nlri, pattrs, false))
+		}
+	}
+	return pathList
+}
+
+func (manager *VrfManager) DeletePathsforPeer(peerInfo *PeerInfo) {
+	for _, vrf := range manager.Vrfs {
+		vrf.DeletePathsforPeer(peerInfo)
+	}
+}
+
+func (manager *VrfManager) MarshalJSON() ([]byte, error) {
+	names := make([]string, 0, len(manager.Vrfs))
+	for name := range manager.Vrfs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	vrfs := make([]*Vrf, 0, len(names))
+	for _, name := range names {
+		vrfs = append(vrfs, manager.Vrfs[name])
+	}
+	return json.Marshal(vrfs)
+}
@@ -0,0 +1,186 @@
+// Copyright (C) 2014 Nippon Telegraph and Telephone Corporation.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or
+// implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"github.com/gopher-net/gopher-net/Godeps/_workspace/src/github.com/stretchr/testify/assert"
+	bgp "github.com/gopher-net/gopher-net/third-party/github.com/gobgp/packet"
+	"net"
+	"testing"
+)
+
+func vpnPath(peer *PeerInfo, rd string, prefix string, length uint8, rts []string) Path {
+	r, _ := bgp.ParseRouteDistinguisher(rd)
+	ecommunities := []bgp.ExtendedCommunityInterface{}
+	for _, s := range rts {
+		e, _ := bgp.ParseExtendedCommunity(s)
+		ecommunities = append(ecommunities, e)
+	}
+	var nlri bgp.AddrPrefixInterface
+	nexthop := "192.168.0.1"
+	if net.ParseIP(prefix).To4() != nil {
+		nlri = bgp.NewLabelledVPNIPAddrPrefix(length, prefix, *bgp.NewLabel(100), r)
+	} else {
+		nlri = bgp.NewLabelledVPNIPv6AddrPrefix(length, prefix, *bgp.NewLabel(100), r)
+		nexthop = "2001:db8::1"
+	}
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		createAsPathAttribute([]uint32{65001}),
+		bgp.NewPathAttributeExtendedCommunities(ecommunities),
+		bgp.NewPathAttributeMpReachNLRI(nexthop, []bgp.AddrPrefixInterface{nlri}),
+	}
+	return CreatePath(peer, nlri, pathAttributes, false)
+}
+
+func testVrfManager(t *testing.T) *VrfManager {
+	manager := NewVrfManager(65000, net.ParseIP("10.0.0.1"), NewLabelPool())
+	red, err := manager.AddVrf("red", "65000:100", []string{"65000:100"}, []string{"rt:65000:100"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(LABEL_MIN), red.Label)
+	blue, err := manager.AddVrf("blue", "65000:200", []string{"65000:200"}, []string{"65000:200", "65000:100"})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(LABEL_MIN+1), blue.Label)
+	return manager
+}
+
+func TestVrfAdd(t *testing.T) {
+	manager := testVrfManager(t)
+	_, err := manager.AddVrf("red", "65000:300", nil, nil)
+	assert.NotNil(t, err)
+	_, err = manager.AddVrf("green", "65000:100", nil, nil)
+	assert.NotNil(t, err)
+	_, err = manager.AddVrf("green", "65000", nil, nil)
+	assert.NotNil(t, err)
+	_, err = manager.AddVrf("green", "65000:300", []string{"foo"}, nil)
+	assert.NotNil(t, err)
+	green, err := manager.AddVrf("green", "65000:300", nil, nil)
+	assert.Nil(t, err)
+	// labels of the rejected VRFs are not used up
+	assert.Equal(t, uint32(LABEL_MIN+2), green.Label)
+}
+
+func TestVrfImport(t *testing.T) {
+	manager := testVrfManager(t)
+	red := manager.Vrfs["red"]
+	blue := manager.Vrfs["blue"]
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), RF: bgp.RF_IPv4_VPN}
+
+	path := vpnPath(peer, "65001:1", "10.10.0.0", 24, []string{"rt:65000:100"})
+	manager.Import([]Path{path})
+	pathList := red.GetBestPathList(bgp.RF_IPv4_UC)
+	assert.Equal(t, 1, len(pathList))
+	assert.Equal(t, "10.10.0.0/24", pathList[0].GetPrefix())
+	assert.Equal(t, "192.168.0.1", pathList[0].GetNexthop().String())
+	_, attr := pathList[0].GetPathAttr(bgp.BGP_ATTR_TYPE_MP_REACH_NLRI)
+	assert.Nil(t, attr)
+	assert.Equal(t, 0, len(blue.GetBestPathList(bgp.RF_IPv4_UC)))
+
+	// the route targets have changed, the path leaves the VRF
+	path = vpnPath(peer, "65001:1", "10.10.0.0", 24, []string{"rt:65000:200"})
+	manager.Import([]Path{path})
+	assert.Equal(t, 0, len(red.GetBestPathList(bgp.RF_IPv4_UC)))
+	assert.Equal(t, 1, len(blue.GetBestPathList(bgp.RF_IPv4_UC)))
+
+	manager.Import([]Path{path.Clone(true)})
+	assert.Equal(t, 0, len(blue.GetBestPathList(bgp.RF_IPv4_UC)))
+
+	path = vpnPath(peer, "65001:1", "2001:db8:1::", 64, []string{"rt:65000:100"})
+	manager.Import([]Path{path})
+	pathList = red.GetBestPathList(bgp.RF_IPv6_UC)
+	assert.Equal(t, 1, len(pathList))
+	assert.Equal(t, "2001:db8:1::/64", pathList[0].GetPrefix())
+	assert.Equal(t, "2001:db8::1", pathList[0].GetNexthop().String())
+
+	manager.DeletePathsforPeer(peer)
+	assert.Equal(t, 0, len(red.GetBestPathList(bgp.RF_IPv6_UC)))
+}
+
+func TestVrfExport(t *testing.T) {
+	manager := testVrfManager(t)
+	red := manager.Vrfs["red"]
+	blue := manager.Vrfs["blue"]
+
+	pathAttributes := []bgp.PathAttributeInterface{
+		bgp.NewPathAttributeOrigin(0),
+		bgp.NewPathAttributeAsPath([]bgp.AsPathParamInterface{}),
+		bgp.NewPathAttributeNextHop("172.16.0.1"),
+	}
+	path := CreatePath(nil, bgp.NewNLRInfo(24, "10.20.0.0"), pathAttributes, false)
+	vpn, err := manager.AddLocalPath("blue", path)
+	assert.Nil(t, err)
+	assert.Equal(t, bgp.RF_IPv4_VPN, vpn.GetRouteFamily())
+	assert.Equal(t, "65000:200:10.20.0.0/24", vpn.GetPrefix())
+	assert.Equal(t, "172.16.0.1", vpn.GetNexthop().String())
+	assert.Equal(t, []uint32{blue.Label}, vpn.GetNlri().(*bgp.LabelledVPNIPAddrPrefix).Labels.Labels)
+	_, attr := vpn.GetPathAttr(bgp.BGP_ATTR_TYPE_EXTENDED_COMMUNITIES)
+	assert.Equal(t, []string{"rt:65000:200", "rt:65000:100"}, extendedCommunityStrings(attr.(*bgp.PathAttributeExtendedCommunities).Value))
+	_, attr = vpn.GetPathAttr(bgp.BGP_ATTR_TYPE_NEXT_HOP)
+	assert.Nil(t, attr)
+
+	// leaked into red by the shared route target
+	assert.Equal(t, 1, len(blue.GetBestPathList(bgp.RF_IPv4_UC)))
+	assert.Equal(t, 1, len(red.GetBestPathList(bgp.RF_IPv4_UC)))
+	assert.Equal(t, 1, len(manager.GetVpnPathList(bgp.RF_IPv4_VPN)))
+	assert.Equal(t, 0, len(manager.GetVpnPathList(bgp.RF_IPv6_VPN)))
+
+	// a single NLRI is advertised per update
+	msgs := CreateUpdateMsgFromPaths([]Path{vpn})
+	assert.Equal(t, 1, len(msgs))
+	buf, _ := msgs[0].Serialize()
+	msg, err := bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+	paths := NewProcessMessage(msg, nil).ToPathList()
+	assert.Equal(t, 1, len(paths))
+	assert.Equal(t, vpn.GetPrefix(), paths[0].GetPrefix())
+	assert.Equal(t, "172.16.0.1", paths[0].GetNexthop().String())
+
+	vpn, err = manager.AddLocalPath("blue", path.Clone(true))
+	assert.Nil(t, err)
+	assert.True(t, vpn.IsWithdraw())
+	assert.Equal(t, 0, len(blue.GetBestPathList(bgp.RF_IPv4_UC)))
+	assert.Equal(t, 0, len(red.GetBestPathList(bgp.RF_IPv4_UC)))
+	assert.Equal(t, 0, len(manager.GetVpnPathList(bgp.RF_IPv4_VPN)))
+
+	msgs = CreateUpdateMsgFromPaths([]Path{vpn})
+	buf, _ = msgs[0].Serialize()
+	msg, err = bgp.ParseBGPMessage(buf)
+	assert.Nil(t, err)
+	paths = NewProcessMessage(msg, nil).ToPathList()
+	assert.Equal(t, 1, len(paths))
+	assert.True(t, paths[0].IsWithdraw())
+
+	_, err = manager.AddLocalPath("green", path)
+	assert.NotNil(t, err)
+}
+
+func TestVpnTable(t *testing.T) {
+	tm := NewTableManager()
+	peer := &PeerInfo{AS: 65001, ID: net.ParseIP("10.0.0.2").To4(), RF: bgp.RF_IPv4_VPN}
+	// the same prefix in two VPNs
+	path1 := vpnPath(peer, "65001:1", "10.10.0.0", 24, nil)
+	path2 := vpnPath(peer, "65001:2", "10.10.0.0", 24, nil)
+	best, _, _ := tm.ProcessPaths([]Path{path1, path2})
+	assert.Equal(t, 2, len(best))
+	j, err := tm.Tables[bgp.RF_IPv4_VPN].MarshalJSON()
+	assert.Nil(t, err)
+	assert.Contains(t, string(j), `"Prefix":"65001:1:10.10.0.0/24"`)
+
+	_, lost, _ := tm.ProcessPaths([]Path{path1.Clone(true)})
+	assert.Equal(t, 1, len(lost))
+	assert.Equal(t, path1.GetPrefix(), lost[0].GetPrefix())
+	assert.Equal(t, 1, len(tm.GetBestPathList(bgp.RF_IPv4_VPN)))
+}